@@ -1,15 +1,39 @@
 package dtos
 
 import (
+	"regexp"
+	"strings"
+
+	"ember/api/validation"
+
 	"github.com/google/uuid"
 )
 
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_.]{3,50}$`)
+
+const (
+	MinPasswordLength = 8
+	// bcrypt ignores everything past 72 bytes, so longer passwords are rejected outright.
+	MaxPasswordBytes = 72
+	MaxEmailLength   = 255
+)
+
 type RegisterRequest struct {
 	Username string `json:"username"`
 	Email    string `json:"email"`
 	Password string `json:"password"`
 }
 
+func (r RegisterRequest) Validate() error {
+	var errs validation.Errors
+	errs.Check(usernamePattern.MatchString(r.Username), "username", "must be 3-50 letters, digits, '_' or '.'")
+	errs.Check(validEmail(r.Email), "email", "must be a valid email address")
+	errs.Check(validation.MaxRunes(r.Email, MaxEmailLength), "email", "is too long")
+	errs.Check(len([]rune(r.Password)) >= MinPasswordLength, "password", "must be at least 8 characters")
+	errs.Check(len(r.Password) <= MaxPasswordBytes, "password", "must be at most 72 bytes")
+	return errs.Err()
+}
+
 type RegisterResponse struct {
 	UserID uuid.UUID `json:"user_id"`
 }
@@ -19,6 +43,18 @@ type LoginRequest struct {
 	Password string `json:"password"`
 }
 
+func (r LoginRequest) Validate() error {
+	var errs validation.Errors
+	errs.Check(validation.Required(r.Email), "email", "is required")
+	errs.Check(validation.Required(r.Password), "password", "is required")
+	return errs.Err()
+}
+
 type LoginResponse struct {
 	Token string `json:"token"`
 }
+
+func validEmail(email string) bool {
+	at := strings.LastIndex(email, "@")
+	return at > 0 && at < len(email)-1 && !strings.ContainsAny(email, " \t\n")
+}
@@ -0,0 +1,49 @@
+package dtos
+
+import (
+	"time"
+
+	"ember/api/validation"
+
+	"github.com/google/uuid"
+)
+
+const MaxCommentLength = 1000
+
+// CreateCommentRequest is the body of POST /pins/{pinID}/comments; ParentID
+// makes it a reply.
+type CreateCommentRequest struct {
+	Body     string     `json:"body"`
+	ParentID *uuid.UUID `json:"parent_id"`
+}
+
+func (r CreateCommentRequest) Validate() error {
+	var errs validation.Errors
+	errs.Check(validation.Required(r.Body), "body", "is required")
+	errs.Check(validation.MaxRunes(r.Body, MaxCommentLength), "body", "must be at most 1000 characters")
+	return errs.Err()
+}
+
+type CommentAuthor struct {
+	ID          uuid.UUID `json:"id"`
+	Username    string    `json:"username"`
+	DisplayName string    `json:"display_name"`
+}
+
+// Comment is a comment or reply. Deleted comments keep their place in the
+// thread with an empty body while they have replies.
+type Comment struct {
+	ID         uuid.UUID     `json:"id"`
+	PinID      uuid.UUID     `json:"pin_id"`
+	ParentID   *uuid.UUID    `json:"parent_id"`
+	Author     CommentAuthor `json:"author"`
+	Body       string        `json:"body"`
+	CreatedAt  time.Time     `json:"created_at"`
+	Deleted    bool          `json:"deleted"`
+	ReplyCount int           `json:"reply_count"`
+}
+
+type GetCommentsResponse struct {
+	Comments   []Comment `json:"comments"`
+	NextCursor *string   `json:"next_cursor"`
+}
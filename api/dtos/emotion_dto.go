@@ -0,0 +1,15 @@
+package dtos
+
+type Emotion struct {
+	Key      string  `json:"key"`
+	Emoji    string  `json:"emoji"`
+	Label    string  `json:"label"`
+	Valence  float64 `json:"valence"`
+	Arousal  float64 `json:"arousal"`
+	Category string  `json:"category"`
+}
+
+type GetEmotionsResponse struct {
+	Version  int64     `json:"version"`
+	Emotions []Emotion `json:"emotions"`
+}
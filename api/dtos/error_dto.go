@@ -0,0 +1,9 @@
+package dtos
+
+import "ember/api/validation"
+
+// ValidationErrorResponse is the 422 body listing every rejected field.
+type ValidationErrorResponse struct {
+	Error  string                  `json:"error"`
+	Fields []validation.FieldError `json:"fields"`
+}
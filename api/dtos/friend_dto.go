@@ -1,6 +1,8 @@
 package dtos
 
 import (
+	"ember/api/validation"
+
 	"github.com/google/uuid"
 )
 
@@ -18,14 +20,24 @@ type FriendRequest struct {
 }
 
 type GetFriendsResponse struct {
-	Friends []Friend `json:"friends"`
+	Friends    []Friend `json:"friends"`
+	NextCursor *string  `json:"next_cursor"`
 }
 
+// GetFriendRequestsResponse pages through requests in both directions
+// together, newest first, so a page may hold any mix of the two.
 type GetFriendRequestsResponse struct {
-	Incoming []FriendRequest `json:"incoming_requests"`
-	Outgoing []FriendRequest `json:"outgoing_requests"`
+	Incoming   []FriendRequest `json:"incoming_requests"`
+	Outgoing   []FriendRequest `json:"outgoing_requests"`
+	NextCursor *string         `json:"next_cursor"`
 }
 
 type PatchFriendRequestsRequest struct {
 	Status string `json:"status"`
 }
+
+func (r PatchFriendRequestsRequest) Validate() error {
+	var errs validation.Errors
+	errs.Check(validation.OneOf(r.Status, "accepted", "rejected"), "status", "must be accepted or rejected")
+	return errs.Err()
+}
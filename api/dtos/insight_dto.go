@@ -0,0 +1,45 @@
+package dtos
+
+import "time"
+
+type MoodCell struct {
+	MinLon          float64        `json:"min_lon"`
+	MinLat          float64        `json:"min_lat"`
+	MaxLon          float64        `json:"max_lon"`
+	MaxLat          float64        `json:"max_lat"`
+	PinCount        int            `json:"pin_count"`
+	MeanValence     float64        `json:"mean_valence"`
+	DominantEmotion string         `json:"dominant_emotion"`
+	Emotions        map[string]int `json:"emotions"`
+}
+
+// MoodSummary rolls up the reported cells; suppressed cells are not included.
+type MoodSummary struct {
+	PinCount        int            `json:"pin_count"`
+	MeanValence     float64        `json:"mean_valence"`
+	DominantEmotion string         `json:"dominant_emotion"`
+	Emotions        map[string]int `json:"emotions"`
+}
+
+type GetMoodInsightsResponse struct {
+	Since       time.Time   `json:"since"`
+	CellSizeDeg float64     `json:"cell_size_deg"`
+	Cells       []MoodCell  `json:"cells"`
+	Summary     MoodSummary `json:"summary"`
+}
+
+type MoodTrendBucket struct {
+	Start           time.Time      `json:"start"`
+	PinCount        int            `json:"pin_count"`
+	MeanValence     float64        `json:"mean_valence"`
+	DominantEmotion string         `json:"dominant_emotion"`
+	Emotions        map[string]int `json:"emotions"`
+}
+
+// GetMoodTrendsResponse is current as of AsOf, the last rollup refresh.
+type GetMoodTrendsResponse struct {
+	Bucket  string            `json:"bucket"`
+	Since   time.Time         `json:"since"`
+	AsOf    time.Time         `json:"as_of"`
+	Buckets []MoodTrendBucket `json:"buckets"`
+}
@@ -0,0 +1,54 @@
+package dtos
+
+import (
+	"encoding/json"
+	"time"
+
+	"ember/api/validation"
+
+	"github.com/google/uuid"
+)
+
+// MaxNotificationIDs caps how many notifications one request may mark read.
+const MaxNotificationIDs = 500
+
+type NotificationActor struct {
+	ID          uuid.UUID `json:"id"`
+	Username    string    `json:"username"`
+	DisplayName string    `json:"display_name"`
+}
+
+type Notification struct {
+	ID        uuid.UUID          `json:"id"`
+	Type      string             `json:"type"`
+	Actor     *NotificationActor `json:"actor,omitempty"`
+	PinID     *uuid.UUID         `json:"pin_id,omitempty"`
+	Data      json.RawMessage    `json:"data"`
+	CreatedAt time.Time          `json:"created_at"`
+	ReadAt    *time.Time         `json:"read_at"`
+}
+
+type GetNotificationsResponse struct {
+	Notifications []Notification `json:"notifications"`
+	UnreadCount   int            `json:"unread_count"`
+	NextCursor    *string        `json:"next_cursor"`
+}
+
+// MarkNotificationsReadRequest is the body of POST /me/notifications/read:
+// either specific IDs or all of them.
+type MarkNotificationsReadRequest struct {
+	IDs []uuid.UUID `json:"ids"`
+	All bool        `json:"all"`
+}
+
+func (r MarkNotificationsReadRequest) Validate() error {
+	var errs validation.Errors
+	errs.Check(r.All != (len(r.IDs) > 0), "ids", "give either ids or all, not both")
+	errs.Check(len(r.IDs) <= MaxNotificationIDs, "ids", "must list at most 500 notifications")
+	return errs.Err()
+}
+
+type MarkNotificationsReadResponse struct {
+	Marked      int64 `json:"marked"`
+	UnreadCount int   `json:"unread_count"`
+}
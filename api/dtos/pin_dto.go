@@ -1,25 +1,254 @@
 package dtos
 
-import "github.com/google/uuid"
-import "time"
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
 
+	"ember/api/models"
+	"ember/api/validation"
+
+	"github.com/google/uuid"
+)
+
+const (
+	MaxPinMessageLength = 500
+	MaxEmotionLength    = 50
+	MaxReactionLength   = 16
+
+	// MaxPinTTLSeconds bounds ttl_seconds for every visibility, including
+	// private pins which otherwise have no maximum lifetime
+	MaxPinTTLSeconds = 365 * 24 * 60 * 60
+)
+
+// PinVisibilities are the values accepted for a pin's visibility.
+var PinVisibilities = []string{"public", "friends", "private"}
+
+// CreatePinRequest is the body of POST /pins. At most one of TTLSeconds and
+// ExpiresAt may be set; either is capped by the visibility's maximum lifetime.
 type CreatePinRequest struct {
-	Emotion    string  `json:"emotion"`
-	Message    string  `json:"message"`
-	Longitude  float64 `json:"longitude"`
-	Latitude   float64 `json:"latitude"`
-	Visibility string  `json:"visibility"`
+	Emotion    string     `json:"emotion"`
+	Message    string     `json:"message"`
+	Longitude  float64    `json:"longitude"`
+	Latitude   float64    `json:"latitude"`
+	Visibility string     `json:"visibility"`
+	TTLSeconds *int64     `json:"ttl_seconds,omitempty"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	// Omitted to follow the author's location privacy defaults
+	LocationPrecision string `json:"location_precision,omitempty"`
 }
 
-type Pin struct {
-	UserID    uuid.UUID `json:"user_id"`
-	Emotion   string    `json:"emotion"`
-	Message   string    `json:"message"`
-	Longitude float64   `json:"longitude"`
-	Latitude  float64   `json:"latitude"`
+func (r CreatePinRequest) Validate() error {
+	var errs validation.Errors
+	errs.Check(validation.Required(r.Emotion), "emotion", "is required")
+	errs.Check(validation.MaxRunes(r.Emotion, MaxEmotionLength), "emotion", "is too long")
+	errs.Check(validation.MaxRunes(r.Message, MaxPinMessageLength), "message", "must be at most 500 characters")
+	errs.Check(validation.Latitude(r.Latitude), "latitude", "must be between -90 and 90")
+	errs.Check(validation.Longitude(r.Longitude), "longitude", "must be between -180 and 180")
+	errs.Check(validation.OneOf(r.Visibility, PinVisibilities...), "visibility", "must be one of public, friends, private")
+	if r.LocationPrecision != "" {
+		errs.Check(validation.OneOf(r.LocationPrecision, models.LocationPrecisions...), "location_precision", "must be one of exact, 100m, 1km, city")
+	}
+	if r.TTLSeconds != nil && r.ExpiresAt != nil {
+		errs.Add("ttl_seconds", "cannot be combined with expires_at")
+	}
+	if r.TTLSeconds != nil {
+		errs.Check(*r.TTLSeconds > 0, "ttl_seconds", "must be positive")
+		errs.Check(*r.TTLSeconds <= MaxPinTTLSeconds, "ttl_seconds", "must be at most one year")
+	}
+	if r.ExpiresAt != nil {
+		errs.Check(r.ExpiresAt.After(time.Now()), "expires_at", "must be in the future")
+	}
+	return errs.Err()
+}
+
+const (
+	MaxBatchPins = 50
+	// MaxBatchPinAge is how long a pin may wait on a device to be uploaded,
+	// and MaxClockSkew how far ahead of the server a device's clock may be.
+	MaxBatchPinAge = 7 * 24 * time.Hour
+	MaxClockSkew   = 5 * time.Minute
+)
+
+// BatchPinsRequest is the body of POST /pins/batch. Only the number of pins
+// is validated here; each pin is validated, and fails, on its own.
+type BatchPinsRequest struct {
+	Pins []BatchPin `json:"pins"`
+}
+
+func (r BatchPinsRequest) Validate() error {
+	var errs validation.Errors
+	errs.Check(len(r.Pins) > 0, "pins", "is required")
+	errs.Check(len(r.Pins) <= MaxBatchPins, "pins", fmt.Sprintf("must have at most %d pins", MaxBatchPins))
+	return errs.Err()
+}
+
+// BatchPin is a pin created on a device, with the device's ID for it and
+// when it was created there. TTLSeconds counts from CreatedAt.
+type BatchPin struct {
+	ClientID  uuid.UUID `json:"client_id"`
 	CreatedAt time.Time `json:"created_at"`
+	CreatePinRequest
+}
+
+func (p BatchPin) Validate() error {
+	var errs validation.Errors
+	errs.Check(p.ClientID != uuid.Nil, "client_id", "is required")
+	errs.Check(!p.CreatedAt.IsZero(), "created_at", "is required")
+	if !p.CreatedAt.IsZero() {
+		errs.Check(time.Since(p.CreatedAt) <= MaxBatchPinAge, "created_at", "is too long ago")
+		errs.Check(time.Until(p.CreatedAt) <= MaxClockSkew, "created_at", "cannot be in the future")
+	}
+	var pinErrs validation.Errors
+	if errors.As(p.CreatePinRequest.Validate(), &pinErrs) {
+		errs = append(errs, pinErrs...)
+	}
+	return errs.Err()
+}
+
+// Batch pin statuses: the pin was created, had been uploaded before (Pin is
+// the one already created, or null if it has expired since), was rejected
+// (see Errors), or couldn't be saved and can be retried.
+const (
+	BatchPinCreated   = "created"
+	BatchPinDuplicate = "duplicate"
+	BatchPinInvalid   = "invalid"
+	BatchPinFailed    = "failed"
+)
+
+type BatchPinResult struct {
+	ClientID uuid.UUID               `json:"client_id"`
+	Status   string                  `json:"status"`
+	Pin      *Pin                    `json:"pin,omitempty"`
+	Errors   []validation.FieldError `json:"errors,omitempty"`
+}
+
+// BatchPinsResponse has a result for each uploaded pin, in request order.
+type BatchPinsResponse struct {
+	Results []BatchPinResult `json:"results"`
+}
+
+// ClearableTime is a time that can also be sent as "" to clear it, which
+// decodes to the zero time.
+type ClearableTime struct {
+	time.Time
+}
+
+func (t *ClearableTime) UnmarshalJSON(data []byte) error {
+	if string(data) == `""` {
+		t.Time = time.Time{}
+		return nil
+	}
+	return json.Unmarshal(data, &t.Time)
+}
+
+// UpdatePinRequest is the body of PATCH /pins/{pinID}; omitted fields are
+// left unchanged. An empty LocationPrecision returns to the author's defaults,
+// and an empty ExpiresAt removes the expiry, up to the visibility's maximum
+// lifetime.
+type UpdatePinRequest struct {
+	Emotion           *string        `json:"emotion"`
+	Message           *string        `json:"message"`
+	Visibility        *string        `json:"visibility"`
+	LocationPrecision *string        `json:"location_precision"`
+	ExpiresAt         *ClearableTime `json:"expires_at"`
 }
 
+func (r UpdatePinRequest) Validate() error {
+	var errs validation.Errors
+	if r.Emotion != nil {
+		errs.Check(validation.Required(*r.Emotion), "emotion", "cannot be empty")
+		errs.Check(validation.MaxRunes(*r.Emotion, MaxEmotionLength), "emotion", "is too long")
+	}
+	if r.Message != nil {
+		errs.Check(validation.MaxRunes(*r.Message, MaxPinMessageLength), "message", "must be at most 500 characters")
+	}
+	if r.Visibility != nil {
+		errs.Check(validation.OneOf(*r.Visibility, PinVisibilities...), "visibility", "must be one of public, friends, private")
+	}
+	if r.LocationPrecision != nil && *r.LocationPrecision != "" {
+		errs.Check(validation.OneOf(*r.LocationPrecision, models.LocationPrecisions...), "location_precision", "must be one of exact, 100m, 1km, city")
+	}
+	if r.ExpiresAt != nil && !r.ExpiresAt.IsZero() {
+		errs.Check(r.ExpiresAt.After(time.Now()), "expires_at", "must be in the future")
+	}
+	return errs.Err()
+}
+
+type Pin struct {
+	ID         uuid.UUID  `json:"id"`
+	UserID     uuid.UUID  `json:"user_id"`
+	Emotion    string     `json:"emotion"`
+	Message    string     `json:"message"`
+	Longitude  float64    `json:"longitude"`
+	Latitude   float64    `json:"latitude"`
+	Visibility string     `json:"visibility"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+
+	// How precise Longitude and Latitude are for the caller. Only the author
+	// is told the pin's own LocationPrecision, if it has one.
+	Precision         string  `json:"precision"`
+	LocationPrecision *string `json:"location_precision,omitempty"`
+
+	// Set only on /pins/nearby results, relative to the query point
+	DistanceM  *float64 `json:"distance_m,omitempty"`
+	BearingDeg *float64 `json:"bearing_deg,omitempty"`
+
+	// Set only on /pins/search results: the matching part of the message,
+	// HTML-escaped, with matched words wrapped in <mark></mark>
+	Snippet *string `json:"snippet,omitempty"`
+
+	// Count per emoji, and which of them are the caller's
+	Reactions    map[string]int `json:"reactions,omitempty"`
+	MyReactions  []string       `json:"my_reactions,omitempty"`
+	CommentCount int            `json:"comment_count,omitempty"`
+
+	Attachments []Attachment `json:"attachments,omitempty"`
+}
+
+// Attachment describes an image on a pin. Blurhash can be drawn as a
+// placeholder until ThumbnailURL loads.
+type Attachment struct {
+	ID           uuid.UUID `json:"id"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+	Width        int       `json:"width"`
+	Height       int       `json:"height"`
+	Blurhash     string    `json:"blurhash"`
+}
+
+// ReactionRequest is the body of PUT /pins/{pinID}/reactions.
+type ReactionRequest struct {
+	Emoji string `json:"emoji"`
+}
+
+func (r ReactionRequest) Validate() error {
+	var errs validation.Errors
+	errs.Check(validation.Emoji(r.Emoji), "emoji", "must be an emoji")
+	errs.Check(validation.MaxRunes(r.Emoji, MaxReactionLength), "emoji", "is too long")
+	return errs.Err()
+}
+
+// GetPinListResponse is a page of pins. NextCursor is null on the last page.
 type GetPinListResponse struct {
-	Pins []Pin `json:"pins"`
+	Pins       []Pin   `json:"pins"`
+	NextCursor *string `json:"next_cursor"`
+}
+
+type PinCluster struct {
+	Longitude       float64        `json:"longitude"`
+	Latitude        float64        `json:"latitude"`
+	Count           int            `json:"count"`
+	DominantEmotion string         `json:"dominant_emotion"`
+	Emotions        map[string]int `json:"emotions"`
+}
+
+// GetPinClustersResponse holds clusters for dense cells and the individual
+// pins of cells too sparse to cluster.
+type GetPinClustersResponse struct {
+	Zoom     int          `json:"zoom"`
+	Clusters []PinCluster `json:"clusters"`
+	Pins     []Pin        `json:"pins"`
 }
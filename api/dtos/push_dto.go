@@ -0,0 +1,64 @@
+package dtos
+
+import (
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"ember/api/models"
+	"ember/api/validation"
+)
+
+const maxDeviceTokenLength = 200
+
+// QuietHoursLayout is the wall-clock format of quiet hour bounds.
+const QuietHoursLayout = "15:04"
+
+// RegisterDeviceRequest is the body of POST /me/devices.
+type RegisterDeviceRequest struct {
+	Token    string `json:"token"`
+	Platform string `json:"platform"`
+}
+
+func (r RegisterDeviceRequest) Validate() error {
+	var errs validation.Errors
+	_, err := hex.DecodeString(r.Token)
+	errs.Check(validation.Required(r.Token), "token", "is required")
+	errs.Check(err == nil && len(r.Token) <= maxDeviceTokenLength, "token", "must be a hex device token")
+	errs.Check(validation.OneOf(r.Platform, models.DevicePlatformIOS), "platform", "must be ios")
+	return errs.Err()
+}
+
+// QuietHours are local wall-clock bounds; End before Start spans midnight.
+type QuietHours struct {
+	Start string `json:"start"`
+	End   string `json:"end"`
+}
+
+// PushPreferences is both the response of GET /me/push-preferences and the
+// body of PUT, which replaces all of them.
+type PushPreferences struct {
+	Enabled    bool        `json:"enabled"`
+	MutedTypes []string    `json:"muted_types"`
+	QuietHours *QuietHours `json:"quiet_hours"`
+	TimeZone   string      `json:"time_zone"`
+}
+
+func (r PushPreferences) Validate() error {
+	var errs validation.Errors
+	for _, t := range r.MutedTypes {
+		if !validation.OneOf(t, models.NotificationTypes...) {
+			errs.Add("muted_types", "must only contain "+strings.Join(models.NotificationTypes, ", "))
+			break
+		}
+	}
+	if r.QuietHours != nil {
+		_, err := time.Parse(QuietHoursLayout, r.QuietHours.Start)
+		errs.Check(err == nil, "quiet_hours.start", "must be HH:MM")
+		_, err = time.Parse(QuietHoursLayout, r.QuietHours.End)
+		errs.Check(err == nil, "quiet_hours.end", "must be HH:MM")
+	}
+	_, err := time.LoadLocation(r.TimeZone)
+	errs.Check(validation.Required(r.TimeZone) && r.TimeZone != "Local" && err == nil, "time_zone", "must be an IANA time zone")
+	return errs.Err()
+}
@@ -0,0 +1,40 @@
+package dtos
+
+type Streak struct {
+	Days  int    `json:"days"`
+	Start string `json:"start,omitempty"` // YYYY-MM-DD in the requested time zone
+	End   string `json:"end,omitempty"`
+}
+
+type StatsPlace struct {
+	Longitude       float64 `json:"longitude"`
+	Latitude        float64 `json:"latitude"`
+	PinCount        int     `json:"pin_count"`
+	DominantEmotion string  `json:"dominant_emotion"`
+}
+
+// MoodSlot summarises the pins in one weekday or hour of the day.
+type MoodSlot struct {
+	PinCount        int            `json:"pin_count"`
+	MeanValence     *float64       `json:"mean_valence"` // null when no pin has a catalogued emotion
+	DominantEmotion string         `json:"dominant_emotion,omitempty"`
+	Emotions        map[string]int `json:"emotions"`
+}
+
+type CalendarDay struct {
+	Date            string `json:"date"` // YYYY-MM-DD in the requested time zone
+	PinCount        int    `json:"pin_count"`
+	DominantEmotion string `json:"dominant_emotion"`
+}
+
+type GetMeStatsResponse struct {
+	TimeZone      string         `json:"time_zone"`
+	TotalPins     int            `json:"total_pins"`
+	Emotions      map[string]int `json:"emotions"`
+	CurrentStreak Streak         `json:"current_streak"`
+	LongestStreak Streak         `json:"longest_streak"`
+	TopPlaces     []StatsPlace   `json:"top_places"`
+	ByWeekday     []MoodSlot     `json:"by_weekday"` // index 0 is Sunday
+	ByHour        []MoodSlot     `json:"by_hour"`    // index 0 is 00:00-00:59
+	Calendar      []CalendarDay  `json:"calendar"`
+}
@@ -0,0 +1,33 @@
+package dtos
+
+import (
+	"ember/api/validation"
+
+	"github.com/google/uuid"
+)
+
+// PinStreamReady is the first event on GET /pins/stream; StreamID is used to
+// move the viewport with PATCH /pins/stream/{streamID}.
+type PinStreamReady struct {
+	StreamID uuid.UUID `json:"stream_id"`
+}
+
+// PinStreamEvent is one change on the pin stream. Pin is omitted for deleted
+// and expired pins, which clients should simply drop.
+type PinStreamEvent struct {
+	Type  string    `json:"type"`
+	PinID uuid.UUID `json:"pin_id"`
+	Pin   *Pin      `json:"pin,omitempty"`
+}
+
+// UpdatePinStreamRequest is the body of PATCH /pins/stream/{streamID}. BBox
+// uses the same minLon,minLat,maxLon,maxLat format as the bbox query parameter.
+type UpdatePinStreamRequest struct {
+	BBox string `json:"bbox"`
+}
+
+func (r UpdatePinStreamRequest) Validate() error {
+	var errs validation.Errors
+	errs.Check(validation.Required(r.BBox), "bbox", "is required")
+	return errs.Err()
+}
@@ -0,0 +1,21 @@
+package dtos
+
+import "github.com/google/uuid"
+
+// SyncResponse is what changed for the caller since the token they sent to
+// GET /sync, or everything when they sent none. Clients apply it and send
+// Token next time. HasMore means more pins changed than fit; sync again
+// straight away with Token. Profile is null when unchanged, and a user in
+// Friends no longer has a request in either direction.
+type SyncResponse struct {
+	Token                 string          `json:"token"`
+	HasMore               bool            `json:"has_more"`
+	Profile               *GetMeResponse  `json:"profile"`
+	Pins                  []Pin           `json:"pins"`
+	DeletedPins           []uuid.UUID     `json:"deleted_pins"`
+	Friends               []Friend        `json:"friends"`
+	RemovedFriends        []uuid.UUID     `json:"removed_friends"`
+	IncomingRequests      []FriendRequest `json:"incoming_requests"`
+	OutgoingRequests      []FriendRequest `json:"outgoing_requests"`
+	RemovedFriendRequests []uuid.UUID     `json:"removed_friend_requests"`
+}
@@ -0,0 +1,11 @@
+package dtos
+
+type TrendingTag struct {
+	Tag         string `json:"tag"`
+	PinCount    int    `json:"pin_count"`
+	AuthorCount int    `json:"author_count"`
+}
+
+type GetTrendingTagsResponse struct {
+	Tags []TrendingTag `json:"tags"`
+}
@@ -3,6 +3,9 @@ package dtos
 import (
 	"time"
 
+	"ember/api/models"
+	"ember/api/validation"
+
 	"github.com/google/uuid"
 )
 
@@ -14,3 +17,18 @@ type GetMeResponse struct {
 	CreatedAt   time.Time `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 }
+
+// LocationPrivacy is the body of GET and PUT /me/location-privacy: how
+// precisely the user's pins are shown to friends and to everyone else unless
+// a pin says otherwise.
+type LocationPrivacy struct {
+	FriendsPrecision string `json:"friends_precision"`
+	PublicPrecision  string `json:"public_precision"`
+}
+
+func (p LocationPrivacy) Validate() error {
+	var errs validation.Errors
+	errs.Check(validation.OneOf(p.FriendsPrecision, models.LocationPrecisions...), "friends_precision", "must be one of exact, 100m, 1km, city")
+	errs.Check(validation.OneOf(p.PublicPrecision, models.LocationPrecisions...), "public_precision", "must be one of exact, 100m, 1km, city")
+	return errs.Err()
+}
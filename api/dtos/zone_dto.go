@@ -0,0 +1,105 @@
+package dtos
+
+import (
+	"fmt"
+	"time"
+
+	"ember/api/models"
+	"ember/api/validation"
+
+	"github.com/google/uuid"
+)
+
+const MaxZoneNameLength = 50
+
+// ZoneVisibilities and ZonePrecisions are what a zone can downgrade pins to.
+var (
+	ZoneVisibilities = []string{"friends", "private"}
+	ZonePrecisions   = models.ApproximatePrecisions
+)
+
+type ZoneCircle struct {
+	Longitude float64 `json:"longitude"`
+	Latitude  float64 `json:"latitude"`
+	RadiusM   int     `json:"radius_m"`
+}
+
+// ZoneRequest is the body of POST /me/zones and PUT /me/zones/{zoneID},
+// which replaces the whole zone. Exactly one of Circle and Polygon is set;
+// Polygon is an open ring of [longitude, latitude] pairs. At least one of
+// Visibility and Precision is set.
+type ZoneRequest struct {
+	Name       string       `json:"name"`
+	Circle     *ZoneCircle  `json:"circle,omitempty"`
+	Polygon    [][2]float64 `json:"polygon,omitempty"`
+	Visibility string       `json:"visibility,omitempty"`
+	Precision  string       `json:"location_precision,omitempty"`
+}
+
+func (r ZoneRequest) Validate() error {
+	var errs validation.Errors
+	errs.Check(validation.Required(r.Name), "name", "is required")
+	errs.Check(validation.MaxRunes(r.Name, MaxZoneNameLength), "name", "is too long")
+
+	switch {
+	case r.Circle != nil && r.Polygon != nil:
+		errs.Add("circle", "cannot be combined with polygon")
+	case r.Circle != nil:
+		errs.Check(validation.Longitude(r.Circle.Longitude), "circle.longitude", "must be between -180 and 180")
+		errs.Check(validation.Latitude(r.Circle.Latitude), "circle.latitude", "must be between -90 and 90")
+		errs.Check(r.Circle.RadiusM >= models.MinZoneRadiusM && r.Circle.RadiusM <= models.MaxZoneRadiusM,
+			"circle.radius_m", fmt.Sprintf("must be between %d and %d", models.MinZoneRadiusM, models.MaxZoneRadiusM))
+	case r.Polygon != nil:
+		ring := r.Ring()
+		valid := true
+		for _, loc := range ring {
+			valid = valid && validation.Longitude(loc.Longitude) && validation.Latitude(loc.Latitude)
+		}
+		errs.Check(valid, "polygon", "must only contain [longitude, latitude] pairs")
+		errs.Check(len(ring) <= models.MaxZonePolygonSize, "polygon", fmt.Sprintf("must have at most %d vertices", models.MaxZonePolygonSize))
+		errs.Check(models.SimplePolygon(ring), "polygon", "must have at least 3 vertices and no crossing edges")
+		errs.Check(models.RingBounds(ring).AreaKm2() <= models.MaxZoneAreaKm2, "polygon", "is too large")
+	default:
+		errs.Add("circle", "a circle or a polygon is required")
+	}
+
+	if r.Visibility == "" && r.Precision == "" {
+		errs.Add("visibility", "a visibility or location_precision is required")
+	}
+	if r.Visibility != "" {
+		errs.Check(validation.OneOf(r.Visibility, ZoneVisibilities...), "visibility", "must be one of friends, private")
+	}
+	if r.Precision != "" {
+		errs.Check(validation.OneOf(r.Precision, ZonePrecisions...), "location_precision", "must be one of 100m, 1km, city")
+	}
+	return errs.Err()
+}
+
+// Ring returns Polygon as locations, without a closing vertex if the client
+// repeated the first one.
+func (r ZoneRequest) Ring() []models.Location {
+	points := r.Polygon
+	if len(points) > 1 && points[0] == points[len(points)-1] {
+		points = points[:len(points)-1]
+	}
+	ring := make([]models.Location, 0, len(points))
+	for _, p := range points {
+		ring = append(ring, models.Location{Longitude: p[0], Latitude: p[1]})
+	}
+	return ring
+}
+
+type Zone struct {
+	ID         uuid.UUID    `json:"id"`
+	Name       string       `json:"name"`
+	Circle     *ZoneCircle  `json:"circle,omitempty"`
+	Polygon    [][2]float64 `json:"polygon,omitempty"`
+	Visibility string       `json:"visibility,omitempty"`
+	Precision  string       `json:"location_precision,omitempty"`
+	CreatedAt  time.Time    `json:"created_at"`
+	UpdatedAt  time.Time    `json:"updated_at"`
+}
+
+type GetZonesResponse struct {
+	Zones []Zone `json:"zones"`
+}
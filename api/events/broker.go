@@ -0,0 +1,145 @@
+package events
+
+import (
+	"sync"
+
+	"ember/api/models"
+	"ember/api/repositories"
+
+	"github.com/google/uuid"
+)
+
+type PinEventType string
+
+const (
+	PinCreated PinEventType = "created"
+	PinUpdated PinEventType = "updated"
+	PinDeleted PinEventType = "deleted"
+	PinExpired PinEventType = "expired"
+)
+
+// PinEvent is a change to a pin. Pin is its state after a create or update,
+// as its author reads it.
+type PinEvent struct {
+	Type PinEventType
+	Pin  models.Pin
+	// Before is the pin as stored before an update, deletion or expiry, so
+	// subscribers who were shown it but no longer are (moved away, made
+	// private, gone) are told to drop it.
+	Before *repositories.PinSnapshot
+}
+
+// subscriptionBuffer is how many events a subscriber may fall behind before
+// it is dropped; a dropped client reconnects and refetches its viewport.
+const subscriptionBuffer = 64
+
+// Broker fans pin events out to in-process subscribers by viewport. It does
+// not know about visibility; subscribers filter what they are sent.
+type Broker struct {
+	mu   sync.RWMutex
+	subs map[uuid.UUID]*Subscription
+}
+
+func NewBroker() *Broker {
+	return &Broker{
+		subs: map[uuid.UUID]*Subscription{},
+	}
+}
+
+// Subscription receives the events touching its viewport on Events, which is
+// closed when the subscription is closed or falls too far behind.
+type Subscription struct {
+	ID     uuid.UUID
+	UserID uuid.UUID
+	Events <-chan PinEvent
+
+	events chan PinEvent
+	broker *Broker
+
+	mu   sync.Mutex
+	bbox models.BBox
+}
+
+func (b *Broker) Subscribe(userID uuid.UUID, bbox models.BBox) *Subscription {
+	events := make(chan PinEvent, subscriptionBuffer)
+	sub := &Subscription{
+		ID:     uuid.New(),
+		UserID: userID,
+		Events: events,
+		events: events,
+		broker: b,
+		bbox:   bbox,
+	}
+
+	b.mu.Lock()
+	b.subs[sub.ID] = sub
+	b.mu.Unlock()
+
+	return sub
+}
+
+// Subscription returns the open subscription with the given ID, or nil.
+func (b *Broker) Subscription(id uuid.UUID) *Subscription {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.subs[id]
+}
+
+// Publish delivers event to every subscription whose viewport the pin is, or
+// for updates was, near enough to be served inside. It never blocks.
+func (b *Broker) Publish(event PinEvent) {
+	var lagging []*Subscription
+
+	b.mu.RLock()
+	for _, sub := range b.subs {
+		if !sub.wants(event) {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			lagging = append(lagging, sub)
+		}
+	}
+	b.mu.RUnlock()
+
+	for _, sub := range lagging {
+		sub.Close()
+	}
+}
+
+// wants matches event pins, which are located exactly, against the viewport
+// grown by the most a served location can be fuzzed; subscribers make the
+// final check against where the pin is served to them.
+func (s *Subscription) wants(event PinEvent) bool {
+	bbox := s.BBox().Grow(models.MaxFuzzMeters)
+	if event.Before != nil && bbox.Contains(event.Before.Location.Longitude, event.Before.Location.Latitude) {
+		return true
+	}
+	return (event.Type == PinCreated || event.Type == PinUpdated) && bbox.Contains(event.Pin.Location.Longitude, event.Pin.Location.Latitude)
+}
+
+func (s *Subscription) BBox() models.BBox {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.bbox
+}
+
+// SetBBox moves the subscription's viewport; later events are matched against it.
+func (s *Subscription) SetBBox(bbox models.BBox) {
+	s.mu.Lock()
+	s.bbox = bbox
+	s.mu.Unlock()
+}
+
+// Close unsubscribes and closes Events. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+
+	if _, ok := s.broker.subs[s.ID]; !ok {
+		return
+	}
+	delete(s.broker.subs, s.ID)
+	close(s.events)
+}
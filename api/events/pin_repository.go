@@ -0,0 +1,70 @@
+package events
+
+import (
+	"time"
+
+	"ember/api/models"
+	"ember/api/repositories"
+
+	"github.com/google/uuid"
+)
+
+// publishingPinRepository publishes every successful pin write to a broker,
+// so handlers and jobs that write pins feed the live stream without knowing it.
+type publishingPinRepository struct {
+	repositories.PinRepository
+	broker *Broker
+}
+
+// PublishPinChanges wraps pinRepo so creates, updates and deletes are
+// published to broker once they have been written.
+func PublishPinChanges(pinRepo repositories.PinRepository, broker *Broker) repositories.PinRepository {
+	return &publishingPinRepository{
+		PinRepository: pinRepo,
+		broker:        broker,
+	}
+}
+
+func (p *publishingPinRepository) CreatePin(userID uuid.UUID, emotion string, message string, lon float64, lat float64, visibility string, precision string, expiresAt *time.Time) (*models.Pin, error) {
+	pin, err := p.PinRepository.CreatePin(userID, emotion, message, lon, lat, visibility, precision, expiresAt)
+	if err == nil && pin != nil {
+		p.broker.Publish(PinEvent{Type: PinCreated, Pin: *pin})
+	}
+	return pin, err
+}
+
+func (p *publishingPinRepository) CreateClientPin(userID uuid.UUID, pin repositories.ClientPin) (*models.Pin, bool, error) {
+	saved, created, err := p.PinRepository.CreateClientPin(userID, pin)
+	if err == nil && created && saved != nil {
+		p.broker.Publish(PinEvent{Type: PinCreated, Pin: *saved})
+	}
+	return saved, created, err
+}
+
+func (p *publishingPinRepository) UpdatePin(userID uuid.UUID, pinID uuid.UUID, update repositories.PinUpdate) (*models.Pin, error) {
+	before, err := p.PinRepository.GetPinSnapshot(pinID)
+	if err != nil {
+		return nil, err
+	}
+
+	pin, err := p.PinRepository.UpdatePin(userID, pinID, update)
+	if err == nil && pin != nil {
+		p.broker.Publish(PinEvent{Type: PinUpdated, Pin: *pin, Before: before})
+	}
+	return pin, err
+}
+
+func (p *publishingPinRepository) DeletePin(userID uuid.UUID, pinID uuid.UUID) error {
+	before, err := p.PinRepository.GetPinSnapshot(pinID)
+	if err != nil {
+		return err
+	}
+
+	if err := p.PinRepository.DeletePin(userID, pinID); err != nil {
+		return err
+	}
+	if before != nil {
+		p.broker.Publish(PinEvent{Type: PinDeleted, Before: before})
+	}
+	return nil
+}
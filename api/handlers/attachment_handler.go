@@ -0,0 +1,318 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+
+	"ember/api/dtos"
+	"ember/api/media"
+	"ember/api/models"
+	"ember/api/repositories"
+	"ember/api/validation"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	maxPinAttachments  = 4
+	maxAttachmentBytes = 10 << 20
+	// Room for a full set of images plus the multipart framing
+	maxAttachmentUploadBytes = maxPinAttachments*maxAttachmentBytes + 1<<20
+	attachmentFormMemory     = 8 << 20
+)
+
+func toAttachmentDTO(a models.Attachment) dtos.Attachment {
+	url := fmt.Sprintf("/pins/%s/attachments/%s", a.PinID, a.ID)
+	return dtos.Attachment{
+		ID:           a.ID,
+		URL:          url,
+		ThumbnailURL: url + "?size=" + media.VariantThumb,
+		Width:        a.Width,
+		Height:       a.Height,
+		Blurhash:     a.Blurhash,
+	}
+}
+
+// processUpload reads and re-encodes one uploaded image, writing the error
+// response itself when it can't.
+func processUpload(w http.ResponseWriter, fh *multipart.FileHeader) (*media.ProcessedImage, bool) {
+	if fh.Size > maxAttachmentBytes {
+		http.Error(w, fmt.Sprintf("%s is larger than 10 MB", fh.Filename), http.StatusRequestEntityTooLarge)
+		return nil, false
+	}
+
+	f, err := fh.Open()
+	if err != nil {
+		log.Println("open uploaded image:", err)
+		http.Error(w, "unable to read image", http.StatusBadRequest)
+		return nil, false
+	}
+	defer f.Close()
+
+	data, err := io.ReadAll(io.LimitReader(f, maxAttachmentBytes+1))
+	if err != nil {
+		http.Error(w, "unable to read image", http.StatusBadRequest)
+		return nil, false
+	}
+
+	processed, err := media.ProcessImage(data)
+	switch {
+	case errors.Is(err, media.ErrUnsupportedImage):
+		http.Error(w, fmt.Sprintf("%s: %v", fh.Filename, err), http.StatusUnsupportedMediaType)
+		return nil, false
+	case errors.Is(err, media.ErrImageTooLarge):
+		var errs validation.Errors
+		errs.Add("images", fh.Filename+" has too many pixels")
+		writeValidationError(w, errs)
+		return nil, false
+	case err != nil:
+		log.Println("process image:", err)
+		http.Error(w, "unable to process image", http.StatusInternalServerError)
+		return nil, false
+	}
+	return processed, true
+}
+
+// deleteAttachmentBlobs removes what was stored for attachments that never
+// made it into the database.
+func deleteAttachmentBlobs(ctx context.Context, store media.BlobStore, attachments []models.Attachment) {
+	for _, a := range attachments {
+		for _, variant := range []string{media.VariantFull, media.VariantThumb} {
+			if err := store.Delete(ctx, media.AttachmentKey(a.ID, variant)); err != nil {
+				log.Println("delete attachment blob:", err)
+			}
+		}
+	}
+}
+
+// POST /pins/{pinID}/attachments (multipart, one or more "images" files)
+func PostPinAttachmentsHandler(pinRepo repositories.PinRepository, attachmentRepo repositories.AttachmentRepository, store media.BlobStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("userID").(uuid.UUID)
+
+		pinID, err := uuid.Parse(chi.URLParam(r, "pinID"))
+		if err != nil {
+			http.Error(w, "invalid pin ID", http.StatusBadRequest)
+			return
+		}
+
+		pin, err := pinRepo.GetPin(userID, pinID)
+		if err != nil {
+			log.Println("get pin:", err)
+			http.Error(w, "unable to attach images", http.StatusInternalServerError)
+			return
+		}
+		if pin == nil {
+			http.Error(w, "pin does not exist", http.StatusNotFound)
+			return
+		}
+		if pin.UserID != userID {
+			http.Error(w, "only the pin's owner can modify it", http.StatusForbidden)
+			return
+		}
+
+		r.Body = http.MaxBytesReader(w, r.Body, maxAttachmentUploadBytes)
+		if err := r.ParseMultipartForm(attachmentFormMemory); err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, "invalid multipart body", http.StatusBadRequest)
+			return
+		}
+		defer r.MultipartForm.RemoveAll()
+
+		files := r.MultipartForm.File["images"]
+		existing, err := pinRepo.QueryPinAttachments([]uuid.UUID{pinID})
+		if err != nil {
+			log.Println("query pin attachments:", err)
+			http.Error(w, "unable to attach images", http.StatusInternalServerError)
+			return
+		}
+
+		var errs validation.Errors
+		errs.Check(len(files) > 0, "images", "is required")
+		errs.Check(len(existing[pinID])+len(files) <= maxPinAttachments, "images", "a pin can have at most 4 images")
+		if err := errs.Err(); err != nil {
+			writeValidationError(w, err)
+			return
+		}
+
+		// Process everything before storing anything, so one bad image
+		// rejects the upload without leaving the others behind.
+		processed := make([]*media.ProcessedImage, 0, len(files))
+		for _, fh := range files {
+			img, ok := processUpload(w, fh)
+			if !ok {
+				return
+			}
+			processed = append(processed, img)
+		}
+
+		ctx := r.Context()
+		attachments := make([]models.Attachment, 0, len(processed))
+		for _, img := range processed {
+			a := models.Attachment{
+				ID:       uuid.New(),
+				Width:    img.Width,
+				Height:   img.Height,
+				Blurhash: img.Blurhash,
+				ByteSize: len(img.Full),
+			}
+			attachments = append(attachments, a)
+
+			err := store.Put(ctx, media.AttachmentKey(a.ID, media.VariantFull), bytes.NewReader(img.Full))
+			if err == nil {
+				err = store.Put(ctx, media.AttachmentKey(a.ID, media.VariantThumb), bytes.NewReader(img.Thumb))
+			}
+			if err != nil {
+				log.Println("store attachment:", err)
+				deleteAttachmentBlobs(context.WithoutCancel(ctx), store, attachments)
+				http.Error(w, "unable to attach images", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		if _, err := attachmentRepo.AddAttachments(userID, pinID, attachments, maxPinAttachments); err != nil {
+			deleteAttachmentBlobs(context.WithoutCancel(ctx), store, attachments)
+			if errors.Is(err, repositories.ErrTooManyAttachments) {
+				var errs validation.Errors
+				errs.Add("images", "a pin can have at most 4 images")
+				writeValidationError(w, errs)
+				return
+			}
+			writePinOwnerError(w, err, "unable to attach images")
+			return
+		}
+
+		resp, err := pinResponse(pinRepo, userID, *pin)
+		if err != nil {
+			log.Println("query pin details:", err)
+			http.Error(w, "unable to fetch pin", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.Println("encode attached pin response:", err)
+		}
+	}
+}
+
+// GET /pins/{pinID}/attachments/{attachmentID}?size=full|thumb
+func GetPinAttachmentHandler(pinRepo repositories.PinRepository, attachmentRepo repositories.AttachmentRepository, store media.BlobStore) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("userID").(uuid.UUID)
+
+		pinID, err := uuid.Parse(chi.URLParam(r, "pinID"))
+		if err != nil {
+			http.Error(w, "invalid pin ID", http.StatusBadRequest)
+			return
+		}
+		attachmentID, err := uuid.Parse(chi.URLParam(r, "attachmentID"))
+		if err != nil {
+			http.Error(w, "invalid attachment ID", http.StatusBadRequest)
+			return
+		}
+
+		variant := r.URL.Query().Get("size")
+		if variant == "" {
+			variant = media.VariantFull
+		}
+		if variant != media.VariantFull && variant != media.VariantThumb {
+			http.Error(w, "size must be full or thumb", http.StatusBadRequest)
+			return
+		}
+
+		// Attachments are only as visible as their pin.
+		pin, err := pinRepo.GetPin(userID, pinID)
+		if err != nil {
+			log.Println("get pin:", err)
+			http.Error(w, "unable to fetch attachment", http.StatusInternalServerError)
+			return
+		}
+		if pin == nil {
+			http.Error(w, "pin does not exist", http.StatusNotFound)
+			return
+		}
+
+		attachment, err := attachmentRepo.GetAttachment(pinID, attachmentID)
+		if err != nil {
+			log.Println("get attachment:", err)
+			http.Error(w, "unable to fetch attachment", http.StatusInternalServerError)
+			return
+		}
+		if attachment == nil {
+			http.Error(w, "attachment does not exist", http.StatusNotFound)
+			return
+		}
+
+		// An attachment's pixels never change, so its ID is a strong validator.
+		etag := `"` + attachment.ID.String() + "-" + variant + `"`
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", "private, max-age=31536000, immutable")
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		blob, err := store.Open(r.Context(), media.AttachmentKey(attachment.ID, variant))
+		if errors.Is(err, media.ErrBlobNotFound) {
+			http.Error(w, "attachment does not exist", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Println("open attachment blob:", err)
+			http.Error(w, "unable to fetch attachment", http.StatusInternalServerError)
+			return
+		}
+		defer blob.Close()
+
+		w.Header().Set("Content-Type", "image/jpeg")
+		if _, err := io.Copy(w, blob); err != nil {
+			log.Println("write attachment:", err)
+		}
+	}
+}
+
+// DELETE /pins/{pinID}/attachments/{attachmentID}
+func DeletePinAttachmentHandler(attachmentRepo repositories.AttachmentRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("userID").(uuid.UUID)
+
+		pinID, err := uuid.Parse(chi.URLParam(r, "pinID"))
+		if err != nil {
+			http.Error(w, "invalid pin ID", http.StatusBadRequest)
+			return
+		}
+		attachmentID, err := uuid.Parse(chi.URLParam(r, "attachmentID"))
+		if err != nil {
+			http.Error(w, "invalid attachment ID", http.StatusBadRequest)
+			return
+		}
+
+		// The blobs go with the next sweep.
+		err = attachmentRepo.DetachAttachment(userID, pinID, attachmentID)
+		switch {
+		case errors.Is(err, repositories.ErrAttachmentNotFound):
+			http.Error(w, "attachment does not exist", http.StatusNotFound)
+		case errors.Is(err, repositories.ErrNotPinOwner):
+			http.Error(w, "only the pin's owner can modify it", http.StatusForbidden)
+		case err != nil:
+			log.Println("detach attachment:", err)
+			http.Error(w, "unable to delete attachment", http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ember/api/dtos"
+	"ember/api/media"
+	"ember/api/models"
+
+	"github.com/google/uuid"
+)
+
+func testPNG(t *testing.T, w int, h int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 128, A: 255})
+		}
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatalf("encode png: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func uploadRequest(t *testing.T, userID uuid.UUID, pinID uuid.UUID, files ...[]byte) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for i, data := range files {
+		part, err := mw.CreateFormFile("images", "photo"+string(rune('a'+i))+".png")
+		if err != nil {
+			t.Fatalf("create form file: %v", err)
+		}
+		part.Write(data)
+	}
+	mw.Close()
+
+	req := authedRequest(http.MethodPost, "/pins/"+pinID.String()+"/attachments", body.String(), userID, "pinID", pinID.String())
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+// attachablePinRepo serves one pin owned by ownerID whose attachments live in attachments.
+func attachablePinRepo(pinID uuid.UUID, ownerID uuid.UUID, attachments *mockAttachmentRepo) *mockPinRepo {
+	return &mockPinRepo{
+		getPinFn: func(userID uuid.UUID, id uuid.UUID) (*models.Pin, error) {
+			if id != pinID {
+				return nil, nil
+			}
+			return &models.Pin{ID: pinID, UserID: ownerID, Emotion: "happy", Visibility: "public", CreatedAt: time.Now()}, nil
+		},
+		attachmentsFn: attachments.query,
+	}
+}
+
+func TestPostPinAttachmentsHandler_StoresImagesAndReturnsPin(t *testing.T) {
+	ownerID, pinID := uuid.New(), uuid.New()
+	attachments := newMockAttachmentRepo(ownerID)
+	store, err := media.NewLocalBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("blob store: %v", err)
+	}
+	handler := PostPinAttachmentsHandler(attachablePinRepo(pinID, ownerID, attachments), attachments, store)
+
+	rec := httptest.NewRecorder()
+	handler(rec, uploadRequest(t, ownerID, pinID, testPNG(t, 64, 48), testPNG(t, 10, 10)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status %d got %d: %s", http.StatusCreated, rec.Code, rec.Body)
+	}
+
+	var pin dtos.Pin
+	if err := json.NewDecoder(rec.Body).Decode(&pin); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(pin.Attachments) != 2 {
+		t.Fatalf("expected 2 attachments got %d", len(pin.Attachments))
+	}
+	a := pin.Attachments[0]
+	if a.Width != 64 || a.Height != 48 || a.Blurhash == "" {
+		t.Errorf("unexpected attachment %+v", a)
+	}
+	if a.ThumbnailURL != a.URL+"?size=thumb" {
+		t.Errorf("thumbnail URL = %q", a.ThumbnailURL)
+	}
+
+	for _, variant := range []string{media.VariantFull, media.VariantThumb} {
+		blob, err := store.Open(context.Background(), media.AttachmentKey(a.ID, variant))
+		if err != nil {
+			t.Fatalf("open %s: %v", variant, err)
+		}
+		blob.Close()
+	}
+}
+
+func TestPostPinAttachmentsHandler_RejectsNonOwner(t *testing.T) {
+	ownerID, pinID := uuid.New(), uuid.New()
+	attachments := newMockAttachmentRepo(ownerID)
+	store, _ := media.NewLocalBlobStore(t.TempDir())
+	handler := PostPinAttachmentsHandler(attachablePinRepo(pinID, ownerID, attachments), attachments, store)
+
+	rec := httptest.NewRecorder()
+	handler(rec, uploadRequest(t, uuid.New(), pinID, testPNG(t, 8, 8)))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d got %d", http.StatusForbidden, rec.Code)
+	}
+}
+
+func TestPostPinAttachmentsHandler_EnforcesLimit(t *testing.T) {
+	ownerID, pinID := uuid.New(), uuid.New()
+	attachments := newMockAttachmentRepo(ownerID)
+	store, _ := media.NewLocalBlobStore(t.TempDir())
+	handler := PostPinAttachmentsHandler(attachablePinRepo(pinID, ownerID, attachments), attachments, store)
+
+	img := testPNG(t, 8, 8)
+	rec := httptest.NewRecorder()
+	handler(rec, uploadRequest(t, ownerID, pinID, img, img, img))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status %d got %d: %s", http.StatusCreated, rec.Code, rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	handler(rec, uploadRequest(t, ownerID, pinID, img, img))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status %d got %d", http.StatusUnprocessableEntity, rec.Code)
+	}
+	if n := len(attachments.byPin[pinID]); n != 3 {
+		t.Errorf("expected 3 attachments to remain got %d", n)
+	}
+}
+
+func TestPostPinAttachmentsHandler_RejectsUnsupportedImage(t *testing.T) {
+	ownerID, pinID := uuid.New(), uuid.New()
+	attachments := newMockAttachmentRepo(ownerID)
+	store, _ := media.NewLocalBlobStore(t.TempDir())
+	handler := PostPinAttachmentsHandler(attachablePinRepo(pinID, ownerID, attachments), attachments, store)
+
+	rec := httptest.NewRecorder()
+	handler(rec, uploadRequest(t, ownerID, pinID, testPNG(t, 8, 8), []byte("not an image")))
+	if rec.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected status %d got %d", http.StatusUnsupportedMediaType, rec.Code)
+	}
+	if len(attachments.byPin[pinID]) != 0 {
+		t.Error("expected no attachments to be stored")
+	}
+}
+
+func TestGetPinAttachmentHandler_ServesThumbnail(t *testing.T) {
+	ownerID, pinID := uuid.New(), uuid.New()
+	attachments := newMockAttachmentRepo(ownerID)
+	store, _ := media.NewLocalBlobStore(t.TempDir())
+	pinRepo := attachablePinRepo(pinID, ownerID, attachments)
+
+	rec := httptest.NewRecorder()
+	PostPinAttachmentsHandler(pinRepo, attachments, store)(rec, uploadRequest(t, ownerID, pinID, testPNG(t, 800, 600)))
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("upload: status %d: %s", rec.Code, rec.Body)
+	}
+	attachmentID := attachments.byPin[pinID][0].ID
+
+	handler := GetPinAttachmentHandler(pinRepo, attachments, store)
+	target := "/pins/" + pinID.String() + "/attachments/" + attachmentID.String() + "?size=thumb"
+	rec = httptest.NewRecorder()
+	handler(rec, authedRequest(http.MethodGet, target, "", uuid.New(), "pinID", pinID.String(), "attachmentID", attachmentID.String()))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "image/jpeg" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	thumb, _, err := image.DecodeConfig(bytes.NewReader(rec.Body.Bytes()))
+	if err != nil {
+		t.Fatalf("decode thumbnail: %v", err)
+	}
+	if thumb.Width > 320 || thumb.Height > 320 {
+		t.Errorf("thumbnail is %dx%d", thumb.Width, thumb.Height)
+	}
+
+	etag := rec.Header().Get("ETag")
+	req := authedRequest(http.MethodGet, target, "", uuid.New(), "pinID", pinID.String(), "attachmentID", attachmentID.String())
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	handler(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Errorf("expected status %d got %d", http.StatusNotModified, rec.Code)
+	}
+}
+
+func TestGetPinAttachmentHandler_HiddenPin(t *testing.T) {
+	ownerID, pinID := uuid.New(), uuid.New()
+	attachments := newMockAttachmentRepo(ownerID)
+	store, _ := media.NewLocalBlobStore(t.TempDir())
+	pinRepo := &mockPinRepo{}
+
+	attachmentID := uuid.New()
+	rec := httptest.NewRecorder()
+	GetPinAttachmentHandler(pinRepo, attachments, store)(rec, authedRequest(http.MethodGet, "/", "", uuid.New(), "pinID", pinID.String(), "attachmentID", attachmentID.String()))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestDeletePinAttachmentHandler(t *testing.T) {
+	ownerID, pinID := uuid.New(), uuid.New()
+	attachments := newMockAttachmentRepo(ownerID)
+	attachmentID := uuid.New()
+	attachments.byPin[pinID] = []models.Attachment{{ID: attachmentID, PinID: pinID}}
+	handler := DeletePinAttachmentHandler(attachments)
+
+	rec := httptest.NewRecorder()
+	handler(rec, authedRequest(http.MethodDelete, "/", "", uuid.New(), "pinID", pinID.String(), "attachmentID", attachmentID.String()))
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d got %d", http.StatusForbidden, rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler(rec, authedRequest(http.MethodDelete, "/", "", ownerID, "pinID", pinID.String(), "attachmentID", attachmentID.String()))
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d got %d", http.StatusNoContent, rec.Code)
+	}
+	if len(attachments.detached) != 1 {
+		t.Error("expected the attachment to be detached")
+	}
+
+	rec = httptest.NewRecorder()
+	handler(rec, authedRequest(http.MethodDelete, "/", "", ownerID, "pinID", pinID.String(), "attachmentID", attachmentID.String()))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d got %d", http.StatusNotFound, rec.Code)
+	}
+}
@@ -20,8 +20,7 @@ func PostRegisterHandler(userRepo repositories.UserRepository) http.HandlerFunc
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req dtos.RegisterRequest
 
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "invalid request body", http.StatusBadRequest)
+		if !decodeRequest(w, r, &req) {
 			return
 		}
 
@@ -54,8 +53,7 @@ func PostLoginHandler(userRepo repositories.UserRepository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req dtos.LoginRequest
 
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "invalid request body", http.StatusBadRequest)
+		if !decodeRequest(w, r, &req) {
 			return
 		}
 
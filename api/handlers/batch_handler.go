@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"ember/api/dtos"
+	"ember/api/repositories"
+	"ember/api/validation"
+
+	"github.com/google/uuid"
+)
+
+// maxBatchRequestBodyBytes bounds POST /pins/batch, which carries up to
+// dtos.MaxBatchPins pins.
+const maxBatchRequestBodyBytes = 1 << 20
+
+// POST /pins/batch
+func PostPinsBatchHandler(pinRepo repositories.PinRepository, emotionRepo repositories.EmotionRepository, notificationRepo repositories.NotificationRepository, userRepo repositories.UserRepository, zoneRepo repositories.ZoneRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("userID").(uuid.UUID)
+
+		var req dtos.BatchPinsRequest
+		if !decodeRequestUpTo(w, r, &req, maxBatchRequestBodyBytes) {
+			return
+		}
+
+		resp := dtos.BatchPinsResponse{Results: make([]dtos.BatchPinResult, len(req.Pins))}
+		for i, item := range req.Pins {
+			resp.Results[i] = createBatchPin(pinRepo, emotionRepo, notificationRepo, userRepo, zoneRepo, userID, item)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.Println("encode batch pins response:", err)
+		}
+	}
+}
+
+// createBatchPin creates one pin of a batch the way POST /pins would, except
+// that its lifetime counts from when it was created on the device.
+func createBatchPin(pinRepo repositories.PinRepository, emotionRepo repositories.EmotionRepository, notificationRepo repositories.NotificationRepository, userRepo repositories.UserRepository, zoneRepo repositories.ZoneRepository, userID uuid.UUID, item dtos.BatchPin) dtos.BatchPinResult {
+	result := dtos.BatchPinResult{ClientID: item.ClientID}
+	invalid := func(errs validation.Errors) dtos.BatchPinResult {
+		result.Status = dtos.BatchPinInvalid
+		result.Errors = errs
+		return result
+	}
+	failed := func(context string, err error) dtos.BatchPinResult {
+		log.Println(context, err)
+		result.Status = dtos.BatchPinFailed
+		return result
+	}
+
+	if err := item.Validate(); err != nil {
+		var errs validation.Errors
+		if !errors.As(err, &errs) {
+			errs.Add("", err.Error())
+		}
+		return invalid(errs)
+	}
+
+	emotion, err := emotionRepo.FindEmotion(item.Emotion)
+	if err != nil {
+		return failed("find emotion:", err)
+	}
+	if emotion == nil {
+		var errs validation.Errors
+		errs.Add("emotion", "is not a known emotion")
+		return invalid(errs)
+	}
+
+	visibility, precision, err := applyPrivateZones(userRepo, zoneRepo, userID, item.Longitude, item.Latitude, item.Visibility, item.LocationPrecision)
+	if err != nil {
+		return failed("apply private zones:", err)
+	}
+
+	expiresAt := capPinExpiry(visibility, requestedPinExpiry(item.CreatePinRequest, item.CreatedAt), item.CreatedAt)
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		var errs validation.Errors
+		errs.Add("expires_at", "has already passed")
+		return invalid(errs)
+	}
+
+	pin, created, err := pinRepo.CreateClientPin(userID, repositories.ClientPin{
+		ClientID:   item.ClientID,
+		CreatedAt:  item.CreatedAt,
+		Emotion:    emotion.Key,
+		Message:    item.Message,
+		Longitude:  item.Longitude,
+		Latitude:   item.Latitude,
+		Visibility: visibility,
+		Precision:  precision,
+		ExpiresAt:  expiresAt,
+	})
+	if err != nil {
+		return failed("create client pin:", err)
+	}
+
+	result.Status = dtos.BatchPinDuplicate
+	if created {
+		result.Status = dtos.BatchPinCreated
+		if pin != nil {
+			indexPinMessage(pinRepo, notificationRepo, userID, *pin)
+		}
+	}
+	if pin != nil {
+		p := toPinDTO(*pin)
+		result.Pin = &p
+	}
+	return result
+}
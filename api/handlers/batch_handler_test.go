@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ember/api/dtos"
+	"ember/api/models"
+	"ember/api/repositories"
+
+	"github.com/google/uuid"
+)
+
+func postBatch(t *testing.T, pinRepo *mockPinRepo, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := authedRequest(http.MethodPost, "/pins/batch", body, uuid.New())
+	rec := httptest.NewRecorder()
+	PostPinsBatchHandler(pinRepo, stubEmotions("happy"), &mockNotificationRepo{}, &mockUserRepo{}, newMockZoneRepo())(rec, req)
+	return rec
+}
+
+func batchPinJSON(clientID uuid.UUID, createdAt time.Time, extra string) string {
+	return fmt.Sprintf(`{"client_id":%q,"created_at":%q,"emotion":"happy","longitude":1,"latitude":2,"visibility":"public"%s}`,
+		clientID, createdAt.Format(time.RFC3339), extra)
+}
+
+func TestPostPinsBatchHandler_IndependentResults(t *testing.T) {
+	uploaded := map[uuid.UUID]models.Pin{}
+	pinRepo := &mockPinRepo{
+		createClientPinFn: func(userID uuid.UUID, pin repositories.ClientPin) (*models.Pin, bool, error) {
+			if existing, ok := uploaded[pin.ClientID]; ok {
+				return &existing, false, nil
+			}
+			saved := models.Pin{ID: uuid.New(), UserID: userID, Emotion: pin.Emotion, Visibility: pin.Visibility, CreatedAt: pin.CreatedAt}
+			uploaded[pin.ClientID] = saved
+			return &saved, true, nil
+		},
+	}
+
+	created, repeated, unknown, tooOld := uuid.New(), uuid.New(), uuid.New(), uuid.New()
+	now := time.Now()
+	body := `{"pins":[` + strings.Join([]string{
+		batchPinJSON(created, now.Add(-time.Hour), ""),
+		batchPinJSON(repeated, now, ""),
+		batchPinJSON(repeated, now, ""),
+		strings.Replace(batchPinJSON(unknown, now, ""), `"happy"`, `"bored"`, 1),
+		batchPinJSON(tooOld, now.Add(-dtos.MaxBatchPinAge-time.Hour), ""),
+	}, ",") + `]}`
+
+	rec := postBatch(t, pinRepo, body)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d got %d: %s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var resp dtos.BatchPinsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+
+	want := []struct {
+		clientID uuid.UUID
+		status   string
+	}{
+		{created, dtos.BatchPinCreated},
+		{repeated, dtos.BatchPinCreated},
+		{repeated, dtos.BatchPinDuplicate},
+		{unknown, dtos.BatchPinInvalid},
+		{tooOld, dtos.BatchPinInvalid},
+	}
+	if len(resp.Results) != len(want) {
+		t.Fatalf("expected %d results got %d", len(want), len(resp.Results))
+	}
+	for i, w := range want {
+		got := resp.Results[i]
+		if got.ClientID != w.clientID || got.Status != w.status {
+			t.Fatalf("result %d: expected %s %q got %s %q", i, w.clientID, w.status, got.ClientID, got.Status)
+		}
+	}
+	if resp.Results[1].Pin == nil || resp.Results[2].Pin == nil || resp.Results[1].Pin.ID != resp.Results[2].Pin.ID {
+		t.Fatalf("expected the duplicate to return the pin already created")
+	}
+	if len(resp.Results[3].Errors) == 0 || resp.Results[3].Errors[0].Field != "emotion" {
+		t.Fatalf("expected an emotion error, got %+v", resp.Results[3].Errors)
+	}
+	if len(resp.Results[4].Errors) == 0 || resp.Results[4].Errors[0].Field != "created_at" {
+		t.Fatalf("expected a created_at error, got %+v", resp.Results[4].Errors)
+	}
+}
+
+func TestPostPinsBatchHandler_RepositoryErrorFailsOnlyThatPin(t *testing.T) {
+	broken := uuid.New()
+	pinRepo := &mockPinRepo{
+		createClientPinFn: func(userID uuid.UUID, pin repositories.ClientPin) (*models.Pin, bool, error) {
+			if pin.ClientID == broken {
+				return nil, false, fmt.Errorf("connection reset")
+			}
+			return &models.Pin{ID: uuid.New(), UserID: userID}, true, nil
+		},
+	}
+
+	now := time.Now()
+	body := `{"pins":[` + batchPinJSON(broken, now, "") + `,` + batchPinJSON(uuid.New(), now, "") + `]}`
+	rec := postBatch(t, pinRepo, body)
+
+	var resp dtos.BatchPinsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(resp.Results) != 2 || resp.Results[0].Status != dtos.BatchPinFailed || resp.Results[1].Status != dtos.BatchPinCreated {
+		t.Fatalf("unexpected results %+v", resp.Results)
+	}
+}
+
+func TestPostPinsBatchHandler_TTLCountsFromCreatedAt(t *testing.T) {
+	var captured *time.Time
+	pinRepo := &mockPinRepo{
+		createClientPinFn: func(userID uuid.UUID, pin repositories.ClientPin) (*models.Pin, bool, error) {
+			captured = pin.ExpiresAt
+			return &models.Pin{ID: uuid.New(), UserID: userID}, true, nil
+		},
+	}
+
+	createdAt := time.Now().Add(-2 * time.Hour).Truncate(time.Second)
+	expired := uuid.New()
+	body := `{"pins":[` + batchPinJSON(uuid.New(), createdAt, `,"ttl_seconds":10800`) + `,` +
+		batchPinJSON(expired, createdAt, `,"ttl_seconds":3600`) + `]}`
+	rec := postBatch(t, pinRepo, body)
+
+	var resp dtos.BatchPinsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if captured == nil || !captured.Equal(createdAt.Add(3*time.Hour)) {
+		t.Fatalf("expected expiry %v got %v", createdAt.Add(3*time.Hour), captured)
+	}
+	if len(resp.Results) != 2 || resp.Results[1].Status != dtos.BatchPinInvalid {
+		t.Fatalf("expected a pin whose TTL already ran out to be invalid, got %+v", resp.Results)
+	}
+}
+
+func TestPostPinsBatchHandler_TooManyPins(t *testing.T) {
+	pins := make([]string, dtos.MaxBatchPins+1)
+	for i := range pins {
+		pins[i] = batchPinJSON(uuid.New(), time.Now(), "")
+	}
+	rec := postBatch(t, &mockPinRepo{}, `{"pins":[`+strings.Join(pins, ",")+`]}`)
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status %d got %d", http.StatusUnprocessableEntity, rec.Code)
+	}
+}
+
+func TestBatchPin_CreatedAtInTheFuture(t *testing.T) {
+	pin := dtos.BatchPin{
+		ClientID:  uuid.New(),
+		CreatedAt: time.Now().Add(dtos.MaxClockSkew + time.Minute),
+		CreatePinRequest: dtos.CreatePinRequest{
+			Emotion:    "happy",
+			Visibility: "public",
+		},
+	}
+	if pin.Validate() == nil {
+		t.Fatalf("expected a created_at in the future to be rejected")
+	}
+	pin.CreatedAt = time.Now().Add(time.Minute)
+	if err := pin.Validate(); err != nil {
+		t.Fatalf("expected a little clock skew to be allowed: %v", err)
+	}
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"ember/api/models"
+	"ember/api/validation"
+)
+
+// parseBBox parses a "minLon,minLat,maxLon,maxLat" query value. minLon may
+// exceed maxLon for viewports that cross the antimeridian.
+func parseBBox(raw string) (models.BBox, error) {
+	parts := strings.Split(raw, ",")
+	if len(parts) != 4 {
+		return models.BBox{}, errors.New("bbox must be minLon,minLat,maxLon,maxLat")
+	}
+
+	var values [4]float64
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+		if err != nil {
+			return models.BBox{}, errors.New("bbox must contain four numbers")
+		}
+		values[i] = v
+	}
+
+	bbox := models.BBox{MinLon: values[0], MinLat: values[1], MaxLon: values[2], MaxLat: values[3]}
+	if !validation.Longitude(bbox.MinLon) || !validation.Longitude(bbox.MaxLon) {
+		return models.BBox{}, errors.New("bbox longitudes must be between -180 and 180")
+	}
+	if !validation.Latitude(bbox.MinLat) || !validation.Latitude(bbox.MaxLat) {
+		return models.BBox{}, errors.New("bbox latitudes must be between -90 and 90")
+	}
+	if bbox.MinLat >= bbox.MaxLat || bbox.MinLon == bbox.MaxLon {
+		return models.BBox{}, errors.New("bbox must have a non-empty area")
+	}
+
+	return bbox, nil
+}
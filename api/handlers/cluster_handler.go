@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+
+	"ember/api/dtos"
+	"ember/api/repositories"
+
+	"github.com/google/uuid"
+)
+
+const (
+	maxClusterZoom = 20
+	// clusterCellsPerTile splits each 256px map tile into 4x4 cells of 64px.
+	clusterCellsPerTile = 4
+	// minClusterSize is the smallest cell that is clustered; sparser cells come back as pins.
+	minClusterSize = 3
+	// maxClusterCells bounds the grid so a wide bbox at a deep zoom can't explode.
+	maxClusterCells = 4096
+)
+
+// clusterCellDeg is the grid cell size, in degrees, used at a web map zoom level.
+func clusterCellDeg(zoom int) float64 {
+	return 360 / (math.Exp2(float64(zoom)) * clusterCellsPerTile)
+}
+
+// GET /pins/clusters?bbox=minLon,minLat,maxLon,maxLat&zoom=
+func GetPinClustersHandler(pinRepo repositories.PinRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		for _, key := range []string{"bbox", "zoom"} {
+			if query.Get(key) == "" {
+				http.Error(w, "missing required query parameter: "+key, http.StatusBadRequest)
+				return
+			}
+		}
+
+		bbox, err := parseBBox(query.Get("bbox"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		zoom, err := strconv.Atoi(query.Get("zoom"))
+		if err != nil || zoom < 0 || zoom > maxClusterZoom {
+			http.Error(w, "zoom must be an integer between 0 and 20", http.StatusBadRequest)
+			return
+		}
+
+		cellDeg := clusterCellDeg(zoom)
+		if math.Ceil(bbox.WidthDeg()/cellDeg)*math.Ceil(bbox.HeightDeg()/cellDeg) > maxClusterCells {
+			http.Error(w, "bbox is too large for this zoom", http.StatusBadRequest)
+			return
+		}
+
+		userID := r.Context().Value("userID").(uuid.UUID)
+		clusters, pins, err := pinRepo.QueryPinClusters(userID, bbox, cellDeg, minClusterSize)
+		if err != nil {
+			log.Println("query pin clusters:", err)
+			http.Error(w, "unable to fetch pins", http.StatusInternalServerError)
+			return
+		}
+
+		resp := dtos.GetPinClustersResponse{
+			Zoom:     zoom,
+			Clusters: make([]dtos.PinCluster, 0, len(clusters)),
+			Pins:     make([]dtos.Pin, 0, len(pins)),
+		}
+		for _, c := range clusters {
+			resp.Clusters = append(resp.Clusters, dtos.PinCluster{
+				Longitude:       c.Location.Longitude,
+				Latitude:        c.Location.Latitude,
+				Count:           c.Count,
+				DominantEmotion: c.DominantEmotion,
+				Emotions:        c.Emotions,
+			})
+		}
+		for _, pin := range pins {
+			resp.Pins = append(resp.Pins, toPinDTO(pin))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.Println("encode pin clusters response:", err)
+		}
+	}
+}
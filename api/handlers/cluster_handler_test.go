@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ember/api/dtos"
+	"ember/api/models"
+
+	"github.com/google/uuid"
+)
+
+func TestGetPinClustersHandler_Success(t *testing.T) {
+	userID := uuid.New()
+	var capturedCell float64
+
+	pinRepo := &mockPinRepo{
+		queryClustersFn: func(id uuid.UUID, bbox models.BBox, cellDeg float64, minSize int) ([]models.PinCluster, []models.Pin, error) {
+			if id != userID || minSize != minClusterSize {
+				t.Fatalf("unexpected arguments %s %d", id, minSize)
+			}
+			capturedCell = cellDeg
+			return []models.PinCluster{
+					{
+						Location:        models.Location{Longitude: -123.1, Latitude: 49.2},
+						Count:           5,
+						DominantEmotion: "happy",
+						Emotions:        map[string]int{"happy": 4, "sad": 1},
+					},
+				},
+				[]models.Pin{{ID: uuid.New(), Emotion: "calm"}},
+				nil
+		},
+	}
+
+	req := authedRequest(http.MethodGet, "/pins/clusters?bbox=-124,49,-122,50&zoom=8", "", userID)
+	rec := httptest.NewRecorder()
+
+	GetPinClustersHandler(pinRepo)(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d got %d", http.StatusOK, rec.Code)
+	}
+	if capturedCell != clusterCellDeg(8) {
+		t.Fatalf("expected cell size %f got %f", clusterCellDeg(8), capturedCell)
+	}
+
+	var resp dtos.GetPinClustersResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.Clusters) != 1 || resp.Clusters[0].Count != 5 || resp.Clusters[0].Emotions["happy"] != 4 {
+		t.Fatalf("unexpected clusters payload: %+v", resp.Clusters)
+	}
+	if len(resp.Pins) != 1 || resp.Pins[0].Emotion != "calm" {
+		t.Fatalf("unexpected pins payload: %+v", resp.Pins)
+	}
+}
+
+func TestGetPinClustersHandler_TooManyCells(t *testing.T) {
+	req := authedRequest(http.MethodGet, "/pins/clusters?bbox=-180,-80,180,80&zoom=15", "", uuid.New())
+	rec := httptest.NewRecorder()
+
+	GetPinClustersHandler(&mockPinRepo{})(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestGetPinClustersHandler_InvalidZoom(t *testing.T) {
+	req := authedRequest(http.MethodGet, "/pins/clusters?bbox=-124,49,-122,50&zoom=30", "", uuid.New())
+	rec := httptest.NewRecorder()
+
+	GetPinClustersHandler(&mockPinRepo{})(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d got %d", http.StatusBadRequest, rec.Code)
+	}
+}
@@ -0,0 +1,237 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"ember/api/dtos"
+	"ember/api/models"
+	"ember/api/repositories"
+	"ember/api/validation"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultCommentPageSize = 20
+	maxCommentPageSize     = 100
+
+	// Notifications quote this much of a comment
+	commentPreviewLength = 80
+)
+
+func toCommentDTO(c models.Comment) dtos.Comment {
+	comment := dtos.Comment{
+		ID:    c.ID,
+		PinID: c.PinID,
+		Author: dtos.CommentAuthor{
+			ID:       c.Author.ID,
+			Username: c.Author.Username,
+		},
+		Body:       c.Body,
+		CreatedAt:  c.CreatedAt,
+		Deleted:    c.Deleted,
+		ReplyCount: c.ReplyCount,
+	}
+	if c.ParentID.Valid {
+		comment.ParentID = &c.ParentID.UUID
+	}
+	if c.Author.DisplayName.Valid {
+		comment.Author.DisplayName = c.Author.DisplayName.String
+	}
+	return comment
+}
+
+func commentPreview(body string) string {
+	runes := []rune(body)
+	if len(runes) <= commentPreviewLength {
+		return body
+	}
+	return string(runes[:commentPreviewLength-1]) + "…"
+}
+
+// GET /pins/{pinID}/comments?parent_id=&limit=&cursor=
+func GetPinCommentsHandler(pinRepo repositories.PinRepository, commentRepo repositories.CommentRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("userID").(uuid.UUID)
+
+		pinID, err := uuid.Parse(chi.URLParam(r, "pinID"))
+		if err != nil {
+			http.Error(w, "invalid pin ID", http.StatusBadRequest)
+			return
+		}
+
+		var parentID *uuid.UUID
+		if raw := r.URL.Query().Get("parent_id"); raw != "" {
+			id, err := uuid.Parse(raw)
+			if err != nil {
+				http.Error(w, "invalid parent_id", http.StatusBadRequest)
+				return
+			}
+			parentID = &id
+		}
+
+		page, err := parsePage(r, defaultCommentPageSize, maxCommentPageSize)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Comments are only as visible as their pin.
+		pin, err := pinRepo.GetPin(userID, pinID)
+		if err != nil {
+			log.Println("get pin:", err)
+			http.Error(w, "unable to fetch comments", http.StatusInternalServerError)
+			return
+		}
+		if pin == nil {
+			http.Error(w, "pin does not exist", http.StatusNotFound)
+			return
+		}
+
+		comments, err := commentRepo.ListComments(userID, pinID, parentID, page)
+		if err != nil {
+			log.Println("list comments:", err)
+			http.Error(w, "unable to fetch comments", http.StatusInternalServerError)
+			return
+		}
+
+		var resp dtos.GetCommentsResponse
+		resp.Comments, resp.NextCursor = paginate(comments, page, toCommentDTO, func(c models.Comment) repositories.Cursor {
+			return repositories.Cursor{CreatedAt: c.CreatedAt, ID: c.ID}
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.Println("encode comments response:", err)
+		}
+	}
+}
+
+// POST /pins/{pinID}/comments
+func PostPinCommentsHandler(pinRepo repositories.PinRepository, commentRepo repositories.CommentRepository, notificationRepo repositories.NotificationRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("userID").(uuid.UUID)
+
+		pinID, err := uuid.Parse(chi.URLParam(r, "pinID"))
+		if err != nil {
+			http.Error(w, "invalid pin ID", http.StatusBadRequest)
+			return
+		}
+
+		var req dtos.CreateCommentRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+
+		comment, err := commentRepo.CreateComment(userID, pinID, req.ParentID, req.Body)
+		switch {
+		case errors.Is(err, repositories.ErrPinNotFound):
+			http.Error(w, "pin does not exist", http.StatusNotFound)
+			return
+		case errors.Is(err, repositories.ErrCommentNotFound):
+			var errs validation.Errors
+			errs.Add("parent_id", "must be a comment on this pin")
+			writeValidationError(w, errs)
+			return
+		case err != nil || comment == nil:
+			log.Println("create comment:", err)
+			http.Error(w, "unable to create comment", http.StatusInternalServerError)
+			return
+		}
+
+		notifyComment(pinRepo, commentRepo, notificationRepo, userID, *comment)
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(toCommentDTO(*comment)); err != nil {
+			log.Println("encode created comment response:", err)
+		}
+	}
+}
+
+// notifyComment tells the author of the parent comment about a reply, and
+// the pin's owner about any other comment on their pin; nobody hears twice.
+func notifyComment(pinRepo repositories.PinRepository, commentRepo repositories.CommentRepository, notificationRepo repositories.NotificationRepository, userID uuid.UUID, comment models.Comment) {
+	data := map[string]any{
+		"comment_id": comment.ID.String(),
+		"preview":    commentPreview(comment.Body),
+	}
+
+	var repliedTo uuid.UUID
+	if comment.ParentID.Valid {
+		parent, err := commentRepo.GetComment(userID, comment.ParentID.UUID)
+		if err != nil {
+			log.Println("get parent comment:", err)
+		} else if parent != nil {
+			repliedTo = parent.Author.ID
+			notify(notificationRepo, repositories.NewNotification{
+				RecipientID: repliedTo,
+				ActorID:     userID,
+				Type:        models.NotificationCommentReply,
+				PinID:       &comment.PinID,
+				Data:        data,
+			})
+		}
+	}
+
+	pin, err := pinRepo.GetPin(userID, comment.PinID)
+	if err != nil {
+		log.Println("get pin:", err)
+		return
+	}
+	if pin != nil && pin.UserID != repliedTo {
+		notify(notificationRepo, repositories.NewNotification{
+			RecipientID: pin.UserID,
+			ActorID:     userID,
+			Type:        models.NotificationPinComment,
+			PinID:       &comment.PinID,
+			Data:        data,
+		})
+	}
+}
+
+// DELETE /pins/{pinID}/comments/{commentID}
+func DeletePinCommentHandler(commentRepo repositories.CommentRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("userID").(uuid.UUID)
+
+		pinID, err := uuid.Parse(chi.URLParam(r, "pinID"))
+		if err != nil {
+			http.Error(w, "invalid pin ID", http.StatusBadRequest)
+			return
+		}
+		commentID, err := uuid.Parse(chi.URLParam(r, "commentID"))
+		if err != nil {
+			http.Error(w, "invalid comment ID", http.StatusBadRequest)
+			return
+		}
+
+		comment, err := commentRepo.GetComment(userID, commentID)
+		if err != nil {
+			log.Println("get comment:", err)
+			http.Error(w, "unable to delete comment", http.StatusInternalServerError)
+			return
+		}
+		if comment == nil || comment.PinID != pinID || comment.Deleted {
+			http.Error(w, "comment does not exist", http.StatusNotFound)
+			return
+		}
+
+		err = commentRepo.DeleteComment(userID, commentID)
+		switch {
+		case errors.Is(err, repositories.ErrCommentNotFound):
+			http.Error(w, "comment does not exist", http.StatusNotFound)
+		case errors.Is(err, repositories.ErrNotCommentAuthor):
+			http.Error(w, "only the comment's author or the pin's owner can delete it", http.StatusForbidden)
+		case err != nil:
+			log.Println("delete comment:", err)
+			http.Error(w, "unable to delete comment", http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+}
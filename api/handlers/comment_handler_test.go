@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ember/api/dtos"
+	"ember/api/models"
+	"ember/api/repositories"
+
+	"github.com/google/uuid"
+)
+
+func visiblePinRepo(pinID uuid.UUID, ownerID uuid.UUID) *mockPinRepo {
+	return &mockPinRepo{
+		getPinFn: func(userID uuid.UUID, id uuid.UUID) (*models.Pin, error) {
+			if id != pinID {
+				return nil, nil
+			}
+			return &models.Pin{ID: pinID, UserID: ownerID, Emotion: "sad", CreatedAt: time.Now()}, nil
+		},
+	}
+}
+
+func TestGetPinCommentsHandler_HiddenPin(t *testing.T) {
+	comments := &mockCommentRepo{
+		listFn: func(userID uuid.UUID, pinID uuid.UUID, parentID *uuid.UUID, page repositories.Page) ([]models.Comment, error) {
+			t.Error("comments of a hidden pin should not be listed")
+			return nil, nil
+		},
+	}
+
+	rec := httptest.NewRecorder()
+	GetPinCommentsHandler(&mockPinRepo{}, comments)(rec, authedRequest(http.MethodGet, "/", "", uuid.New(), "pinID", uuid.New().String()))
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestGetPinCommentsHandler_PagesThroughReplies(t *testing.T) {
+	userID, pinID, parentID := uuid.New(), uuid.New(), uuid.New()
+	base := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	comments := &mockCommentRepo{
+		listFn: func(u uuid.UUID, p uuid.UUID, parent *uuid.UUID, page repositories.Page) ([]models.Comment, error) {
+			if parent == nil || *parent != parentID {
+				t.Errorf("parent = %v, want %v", parent, parentID)
+			}
+			if page.Limit != 2 {
+				t.Errorf("limit = %d, want 2", page.Limit)
+			}
+			var out []models.Comment
+			for i := 0; i < page.Limit+1; i++ {
+				out = append(out, models.Comment{
+					ID:        uuid.New(),
+					PinID:     pinID,
+					ParentID:  uuid.NullUUID{UUID: parentID, Valid: true},
+					Author:    models.User{ID: uuid.New(), Username: "sam"},
+					Body:      "you ok?",
+					CreatedAt: base.Add(time.Duration(i) * time.Minute),
+				})
+			}
+			return out, nil
+		},
+	}
+
+	target := "/pins/" + pinID.String() + "/comments?limit=2&parent_id=" + parentID.String()
+	rec := httptest.NewRecorder()
+	GetPinCommentsHandler(visiblePinRepo(pinID, uuid.New()), comments)(rec, authedRequest(http.MethodGet, target, "", userID, "pinID", pinID.String()))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	var resp dtos.GetCommentsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Comments) != 2 || resp.NextCursor == nil {
+		t.Fatalf("expected a full page with a cursor, got %d comments, cursor %v", len(resp.Comments), resp.NextCursor)
+	}
+	if resp.Comments[0].ParentID == nil || *resp.Comments[0].ParentID != parentID {
+		t.Errorf("replies should carry their parent")
+	}
+}
+
+func TestPostPinCommentsHandler_NotifiesOwnerAndParentAuthor(t *testing.T) {
+	userID, ownerID, parentAuthor := uuid.New(), uuid.New(), uuid.New()
+	pinID, parentID := uuid.New(), uuid.New()
+
+	comments := &mockCommentRepo{
+		createFn: func(u uuid.UUID, p uuid.UUID, parent *uuid.UUID, body string) (*models.Comment, error) {
+			return &models.Comment{
+				ID:       uuid.New(),
+				PinID:    p,
+				ParentID: uuid.NullUUID{UUID: *parent, Valid: true},
+				Author:   models.User{ID: u, Username: "sam"},
+				Body:     body,
+			}, nil
+		},
+		getFn: func(u uuid.UUID, id uuid.UUID) (*models.Comment, error) {
+			return &models.Comment{ID: id, PinID: pinID, Author: models.User{ID: parentAuthor}}, nil
+		},
+	}
+	notifications := &mockNotificationRepo{}
+
+	body := `{"body":"` + strings.Repeat("a", 100) + `","parent_id":"` + parentID.String() + `"}`
+	rec := httptest.NewRecorder()
+	PostPinCommentsHandler(visiblePinRepo(pinID, ownerID), comments, notifications)(rec, authedRequest(http.MethodPost, "/", body, userID, "pinID", pinID.String()))
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status %d got %d: %s", http.StatusCreated, rec.Code, rec.Body)
+	}
+	if len(notifications.created) != 2 {
+		t.Fatalf("expected two notifications, got %+v", notifications.created)
+	}
+	got := map[uuid.UUID]string{}
+	for _, n := range notifications.created {
+		got[n.RecipientID] = n.Type
+		if preview := n.Data["preview"].(string); len([]rune(preview)) != commentPreviewLength {
+			t.Errorf("preview has %d characters, want %d", len([]rune(preview)), commentPreviewLength)
+		}
+	}
+	if got[parentAuthor] != models.NotificationCommentReply || got[ownerID] != models.NotificationPinComment {
+		t.Errorf("unexpected notifications %v", got)
+	}
+}
+
+func TestPostPinCommentsHandler_OwnerReplyNotifiedOnce(t *testing.T) {
+	userID, ownerID, pinID := uuid.New(), uuid.New(), uuid.New()
+
+	comments := &mockCommentRepo{
+		createFn: func(u uuid.UUID, p uuid.UUID, parent *uuid.UUID, body string) (*models.Comment, error) {
+			return &models.Comment{ID: uuid.New(), PinID: p, ParentID: uuid.NullUUID{UUID: *parent, Valid: true}, Body: body}, nil
+		},
+		getFn: func(u uuid.UUID, id uuid.UUID) (*models.Comment, error) {
+			return &models.Comment{ID: id, PinID: pinID, Author: models.User{ID: ownerID}}, nil
+		},
+	}
+	notifications := &mockNotificationRepo{}
+
+	body := `{"body":"thanks!","parent_id":"` + uuid.New().String() + `"}`
+	rec := httptest.NewRecorder()
+	PostPinCommentsHandler(visiblePinRepo(pinID, ownerID), comments, notifications)(rec, authedRequest(http.MethodPost, "/", body, userID, "pinID", pinID.String()))
+
+	if len(notifications.created) != 1 || notifications.created[0].Type != models.NotificationCommentReply {
+		t.Errorf("expected a single reply notification, got %+v", notifications.created)
+	}
+}
+
+func TestPostPinCommentsHandler_Errors(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		err  error
+		want int
+	}{
+		{"empty body", `{"body":"  "}`, nil, http.StatusUnprocessableEntity},
+		{"too long", `{"body":"` + strings.Repeat("a", dtos.MaxCommentLength+1) + `"}`, nil, http.StatusUnprocessableEntity},
+		{"hidden pin", `{"body":"hi"}`, repositories.ErrPinNotFound, http.StatusNotFound},
+		{"foreign parent", `{"body":"hi","parent_id":"` + uuid.New().String() + `"}`, repositories.ErrCommentNotFound, http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			comments := &mockCommentRepo{
+				createFn: func(u uuid.UUID, p uuid.UUID, parent *uuid.UUID, body string) (*models.Comment, error) {
+					return nil, tt.err
+				},
+			}
+
+			rec := httptest.NewRecorder()
+			PostPinCommentsHandler(&mockPinRepo{}, comments, &mockNotificationRepo{})(rec, authedRequest(http.MethodPost, "/", tt.body, uuid.New(), "pinID", uuid.New().String()))
+
+			if rec.Code != tt.want {
+				t.Errorf("expected status %d got %d", tt.want, rec.Code)
+			}
+		})
+	}
+}
+
+func TestDeletePinCommentHandler(t *testing.T) {
+	pinID, commentID := uuid.New(), uuid.New()
+	comments := &mockCommentRepo{
+		getFn: func(u uuid.UUID, id uuid.UUID) (*models.Comment, error) {
+			return &models.Comment{ID: id, PinID: pinID}, nil
+		},
+		deleteFn: func(u uuid.UUID, id uuid.UUID) error {
+			return repositories.ErrNotCommentAuthor
+		},
+	}
+
+	req := authedRequest(http.MethodDelete, "/", "", uuid.New(), "pinID", pinID.String(), "commentID", commentID.String())
+	rec := httptest.NewRecorder()
+	DeletePinCommentHandler(comments)(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("stranger: expected status %d got %d", http.StatusForbidden, rec.Code)
+	}
+
+	comments.deleteFn = func(u uuid.UUID, id uuid.UUID) error { return nil }
+	rec = httptest.NewRecorder()
+	DeletePinCommentHandler(comments)(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("author: expected status %d got %d", http.StatusNoContent, rec.Code)
+	}
+
+	otherPin := authedRequest(http.MethodDelete, "/", "", uuid.New(), "pinID", uuid.New().String(), "commentID", commentID.String())
+	rec = httptest.NewRecorder()
+	DeletePinCommentHandler(comments)(rec, otherPin)
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("comment under another pin: expected status %d got %d", http.StatusNotFound, rec.Code)
+	}
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"ember/api/dtos"
+	"ember/api/repositories"
+	"ember/api/validation"
+)
+
+// emotionCatalogMaxAge is how long clients may use the catalogue before revalidating it.
+const emotionCatalogMaxAge = 300
+
+func emotionCatalogETag(version int64) string {
+	return fmt.Sprintf(`"emotions-v%d"`, version)
+}
+
+// etagMatches reports whether an If-None-Match header lists etag (or is "*").
+func etagMatches(ifNoneMatch string, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+		if candidate == etag || candidate == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// GET /emotions
+func GetEmotionsHandler(emotionRepo repositories.EmotionRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", emotionCatalogMaxAge))
+
+		// Most requests are revalidations, which only need the version.
+		if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" {
+			version, err := emotionRepo.GetCatalogVersion()
+			if err != nil {
+				log.Println("get emotion catalog version:", err)
+				http.Error(w, "unable to fetch emotions", http.StatusInternalServerError)
+				return
+			}
+			if etag := emotionCatalogETag(version); etagMatches(ifNoneMatch, etag) {
+				w.Header().Set("ETag", etag)
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+		}
+
+		catalog, err := emotionRepo.GetCatalog()
+		if err != nil {
+			log.Println("get emotion catalog:", err)
+			http.Error(w, "unable to fetch emotions", http.StatusInternalServerError)
+			return
+		}
+
+		resp := dtos.GetEmotionsResponse{
+			Version:  catalog.Version,
+			Emotions: make([]dtos.Emotion, 0, len(catalog.Emotions)),
+		}
+		for _, e := range catalog.Emotions {
+			resp.Emotions = append(resp.Emotions, dtos.Emotion{
+				Key:      e.Key,
+				Emoji:    e.Emoji,
+				Label:    e.Label,
+				Valence:  e.Valence,
+				Arousal:  e.Arousal,
+				Category: e.Category,
+			})
+		}
+
+		w.Header().Set("ETag", emotionCatalogETag(catalog.Version))
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.Println("encode emotions response:", err)
+		}
+	}
+}
+
+// resolveEmotion maps a client-supplied emotion key or emoji to its catalogue
+// key. It writes a 422 (unknown emotion) or 500 response and returns false on failure.
+func resolveEmotion(w http.ResponseWriter, emotionRepo repositories.EmotionRepository, value string) (string, bool) {
+	emotion, err := emotionRepo.FindEmotion(value)
+	if err != nil {
+		log.Println("find emotion:", err)
+		http.Error(w, "unable to validate emotion", http.StatusInternalServerError)
+		return "", false
+	}
+	if emotion == nil {
+		var errs validation.Errors
+		errs.Add("emotion", "is not a known emotion")
+		writeValidationError(w, errs)
+		return "", false
+	}
+	return emotion.Key, true
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ember/api/dtos"
+	"ember/api/models"
+
+	"github.com/google/uuid"
+)
+
+func TestGetEmotionsHandler_Success(t *testing.T) {
+	repo := &mockEmotionRepo{
+		getCatalogFn: func() (*models.EmotionCatalog, error) {
+			return &models.EmotionCatalog{
+				Version: 7,
+				Emotions: []models.Emotion{
+					{Key: "happy", Emoji: "🙂", Label: "Happy", Valence: 0.6, Arousal: 0.5, Category: "joy"},
+				},
+			}, nil
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/emotions", nil)
+	rec := httptest.NewRecorder()
+
+	GetEmotionsHandler(repo)(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d got %d", http.StatusOK, rec.Code)
+	}
+	if etag := rec.Header().Get("ETag"); etag != `"emotions-v7"` {
+		t.Fatalf("unexpected ETag %q", etag)
+	}
+
+	var resp dtos.GetEmotionsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Version != 7 || len(resp.Emotions) != 1 || resp.Emotions[0].Emoji != "🙂" {
+		t.Fatalf("unexpected catalogue payload: %+v", resp)
+	}
+}
+
+func TestGetEmotionsHandler_NotModified(t *testing.T) {
+	repo := &mockEmotionRepo{
+		getCatalogVersionFn: func() (int64, error) {
+			return 7, nil
+		},
+		getCatalogFn: func() (*models.EmotionCatalog, error) {
+			t.Fatalf("catalogue must not be loaded when the client copy is current")
+			return nil, nil
+		},
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/emotions", nil)
+	req.Header.Set("If-None-Match", `W/"emotions-v7"`)
+	rec := httptest.NewRecorder()
+
+	GetEmotionsHandler(repo)(rec, req)
+
+	if rec.Code != http.StatusNotModified {
+		t.Fatalf("expected status %d got %d", http.StatusNotModified, rec.Code)
+	}
+}
+
+func TestPostPinsHandler_ResolvesEmoji(t *testing.T) {
+	var captured string
+	pinRepo := &mockPinRepo{
+		createPinFn: func(u uuid.UUID, emotion string, message string, lon float64, lat float64, visibility string, precision string, expiresAt *time.Time) (*models.Pin, error) {
+			captured = emotion
+			return &models.Pin{ID: uuid.New(), UserID: u, Emotion: emotion}, nil
+		},
+	}
+	emotionRepo := &mockEmotionRepo{
+		findEmotionFn: func(keyOrEmoji string) (*models.Emotion, error) {
+			if keyOrEmoji == "😢" {
+				return &models.Emotion{Key: "sad", Emoji: "😢"}, nil
+			}
+			return nil, nil
+		},
+	}
+
+	body := `{"emotion":"😢","longitude":1,"latitude":2,"visibility":"public"}`
+	req := authedRequest(http.MethodPost, "/pins", body, uuid.New())
+	rec := httptest.NewRecorder()
+
+	PostPinsHandler(pinRepo, emotionRepo, &mockNotificationRepo{}, &mockUserRepo{}, newMockZoneRepo())(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status %d got %d", http.StatusCreated, rec.Code)
+	}
+	if captured != "sad" {
+		t.Fatalf("expected emoji to be stored as its key, got %q", captured)
+	}
+}
+
+func TestPostPinsHandler_UnknownEmotion(t *testing.T) {
+	body := `{"emotion":"hungry","longitude":1,"latitude":2,"visibility":"public"}`
+	req := authedRequest(http.MethodPost, "/pins", body, uuid.New())
+	rec := httptest.NewRecorder()
+
+	PostPinsHandler(&mockPinRepo{}, stubEmotions("happy"), &mockNotificationRepo{}, &mockUserRepo{}, newMockZoneRepo())(rec, req)
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status %d got %d", http.StatusUnprocessableEntity, rec.Code)
+	}
+}
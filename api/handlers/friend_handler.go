@@ -6,6 +6,7 @@ import (
 	"net/http"
 
 	"ember/api/dtos"
+	"ember/api/models"
 	"ember/api/repositories"
 	"log"
 
@@ -15,36 +16,59 @@ import (
 
 // --- FRIENDS ---
 
-// GET /friends
+func toFriendDTO(user models.User) dtos.Friend {
+	friend := dtos.Friend{
+		ID:       user.ID,
+		Username: user.Username,
+	}
+
+	if user.DisplayName.Valid {
+		friend.DisplayName = user.DisplayName.String
+	}
+
+	if user.Bio.Valid {
+		friend.Bio = user.Bio.String
+	}
+
+	return friend
+}
+
+func toFriendRequestDTO(request models.FriendRequest) dtos.FriendRequest {
+	resp := dtos.FriendRequest{
+		ID:       request.ID,
+		Username: request.Username,
+	}
+
+	if request.DisplayName.Valid {
+		resp.DisplayName = request.DisplayName.String
+	}
+
+	return resp
+}
+
+// GET /friends?limit=&cursor=
 func GetFriendsHandler(userRepo repositories.UserRepository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		userID := r.Context().Value("userID").(uuid.UUID)
 
+		page, err := parsePage(r, defaultPageSize, maxPageSize)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
 		// Query database for friends information
-		friendsList, err := userRepo.GetFriendsByUUID(userID)
+		friendsList, err := userRepo.GetFriendsByUUID(userID, page)
 		if err != nil {
 			log.Println(err)
 			http.Error(w, "Unable to query friends data", http.StatusInternalServerError)
+			return
 		}
 
 		var resp dtos.GetFriendsResponse
-		resp.Friends = []dtos.Friend{}
-		for _, v := range friendsList {
-			friend := dtos.Friend{
-				ID:       v.ID,
-				Username: v.Username,
-			}
-
-			if v.DisplayName.Valid {
-				friend.DisplayName = v.DisplayName.String
-			}
-
-			if v.Bio.Valid {
-				friend.Bio = v.Bio.String
-			}
-
-			resp.Friends = append(resp.Friends, friend)
-		}
+		resp.Friends, resp.NextCursor = paginate(friendsList, page, toFriendDTO, func(u models.User) repositories.Cursor {
+			return repositories.Cursor{Username: u.Username, ID: u.ID}
+		})
 
 		w.Header().Set("Content-Type", "application/json")
 		json.NewEncoder(w).Encode(resp)
@@ -81,7 +105,7 @@ func DeleteFriendsHandler(userRepo repositories.UserRepository) http.HandlerFunc
 // --- FRIEND REQUESTS ---
 
 // POST /friends/requests/{friendID}
-func PostFriendRequestsHandler(userRepo repositories.UserRepository) http.HandlerFunc {
+func PostFriendRequestsHandler(userRepo repositories.UserRepository, notificationRepo repositories.NotificationRepository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		userID := r.Context().Value("userID").(uuid.UUID)
 
@@ -113,49 +137,52 @@ func PostFriendRequestsHandler(userRepo repositories.UserRepository) http.Handle
 			return
 		}
 
+		notify(notificationRepo, repositories.NewNotification{
+			RecipientID: friendID,
+			ActorID:     userID,
+			Type:        models.NotificationFriendRequest,
+		})
+
 		w.WriteHeader(http.StatusOK)
 	}
 }
 
-// GET /friends/requests
+// GET /friends/requests?limit=&cursor=
 func GetFriendRequestsHandler(userRepo repositories.UserRepository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		userID := r.Context().Value("userID").(uuid.UUID)
 
+		page, err := parsePage(r, defaultPageSize, maxPageSize)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
 		// Query database for friends information
-		incomingList, outgoingList, err := userRepo.GetFriendRequestsByUUID(userID)
+		requestList, err := userRepo.GetFriendRequestsByUUID(userID, page)
 		if err != nil {
 			log.Println(err)
 			http.Error(w, "Unable to query friend requests data", http.StatusInternalServerError)
+			return
 		}
 
-		var resp dtos.GetFriendRequestsResponse
-		resp.Incoming = []dtos.FriendRequest{}
-		resp.Outgoing = []dtos.FriendRequest{}
-		for _, v := range incomingList {
-			request := dtos.FriendRequest{
-				ID:       v.ID,
-				Username: v.Username,
-			}
-
-			if v.DisplayName.Valid {
-				request.DisplayName = v.DisplayName.String
-			}
+		requests, next := paginate(requestList, page, func(v models.FriendRequest) models.FriendRequest {
+			return v
+		}, func(v models.FriendRequest) repositories.Cursor {
+			return repositories.Cursor{CreatedAt: v.RequestedAt, ID: v.ID}
+		})
 
-			resp.Incoming = append(resp.Incoming, request)
+		resp := dtos.GetFriendRequestsResponse{
+			Incoming:   []dtos.FriendRequest{},
+			Outgoing:   []dtos.FriendRequest{},
+			NextCursor: next,
 		}
-
-		for _, v := range outgoingList {
-			request := dtos.FriendRequest{
-				ID:       v.ID,
-				Username: v.Username,
-			}
-
-			if v.DisplayName.Valid {
-				request.DisplayName = v.DisplayName.String
+		for _, v := range requests {
+			if v.Incoming {
+				resp.Incoming = append(resp.Incoming, toFriendRequestDTO(v))
+			} else {
+				resp.Outgoing = append(resp.Outgoing, toFriendRequestDTO(v))
 			}
-
-			resp.Outgoing = append(resp.Outgoing, request)
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -164,7 +191,7 @@ func GetFriendRequestsHandler(userRepo repositories.UserRepository) http.Handler
 }
 
 // PATCH /friends/requests/{friendID}
-func PatchFriendRequestsHandler(userRepo repositories.UserRepository) http.HandlerFunc {
+func PatchFriendRequestsHandler(userRepo repositories.UserRepository, notificationRepo repositories.NotificationRepository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		userID := r.Context().Value("userID").(uuid.UUID)
 
@@ -176,8 +203,7 @@ func PatchFriendRequestsHandler(userRepo repositories.UserRepository) http.Handl
 		}
 
 		var req dtos.PatchFriendRequestsRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "invalid request body", http.StatusBadRequest)
+		if !decodeRequest(w, r, &req) {
 			return
 		}
 
@@ -199,6 +225,12 @@ func PatchFriendRequestsHandler(userRepo repositories.UserRepository) http.Handl
 				return
 			}
 
+			notify(notificationRepo, repositories.NewNotification{
+				RecipientID: friendID,
+				ActorID:     userID,
+				Type:        models.NotificationFriendAccepted,
+			})
+
 		case "rejected":
 			success, err := userRepo.RejectFriendRequest(userID, friendID)
 			if err != nil {
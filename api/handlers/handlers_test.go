@@ -26,12 +26,14 @@ type mockUserRepo struct {
 	createUserFn             func(username string, email string, passwordHash string) (uuid.UUID, error)
 	getUserByUUIDFn          func(id uuid.UUID) (*models.User, error)
 	getPasswordHashByEmailFn func(email string) (uuid.UUID, string, error)
-	getFriendsByUUIDFn       func(id uuid.UUID) ([]models.User, error)
-	getFriendRequestsFn      func(id uuid.UUID) ([]models.User, []models.User, error)
+	getFriendsByUUIDFn       func(id uuid.UUID, page repositories.Page) ([]models.User, error)
+	getFriendRequestsFn      func(id uuid.UUID, page repositories.Page) ([]models.FriendRequest, error)
 	createFriendRequestFn    func(userID uuid.UUID, friendID uuid.UUID) (bool, error)
 	acceptFriendRequestFn    func(userID uuid.UUID, requesterID uuid.UUID) (bool, error)
 	rejectFriendRequestFn    func(userID uuid.UUID, requesterID uuid.UUID) (bool, error)
 	deleteFriendFn           func(userID uuid.UUID, friendID uuid.UUID) (bool, error)
+	areFriendsFn             func(userID uuid.UUID, otherID uuid.UUID) (bool, error)
+	privacy                  map[uuid.UUID]models.LocationPrivacy
 }
 
 func (m *mockUserRepo) CreateUser(username string, email string, passwordHash string) (uuid.UUID, error) {
@@ -55,18 +57,18 @@ func (m *mockUserRepo) GetPasswordHashByEmail(email string) (uuid.UUID, string,
 	return uuid.Nil, "", nil
 }
 
-func (m *mockUserRepo) GetFriendsByUUID(id uuid.UUID) ([]models.User, error) {
+func (m *mockUserRepo) GetFriendsByUUID(id uuid.UUID, page repositories.Page) ([]models.User, error) {
 	if m.getFriendsByUUIDFn != nil {
-		return m.getFriendsByUUIDFn(id)
+		return m.getFriendsByUUIDFn(id, page)
 	}
 	return nil, nil
 }
 
-func (m *mockUserRepo) GetFriendRequestsByUUID(id uuid.UUID) ([]models.User, []models.User, error) {
+func (m *mockUserRepo) GetFriendRequestsByUUID(id uuid.UUID, page repositories.Page) ([]models.FriendRequest, error) {
 	if m.getFriendRequestsFn != nil {
-		return m.getFriendRequestsFn(id)
+		return m.getFriendRequestsFn(id, page)
 	}
-	return nil, nil, nil
+	return nil, nil
 }
 
 func (m *mockUserRepo) CreateFriendRequest(userID uuid.UUID, friendID uuid.UUID) (bool, error) {
@@ -97,41 +99,590 @@ func (m *mockUserRepo) DeleteFriend(userID uuid.UUID, friendID uuid.UUID) (bool,
 	return false, nil
 }
 
+func (m *mockUserRepo) AreFriends(userID uuid.UUID, otherID uuid.UUID) (bool, error) {
+	if m.areFriendsFn != nil {
+		return m.areFriendsFn(userID, otherID)
+	}
+	return false, nil
+}
+
+func (m *mockUserRepo) GetLocationPrivacy(userID uuid.UUID) (*models.LocationPrivacy, error) {
+	privacy, ok := m.privacy[userID]
+	if !ok {
+		return &models.LocationPrivacy{FriendsPrecision: models.Precision100m, PublicPrecision: models.Precision1km}, nil
+	}
+	return &privacy, nil
+}
+
+func (m *mockUserRepo) SaveLocationPrivacy(userID uuid.UUID, privacy models.LocationPrivacy) error {
+	if m.privacy == nil {
+		m.privacy = map[uuid.UUID]models.LocationPrivacy{}
+	}
+	m.privacy[userID] = privacy
+	return nil
+}
+
 type mockPinRepo struct {
-	createPinFn       func(userID uuid.UUID, emotion string, message string, lon float64, lat float64, visibility string) error
-	queryNearbyPinsFn func(userID uuid.UUID, lon float64, lat float64, radiusKm float64) ([]models.Pin, error)
-	queryFriendPinsFn func(userID uuid.UUID) ([]models.Pin, error)
-	queryUserPinsFn   func(userID uuid.UUID) ([]models.Pin, error)
+	createPinFn       func(userID uuid.UUID, emotion string, message string, lon float64, lat float64, visibility string, precision string, expiresAt *time.Time) (*models.Pin, error)
+	getPinFn          func(userID uuid.UUID, pinID uuid.UUID) (*models.Pin, error)
+	getSnapshotFn     func(pinID uuid.UUID) (*repositories.PinSnapshot, error)
+	serveSnapshotFn   func(userID uuid.UUID, pin repositories.PinSnapshot) (*models.Location, error)
+	updatePinFn       func(userID uuid.UUID, pinID uuid.UUID, update repositories.PinUpdate) (*models.Pin, error)
+	deletePinFn       func(userID uuid.UUID, pinID uuid.UUID) error
+	queryNearbyPinsFn func(userID uuid.UUID, query repositories.NearbyPinQuery) ([]models.NearbyPin, error)
+	queryFriendPinsFn func(userID uuid.UUID, page repositories.Page) ([]models.Pin, error)
+	queryUserPinsFn   func(userID uuid.UUID, page repositories.Page) ([]models.Pin, error)
+	deleteExpiredFn   func(retention time.Duration) (int64, error)
+	queryPinsInBBoxFn func(userID uuid.UUID, bbox models.BBox, limit int) ([]models.Pin, error)
+	queryClustersFn   func(userID uuid.UUID, bbox models.BBox, cellDeg float64, minClusterSize int) ([]models.PinCluster, []models.Pin, error)
+	queryPinTileFn    func(userID uuid.UUID, z int, x int, y int, cellMeters float64) ([]byte, error)
+	queryExpiredFn    func(from time.Time, to time.Time) ([]repositories.PinSnapshot, error)
+	addReactionFn     func(userID uuid.UUID, pinID uuid.UUID, emoji string) (bool, error)
+	removeReactionFn  func(userID uuid.UUID, pinID uuid.UUID, emoji string) error
+	engagementFn      func(userID uuid.UUID, pinIDs []uuid.UUID) (map[uuid.UUID]models.PinEngagement, error)
+	attachmentsFn     func(pinIDs []uuid.UUID) (map[uuid.UUID][]models.Attachment, error)
+	saveRefsFn        func(pinID uuid.UUID, refs models.MessageRefs) ([]uuid.UUID, error)
+	searchPinsFn      func(userID uuid.UUID, query repositories.PinSearchQuery) ([]models.SearchedPin, error)
+	createClientPinFn func(userID uuid.UUID, pin repositories.ClientPin) (*models.Pin, bool, error)
 }
 
-func (m *mockPinRepo) CreatePin(userID uuid.UUID, emotion string, message string, lon float64, lat float64, visibility string) error {
+func (m *mockPinRepo) CreatePin(userID uuid.UUID, emotion string, message string, lon float64, lat float64, visibility string, precision string, expiresAt *time.Time) (*models.Pin, error) {
 	if m.createPinFn != nil {
-		return m.createPinFn(userID, emotion, message, lon, lat, visibility)
+		return m.createPinFn(userID, emotion, message, lon, lat, visibility, precision, expiresAt)
+	}
+	return nil, nil
+}
+
+func (m *mockPinRepo) CreateClientPin(userID uuid.UUID, pin repositories.ClientPin) (*models.Pin, bool, error) {
+	if m.createClientPinFn != nil {
+		return m.createClientPinFn(userID, pin)
+	}
+	return nil, false, nil
+}
+
+func (m *mockPinRepo) GetPin(userID uuid.UUID, pinID uuid.UUID) (*models.Pin, error) {
+	if m.getPinFn != nil {
+		return m.getPinFn(userID, pinID)
+	}
+	return nil, nil
+}
+
+func (m *mockPinRepo) GetPinSnapshot(pinID uuid.UUID) (*repositories.PinSnapshot, error) {
+	if m.getSnapshotFn != nil {
+		return m.getSnapshotFn(pinID)
+	}
+	return nil, nil
+}
+
+func (m *mockPinRepo) ServePinSnapshot(userID uuid.UUID, pin repositories.PinSnapshot) (*models.Location, error) {
+	if m.serveSnapshotFn != nil {
+		return m.serveSnapshotFn(userID, pin)
+	}
+	return nil, nil
+}
+
+func (m *mockPinRepo) UpdatePin(userID uuid.UUID, pinID uuid.UUID, update repositories.PinUpdate) (*models.Pin, error) {
+	if m.updatePinFn != nil {
+		return m.updatePinFn(userID, pinID, update)
+	}
+	return nil, nil
+}
+
+func (m *mockPinRepo) DeletePin(userID uuid.UUID, pinID uuid.UUID) error {
+	if m.deletePinFn != nil {
+		return m.deletePinFn(userID, pinID)
 	}
 	return nil
 }
 
-func (m *mockPinRepo) QueryNearbyPins(userID uuid.UUID, lon float64, lat float64, radiusKm float64) ([]models.Pin, error) {
+func (m *mockPinRepo) QueryNearbyPins(userID uuid.UUID, query repositories.NearbyPinQuery) ([]models.NearbyPin, error) {
 	if m.queryNearbyPinsFn != nil {
-		return m.queryNearbyPinsFn(userID, lon, lat, radiusKm)
+		return m.queryNearbyPinsFn(userID, query)
+	}
+	return nil, nil
+}
+
+func (m *mockPinRepo) QueryPinsInBBox(userID uuid.UUID, bbox models.BBox, limit int) ([]models.Pin, error) {
+	if m.queryPinsInBBoxFn != nil {
+		return m.queryPinsInBBoxFn(userID, bbox, limit)
+	}
+	return nil, nil
+}
+
+func (m *mockPinRepo) QueryPinClusters(userID uuid.UUID, bbox models.BBox, cellDeg float64, minClusterSize int) ([]models.PinCluster, []models.Pin, error) {
+	if m.queryClustersFn != nil {
+		return m.queryClustersFn(userID, bbox, cellDeg, minClusterSize)
+	}
+	return nil, nil, nil
+}
+
+func (m *mockPinRepo) QueryPinTile(userID uuid.UUID, z int, x int, y int, cellMeters float64) ([]byte, error) {
+	if m.queryPinTileFn != nil {
+		return m.queryPinTileFn(userID, z, x, y, cellMeters)
 	}
 	return nil, nil
 }
 
-func (m *mockPinRepo) QueryFriendPins(userID uuid.UUID) ([]models.Pin, error) {
+func (m *mockPinRepo) QueryFriendPins(userID uuid.UUID, page repositories.Page) ([]models.Pin, error) {
 	if m.queryFriendPinsFn != nil {
-		return m.queryFriendPinsFn(userID)
+		return m.queryFriendPinsFn(userID, page)
 	}
 	return nil, nil
 }
 
-func (m *mockPinRepo) QueryUserPins(userID uuid.UUID) ([]models.Pin, error) {
+func (m *mockPinRepo) QueryUserPins(userID uuid.UUID, page repositories.Page) ([]models.Pin, error) {
 	if m.queryUserPinsFn != nil {
-		return m.queryUserPinsFn(userID)
+		return m.queryUserPinsFn(userID, page)
+	}
+	return nil, nil
+}
+
+func (m *mockPinRepo) QueryExpiredPins(from time.Time, to time.Time) ([]repositories.PinSnapshot, error) {
+	if m.queryExpiredFn != nil {
+		return m.queryExpiredFn(from, to)
+	}
+	return nil, nil
+}
+
+func (m *mockPinRepo) DeleteExpiredPins(retention time.Duration) (int64, error) {
+	if m.deleteExpiredFn != nil {
+		return m.deleteExpiredFn(retention)
+	}
+	return 0, nil
+}
+
+func (m *mockPinRepo) AddPinReaction(userID uuid.UUID, pinID uuid.UUID, emoji string) (bool, error) {
+	if m.addReactionFn != nil {
+		return m.addReactionFn(userID, pinID, emoji)
+	}
+	return false, nil
+}
+
+func (m *mockPinRepo) RemovePinReaction(userID uuid.UUID, pinID uuid.UUID, emoji string) error {
+	if m.removeReactionFn != nil {
+		return m.removeReactionFn(userID, pinID, emoji)
+	}
+	return nil
+}
+
+func (m *mockPinRepo) QueryPinEngagement(userID uuid.UUID, pinIDs []uuid.UUID) (map[uuid.UUID]models.PinEngagement, error) {
+	if m.engagementFn != nil {
+		return m.engagementFn(userID, pinIDs)
+	}
+	return nil, nil
+}
+
+func (m *mockPinRepo) QueryPinAttachments(pinIDs []uuid.UUID) (map[uuid.UUID][]models.Attachment, error) {
+	if m.attachmentsFn != nil {
+		return m.attachmentsFn(pinIDs)
+	}
+	return nil, nil
+}
+
+func (m *mockPinRepo) SearchPins(userID uuid.UUID, query repositories.PinSearchQuery) ([]models.SearchedPin, error) {
+	if m.searchPinsFn != nil {
+		return m.searchPinsFn(userID, query)
+	}
+	return nil, nil
+}
+
+func (m *mockPinRepo) SavePinMessageRefs(pinID uuid.UUID, refs models.MessageRefs) ([]uuid.UUID, error) {
+	if m.saveRefsFn != nil {
+		return m.saveRefsFn(pinID, refs)
+	}
+	return nil, nil
+}
+
+type mockEmotionRepo struct {
+	getCatalogFn        func() (*models.EmotionCatalog, error)
+	getCatalogVersionFn func() (int64, error)
+	findEmotionFn       func(keyOrEmoji string) (*models.Emotion, error)
+}
+
+func (m *mockEmotionRepo) GetCatalog() (*models.EmotionCatalog, error) {
+	if m.getCatalogFn != nil {
+		return m.getCatalogFn()
+	}
+	return &models.EmotionCatalog{}, nil
+}
+
+func (m *mockEmotionRepo) GetCatalogVersion() (int64, error) {
+	if m.getCatalogVersionFn != nil {
+		return m.getCatalogVersionFn()
+	}
+	return 0, nil
+}
+
+func (m *mockEmotionRepo) FindEmotion(keyOrEmoji string) (*models.Emotion, error) {
+	if m.findEmotionFn != nil {
+		return m.findEmotionFn(keyOrEmoji)
+	}
+	return nil, nil
+}
+
+// stubEmotions returns an emotion repository that knows exactly the given keys.
+func stubEmotions(keys ...string) *mockEmotionRepo {
+	return &mockEmotionRepo{
+		findEmotionFn: func(keyOrEmoji string) (*models.Emotion, error) {
+			for _, k := range keys {
+				if k == keyOrEmoji {
+					return &models.Emotion{Key: k}, nil
+				}
+			}
+			return nil, nil
+		},
+	}
+}
+
+type mockNotificationRepo struct {
+	created       []repositories.NewNotification
+	listFn        func(userID uuid.UUID, page repositories.Page) ([]models.Notification, error)
+	countUnreadFn func(userID uuid.UUID) (int, error)
+	markReadFn    func(userID uuid.UUID, ids []uuid.UUID) (int64, error)
+	markAllReadFn func(userID uuid.UUID) (int64, error)
+}
+
+func (m *mockNotificationRepo) CreateNotification(n repositories.NewNotification) (*models.Notification, error) {
+	m.created = append(m.created, n)
+	return &models.Notification{ID: uuid.New(), Type: n.Type}, nil
+}
+
+func (m *mockNotificationRepo) ListNotifications(userID uuid.UUID, page repositories.Page) ([]models.Notification, error) {
+	if m.listFn != nil {
+		return m.listFn(userID, page)
+	}
+	return nil, nil
+}
+
+func (m *mockNotificationRepo) CountUnreadNotifications(userID uuid.UUID) (int, error) {
+	if m.countUnreadFn != nil {
+		return m.countUnreadFn(userID)
+	}
+	return 0, nil
+}
+
+func (m *mockNotificationRepo) MarkNotificationsRead(userID uuid.UUID, ids []uuid.UUID) (int64, error) {
+	if m.markReadFn != nil {
+		return m.markReadFn(userID, ids)
+	}
+	return 0, nil
+}
+
+func (m *mockNotificationRepo) MarkAllNotificationsRead(userID uuid.UUID) (int64, error) {
+	if m.markAllReadFn != nil {
+		return m.markAllReadFn(userID)
+	}
+	return 0, nil
+}
+
+type mockCommentRepo struct {
+	createFn func(userID uuid.UUID, pinID uuid.UUID, parentID *uuid.UUID, body string) (*models.Comment, error)
+	getFn    func(userID uuid.UUID, commentID uuid.UUID) (*models.Comment, error)
+	listFn   func(userID uuid.UUID, pinID uuid.UUID, parentID *uuid.UUID, page repositories.Page) ([]models.Comment, error)
+	deleteFn func(userID uuid.UUID, commentID uuid.UUID) error
+}
+
+func (m *mockCommentRepo) CreateComment(userID uuid.UUID, pinID uuid.UUID, parentID *uuid.UUID, body string) (*models.Comment, error) {
+	if m.createFn != nil {
+		return m.createFn(userID, pinID, parentID, body)
+	}
+	return nil, nil
+}
+
+func (m *mockCommentRepo) GetComment(userID uuid.UUID, commentID uuid.UUID) (*models.Comment, error) {
+	if m.getFn != nil {
+		return m.getFn(userID, commentID)
+	}
+	return nil, nil
+}
+
+func (m *mockCommentRepo) ListComments(userID uuid.UUID, pinID uuid.UUID, parentID *uuid.UUID, page repositories.Page) ([]models.Comment, error) {
+	if m.listFn != nil {
+		return m.listFn(userID, pinID, parentID, page)
+	}
+	return nil, nil
+}
+
+func (m *mockCommentRepo) DeleteComment(userID uuid.UUID, commentID uuid.UUID) error {
+	if m.deleteFn != nil {
+		return m.deleteFn(userID, commentID)
+	}
+	return nil
+}
+
+// mockAttachmentRepo keeps attachments in memory, keyed by pin, for pins
+// owned by owner.
+type mockAttachmentRepo struct {
+	owner    uuid.UUID
+	byPin    map[uuid.UUID][]models.Attachment
+	detached []uuid.UUID
+}
+
+func newMockAttachmentRepo(owner uuid.UUID) *mockAttachmentRepo {
+	return &mockAttachmentRepo{owner: owner, byPin: map[uuid.UUID][]models.Attachment{}}
+}
+
+// query can stand in for mockPinRepo.attachmentsFn.
+func (m *mockAttachmentRepo) query(pinIDs []uuid.UUID) (map[uuid.UUID][]models.Attachment, error) {
+	out := map[uuid.UUID][]models.Attachment{}
+	for _, id := range pinIDs {
+		if len(m.byPin[id]) > 0 {
+			out[id] = m.byPin[id]
+		}
+	}
+	return out, nil
+}
+
+func (m *mockAttachmentRepo) AddAttachments(userID uuid.UUID, pinID uuid.UUID, attachments []models.Attachment, max int) ([]models.Attachment, error) {
+	if userID != m.owner {
+		return nil, repositories.ErrNotPinOwner
+	}
+	if len(m.byPin[pinID])+len(attachments) > max {
+		return nil, repositories.ErrTooManyAttachments
+	}
+	added := make([]models.Attachment, 0, len(attachments))
+	for _, a := range attachments {
+		a.PinID = pinID
+		a.Position = len(m.byPin[pinID])
+		m.byPin[pinID] = append(m.byPin[pinID], a)
+		added = append(added, a)
+	}
+	return added, nil
+}
+
+func (m *mockAttachmentRepo) GetAttachment(pinID uuid.UUID, attachmentID uuid.UUID) (*models.Attachment, error) {
+	for _, a := range m.byPin[pinID] {
+		if a.ID == attachmentID {
+			return &a, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *mockAttachmentRepo) DetachAttachment(userID uuid.UUID, pinID uuid.UUID, attachmentID uuid.UUID) error {
+	for i, a := range m.byPin[pinID] {
+		if a.ID != attachmentID {
+			continue
+		}
+		if userID != m.owner {
+			return repositories.ErrNotPinOwner
+		}
+		m.byPin[pinID] = append(m.byPin[pinID][:i], m.byPin[pinID][i+1:]...)
+		m.detached = append(m.detached, a.ID)
+		return nil
+	}
+	return repositories.ErrAttachmentNotFound
+}
+
+func (m *mockAttachmentRepo) ListDetachedAttachments(limit int) ([]uuid.UUID, error) {
+	return m.detached, nil
+}
+
+func (m *mockAttachmentRepo) PurgeAttachments(ids []uuid.UUID) error {
+	m.detached = nil
+	return nil
+}
+
+type mockTagRepo struct {
+	tagPinsFn  func(userID uuid.UUID, tag string, page repositories.Page) ([]models.Pin, error)
+	trendingFn func(userID uuid.UUID, bbox models.BBox, since time.Time, limit int) ([]models.TrendingTag, error)
+}
+
+func (m *mockTagRepo) QueryTagPins(userID uuid.UUID, tag string, page repositories.Page) ([]models.Pin, error) {
+	if m.tagPinsFn != nil {
+		return m.tagPinsFn(userID, tag, page)
+	}
+	return nil, nil
+}
+
+func (m *mockTagRepo) QueryTrendingTags(userID uuid.UUID, bbox models.BBox, since time.Time, limit int) ([]models.TrendingTag, error) {
+	if m.trendingFn != nil {
+		return m.trendingFn(userID, bbox, since, limit)
+	}
+	return nil, nil
+}
+
+// mockZoneRepo keeps zones in memory. QueryZonesAt returns zonesAt as is,
+// since the mock has no geometry.
+type mockZoneRepo struct {
+	zones   map[uuid.UUID]models.Zone
+	owners  map[uuid.UUID]uuid.UUID
+	zonesAt []models.Zone
+}
+
+func newMockZoneRepo() *mockZoneRepo {
+	return &mockZoneRepo{zones: map[uuid.UUID]models.Zone{}, owners: map[uuid.UUID]uuid.UUID{}}
+}
+
+func (m *mockZoneRepo) ListZones(userID uuid.UUID) ([]models.Zone, error) {
+	var zones []models.Zone
+	for id, zone := range m.zones {
+		if m.owners[id] == userID {
+			zones = append(zones, zone)
+		}
+	}
+	return zones, nil
+}
+
+func (m *mockZoneRepo) CreateZone(userID uuid.UUID, zone models.Zone) (*models.Zone, error) {
+	zones, _ := m.ListZones(userID)
+	if len(zones) >= models.MaxZonesPerUser {
+		return nil, repositories.ErrTooManyZones
+	}
+	zone.ID = uuid.New()
+	zone.CreatedAt = time.Now()
+	zone.UpdatedAt = zone.CreatedAt
+	m.zones[zone.ID] = zone
+	m.owners[zone.ID] = userID
+	return &zone, nil
+}
+
+func (m *mockZoneRepo) UpdateZone(userID uuid.UUID, zoneID uuid.UUID, zone models.Zone) (*models.Zone, error) {
+	existing, ok := m.zones[zoneID]
+	if !ok || m.owners[zoneID] != userID {
+		return nil, repositories.ErrZoneNotFound
+	}
+	zone.ID = zoneID
+	zone.CreatedAt = existing.CreatedAt
+	zone.UpdatedAt = time.Now()
+	m.zones[zoneID] = zone
+	return &zone, nil
+}
+
+func (m *mockZoneRepo) DeleteZone(userID uuid.UUID, zoneID uuid.UUID) error {
+	if _, ok := m.zones[zoneID]; !ok || m.owners[zoneID] != userID {
+		return repositories.ErrZoneNotFound
+	}
+	delete(m.zones, zoneID)
+	delete(m.owners, zoneID)
+	return nil
+}
+
+func (m *mockZoneRepo) QueryZonesAt(userID uuid.UUID, lon float64, lat float64) ([]models.Zone, error) {
+	return m.zonesAt, nil
+}
+
+type mockSyncRepo struct {
+	getChangesFn func(userID uuid.UUID, token *repositories.SyncToken, pinLimit int) (*models.SyncChanges, error)
+}
+
+func (m *mockSyncRepo) GetChanges(userID uuid.UUID, token *repositories.SyncToken, pinLimit int) (*models.SyncChanges, error) {
+	if m.getChangesFn != nil {
+		return m.getChangesFn(userID, token, pinLimit)
+	}
+	return &models.SyncChanges{AsOf: time.Now()}, nil
+}
+
+func (m *mockSyncRepo) PruneDeletions() (int64, error) {
+	return 0, nil
+}
+
+// mockIdempotencyRepo keeps keys in memory and never expires them.
+type mockIdempotencyRepo struct {
+	keys map[string]models.IdempotentRequest
+}
+
+func newMockIdempotencyRepo() *mockIdempotencyRepo {
+	return &mockIdempotencyRepo{keys: map[string]models.IdempotentRequest{}}
+}
+
+func (m *mockIdempotencyRepo) ClaimIdempotencyKey(scope string, key string, fingerprint string, lockTTL time.Duration) (*models.IdempotentRequest, error) {
+	if first, ok := m.keys[scope+"/"+key]; ok {
+		return &first, nil
+	}
+	m.keys[scope+"/"+key] = models.IdempotentRequest{Fingerprint: fingerprint}
+	return nil, nil
+}
+
+func (m *mockIdempotencyRepo) SaveIdempotentResponse(scope string, key string, req models.IdempotentRequest, ttl time.Duration) error {
+	m.keys[scope+"/"+key] = req
+	return nil
+}
+
+func (m *mockIdempotencyRepo) ReleaseIdempotencyKey(scope string, key string) error {
+	delete(m.keys, scope+"/"+key)
+	return nil
+}
+
+func (m *mockIdempotencyRepo) PruneIdempotencyKeys() (int64, error) {
+	return 0, nil
+}
+
+// mockPushRepo keeps devices and preferences in memory; the delivery worker
+// methods are exercised in the jobs package instead.
+type mockPushRepo struct {
+	devices map[string]uuid.UUID
+	prefs   map[uuid.UUID]models.PushPreferences
+}
+
+func newMockPushRepo() *mockPushRepo {
+	return &mockPushRepo{devices: map[string]uuid.UUID{}, prefs: map[uuid.UUID]models.PushPreferences{}}
+}
+
+func (m *mockPushRepo) RegisterDevice(userID uuid.UUID, platform string, token string) error {
+	m.devices[token] = userID
+	return nil
+}
+
+func (m *mockPushRepo) DeleteDevice(userID uuid.UUID, token string) (bool, error) {
+	if owner, ok := m.devices[token]; !ok || owner != userID {
+		return false, nil
+	}
+	delete(m.devices, token)
+	return true, nil
+}
+
+func (m *mockPushRepo) DeleteDeviceTokens(tokens []string) (int64, error) {
+	return 0, nil
+}
+
+func (m *mockPushRepo) GetPushPreferences(userID uuid.UUID) (models.PushPreferences, error) {
+	if prefs, ok := m.prefs[userID]; ok {
+		return prefs, nil
+	}
+	return models.DefaultPushPreferences(), nil
+}
+
+func (m *mockPushRepo) SavePushPreferences(userID uuid.UUID, prefs models.PushPreferences) error {
+	m.prefs[userID] = prefs
+	return nil
+}
+
+func (m *mockPushRepo) ClaimDuePushes(limit int, lease time.Duration) ([]models.PendingPush, error) {
+	return nil, nil
+}
+
+func (m *mockPushRepo) FinishPushes(ids []uuid.UUID, status string) error {
+	return nil
+}
+
+func (m *mockPushRepo) ReschedulePushes(ids []uuid.UUID, at time.Time, countAttempt bool) error {
+	return nil
+}
+
+type mockInsightRepo struct {
+	queryMoodGridFn   func(bbox models.BBox, since time.Time, cellDeg float64, minUsers int) ([]models.MoodCell, error)
+	queryMoodTrendsFn func(lon float64, lat float64, radiusKm float64, bucket string, since time.Time, minAuthors int) (*models.MoodTrend, error)
+}
+
+func (m *mockInsightRepo) QueryMoodGrid(bbox models.BBox, since time.Time, cellDeg float64, minUsers int) ([]models.MoodCell, error) {
+	if m.queryMoodGridFn != nil {
+		return m.queryMoodGridFn(bbox, since, cellDeg, minUsers)
 	}
 	return nil, nil
 }
 
+func (m *mockInsightRepo) QueryMoodTrends(lon float64, lat float64, radiusKm float64, bucket string, since time.Time, minAuthors int) (*models.MoodTrend, error) {
+	if m.queryMoodTrendsFn != nil {
+		return m.queryMoodTrendsFn(lon, lat, radiusKm, bucket, since, minAuthors)
+	}
+	return &models.MoodTrend{}, nil
+}
+
+func (m *mockInsightRepo) RefreshMoodRollups(lookback time.Duration) (int64, error) {
+	return 0, nil
+}
+
 func TestPostRegisterHandler_Success(t *testing.T) {
 	t.Helper()
 	var capturedHash string
@@ -187,6 +738,33 @@ func TestPostRegisterHandler_InvalidJSON(t *testing.T) {
 	}
 }
 
+func TestPostRegisterHandler_ValidationErrors(t *testing.T) {
+	repo := &mockUserRepo{
+		createUserFn: func(username string, email string, passwordHash string) (uuid.UUID, error) {
+			t.Fatalf("CreateUser must not be called for an invalid request")
+			return uuid.Nil, nil
+		},
+	}
+
+	handler := PostRegisterHandler(repo)
+	req := httptest.NewRequest(http.MethodPost, "/auth/register", strings.NewReader(`{"username":"a b","email":"not-an-email","password":"short"}`))
+	rec := httptest.NewRecorder()
+
+	handler(rec, req)
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status %d got %d", http.StatusUnprocessableEntity, rec.Code)
+	}
+
+	var resp dtos.ValidationErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.Fields) != 3 {
+		t.Fatalf("expected username, email and password errors, got %+v", resp.Fields)
+	}
+}
+
 func TestPostLoginHandler_Success(t *testing.T) {
 	userID := uuid.New()
 	hash, err := bcrypt.GenerateFromPassword([]byte("supersecret"), bcrypt.DefaultCost)
@@ -289,8 +867,7 @@ func TestGetMeHandler_Success(t *testing.T) {
 	}
 
 	handler := GetMeHandler(repo)
-	req := httptest.NewRequest(http.MethodGet, "/me", nil)
-	req = req.WithContext(context.WithValue(req.Context(), "userID", userID))
+	req := authedRequest(http.MethodGet, "/me", "", userID)
 	rec := httptest.NewRecorder()
 
 	handler(rec, req)
@@ -317,8 +894,7 @@ func TestGetMeHandler_Error(t *testing.T) {
 	}
 
 	handler := GetMeHandler(repo)
-	req := httptest.NewRequest(http.MethodGet, "/me", nil)
-	req = req.WithContext(context.WithValue(req.Context(), "userID", uuid.New()))
+	req := authedRequest(http.MethodGet, "/me", "", uuid.New())
 	rec := httptest.NewRecorder()
 
 	handler(rec, req)
@@ -333,7 +909,7 @@ func TestGetFriendsHandler_Success(t *testing.T) {
 	friendID := uuid.New()
 
 	repo := &mockUserRepo{
-		getFriendsByUUIDFn: func(id uuid.UUID) ([]models.User, error) {
+		getFriendsByUUIDFn: func(id uuid.UUID, page repositories.Page) ([]models.User, error) {
 			if id != userID {
 				t.Fatalf("unexpected user ID %s", id)
 			}
@@ -349,8 +925,7 @@ func TestGetFriendsHandler_Success(t *testing.T) {
 	}
 
 	handler := GetFriendsHandler(repo)
-	req := httptest.NewRequest(http.MethodGet, "/friends", nil)
-	req = req.WithContext(context.WithValue(req.Context(), "userID", userID))
+	req := authedRequest(http.MethodGet, "/friends", "", userID)
 	rec := httptest.NewRecorder()
 
 	handler(rec, req)
@@ -383,9 +958,7 @@ func TestDeleteFriendsHandler_Success(t *testing.T) {
 	}
 
 	handler := DeleteFriendsHandler(repo)
-	req := httptest.NewRequest(http.MethodDelete, "/friends/"+friendID.String(), nil)
-	req = req.WithContext(context.WithValue(req.Context(), "userID", userID))
-	req = addFriendIDParam(req, friendID.String())
+	req := authedRequest(http.MethodDelete, "/friends/"+friendID.String(), "", userID, "friendID", friendID.String())
 	rec := httptest.NewRecorder()
 
 	handler(rec, req)
@@ -403,9 +976,7 @@ func TestDeleteFriendsHandler_NotFound(t *testing.T) {
 	}
 
 	handler := DeleteFriendsHandler(repo)
-	req := httptest.NewRequest(http.MethodDelete, "/friends/"+uuid.New().String(), nil)
-	req = req.WithContext(context.WithValue(req.Context(), "userID", uuid.New()))
-	req = addFriendIDParam(req, uuid.New().String())
+	req := authedRequest(http.MethodDelete, "/friends/"+uuid.New().String(), "", uuid.New(), "friendID", uuid.New().String())
 	rec := httptest.NewRecorder()
 
 	handler(rec, req)
@@ -428,10 +999,8 @@ func TestPostFriendRequestsHandler_Success(t *testing.T) {
 		},
 	}
 
-	handler := PostFriendRequestsHandler(repo)
-	req := httptest.NewRequest(http.MethodPost, "/friends/requests/"+targetID.String(), nil)
-	req = req.WithContext(context.WithValue(req.Context(), "userID", userID))
-	req = addFriendIDParam(req, targetID.String())
+	handler := PostFriendRequestsHandler(repo, &mockNotificationRepo{})
+	req := authedRequest(http.MethodPost, "/friends/requests/"+targetID.String(), "", userID, "friendID", targetID.String())
 	rec := httptest.NewRecorder()
 
 	handler(rec, req)
@@ -448,11 +1017,9 @@ func TestPostFriendRequestsHandler_TargetMissing(t *testing.T) {
 		},
 	}
 
-	handler := PostFriendRequestsHandler(repo)
+	handler := PostFriendRequestsHandler(repo, &mockNotificationRepo{})
 	target := uuid.New()
-	req := httptest.NewRequest(http.MethodPost, "/friends/requests/"+target.String(), nil)
-	req = req.WithContext(context.WithValue(req.Context(), "userID", uuid.New()))
-	req = addFriendIDParam(req, target.String())
+	req := authedRequest(http.MethodPost, "/friends/requests/"+target.String(), "", uuid.New(), "friendID", target.String())
 	rec := httptest.NewRecorder()
 
 	handler(rec, req)
@@ -469,11 +1036,9 @@ func TestPostFriendRequestsHandler_Duplicate(t *testing.T) {
 		},
 	}
 
-	handler := PostFriendRequestsHandler(repo)
+	handler := PostFriendRequestsHandler(repo, &mockNotificationRepo{})
 	target := uuid.New()
-	req := httptest.NewRequest(http.MethodPost, "/friends/requests/"+target.String(), nil)
-	req = req.WithContext(context.WithValue(req.Context(), "userID", uuid.New()))
-	req = addFriendIDParam(req, target.String())
+	req := authedRequest(http.MethodPost, "/friends/requests/"+target.String(), "", uuid.New(), "friendID", target.String())
 	rec := httptest.NewRecorder()
 
 	handler(rec, req)
@@ -487,10 +1052,8 @@ func TestPostFriendRequestsHandler_SelfRequest(t *testing.T) {
 	userID := uuid.New()
 	repo := &mockUserRepo{}
 
-	handler := PostFriendRequestsHandler(repo)
-	req := httptest.NewRequest(http.MethodPost, "/friends/requests/"+userID.String(), nil)
-	req = req.WithContext(context.WithValue(req.Context(), "userID", userID))
-	req = addFriendIDParam(req, userID.String())
+	handler := PostFriendRequestsHandler(repo, &mockNotificationRepo{})
+	req := authedRequest(http.MethodPost, "/friends/requests/"+userID.String(), "", userID, "friendID", userID.String())
 	rec := httptest.NewRecorder()
 
 	handler(rec, req)
@@ -502,10 +1065,8 @@ func TestPostFriendRequestsHandler_SelfRequest(t *testing.T) {
 
 func TestPostFriendRequestsHandler_InvalidUUID(t *testing.T) {
 	repo := &mockUserRepo{}
-	handler := PostFriendRequestsHandler(repo)
-	req := httptest.NewRequest(http.MethodPost, "/friends/requests/not-a-uuid", nil)
-	req = req.WithContext(context.WithValue(req.Context(), "userID", uuid.New()))
-	req = addFriendIDParam(req, "not-a-uuid")
+	handler := PostFriendRequestsHandler(repo, &mockNotificationRepo{})
+	req := authedRequest(http.MethodPost, "/friends/requests/not-a-uuid", "", uuid.New(), "friendID", "not-a-uuid")
 	rec := httptest.NewRecorder()
 
 	handler(rec, req)
@@ -521,23 +1082,24 @@ func TestGetFriendRequestsHandler_Success(t *testing.T) {
 	outgoingID := uuid.New()
 
 	repo := &mockUserRepo{
-		getFriendRequestsFn: func(id uuid.UUID) ([]models.User, []models.User, error) {
+		getFriendRequestsFn: func(id uuid.UUID, page repositories.Page) ([]models.FriendRequest, error) {
 			if id != userID {
 				t.Fatalf("unexpected user ID %s", id)
 			}
-			return []models.User{
-					{ID: incomingID, Username: "bob", DisplayName: sql.NullString{String: "Bob", Valid: true}},
+			return []models.FriendRequest{
+				{
+					User:     models.User{ID: incomingID, Username: "bob", DisplayName: sql.NullString{String: "Bob", Valid: true}},
+					Incoming: true,
 				},
-				[]models.User{
-					{ID: outgoingID, Username: "carol", DisplayName: sql.NullString{String: "Carol", Valid: true}},
+				{
+					User: models.User{ID: outgoingID, Username: "carol", DisplayName: sql.NullString{String: "Carol", Valid: true}},
 				},
-				nil
+			}, nil
 		},
 	}
 
 	handler := GetFriendRequestsHandler(repo)
-	req := httptest.NewRequest(http.MethodGet, "/friends/requests", nil)
-	req = req.WithContext(context.WithValue(req.Context(), "userID", userID))
+	req := authedRequest(http.MethodGet, "/friends/requests", "", userID)
 	rec := httptest.NewRecorder()
 
 	handler(rec, req)
@@ -573,11 +1135,9 @@ func TestPatchFriendRequestsHandler_Accept(t *testing.T) {
 		},
 	}
 
-	handler := PatchFriendRequestsHandler(repo)
-	req := httptest.NewRequest(http.MethodPatch, "/friends/requests/"+requesterID.String(), strings.NewReader(`{"status":"accepted"}`))
+	handler := PatchFriendRequestsHandler(repo, &mockNotificationRepo{})
+	req := authedRequest(http.MethodPatch, "/friends/requests/"+requesterID.String(), `{"status":"accepted"}`, userID, "friendID", requesterID.String())
 	req.Header.Set("Content-Type", "application/json")
-	req = req.WithContext(context.WithValue(req.Context(), "userID", userID))
-	req = addFriendIDParam(req, requesterID.String())
 	rec := httptest.NewRecorder()
 
 	handler(rec, req)
@@ -594,11 +1154,9 @@ func TestPatchFriendRequestsHandler_AcceptNoPending(t *testing.T) {
 		},
 	}
 
-	handler := PatchFriendRequestsHandler(repo)
-	req := httptest.NewRequest(http.MethodPatch, "/friends/requests/"+uuid.New().String(), strings.NewReader(`{"status":"accepted"}`))
+	handler := PatchFriendRequestsHandler(repo, &mockNotificationRepo{})
+	req := authedRequest(http.MethodPatch, "/friends/requests/"+uuid.New().String(), `{"status":"accepted"}`, uuid.New(), "friendID", uuid.New().String())
 	req.Header.Set("Content-Type", "application/json")
-	req = req.WithContext(context.WithValue(req.Context(), "userID", uuid.New()))
-	req = addFriendIDParam(req, uuid.New().String())
 	rec := httptest.NewRecorder()
 
 	handler(rec, req)
@@ -621,11 +1179,9 @@ func TestPatchFriendRequestsHandler_Reject(t *testing.T) {
 		},
 	}
 
-	handler := PatchFriendRequestsHandler(repo)
-	req := httptest.NewRequest(http.MethodPatch, "/friends/requests/"+requesterID.String(), strings.NewReader(`{"status":"rejected"}`))
+	handler := PatchFriendRequestsHandler(repo, &mockNotificationRepo{})
+	req := authedRequest(http.MethodPatch, "/friends/requests/"+requesterID.String(), `{"status":"rejected"}`, userID, "friendID", requesterID.String())
 	req.Header.Set("Content-Type", "application/json")
-	req = req.WithContext(context.WithValue(req.Context(), "userID", userID))
-	req = addFriendIDParam(req, requesterID.String())
 	rec := httptest.NewRecorder()
 
 	handler(rec, req)
@@ -635,115 +1191,254 @@ func TestPatchFriendRequestsHandler_Reject(t *testing.T) {
 	}
 }
 
-func TestPatchFriendRequestsHandler_RejectNoPending(t *testing.T) {
-	repo := &mockUserRepo{
-		rejectFriendRequestFn: func(u uuid.UUID, r uuid.UUID) (bool, error) {
-			return false, nil
+func TestPatchFriendRequestsHandler_RejectNoPending(t *testing.T) {
+	repo := &mockUserRepo{
+		rejectFriendRequestFn: func(u uuid.UUID, r uuid.UUID) (bool, error) {
+			return false, nil
+		},
+	}
+
+	handler := PatchFriendRequestsHandler(repo, &mockNotificationRepo{})
+	req := authedRequest(http.MethodPatch, "/friends/requests/"+uuid.New().String(), `{"status":"rejected"}`, uuid.New(), "friendID", uuid.New().String())
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	handler(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestPatchFriendRequestsHandler_InvalidStatus(t *testing.T) {
+	repo := &mockUserRepo{}
+
+	handler := PatchFriendRequestsHandler(repo, &mockNotificationRepo{})
+	req := authedRequest(http.MethodPatch, "/friends/requests/"+uuid.New().String(), `{"status":"unknown"}`, uuid.New(), "friendID", uuid.New().String())
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	handler(rec, req)
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status %d got %d", http.StatusUnprocessableEntity, rec.Code)
+	}
+}
+
+func TestPatchFriendRequestsHandler_InvalidBody(t *testing.T) {
+	repo := &mockUserRepo{}
+
+	handler := PatchFriendRequestsHandler(repo, &mockNotificationRepo{})
+	req := authedRequest(http.MethodPatch, "/friends/requests/"+uuid.New().String(), `invalid`, uuid.New(), "friendID", uuid.New().String())
+	rec := httptest.NewRecorder()
+
+	handler(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestPostPinsHandler_Success(t *testing.T) {
+	userID := uuid.New()
+	pinID := uuid.New()
+	var captured struct {
+		userID     uuid.UUID
+		emotion    string
+		message    string
+		longitude  float64
+		latitude   float64
+		visibility string
+	}
+
+	pinRepo := &mockPinRepo{
+		createPinFn: func(u uuid.UUID, emotion string, message string, lon float64, lat float64, visibility string, precision string, expiresAt *time.Time) (*models.Pin, error) {
+			captured = struct {
+				userID     uuid.UUID
+				emotion    string
+				message    string
+				longitude  float64
+				latitude   float64
+				visibility string
+			}{u, emotion, message, lon, lat, visibility}
+			return &models.Pin{
+				ID:         pinID,
+				UserID:     u,
+				Emotion:    emotion,
+				Message:    sql.NullString{String: message, Valid: true},
+				Location:   models.Location{Latitude: lat, Longitude: lon},
+				Visibility: visibility,
+				CreatedAt:  time.Now().UTC(),
+			}, nil
+		},
+	}
+
+	body := `{"emotion":"happy","message":"Coffee is great","longitude":-123.12,"latitude":49.28,"visibility":"public"}`
+	req := authedRequest(http.MethodPost, "/pins", body, userID)
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+
+	PostPinsHandler(pinRepo, stubEmotions("happy"), &mockNotificationRepo{}, &mockUserRepo{}, newMockZoneRepo())(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status %d got %d", http.StatusCreated, rec.Code)
+	}
+
+	if captured.userID != userID || captured.emotion != "happy" || captured.message != "Coffee is great" {
+		t.Fatalf("unexpected captured values: %+v", captured)
+	}
+	if captured.longitude != -123.12 || captured.latitude != 49.28 || captured.visibility != "public" {
+		t.Fatalf("unexpected captured location/visibility: %+v", captured)
+	}
+
+	var resp dtos.Pin
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.ID != pinID || resp.UserID != userID || resp.Visibility != "public" {
+		t.Fatalf("unexpected created pin payload: %+v", resp)
+	}
+}
+
+func TestPostPinsHandler_InvalidBody(t *testing.T) {
+	pinRepo := &mockPinRepo{}
+	req := authedRequest(http.MethodPost, "/pins", `bad`, uuid.New())
+	rec := httptest.NewRecorder()
+
+	PostPinsHandler(pinRepo, stubEmotions("happy"), &mockNotificationRepo{}, &mockUserRepo{}, newMockZoneRepo())(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestPostPinsHandler_TTLCappedByVisibility(t *testing.T) {
+	var captured *time.Time
+	pinRepo := &mockPinRepo{
+		createPinFn: func(u uuid.UUID, emotion string, message string, lon float64, lat float64, visibility string, precision string, expiresAt *time.Time) (*models.Pin, error) {
+			captured = expiresAt
+			return &models.Pin{ID: uuid.New(), UserID: u, Visibility: visibility}, nil
+		},
+	}
+
+	// 60 days is beyond the public cap, so the pin must expire at the cap instead.
+	body := `{"emotion":"happy","longitude":1,"latitude":2,"visibility":"public","ttl_seconds":5184000}`
+	req := authedRequest(http.MethodPost, "/pins", body, uuid.New())
+	rec := httptest.NewRecorder()
+
+	before := time.Now()
+	PostPinsHandler(pinRepo, stubEmotions("happy"), &mockNotificationRepo{}, &mockUserRepo{}, newMockZoneRepo())(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status %d got %d", http.StatusCreated, rec.Code)
+	}
+	if captured == nil {
+		t.Fatalf("expected an expiry to be set")
+	}
+	limit := before.Add(maxPinLifetime["public"])
+	if captured.Before(limit) || captured.After(limit.Add(time.Minute)) {
+		t.Fatalf("expected expiry near %v got %v", limit, captured)
+	}
+}
+
+func TestPostPinsHandler_PrivateWithoutTTLNeverExpires(t *testing.T) {
+	var captured *time.Time
+	pinRepo := &mockPinRepo{
+		createPinFn: func(u uuid.UUID, emotion string, message string, lon float64, lat float64, visibility string, precision string, expiresAt *time.Time) (*models.Pin, error) {
+			captured = expiresAt
+			return &models.Pin{ID: uuid.New(), UserID: u, Visibility: visibility}, nil
 		},
 	}
 
-	handler := PatchFriendRequestsHandler(repo)
-	req := httptest.NewRequest(http.MethodPatch, "/friends/requests/"+uuid.New().String(), strings.NewReader(`{"status":"rejected"}`))
-	req.Header.Set("Content-Type", "application/json")
-	req = req.WithContext(context.WithValue(req.Context(), "userID", uuid.New()))
-	req = addFriendIDParam(req, uuid.New().String())
+	body := `{"emotion":"happy","longitude":1,"latitude":2,"visibility":"private"}`
+	req := authedRequest(http.MethodPost, "/pins", body, uuid.New())
 	rec := httptest.NewRecorder()
 
-	handler(rec, req)
+	PostPinsHandler(pinRepo, stubEmotions("happy"), &mockNotificationRepo{}, &mockUserRepo{}, newMockZoneRepo())(rec, req)
 
-	if rec.Code != http.StatusBadRequest {
-		t.Fatalf("expected status %d got %d", http.StatusBadRequest, rec.Code)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status %d got %d", http.StatusCreated, rec.Code)
+	}
+	if captured != nil {
+		t.Fatalf("expected no expiry got %v", captured)
 	}
 }
 
-func TestPatchFriendRequestsHandler_InvalidStatus(t *testing.T) {
-	repo := &mockUserRepo{}
-
-	handler := PatchFriendRequestsHandler(repo)
-	req := httptest.NewRequest(http.MethodPatch, "/friends/requests/"+uuid.New().String(), strings.NewReader(`{"status":"unknown"}`))
-	req.Header.Set("Content-Type", "application/json")
-	req = req.WithContext(context.WithValue(req.Context(), "userID", uuid.New()))
-	req = addFriendIDParam(req, uuid.New().String())
+func TestPostPinsHandler_ConflictingExpiry(t *testing.T) {
+	body := `{"emotion":"happy","longitude":1,"latitude":2,"visibility":"public","ttl_seconds":60,"expires_at":"2099-01-01T00:00:00Z"}`
+	req := authedRequest(http.MethodPost, "/pins", body, uuid.New())
 	rec := httptest.NewRecorder()
 
-	handler(rec, req)
+	PostPinsHandler(&mockPinRepo{}, stubEmotions("happy"), &mockNotificationRepo{}, &mockUserRepo{}, newMockZoneRepo())(rec, req)
 
-	if rec.Code != http.StatusBadRequest {
-		t.Fatalf("expected status %d got %d", http.StatusBadRequest, rec.Code)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status %d got %d", http.StatusUnprocessableEntity, rec.Code)
 	}
 }
 
-func TestPatchFriendRequestsHandler_InvalidBody(t *testing.T) {
-	repo := &mockUserRepo{}
+func TestPostPinsHandler_TTLTooLong(t *testing.T) {
+	pinRepo := &mockPinRepo{
+		createPinFn: func(u uuid.UUID, emotion string, message string, lon float64, lat float64, visibility string, precision string, expiresAt *time.Time) (*models.Pin, error) {
+			t.Fatalf("CreatePin must not be called for a TTL that overflows")
+			return nil, nil
+		},
+	}
 
-	handler := PatchFriendRequestsHandler(repo)
-	req := httptest.NewRequest(http.MethodPatch, "/friends/requests/"+uuid.New().String(), strings.NewReader(`invalid`))
-	req = req.WithContext(context.WithValue(req.Context(), "userID", uuid.New()))
-	req = addFriendIDParam(req, uuid.New().String())
+	// Large enough to overflow a time.Duration
+	body := `{"emotion":"happy","longitude":1,"latitude":2,"visibility":"private","ttl_seconds":10000000000}`
+	req := authedRequest(http.MethodPost, "/pins", body, uuid.New())
 	rec := httptest.NewRecorder()
 
-	handler(rec, req)
+	PostPinsHandler(pinRepo, stubEmotions("happy"), &mockNotificationRepo{}, &mockUserRepo{}, newMockZoneRepo())(rec, req)
 
-	if rec.Code != http.StatusBadRequest {
-		t.Fatalf("expected status %d got %d", http.StatusBadRequest, rec.Code)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status %d got %d", http.StatusUnprocessableEntity, rec.Code)
 	}
 }
 
-func TestPostPinsHandler_Success(t *testing.T) {
-	userID := uuid.New()
-	var captured struct {
-		userID     uuid.UUID
-		emotion    string
-		message    string
-		longitude  float64
-		latitude   float64
-		visibility string
-	}
-
+func TestPostPinsHandler_ValidationErrors(t *testing.T) {
 	pinRepo := &mockPinRepo{
-		createPinFn: func(u uuid.UUID, emotion string, message string, lon float64, lat float64, visibility string) error {
-			captured = struct {
-				userID     uuid.UUID
-				emotion    string
-				message    string
-				longitude  float64
-				latitude   float64
-				visibility string
-			}{u, emotion, message, lon, lat, visibility}
-			return nil
+		createPinFn: func(u uuid.UUID, emotion string, message string, lon float64, lat float64, visibility string, precision string, expiresAt *time.Time) (*models.Pin, error) {
+			t.Fatalf("CreatePin must not be called for an invalid request")
+			return nil, nil
 		},
 	}
 
-	body := `{"emotion":"happy","message":"Coffee is great","longitude":-123.12,"latitude":49.28,"visibility":"public"}`
-	req := httptest.NewRequest(http.MethodPost, "/pins", strings.NewReader(body))
-	req.Header.Set("Content-Type", "application/json")
-	req = req.WithContext(context.WithValue(req.Context(), "userID", userID))
+	body := fmt.Sprintf(`{"emotion":"","message":%q,"longitude":190,"latitude":-91,"visibility":"everyone","location_precision":"street"}`, strings.Repeat("a", 501))
+	req := authedRequest(http.MethodPost, "/pins", body, uuid.New())
 	rec := httptest.NewRecorder()
 
-	PostPinsHandler(pinRepo)(rec, req)
+	PostPinsHandler(pinRepo, stubEmotions("happy"), &mockNotificationRepo{}, &mockUserRepo{}, newMockZoneRepo())(rec, req)
 
-	if rec.Code != http.StatusCreated {
-		t.Fatalf("expected status %d got %d", http.StatusCreated, rec.Code)
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status %d got %d", http.StatusUnprocessableEntity, rec.Code)
 	}
 
-	if captured.userID != userID || captured.emotion != "happy" || captured.message != "Coffee is great" {
-		t.Fatalf("unexpected captured values: %+v", captured)
+	var resp dtos.ValidationErrorResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
 	}
-	if captured.longitude != -123.12 || captured.latitude != 49.28 || captured.visibility != "public" {
-		t.Fatalf("unexpected captured location/visibility: %+v", captured)
+
+	failed := map[string]bool{}
+	for _, f := range resp.Fields {
+		failed[f.Field] = true
+	}
+	for _, field := range []string{"emotion", "message", "longitude", "latitude", "visibility", "location_precision"} {
+		if !failed[field] {
+			t.Fatalf("expected %s to be reported, got %+v", field, resp.Fields)
+		}
 	}
 }
 
-func TestPostPinsHandler_InvalidBody(t *testing.T) {
-	pinRepo := &mockPinRepo{}
-	req := httptest.NewRequest(http.MethodPost, "/pins", strings.NewReader(`bad`))
-	req = req.WithContext(context.WithValue(req.Context(), "userID", uuid.New()))
+func TestPostPinsHandler_BodyTooLarge(t *testing.T) {
+	body := `{"emotion":"happy","message":"` + strings.Repeat("a", maxRequestBodyBytes) + `"}`
+	req := authedRequest(http.MethodPost, "/pins", body, uuid.New())
 	rec := httptest.NewRecorder()
 
-	PostPinsHandler(pinRepo)(rec, req)
+	PostPinsHandler(&mockPinRepo{}, stubEmotions("happy"), &mockNotificationRepo{}, &mockUserRepo{}, newMockZoneRepo())(rec, req)
 
-	if rec.Code != http.StatusBadRequest {
-		t.Fatalf("expected status %d got %d", http.StatusBadRequest, rec.Code)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected status %d got %d", http.StatusRequestEntityTooLarge, rec.Code)
 	}
 }
 
@@ -753,7 +1448,7 @@ func TestGetPinsFriendsHandler_Success(t *testing.T) {
 	now := time.Now().UTC()
 
 	pinRepo := &mockPinRepo{
-		queryFriendPinsFn: func(id uuid.UUID) ([]models.Pin, error) {
+		queryFriendPinsFn: func(id uuid.UUID, page repositories.Page) ([]models.Pin, error) {
 			if id != userID {
 				t.Fatalf("unexpected user ID %s", id)
 			}
@@ -773,8 +1468,7 @@ func TestGetPinsFriendsHandler_Success(t *testing.T) {
 		},
 	}
 
-	req := httptest.NewRequest(http.MethodGet, "/pins/friends", nil)
-	req = req.WithContext(context.WithValue(req.Context(), "userID", userID))
+	req := authedRequest(http.MethodGet, "/pins/friends", "", userID)
 	rec := httptest.NewRecorder()
 
 	GetPinsFriendsHandler(pinRepo)(rec, req)
@@ -806,13 +1500,12 @@ func TestGetPinsFriendsHandler_Success(t *testing.T) {
 
 func TestGetPinsFriendsHandler_Error(t *testing.T) {
 	pinRepo := &mockPinRepo{
-		queryFriendPinsFn: func(id uuid.UUID) ([]models.Pin, error) {
+		queryFriendPinsFn: func(id uuid.UUID, page repositories.Page) ([]models.Pin, error) {
 			return nil, errors.New("boom")
 		},
 	}
 
-	req := httptest.NewRequest(http.MethodGet, "/pins/friends", nil)
-	req = req.WithContext(context.WithValue(req.Context(), "userID", uuid.New()))
+	req := authedRequest(http.MethodGet, "/pins/friends", "", uuid.New())
 	rec := httptest.NewRecorder()
 
 	GetPinsFriendsHandler(pinRepo)(rec, req)
@@ -827,7 +1520,7 @@ func TestGetPinsMeHandler_Success(t *testing.T) {
 	now := time.Now().UTC()
 
 	pinRepo := &mockPinRepo{
-		queryUserPinsFn: func(id uuid.UUID) ([]models.Pin, error) {
+		queryUserPinsFn: func(id uuid.UUID, page repositories.Page) ([]models.Pin, error) {
 			if id != userID {
 				t.Fatalf("unexpected user ID %s", id)
 			}
@@ -847,8 +1540,7 @@ func TestGetPinsMeHandler_Success(t *testing.T) {
 		},
 	}
 
-	req := httptest.NewRequest(http.MethodGet, "/pins/me", nil)
-	req = req.WithContext(context.WithValue(req.Context(), "userID", userID))
+	req := authedRequest(http.MethodGet, "/pins/me", "", userID)
 	rec := httptest.NewRecorder()
 
 	GetPinsMeHandler(pinRepo)(rec, req)
@@ -869,13 +1561,12 @@ func TestGetPinsMeHandler_Success(t *testing.T) {
 
 func TestGetPinsMeHandler_Error(t *testing.T) {
 	pinRepo := &mockPinRepo{
-		queryUserPinsFn: func(id uuid.UUID) ([]models.Pin, error) {
+		queryUserPinsFn: func(id uuid.UUID, page repositories.Page) ([]models.Pin, error) {
 			return nil, errors.New("boom")
 		},
 	}
 
-	req := httptest.NewRequest(http.MethodGet, "/pins/me", nil)
-	req = req.WithContext(context.WithValue(req.Context(), "userID", uuid.New()))
+	req := authedRequest(http.MethodGet, "/pins/me", "", uuid.New())
 	rec := httptest.NewRecorder()
 
 	GetPinsMeHandler(pinRepo)(rec, req)
@@ -902,35 +1593,41 @@ func TestGetPinsNearbyHandler_Success(t *testing.T) {
 	}
 
 	pinRepo := &mockPinRepo{
-		queryNearbyPinsFn: func(id uuid.UUID, lon float64, lat float64, radius float64) ([]models.Pin, error) {
+		queryNearbyPinsFn: func(id uuid.UUID, query repositories.NearbyPinQuery) ([]models.NearbyPin, error) {
 			captured = struct {
 				userID    uuid.UUID
 				longitude float64
 				latitude  float64
 				radiusKm  float64
-			}{id, lon, lat, radius}
-			return []models.Pin{
+			}{id, query.Longitude, query.Latitude, query.RadiusKm}
+			if query.Sort != repositories.PinSortRecent || query.Page.Limit != defaultPinLimit || query.Page.After != nil || query.Since != nil || query.Emotion != "" {
+				t.Fatalf("unexpected default filters: %+v", query)
+			}
+			return []models.NearbyPin{
 				{
-					UserID:  uuid.New(),
-					Emotion: "curious",
-					Message: sql.NullString{String: "Checking this place", Valid: true},
-					Location: models.Location{
-						Latitude:  latitude,
-						Longitude: longitude,
+					Pin: models.Pin{
+						UserID:  uuid.New(),
+						Emotion: "curious",
+						Message: sql.NullString{String: "Checking this place", Valid: true},
+						Location: models.Location{
+							Latitude:  latitude,
+							Longitude: longitude,
+						},
+						Visibility: "public",
+						CreatedAt:  now,
 					},
-					Visibility: "public",
-					CreatedAt:  now,
+					DistanceM:  120.5,
+					BearingDeg: 270,
 				},
 			}, nil
 		},
 	}
 
 	url := fmt.Sprintf("/pins/nearby?longitude=%f&latitude=%f&radius_km=%f", longitude, latitude, radiusKm)
-	req := httptest.NewRequest(http.MethodGet, url, nil)
-	req = req.WithContext(context.WithValue(req.Context(), "userID", userID))
+	req := authedRequest(http.MethodGet, url, "", userID)
 	rec := httptest.NewRecorder()
 
-	GetPinsNearbyHandler(pinRepo)(rec, req)
+	GetPinsNearbyHandler(pinRepo, stubEmotions())(rec, req)
 
 	if rec.Code != http.StatusOK {
 		t.Fatalf("expected status %d got %d", http.StatusOK, rec.Code)
@@ -948,15 +1645,61 @@ func TestGetPinsNearbyHandler_Success(t *testing.T) {
 	if len(resp.Pins) != 1 || resp.Pins[0].Emotion != "curious" || resp.Pins[0].Message != "Checking this place" {
 		t.Fatalf("unexpected pins payload: %+v", resp.Pins)
 	}
+	if resp.Pins[0].DistanceM == nil || *resp.Pins[0].DistanceM != 120.5 || resp.Pins[0].BearingDeg == nil || *resp.Pins[0].BearingDeg != 270 {
+		t.Fatalf("expected distance and bearing on nearby pins: %+v", resp.Pins[0])
+	}
+}
+
+func TestGetPinsNearbyHandler_Filters(t *testing.T) {
+	var captured repositories.NearbyPinQuery
+	pinRepo := &mockPinRepo{
+		queryNearbyPinsFn: func(id uuid.UUID, query repositories.NearbyPinQuery) ([]models.NearbyPin, error) {
+			captured = query
+			return nil, nil
+		},
+	}
+
+	req := authedRequest(http.MethodGet, "/pins/nearby?longitude=1&latitude=2&radius_km=3&sort=relevance&limit=10&since=2026-01-02T15:04:05Z&emotion=happy", "", uuid.New())
+	rec := httptest.NewRecorder()
+
+	GetPinsNearbyHandler(pinRepo, stubEmotions("happy"))(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d got %d", http.StatusOK, rec.Code)
+	}
+	if captured.Sort != repositories.PinSortRelevance || captured.Page.Limit != 10 || captured.Emotion != "happy" {
+		t.Fatalf("unexpected filters: %+v", captured)
+	}
+	if captured.Since == nil || !captured.Since.Equal(time.Date(2026, 1, 2, 15, 4, 5, 0, time.UTC)) {
+		t.Fatalf("unexpected since: %v", captured.Since)
+	}
+}
+
+func TestGetPinsNearbyHandler_InvalidFilters(t *testing.T) {
+	cases := map[string]int{
+		"sort=closest":     http.StatusBadRequest,
+		"limit=0":          http.StatusBadRequest,
+		"since=yesterday":  http.StatusBadRequest,
+		"emotion=confused": http.StatusUnprocessableEntity,
+	}
+	for filter, status := range cases {
+		req := authedRequest(http.MethodGet, "/pins/nearby?longitude=1&latitude=2&radius_km=3&"+filter, "", uuid.New())
+		rec := httptest.NewRecorder()
+
+		GetPinsNearbyHandler(&mockPinRepo{}, stubEmotions("happy"))(rec, req)
+
+		if rec.Code != status {
+			t.Fatalf("%s: expected status %d got %d", filter, status, rec.Code)
+		}
+	}
 }
 
 func TestGetPinsNearbyHandler_MissingParam(t *testing.T) {
 	pinRepo := &mockPinRepo{}
-	req := httptest.NewRequest(http.MethodGet, "/pins/nearby?longitude=1&latitude=2", nil)
-	req = req.WithContext(context.WithValue(req.Context(), "userID", uuid.New()))
+	req := authedRequest(http.MethodGet, "/pins/nearby?longitude=1&latitude=2", "", uuid.New())
 	rec := httptest.NewRecorder()
 
-	GetPinsNearbyHandler(pinRepo)(rec, req)
+	GetPinsNearbyHandler(pinRepo, stubEmotions())(rec, req)
 
 	if rec.Code != http.StatusBadRequest {
 		t.Fatalf("expected status %d got %d", http.StatusBadRequest, rec.Code)
@@ -965,11 +1708,10 @@ func TestGetPinsNearbyHandler_MissingParam(t *testing.T) {
 
 func TestGetPinsNearbyHandler_InvalidRadius(t *testing.T) {
 	pinRepo := &mockPinRepo{}
-	req := httptest.NewRequest(http.MethodGet, "/pins/nearby?longitude=1&latitude=2&radius_km=-1", nil)
-	req = req.WithContext(context.WithValue(req.Context(), "userID", uuid.New()))
+	req := authedRequest(http.MethodGet, "/pins/nearby?longitude=1&latitude=2&radius_km=-1", "", uuid.New())
 	rec := httptest.NewRecorder()
 
-	GetPinsNearbyHandler(pinRepo)(rec, req)
+	GetPinsNearbyHandler(pinRepo, stubEmotions())(rec, req)
 
 	if rec.Code != http.StatusBadRequest {
 		t.Fatalf("expected status %d got %d", http.StatusBadRequest, rec.Code)
@@ -980,17 +1722,16 @@ func TestGetPinsNearbyHandler_RadiusClamped(t *testing.T) {
 	userID := uuid.New()
 	var capturedRadius float64
 	pinRepo := &mockPinRepo{
-		queryNearbyPinsFn: func(id uuid.UUID, lon float64, lat float64, radius float64) ([]models.Pin, error) {
-			capturedRadius = radius
+		queryNearbyPinsFn: func(id uuid.UUID, query repositories.NearbyPinQuery) ([]models.NearbyPin, error) {
+			capturedRadius = query.RadiusKm
 			return nil, nil
 		},
 	}
 
-	req := httptest.NewRequest(http.MethodGet, "/pins/nearby?longitude=1&latitude=2&radius_km=30", nil)
-	req = req.WithContext(context.WithValue(req.Context(), "userID", userID))
+	req := authedRequest(http.MethodGet, "/pins/nearby?longitude=1&latitude=2&radius_km=30", "", userID)
 	rec := httptest.NewRecorder()
 
-	GetPinsNearbyHandler(pinRepo)(rec, req)
+	GetPinsNearbyHandler(pinRepo, stubEmotions())(rec, req)
 
 	if rec.Code != http.StatusOK {
 		t.Fatalf("expected status %d got %d", http.StatusOK, rec.Code)
@@ -1001,9 +1742,336 @@ func TestGetPinsNearbyHandler_RadiusClamped(t *testing.T) {
 	}
 }
 
-func addFriendIDParam(req *http.Request, friendID string) *http.Request {
-	rctx := chi.NewRouteContext()
-	rctx.URLParams.Add("friendID", friendID)
+func TestGetPinsHandler_AntimeridianBBox(t *testing.T) {
+	userID := uuid.New()
+	var capturedBBox models.BBox
+	var capturedLimit int
+
+	pinRepo := &mockPinRepo{
+		queryPinsInBBoxFn: func(id uuid.UUID, bbox models.BBox, limit int) ([]models.Pin, error) {
+			if id != userID {
+				t.Fatalf("unexpected user ID %s", id)
+			}
+			capturedBBox = bbox
+			capturedLimit = limit
+			return []models.Pin{{ID: uuid.New(), Emotion: "calm", Location: models.Location{Longitude: 179.9, Latitude: -17.7}}}, nil
+		},
+	}
+
+	req := authedRequest(http.MethodGet, "/pins?bbox=179.8,-17.8,-179.9,-17.6&limit=1000", "", userID)
+	rec := httptest.NewRecorder()
+
+	GetPinsHandler(pinRepo)(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d got %d", http.StatusOK, rec.Code)
+	}
+	if !capturedBBox.CrossesAntimeridian() {
+		t.Fatalf("expected bbox to cross the antimeridian: %+v", capturedBBox)
+	}
+	east, west := capturedBBox.Envelopes()
+	if east.MaxLon != 180 || west.MinLon != -180 || east.MinLon != 179.8 || west.MaxLon != -179.9 {
+		t.Fatalf("unexpected envelopes %+v %+v", east, west)
+	}
+	if capturedLimit != maxPinLimit {
+		t.Fatalf("expected limit clamped to %d got %d", maxPinLimit, capturedLimit)
+	}
+
+	var resp dtos.GetPinListResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.Pins) != 1 {
+		t.Fatalf("expected one pin, got %d", len(resp.Pins))
+	}
+}
+
+func TestGetPinsHandler_BBoxTooLarge(t *testing.T) {
+	req := authedRequest(http.MethodGet, "/pins?bbox=-130,30,-100,50", "", uuid.New())
+	rec := httptest.NewRecorder()
+
+	GetPinsHandler(&mockPinRepo{})(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestGetPinsHandler_InvalidBBox(t *testing.T) {
+	for _, bbox := range []string{"", "1,2,3", "a,b,c,d", "-123,49,-122,95", "-123,49.3,-122,49.2"} {
+		req := authedRequest(http.MethodGet, "/pins?bbox="+bbox, "", uuid.New())
+		rec := httptest.NewRecorder()
+
+		GetPinsHandler(&mockPinRepo{})(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("bbox %q: expected status %d got %d", bbox, http.StatusBadRequest, rec.Code)
+		}
+	}
+}
+
+func TestGetPinHandler_Success(t *testing.T) {
+	userID := uuid.New()
+	pinID := uuid.New()
+
+	pinRepo := &mockPinRepo{
+		getPinFn: func(u uuid.UUID, p uuid.UUID) (*models.Pin, error) {
+			if u != userID || p != pinID {
+				t.Fatalf("unexpected IDs %s %s", u, p)
+			}
+			return &models.Pin{ID: pinID, UserID: uuid.New(), Emotion: "calm", Visibility: "public"}, nil
+		},
+	}
+
+	req := authedRequest(http.MethodGet, "/pins/"+pinID.String(), "", userID, "pinID", pinID.String())
+	rec := httptest.NewRecorder()
+
+	GetPinHandler(pinRepo)(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d got %d", http.StatusOK, rec.Code)
+	}
+
+	var resp dtos.Pin
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.ID != pinID || resp.Emotion != "calm" {
+		t.Fatalf("unexpected pin payload: %+v", resp)
+	}
+}
+
+func TestGetPinHandler_NotVisible(t *testing.T) {
+	pinRepo := &mockPinRepo{}
+	pinID := uuid.New()
+
+	req := authedRequest(http.MethodGet, "/pins/"+pinID.String(), "", uuid.New(), "pinID", pinID.String())
+	rec := httptest.NewRecorder()
+
+	GetPinHandler(pinRepo)(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestPatchPinHandler_Success(t *testing.T) {
+	userID := uuid.New()
+	pinID := uuid.New()
+	var captured repositories.PinUpdate
+
+	pinRepo := &mockPinRepo{
+		getPinFn: func(u uuid.UUID, p uuid.UUID) (*models.Pin, error) {
+			return &models.Pin{ID: p, UserID: u, Emotion: "sad", Visibility: "public", CreatedAt: time.Now()}, nil
+		},
+		updatePinFn: func(u uuid.UUID, p uuid.UUID, update repositories.PinUpdate) (*models.Pin, error) {
+			captured = update
+			return &models.Pin{ID: p, UserID: u, Emotion: "sad", Visibility: "friends"}, nil
+		},
+	}
+
+	req := authedRequest(http.MethodPatch, "/pins/"+pinID.String(), `{"visibility":"friends"}`, userID, "pinID", pinID.String())
+	rec := httptest.NewRecorder()
+
+	PatchPinHandler(pinRepo, stubEmotions(), &mockNotificationRepo{})(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d got %d", http.StatusOK, rec.Code)
+	}
+	if captured.Visibility == nil || *captured.Visibility != "friends" || captured.Emotion != nil || captured.Message != nil {
+		t.Fatalf("unexpected update: %+v", captured)
+	}
+}
+
+func TestPatchPinHandler_VisibilityChangeCapsExpiry(t *testing.T) {
+	createdAt := time.Now().Add(-time.Hour).UTC()
+	var captured repositories.PinUpdate
+
+	pinRepo := &mockPinRepo{
+		getPinFn: func(u uuid.UUID, p uuid.UUID) (*models.Pin, error) {
+			return &models.Pin{ID: p, UserID: u, Visibility: "private", CreatedAt: createdAt}, nil
+		},
+		updatePinFn: func(u uuid.UUID, p uuid.UUID, update repositories.PinUpdate) (*models.Pin, error) {
+			captured = update
+			return &models.Pin{ID: p, UserID: u, Visibility: "public"}, nil
+		},
+	}
+
+	pinID := uuid.New()
+	req := authedRequest(http.MethodPatch, "/pins/"+pinID.String(), `{"visibility":"public"}`, uuid.New(), "pinID", pinID.String())
+	rec := httptest.NewRecorder()
+
+	PatchPinHandler(pinRepo, stubEmotions(), &mockNotificationRepo{})(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d got %d", http.StatusOK, rec.Code)
+	}
+	if captured.ExpiresAt == nil || !captured.ExpiresAt.Equal(createdAt.Add(maxPinLifetime["public"])) {
+		t.Fatalf("expected expiry capped from created_at, got %v", captured.ExpiresAt)
+	}
+}
+
+func TestPatchPinHandler_ClearExpiry(t *testing.T) {
+	createdAt := time.Now().Add(-time.Hour).UTC()
+	expiresAt := time.Now().Add(time.Hour)
+	publicCap := createdAt.Add(maxPinLifetime["public"])
+
+	cases := []struct {
+		name       string
+		body       string
+		wantClear  bool
+		wantExpiry *time.Time
+	}{
+		{"private pin", `{"visibility":"private","expires_at":""}`, true, nil},
+		{"public pin keeps its cap", `{"expires_at":""}`, false, &publicCap},
+	}
+
+	for _, tc := range cases {
+		var captured repositories.PinUpdate
+		pinRepo := &mockPinRepo{
+			getPinFn: func(u uuid.UUID, p uuid.UUID) (*models.Pin, error) {
+				pin := &models.Pin{ID: p, UserID: u, Visibility: "public", CreatedAt: createdAt}
+				pin.ExpiresAt.Time, pin.ExpiresAt.Valid = expiresAt, true
+				return pin, nil
+			},
+			updatePinFn: func(u uuid.UUID, p uuid.UUID, update repositories.PinUpdate) (*models.Pin, error) {
+				captured = update
+				return &models.Pin{ID: p, UserID: u}, nil
+			},
+		}
+
+		pinID := uuid.New()
+		req := authedRequest(http.MethodPatch, "/pins/"+pinID.String(), tc.body, uuid.New(), "pinID", pinID.String())
+		rec := httptest.NewRecorder()
+
+		PatchPinHandler(pinRepo, stubEmotions(), &mockNotificationRepo{})(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected status %d got %d", tc.name, http.StatusOK, rec.Code)
+		}
+		if captured.ClearExpiry != tc.wantClear {
+			t.Fatalf("%s: expected ClearExpiry %v", tc.name, tc.wantClear)
+		}
+		if (tc.wantExpiry == nil) != (captured.ExpiresAt == nil) || (tc.wantExpiry != nil && !captured.ExpiresAt.Equal(*tc.wantExpiry)) {
+			t.Fatalf("%s: expected expiry %v got %v", tc.name, tc.wantExpiry, captured.ExpiresAt)
+		}
+	}
+}
+
+func TestPatchPinHandler_LocationPrecision(t *testing.T) {
+	var captured repositories.PinUpdate
+	pinRepo := &mockPinRepo{
+		getPinFn: func(u uuid.UUID, p uuid.UUID) (*models.Pin, error) {
+			return &models.Pin{ID: p, UserID: u, Visibility: "public", CreatedAt: time.Now()}, nil
+		},
+		updatePinFn: func(u uuid.UUID, p uuid.UUID, update repositories.PinUpdate) (*models.Pin, error) {
+			captured = update
+			return &models.Pin{
+				ID: p, UserID: u, Visibility: "public",
+				Precision:        models.PrecisionExact,
+				PrecisionSetting: sql.NullString{String: *update.Precision, Valid: true},
+			}, nil
+		},
+	}
+
+	pinID := uuid.New()
+	req := authedRequest(http.MethodPatch, "/pins/"+pinID.String(), `{"location_precision":"city"}`, uuid.New(), "pinID", pinID.String())
+	rec := httptest.NewRecorder()
+
+	PatchPinHandler(pinRepo, stubEmotions(), &mockNotificationRepo{})(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	if captured.Precision == nil || *captured.Precision != models.PrecisionCity {
+		t.Fatalf("unexpected update: %+v", captured)
+	}
+	var resp dtos.Pin
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	// The author sees the exact location and the pin's own setting
+	if resp.Precision != models.PrecisionExact || resp.LocationPrecision == nil || *resp.LocationPrecision != models.PrecisionCity {
+		t.Fatalf("unexpected precision in response: %+v", resp)
+	}
+}
+
+func TestPatchPinHandler_NotOwner(t *testing.T) {
+	pinRepo := &mockPinRepo{
+		updatePinFn: func(u uuid.UUID, p uuid.UUID, update repositories.PinUpdate) (*models.Pin, error) {
+			return nil, repositories.ErrNotPinOwner
+		},
+	}
+
+	pinID := uuid.New()
+	req := authedRequest(http.MethodPatch, "/pins/"+pinID.String(), `{"message":"hi"}`, uuid.New(), "pinID", pinID.String())
+	rec := httptest.NewRecorder()
+
+	PatchPinHandler(pinRepo, stubEmotions(), &mockNotificationRepo{})(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d got %d", http.StatusForbidden, rec.Code)
+	}
+}
+
+func TestDeletePinHandler_NoContent(t *testing.T) {
+	pinID := uuid.New()
+	req := authedRequest(http.MethodDelete, "/pins/"+pinID.String(), "", uuid.New(), "pinID", pinID.String())
+	rec := httptest.NewRecorder()
+
+	DeletePinHandler(&mockPinRepo{})(rec, req)
+
+	if rec.Code != http.StatusNoContent || rec.Body.Len() != 0 {
+		t.Fatalf("expected status %d with no body, got %d %q", http.StatusNoContent, rec.Code, rec.Body.String())
+	}
+}
+
+func TestDeletePinHandler_NotFound(t *testing.T) {
+	pinRepo := &mockPinRepo{
+		deletePinFn: func(u uuid.UUID, p uuid.UUID) error {
+			return repositories.ErrPinNotFound
+		},
+	}
+
+	pinID := uuid.New()
+	req := authedRequest(http.MethodDelete, "/pins/"+pinID.String(), "", uuid.New(), "pinID", pinID.String())
+	rec := httptest.NewRecorder()
+
+	DeletePinHandler(pinRepo)(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestDeletePinHandler_InvalidUUID(t *testing.T) {
+	req := authedRequest(http.MethodDelete, "/pins/not-a-uuid", "", uuid.New(), "pinID", "not-a-uuid")
+	rec := httptest.NewRecorder()
+
+	DeletePinHandler(&mockPinRepo{})(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+// authedRequest builds a request made by userID, with chi URL params given
+// as key, value pairs.
+func authedRequest(method string, target string, body string, userID uuid.UUID, params ...string) *http.Request {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req = req.WithContext(context.WithValue(req.Context(), "userID", userID))
+	for i := 0; i+1 < len(params); i += 2 {
+		req = addURLParam(req, params[i], params[i+1])
+	}
+	return req
+}
+
+func addURLParam(req *http.Request, key string, value string) *http.Request {
+	rctx, ok := req.Context().Value(chi.RouteCtxKey).(*chi.Context)
+	if !ok {
+		rctx = chi.NewRouteContext()
+	}
+	rctx.URLParams.Add(key, value)
 	ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
 	return req.WithContext(ctx)
 }
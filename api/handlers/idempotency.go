@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"ember/api/models"
+	"ember/api/repositories"
+	"ember/api/validation"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// How long a response is kept to be replayed to retries
+	idempotencyKeyTTL = 24 * time.Hour
+
+	// A request still being handled after this long is assumed to have died,
+	// and its key may be used again
+	idempotencyLockTTL = time.Minute
+
+	maxIdempotencyKeyLength = 255
+)
+
+// IdempotencyMiddleware lets clients safely retry requests sent with an
+// Idempotency-Key header. The first response with a key is stored for
+// idempotencyKeyTTL and replayed, marked with Idempotent-Replayed, to exact
+// repeats of the request; reusing the key for a different request is a 422,
+// and retrying while the first is still being handled a 409. Server errors
+// aren't stored, so those requests can be retried. Keys are scoped to the
+// caller, so this must run after auth.AuthMiddleware; requests without one
+// are passed through unstored, since responses to them (such as issued
+// tokens) must not be replayed to whoever repeats them.
+func IdempotencyMiddleware(idempotencyRepo repositories.IdempotencyRepository) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get("Idempotency-Key")
+			userID, signedIn := r.Context().Value("userID").(uuid.UUID)
+			if key == "" || !signedIn {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxIdempotencyKeyLength {
+				http.Error(w, "Idempotency-Key is too long", http.StatusBadRequest)
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestBodyBytes))
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+					return
+				}
+				http.Error(w, "invalid request body", http.StatusBadRequest)
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			scope := userID.String()
+			fingerprint := requestFingerprint(r, body)
+
+			first, err := idempotencyRepo.ClaimIdempotencyKey(scope, key, fingerprint, idempotencyLockTTL)
+			if err != nil {
+				log.Println("claim idempotency key:", err)
+				http.Error(w, "unable to process request", http.StatusInternalServerError)
+				return
+			}
+			if first != nil {
+				replayIdempotentResponse(w, *first, fingerprint)
+				return
+			}
+
+			rec := &recordingWriter{ResponseWriter: w}
+			next.ServeHTTP(rec, r)
+
+			status := rec.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			if status >= http.StatusInternalServerError {
+				if err := idempotencyRepo.ReleaseIdempotencyKey(scope, key); err != nil {
+					log.Println("release idempotency key:", err)
+				}
+				return
+			}
+
+			err = idempotencyRepo.SaveIdempotentResponse(scope, key, models.IdempotentRequest{
+				Fingerprint: fingerprint,
+				StatusCode:  status,
+				ContentType: rec.Header().Get("Content-Type"),
+				Body:        rec.body.Bytes(),
+			}, idempotencyKeyTTL)
+			if err != nil {
+				log.Println("save idempotent response:", err)
+			}
+		})
+	}
+}
+
+// replayIdempotentResponse answers a request whose key was already used by
+// first.
+func replayIdempotentResponse(w http.ResponseWriter, first models.IdempotentRequest, fingerprint string) {
+	switch {
+	case first.Fingerprint != fingerprint:
+		var errs validation.Errors
+		errs.Add("Idempotency-Key", "was already used for a different request")
+		writeValidationError(w, errs)
+	case first.StatusCode == 0:
+		http.Error(w, "a request with this Idempotency-Key is still in progress", http.StatusConflict)
+	default:
+		if first.ContentType != "" {
+			w.Header().Set("Content-Type", first.ContentType)
+		}
+		w.Header().Set("Idempotent-Replayed", "true")
+		w.WriteHeader(first.StatusCode)
+		if _, err := w.Write(first.Body); err != nil {
+			log.Println("write replayed response:", err)
+		}
+	}
+}
+
+// requestFingerprint identifies a request by its method, path and body.
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(r.Method + " " + r.URL.Path + "\n"))
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// recordingWriter passes a response through while keeping a copy of it.
+type recordingWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rw *recordingWriter) WriteHeader(status int) {
+	if rw.status == 0 {
+		rw.status = status
+	}
+	rw.ResponseWriter.WriteHeader(status)
+}
+
+func (rw *recordingWriter) Write(b []byte) (int, error) {
+	if rw.status == 0 {
+		rw.status = http.StatusOK
+	}
+	rw.body.Write(b)
+	return rw.ResponseWriter.Write(b)
+}
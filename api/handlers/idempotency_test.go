@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// countingHandler responds with status and the number of requests it has
+// handled so far.
+func countingHandler(status int, calls *int) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*calls++
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(`{"call":` + strconv.Itoa(*calls) + `}`))
+	})
+}
+
+func idempotentRequest(userID uuid.UUID, key string, body string) *http.Request {
+	req := authedRequest(http.MethodPost, "/pins", body, userID)
+	if key != "" {
+		req.Header.Set("Idempotency-Key", key)
+	}
+	return req
+}
+
+func TestIdempotencyMiddleware_ReplaysExactRepeats(t *testing.T) {
+	calls := 0
+	h := IdempotencyMiddleware(newMockIdempotencyRepo())(countingHandler(http.StatusCreated, &calls))
+	userID := uuid.New()
+
+	first := httptest.NewRecorder()
+	h.ServeHTTP(first, idempotentRequest(userID, "abc", `{"emotion":"happy"}`))
+	retry := httptest.NewRecorder()
+	h.ServeHTTP(retry, idempotentRequest(userID, "abc", `{"emotion":"happy"}`))
+
+	if calls != 1 {
+		t.Fatalf("expected the handler to run once, ran %d times", calls)
+	}
+	if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() {
+		t.Fatalf("expected the first response replayed, got %d %q", retry.Code, retry.Body.String())
+	}
+	if retry.Header().Get("Content-Type") != "application/json" || retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("unexpected replay headers %v", retry.Header())
+	}
+	if first.Header().Get("Idempotent-Replayed") != "" {
+		t.Fatalf("the first response is not a replay")
+	}
+}
+
+func TestIdempotencyMiddleware_DifferentBody(t *testing.T) {
+	calls := 0
+	h := IdempotencyMiddleware(newMockIdempotencyRepo())(countingHandler(http.StatusCreated, &calls))
+	userID := uuid.New()
+
+	h.ServeHTTP(httptest.NewRecorder(), idempotentRequest(userID, "abc", `{"emotion":"happy"}`))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, idempotentRequest(userID, "abc", `{"emotion":"sad"}`))
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status %d got %d", http.StatusUnprocessableEntity, rec.Code)
+	}
+	if calls != 1 {
+		t.Fatalf("expected the handler to run once, ran %d times", calls)
+	}
+}
+
+func TestIdempotencyMiddleware_KeysAreScopedToTheCaller(t *testing.T) {
+	calls := 0
+	h := IdempotencyMiddleware(newMockIdempotencyRepo())(countingHandler(http.StatusCreated, &calls))
+
+	h.ServeHTTP(httptest.NewRecorder(), idempotentRequest(uuid.New(), "abc", `{}`))
+	h.ServeHTTP(httptest.NewRecorder(), idempotentRequest(uuid.New(), "abc", `{}`))
+
+	if calls != 2 {
+		t.Fatalf("expected both users' requests to run, ran %d", calls)
+	}
+}
+
+func TestIdempotencyMiddleware_WithoutKey(t *testing.T) {
+	calls := 0
+	h := IdempotencyMiddleware(newMockIdempotencyRepo())(countingHandler(http.StatusCreated, &calls))
+	userID := uuid.New()
+
+	h.ServeHTTP(httptest.NewRecorder(), idempotentRequest(userID, "", `{}`))
+	h.ServeHTTP(httptest.NewRecorder(), idempotentRequest(userID, "", `{}`))
+
+	if calls != 2 {
+		t.Fatalf("expected requests without a key to always run, ran %d", calls)
+	}
+}
+
+func TestIdempotencyMiddleware_WithoutCaller(t *testing.T) {
+	calls := 0
+	h := IdempotencyMiddleware(newMockIdempotencyRepo())(countingHandler(http.StatusCreated, &calls))
+
+	for range 2 {
+		req := httptest.NewRequest(http.MethodPost, "/auth/register", strings.NewReader(`{}`))
+		req.Header.Set("Idempotency-Key", "abc")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Header().Get("Idempotent-Replayed") != "" {
+			t.Fatalf("expected an unauthenticated response never to be replayed")
+		}
+	}
+
+	if calls != 2 {
+		t.Fatalf("expected unauthenticated requests to always run, ran %d", calls)
+	}
+}
+
+func TestIdempotencyMiddleware_ServerErrorsCanBeRetried(t *testing.T) {
+	calls := 0
+	h := IdempotencyMiddleware(newMockIdempotencyRepo())(countingHandler(http.StatusInternalServerError, &calls))
+	userID := uuid.New()
+
+	h.ServeHTTP(httptest.NewRecorder(), idempotentRequest(userID, "abc", `{}`))
+	h.ServeHTTP(httptest.NewRecorder(), idempotentRequest(userID, "abc", `{}`))
+
+	if calls != 2 {
+		t.Fatalf("expected the retry to run again, ran %d", calls)
+	}
+}
+
+func TestIdempotencyMiddleware_InProgress(t *testing.T) {
+	repo := newMockIdempotencyRepo()
+	userID := uuid.New()
+	var retry *httptest.ResponseRecorder
+	var h http.Handler
+	h = IdempotencyMiddleware(repo)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Retried before the first request has been answered
+		if retry == nil {
+			retry = httptest.NewRecorder()
+			h.ServeHTTP(retry, idempotentRequest(userID, "abc", `{}`))
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+
+	h.ServeHTTP(httptest.NewRecorder(), idempotentRequest(userID, "abc", `{}`))
+
+	if retry == nil || retry.Code != http.StatusConflict {
+		t.Fatalf("expected status %d for a request still in progress", http.StatusConflict)
+	}
+}
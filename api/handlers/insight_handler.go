@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"ember/api/dtos"
+	"ember/api/models"
+	"ember/api/repositories"
+)
+
+const (
+	// minMoodCellUsers is the k in k-anonymity: cells with pins from fewer
+	// distinct users are never reported.
+	minMoodCellUsers = 5
+	// moodGridCells is how many cells span the longer side of the bbox.
+	moodGridCells = 16
+	// minMoodCellDeg (~500 m) stops a tiny bbox from zooming in on single homes.
+	minMoodCellDeg        = 0.005
+	maxInsightBBoxAreaKm2 = 250000.0
+	defaultMoodWindow     = 24 * time.Hour
+	maxMoodWindow         = 7 * 24 * time.Hour
+
+	// minTrendBucketAuthors suppresses trend buckets with pins from fewer
+	// distinct users, like minMoodCellUsers. minTrendRadiusKm (about one
+	// rollup cell) stops a tiny radius from zooming in on a single home.
+	minTrendBucketAuthors = 5
+	minTrendRadiusKm      = 1.0
+	defaultTrendRadiusKm  = 2.0
+	maxTrendRadiusKm      = 50.0
+	defaultTrendBucket    = "day"
+)
+
+// trendWindows holds the default and maximum look-back per trend bucket, which
+// together bound the number of points in a series.
+var trendWindows = map[string]struct{ def, max time.Duration }{
+	"hour": {48 * time.Hour, 7 * 24 * time.Hour},
+	"day":  {30 * 24 * time.Hour, 365 * 24 * time.Hour},
+	"week": {26 * 7 * 24 * time.Hour, 104 * 7 * 24 * time.Hour},
+}
+
+// parseSince parses an optional RFC 3339 "since" value, defaulting to now-def
+// and clamping to at most maxWindow ago.
+func parseSince(raw string, now time.Time, def time.Duration, maxWindow time.Duration) (time.Time, error) {
+	since := now.Add(-def)
+	if raw != "" {
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return time.Time{}, err
+		}
+		since = parsed
+	}
+	if earliest := now.Add(-maxWindow); since.Before(earliest) {
+		since = earliest
+	}
+	return since, nil
+}
+
+// GET /insights/mood?bbox=minLon,minLat,maxLon,maxLat&since=
+func GetMoodInsightsHandler(insightRepo repositories.InsightRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("bbox") == "" {
+			http.Error(w, "missing required query parameter: bbox", http.StatusBadRequest)
+			return
+		}
+
+		bbox, err := parseBBox(query.Get("bbox"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if bbox.AreaKm2() > maxInsightBBoxAreaKm2 {
+			http.Error(w, "bbox is too large; zoom in", http.StatusBadRequest)
+			return
+		}
+
+		since, err := parseSince(query.Get("since"), time.Now(), defaultMoodWindow, maxMoodWindow)
+		if err != nil {
+			http.Error(w, "since must be an RFC 3339 timestamp", http.StatusBadRequest)
+			return
+		}
+
+		cellDeg := math.Max(math.Max(bbox.WidthDeg(), bbox.HeightDeg())/moodGridCells, minMoodCellDeg)
+		cells, err := insightRepo.QueryMoodGrid(bbox, since, cellDeg, minMoodCellUsers)
+		if err != nil {
+			log.Println("query mood grid:", err)
+			http.Error(w, "unable to compute mood insights", http.StatusInternalServerError)
+			return
+		}
+
+		resp := dtos.GetMoodInsightsResponse{
+			Since:       since,
+			CellSizeDeg: cellDeg,
+			Cells:       make([]dtos.MoodCell, 0, len(cells)),
+			Summary:     dtos.MoodSummary{Emotions: map[string]int{}},
+		}
+		var valenceSum float64
+		for _, c := range cells {
+			resp.Cells = append(resp.Cells, dtos.MoodCell{
+				MinLon:          c.Bounds.MinLon,
+				MinLat:          c.Bounds.MinLat,
+				MaxLon:          c.Bounds.MaxLon,
+				MaxLat:          c.Bounds.MaxLat,
+				PinCount:        c.PinCount,
+				MeanValence:     c.MeanValence,
+				DominantEmotion: c.DominantEmotion,
+				Emotions:        c.Emotions,
+			})
+
+			resp.Summary.PinCount += c.PinCount
+			valenceSum += c.MeanValence * float64(c.PinCount)
+			for emotion, count := range c.Emotions {
+				resp.Summary.Emotions[emotion] += count
+			}
+		}
+		if resp.Summary.PinCount > 0 {
+			resp.Summary.MeanValence = valenceSum / float64(resp.Summary.PinCount)
+			resp.Summary.DominantEmotion = models.DominantEmotion(resp.Summary.Emotions)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.Println("encode mood insights response:", err)
+		}
+	}
+}
+
+// GET /insights/trends?lat=&lon=&radius_km=&bucket=hour|day|week&since=
+func GetMoodTrendsHandler(insightRepo repositories.InsightRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		for _, key := range []string{"lat", "lon"} {
+			if query.Get(key) == "" {
+				http.Error(w, "missing required query parameter: "+key, http.StatusBadRequest)
+				return
+			}
+		}
+
+		lat, err := strconv.ParseFloat(query.Get("lat"), 64)
+		if err != nil || lat < -90 || lat > 90 {
+			http.Error(w, "invalid lat", http.StatusBadRequest)
+			return
+		}
+
+		lon, err := strconv.ParseFloat(query.Get("lon"), 64)
+		if err != nil || lon < -180 || lon > 180 {
+			http.Error(w, "invalid lon", http.StatusBadRequest)
+			return
+		}
+
+		radiusKm := defaultTrendRadiusKm
+		if raw := query.Get("radius_km"); raw != "" {
+			radiusKm, err = strconv.ParseFloat(raw, 64)
+			if err != nil || radiusKm <= 0 {
+				http.Error(w, "radius_km must be a positive number", http.StatusBadRequest)
+				return
+			}
+		}
+		radiusKm = math.Min(math.Max(radiusKm, minTrendRadiusKm), maxTrendRadiusKm)
+
+		bucket := query.Get("bucket")
+		if bucket == "" {
+			bucket = defaultTrendBucket
+		}
+		window, ok := trendWindows[bucket]
+		if !ok {
+			http.Error(w, "bucket must be one of hour, day, week", http.StatusBadRequest)
+			return
+		}
+
+		since, err := parseSince(query.Get("since"), time.Now(), window.def, window.max)
+		if err != nil {
+			http.Error(w, "since must be an RFC 3339 timestamp", http.StatusBadRequest)
+			return
+		}
+
+		trend, err := insightRepo.QueryMoodTrends(lon, lat, radiusKm, bucket, since, minTrendBucketAuthors)
+		if err != nil {
+			log.Println("query mood trends:", err)
+			http.Error(w, "unable to compute mood trends", http.StatusInternalServerError)
+			return
+		}
+
+		resp := dtos.GetMoodTrendsResponse{
+			Bucket:  bucket,
+			Since:   since,
+			AsOf:    trend.AsOf,
+			Buckets: make([]dtos.MoodTrendBucket, 0, len(trend.Buckets)),
+		}
+		for _, b := range trend.Buckets {
+			resp.Buckets = append(resp.Buckets, dtos.MoodTrendBucket{
+				Start:           b.Start,
+				PinCount:        b.PinCount,
+				MeanValence:     b.MeanValence,
+				DominantEmotion: b.DominantEmotion,
+				Emotions:        b.Emotions,
+			})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.Println("encode mood trends response:", err)
+		}
+	}
+}
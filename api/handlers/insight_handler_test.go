@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"encoding/json"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ember/api/dtos"
+	"ember/api/models"
+
+	"github.com/google/uuid"
+)
+
+func TestGetMoodInsightsHandler_Success(t *testing.T) {
+	var capturedSince time.Time
+	var capturedCell float64
+
+	insightRepo := &mockInsightRepo{
+		queryMoodGridFn: func(bbox models.BBox, since time.Time, cellDeg float64, minUsers int) ([]models.MoodCell, error) {
+			if minUsers != minMoodCellUsers {
+				t.Fatalf("expected k-anonymity threshold %d got %d", minMoodCellUsers, minUsers)
+			}
+			capturedSince = since
+			capturedCell = cellDeg
+			return []models.MoodCell{
+				{PinCount: 6, MeanValence: 0.5, DominantEmotion: "happy", Emotions: map[string]int{"happy": 5, "sad": 1}},
+				{PinCount: 2, MeanValence: -0.5, DominantEmotion: "sad", Emotions: map[string]int{"sad": 2}},
+			}, nil
+		},
+	}
+
+	req := authedRequest(http.MethodGet, "/insights/mood?bbox=-123.3,49.1,-122.9,49.4", "", uuid.New())
+	rec := httptest.NewRecorder()
+
+	GetMoodInsightsHandler(insightRepo)(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d got %d", http.StatusOK, rec.Code)
+	}
+	if window := time.Since(capturedSince); window < defaultMoodWindow-time.Minute || window > defaultMoodWindow+time.Minute {
+		t.Fatalf("expected default window of %s got %s", defaultMoodWindow, window)
+	}
+	if math.Abs(capturedCell-0.4/moodGridCells) > 1e-9 {
+		t.Fatalf("unexpected cell size %f", capturedCell)
+	}
+
+	var resp dtos.GetMoodInsightsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.Cells) != 2 {
+		t.Fatalf("expected 2 cells got %d", len(resp.Cells))
+	}
+	if resp.Summary.PinCount != 8 || resp.Summary.Emotions["sad"] != 3 || resp.Summary.DominantEmotion != "happy" {
+		t.Fatalf("unexpected summary: %+v", resp.Summary)
+	}
+	if math.Abs(resp.Summary.MeanValence-0.25) > 1e-9 {
+		t.Fatalf("expected weighted mean valence 0.25 got %f", resp.Summary.MeanValence)
+	}
+}
+
+func TestGetMoodInsightsHandler_ClampsSince(t *testing.T) {
+	var capturedSince time.Time
+	insightRepo := &mockInsightRepo{
+		queryMoodGridFn: func(bbox models.BBox, since time.Time, cellDeg float64, minUsers int) ([]models.MoodCell, error) {
+			capturedSince = since
+			return nil, nil
+		},
+	}
+
+	req := authedRequest(http.MethodGet, "/insights/mood?bbox=-123.3,49.1,-122.9,49.4&since=2000-01-01T00:00:00Z", "", uuid.New())
+	rec := httptest.NewRecorder()
+
+	GetMoodInsightsHandler(insightRepo)(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d got %d", http.StatusOK, rec.Code)
+	}
+	if time.Since(capturedSince) > maxMoodWindow+time.Minute {
+		t.Fatalf("expected since to be clamped to %s, got %s", maxMoodWindow, capturedSince)
+	}
+}
+
+func TestGetMoodInsightsHandler_BadRequests(t *testing.T) {
+	for _, target := range []string{
+		"/insights/mood",
+		"/insights/mood?bbox=-180,-80,180,80",
+		"/insights/mood?bbox=-123.3,49.1,-122.9,49.4&since=yesterday",
+	} {
+		req := authedRequest(http.MethodGet, target, "", uuid.New())
+		rec := httptest.NewRecorder()
+
+		GetMoodInsightsHandler(&mockInsightRepo{})(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected status %d got %d", target, http.StatusBadRequest, rec.Code)
+		}
+	}
+}
+
+func TestGetMoodTrendsHandler_Success(t *testing.T) {
+	var capturedBucket string
+	var capturedRadius float64
+	var capturedSince time.Time
+	start := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+
+	insightRepo := &mockInsightRepo{
+		queryMoodTrendsFn: func(lon float64, lat float64, radiusKm float64, bucket string, since time.Time, minAuthors int) (*models.MoodTrend, error) {
+			if lon != -123.1 || lat != 49.2 || minAuthors != minTrendBucketAuthors {
+				t.Fatalf("unexpected arguments %f %f %d", lon, lat, minAuthors)
+			}
+			capturedBucket = bucket
+			capturedRadius = radiusKm
+			capturedSince = since
+			return &models.MoodTrend{
+				AsOf: start,
+				Buckets: []models.MoodTrendBucket{
+					{Start: start, PinCount: 7, MeanValence: 0.3, DominantEmotion: "calm", Emotions: map[string]int{"calm": 7}},
+				},
+			}, nil
+		},
+	}
+
+	req := authedRequest(http.MethodGet, "/insights/trends?lat=49.2&lon=-123.1&radius_km=500&bucket=week", "", uuid.New())
+	rec := httptest.NewRecorder()
+
+	GetMoodTrendsHandler(insightRepo)(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d got %d", http.StatusOK, rec.Code)
+	}
+	if capturedBucket != "week" || capturedRadius != maxTrendRadiusKm {
+		t.Fatalf("unexpected bucket %q or radius %f", capturedBucket, capturedRadius)
+	}
+	if window := time.Since(capturedSince); window < trendWindows["week"].def-time.Minute || window > trendWindows["week"].def+time.Minute {
+		t.Fatalf("expected default weekly window got %s", window)
+	}
+
+	var resp dtos.GetMoodTrendsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !resp.AsOf.Equal(start) || len(resp.Buckets) != 1 || resp.Buckets[0].PinCount != 7 || resp.Buckets[0].DominantEmotion != "calm" {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+}
+
+func TestGetMoodTrendsHandler_MinimumRadius(t *testing.T) {
+	var capturedRadius float64
+	insightRepo := &mockInsightRepo{
+		queryMoodTrendsFn: func(lon float64, lat float64, radiusKm float64, bucket string, since time.Time, minAuthors int) (*models.MoodTrend, error) {
+			capturedRadius = radiusKm
+			return &models.MoodTrend{}, nil
+		},
+	}
+
+	req := authedRequest(http.MethodGet, "/insights/trends?lat=49.2&lon=-123.1&radius_km=0.05", "", uuid.New())
+	rec := httptest.NewRecorder()
+
+	GetMoodTrendsHandler(insightRepo)(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d got %d", http.StatusOK, rec.Code)
+	}
+	if capturedRadius != minTrendRadiusKm {
+		t.Fatalf("expected radius raised to %f got %f", minTrendRadiusKm, capturedRadius)
+	}
+}
+
+func TestGetMoodTrendsHandler_BadRequests(t *testing.T) {
+	for _, target := range []string{
+		"/insights/trends?lon=-123.1",
+		"/insights/trends?lat=91&lon=-123.1",
+		"/insights/trends?lat=49.2&lon=-123.1&radius_km=-1",
+		"/insights/trends?lat=49.2&lon=-123.1&bucket=month",
+	} {
+		req := authedRequest(http.MethodGet, target, "", uuid.New())
+		rec := httptest.NewRecorder()
+
+		GetMoodTrendsHandler(&mockInsightRepo{})(rec, req)
+
+		if rec.Code != http.StatusBadRequest {
+			t.Fatalf("%s: expected status %d got %d", target, http.StatusBadRequest, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"ember/api/dtos"
+	"ember/api/models"
+	"ember/api/repositories"
+
+	"github.com/google/uuid"
+)
+
+// notify records a notification. Failing to notify never fails the action
+// that caused it, so errors are only logged.
+func notify(notificationRepo repositories.NotificationRepository, n repositories.NewNotification) {
+	if n.RecipientID == n.ActorID {
+		return
+	}
+	if _, err := notificationRepo.CreateNotification(n); err != nil {
+		log.Printf("create %s notification for %s: %v", n.Type, n.RecipientID, err)
+	}
+}
+
+func toNotificationDTO(n models.Notification) dtos.Notification {
+	notification := dtos.Notification{
+		ID:        n.ID,
+		Type:      n.Type,
+		Data:      n.Data,
+		CreatedAt: n.CreatedAt,
+	}
+	if n.Actor != nil {
+		notification.Actor = &dtos.NotificationActor{
+			ID:       n.Actor.ID,
+			Username: n.Actor.Username,
+		}
+		if n.Actor.DisplayName.Valid {
+			notification.Actor.DisplayName = n.Actor.DisplayName.String
+		}
+	}
+	if n.PinID.Valid {
+		notification.PinID = &n.PinID.UUID
+	}
+	if n.ReadAt.Valid {
+		notification.ReadAt = &n.ReadAt.Time
+	}
+	return notification
+}
+
+// GET /me/notifications?limit=&cursor=
+func GetNotificationsHandler(notificationRepo repositories.NotificationRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("userID").(uuid.UUID)
+
+		page, err := parsePage(r, defaultPageSize, maxPageSize)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		notifications, err := notificationRepo.ListNotifications(userID, page)
+		if err != nil {
+			log.Println("list notifications:", err)
+			http.Error(w, "unable to fetch notifications", http.StatusInternalServerError)
+			return
+		}
+
+		unread, err := notificationRepo.CountUnreadNotifications(userID)
+		if err != nil {
+			log.Println("count unread notifications:", err)
+			http.Error(w, "unable to fetch notifications", http.StatusInternalServerError)
+			return
+		}
+
+		resp := dtos.GetNotificationsResponse{UnreadCount: unread}
+		resp.Notifications, resp.NextCursor = paginate(notifications, page, toNotificationDTO, func(n models.Notification) repositories.Cursor {
+			return repositories.Cursor{CreatedAt: n.CreatedAt, ID: n.ID}
+		})
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.Println("encode notifications response:", err)
+		}
+	}
+}
+
+// POST /me/notifications/read
+func PostNotificationsReadHandler(notificationRepo repositories.NotificationRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("userID").(uuid.UUID)
+
+		var req dtos.MarkNotificationsReadRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+
+		var marked int64
+		var err error
+		if req.All {
+			marked, err = notificationRepo.MarkAllNotificationsRead(userID)
+		} else {
+			marked, err = notificationRepo.MarkNotificationsRead(userID, req.IDs)
+		}
+		if err != nil {
+			log.Println("mark notifications read:", err)
+			http.Error(w, "unable to mark notifications read", http.StatusInternalServerError)
+			return
+		}
+
+		unread, err := notificationRepo.CountUnreadNotifications(userID)
+		if err != nil {
+			log.Println("count unread notifications:", err)
+			http.Error(w, "unable to mark notifications read", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(dtos.MarkNotificationsReadResponse{Marked: marked, UnreadCount: unread}); err != nil {
+			log.Println("encode mark notifications read response:", err)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ember/api/dtos"
+	"ember/api/models"
+	"ember/api/repositories"
+
+	"github.com/google/uuid"
+)
+
+func TestPostFriendRequestsHandler_NotifiesRecipient(t *testing.T) {
+	userID, friendID := uuid.New(), uuid.New()
+	repo := &mockUserRepo{
+		createFriendRequestFn: func(u uuid.UUID, f uuid.UUID) (bool, error) {
+			return true, nil
+		},
+	}
+	notifications := &mockNotificationRepo{}
+
+	req := authedRequest(http.MethodPost, "/friends/requests/"+friendID.String(), "", userID, "friendID", friendID.String())
+	rec := httptest.NewRecorder()
+
+	PostFriendRequestsHandler(repo, notifications)(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d got %d", http.StatusOK, rec.Code)
+	}
+	if len(notifications.created) != 1 {
+		t.Fatalf("expected one notification, got %d", len(notifications.created))
+	}
+	n := notifications.created[0]
+	if n.Type != models.NotificationFriendRequest || n.RecipientID != friendID || n.ActorID != userID {
+		t.Fatalf("unexpected notification %+v", n)
+	}
+}
+
+func TestPatchFriendRequestsHandler_AcceptNotifiesRequester(t *testing.T) {
+	userID, requesterID := uuid.New(), uuid.New()
+	repo := &mockUserRepo{
+		acceptFriendRequestFn: func(u uuid.UUID, r uuid.UUID) (bool, error) {
+			return true, nil
+		},
+		rejectFriendRequestFn: func(u uuid.UUID, r uuid.UUID) (bool, error) {
+			return true, nil
+		},
+	}
+	notifications := &mockNotificationRepo{}
+
+	for _, status := range []string{"accepted", "rejected"} {
+		req := authedRequest(http.MethodPatch, "/friends/requests/"+requesterID.String(), `{"status":"`+status+`"}`, userID, "friendID", requesterID.String())
+		rec := httptest.NewRecorder()
+
+		PatchFriendRequestsHandler(repo, notifications)(rec, req)
+
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected status %d got %d", status, http.StatusOK, rec.Code)
+		}
+	}
+
+	// Rejections are silent
+	if len(notifications.created) != 1 {
+		t.Fatalf("expected one notification, got %d", len(notifications.created))
+	}
+	n := notifications.created[0]
+	if n.Type != models.NotificationFriendAccepted || n.RecipientID != requesterID || n.ActorID != userID {
+		t.Fatalf("unexpected notification %+v", n)
+	}
+}
+
+func TestGetNotificationsHandler_Success(t *testing.T) {
+	userID, actorID, pinID := uuid.New(), uuid.New(), uuid.New()
+	notifications := &mockNotificationRepo{
+		listFn: func(id uuid.UUID, page repositories.Page) ([]models.Notification, error) {
+			if id != userID || page.Limit != defaultPageSize {
+				t.Fatalf("unexpected arguments %s %+v", id, page)
+			}
+			return []models.Notification{
+				{
+					ID:        uuid.New(),
+					Type:      models.NotificationPinReaction,
+					Actor:     &models.User{ID: actorID, Username: "bob"},
+					PinID:     uuid.NullUUID{UUID: pinID, Valid: true},
+					Data:      json.RawMessage(`{"emoji":"🔥"}`),
+					CreatedAt: time.Now(),
+				},
+			}, nil
+		},
+		countUnreadFn: func(id uuid.UUID) (int, error) {
+			return 3, nil
+		},
+	}
+
+	req := authedRequest(http.MethodGet, "/me/notifications", "", userID)
+	rec := httptest.NewRecorder()
+
+	GetNotificationsHandler(notifications)(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d got %d", http.StatusOK, rec.Code)
+	}
+
+	var resp dtos.GetNotificationsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.UnreadCount != 3 || len(resp.Notifications) != 1 || resp.NextCursor != nil {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+	n := resp.Notifications[0]
+	if n.Actor == nil || n.Actor.ID != actorID || n.PinID == nil || *n.PinID != pinID || n.ReadAt != nil || string(n.Data) != `{"emoji":"🔥"}` {
+		t.Fatalf("unexpected notification: %+v", n)
+	}
+}
+
+func TestPostNotificationsReadHandler(t *testing.T) {
+	userID := uuid.New()
+	ids := []uuid.UUID{uuid.New(), uuid.New()}
+	var markedIDs []uuid.UUID
+	notifications := &mockNotificationRepo{
+		markReadFn: func(id uuid.UUID, given []uuid.UUID) (int64, error) {
+			markedIDs = given
+			return int64(len(given)), nil
+		},
+		countUnreadFn: func(id uuid.UUID) (int, error) {
+			return 1, nil
+		},
+	}
+
+	body, _ := json.Marshal(dtos.MarkNotificationsReadRequest{IDs: ids})
+	req := authedRequest(http.MethodPost, "/me/notifications/read", string(body), userID)
+	rec := httptest.NewRecorder()
+
+	PostNotificationsReadHandler(notifications)(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d got %d", http.StatusOK, rec.Code)
+	}
+	var resp dtos.MarkNotificationsReadResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Marked != 2 || resp.UnreadCount != 1 || len(markedIDs) != 2 {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+}
+
+func TestPostNotificationsReadHandler_Invalid(t *testing.T) {
+	for _, body := range []string{`{}`, `{"all":true,"ids":["` + uuid.New().String() + `"]}`} {
+		req := authedRequest(http.MethodPost, "/me/notifications/read", body, uuid.New())
+		rec := httptest.NewRecorder()
+
+		PostNotificationsReadHandler(&mockNotificationRepo{})(rec, req)
+
+		if rec.Code != http.StatusUnprocessableEntity {
+			t.Fatalf("%s: expected status %d got %d", body, http.StatusUnprocessableEntity, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"ember/api/repositories"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// encodeCursor makes a cursor opaque to clients; they only ever echo it back.
+func encodeCursor(c repositories.Cursor) string {
+	raw, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(s string) (*repositories.Cursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+
+	var c repositories.Cursor
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return nil, errInvalidCursor
+	}
+	return &c, nil
+}
+
+// parsePage reads the limit and cursor query parameters of a list endpoint.
+func parsePage(r *http.Request, def int, max int) (repositories.Page, error) {
+	query := r.URL.Query()
+
+	limit, err := parseLimit(query.Get("limit"), def, max)
+	if err != nil {
+		return repositories.Page{}, err
+	}
+
+	page := repositories.Page{Limit: limit}
+	if raw := query.Get("cursor"); raw != "" {
+		page.After, err = decodeCursor(raw)
+		if err != nil {
+			return repositories.Page{}, err
+		}
+	}
+	return page, nil
+}
+
+// paginate converts one page of repository rows to DTOs. Repositories fetch
+// one row past page.Limit; if it is there, it is dropped and the cursor of the
+// last kept row is returned as the next cursor, otherwise the next cursor is nil.
+func paginate[T any, D any](rows []T, page repositories.Page, toDTO func(T) D, cursorOf func(T) repositories.Cursor) ([]D, *string) {
+	var next *string
+	if len(rows) > page.Limit {
+		rows = rows[:page.Limit]
+		cursor := encodeCursor(cursorOf(rows[len(rows)-1]))
+		next = &cursor
+	}
+
+	dtos := make([]D, 0, len(rows))
+	for _, row := range rows {
+		dtos = append(dtos, toDTO(row))
+	}
+	return dtos, next
+}
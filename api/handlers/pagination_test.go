@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ember/api/dtos"
+	"ember/api/models"
+	"ember/api/repositories"
+
+	"github.com/google/uuid"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	want := repositories.Cursor{
+		CreatedAt: time.Date(2026, 5, 1, 12, 30, 0, 123456000, time.UTC),
+		Score:     1.2345678901234567,
+		ID:        uuid.New(),
+	}
+
+	got, err := decodeCursor(encodeCursor(want))
+	if err != nil {
+		t.Fatalf("decode cursor: %v", err)
+	}
+	if !got.CreatedAt.Equal(want.CreatedAt) || got.Score != want.Score || got.ID != want.ID {
+		t.Fatalf("expected %+v got %+v", want, *got)
+	}
+}
+
+func TestGetPinsMeHandler_Paginates(t *testing.T) {
+	userID := uuid.New()
+	base := time.Now().UTC()
+	pins := []models.Pin{
+		{ID: uuid.New(), Emotion: "happy", CreatedAt: base},
+		{ID: uuid.New(), Emotion: "calm", CreatedAt: base.Add(-time.Minute)},
+		{ID: uuid.New(), Emotion: "sad", CreatedAt: base.Add(-2 * time.Minute)},
+	}
+
+	var pages []repositories.Page
+	pinRepo := &mockPinRepo{
+		queryUserPinsFn: func(id uuid.UUID, page repositories.Page) ([]models.Pin, error) {
+			pages = append(pages, page)
+			if page.After == nil {
+				return pins, nil // one more than the limit of 2
+			}
+			return pins[2:], nil
+		},
+	}
+
+	req := authedRequest(http.MethodGet, "/pins/me?limit=2", "", userID)
+	rec := httptest.NewRecorder()
+	GetPinsMeHandler(pinRepo)(rec, req)
+
+	var first dtos.GetPinListResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &first); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(first.Pins) != 2 || first.NextCursor == nil {
+		t.Fatalf("expected 2 pins and a next cursor, got %+v", first)
+	}
+
+	req = authedRequest(http.MethodGet, "/pins/me?limit=2&cursor="+*first.NextCursor, "", userID)
+	rec = httptest.NewRecorder()
+	GetPinsMeHandler(pinRepo)(rec, req)
+
+	var second dtos.GetPinListResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &second); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(second.Pins) != 1 || second.Pins[0].ID != pins[2].ID || second.NextCursor != nil {
+		t.Fatalf("unexpected last page: %+v", second)
+	}
+
+	after := pages[1].After
+	if after == nil || after.ID != pins[1].ID || !after.CreatedAt.Equal(pins[1].CreatedAt) {
+		t.Fatalf("expected the second request to resume after the second pin, got %+v", after)
+	}
+}
+
+func TestGetPinsFriendsHandler_InvalidCursor(t *testing.T) {
+	req := authedRequest(http.MethodGet, "/pins/friends?cursor=not-a-cursor!", "", uuid.New())
+	rec := httptest.NewRecorder()
+
+	GetPinsFriendsHandler(&mockPinRepo{})(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestGetFriendsHandler_NextCursor(t *testing.T) {
+	repo := &mockUserRepo{
+		getFriendsByUUIDFn: func(id uuid.UUID, page repositories.Page) ([]models.User, error) {
+			if page.Limit != 1 {
+				t.Fatalf("expected limit 1 got %d", page.Limit)
+			}
+			return []models.User{{ID: uuid.New(), Username: "alice"}, {ID: uuid.New(), Username: "bob"}}, nil
+		},
+	}
+
+	req := authedRequest(http.MethodGet, "/friends?limit=1", "", uuid.New())
+	rec := httptest.NewRecorder()
+
+	GetFriendsHandler(repo)(rec, req)
+
+	var resp dtos.GetFriendsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.Friends) != 1 || resp.NextCursor == nil {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+
+	cursor, err := decodeCursor(*resp.NextCursor)
+	if err != nil || cursor.Username != "alice" {
+		t.Fatalf("expected cursor after alice, got %+v (%v)", cursor, err)
+	}
+}
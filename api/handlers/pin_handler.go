@@ -2,42 +2,147 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"strconv"
+	"time"
 
 	"ember/api/dtos"
+	"ember/api/models"
 	"ember/api/repositories"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 )
 
 const maxNearbyRadiusKm = 25.0
 
+const (
+	// maxBBoxAreaKm2 keeps viewport queries to roughly city scale; zoomed-out maps should cluster instead.
+	maxBBoxAreaKm2  = 10000.0
+	defaultPinLimit = 200
+	maxPinLimit     = 500
+)
+
+// maxPinLifetime caps how long a pin lives after creation, per visibility.
+// Visibilities without an entry may live forever.
+var maxPinLifetime = map[string]time.Duration{
+	"public":  7 * 24 * time.Hour,
+	"friends": 30 * 24 * time.Hour,
+}
+
+// capPinExpiry clamps the requested expiry to the visibility's maximum
+// lifetime counted from createdAt. A nil request means "as long as allowed".
+func capPinExpiry(visibility string, requested *time.Time, createdAt time.Time) *time.Time {
+	maxLifetime, ok := maxPinLifetime[visibility]
+	if !ok {
+		return requested
+	}
+
+	latest := createdAt.Add(maxLifetime)
+	if requested == nil || requested.After(latest) {
+		return &latest
+	}
+	return requested
+}
+
+// requestedPinExpiry turns the TTL or absolute expiry of a validated create request into a time.
+func requestedPinExpiry(req dtos.CreatePinRequest, now time.Time) *time.Time {
+	if req.TTLSeconds != nil {
+		expiresAt := now.Add(time.Duration(*req.TTLSeconds) * time.Second)
+		return &expiresAt
+	}
+	return req.ExpiresAt
+}
+
+func toPinDTO(pin models.Pin) dtos.Pin {
+	p := dtos.Pin{
+		ID:         pin.ID,
+		UserID:     pin.UserID,
+		Emotion:    pin.Emotion,
+		Longitude:  pin.Location.Longitude,
+		Latitude:   pin.Location.Latitude,
+		Visibility: pin.Visibility,
+		CreatedAt:  pin.CreatedAt,
+		Precision:  pin.Precision,
+	}
+	if pin.PrecisionSetting.Valid {
+		p.LocationPrecision = &pin.PrecisionSetting.String
+	}
+	if pin.Message.Valid {
+		p.Message = pin.Message.String
+	}
+	if pin.ExpiresAt.Valid {
+		p.ExpiresAt = &pin.ExpiresAt.Time
+	}
+	return p
+}
+
+// withPinDetails fills in the attachments, reactions and comment counts of
+// pins about to be sent to userID.
+func withPinDetails(pinRepo repositories.PinRepository, userID uuid.UUID, pins []dtos.Pin) error {
+	ids := make([]uuid.UUID, 0, len(pins))
+	for _, pin := range pins {
+		ids = append(ids, pin.ID)
+	}
+
+	engagement, err := pinRepo.QueryPinEngagement(userID, ids)
+	if err != nil {
+		return err
+	}
+	attachments, err := pinRepo.QueryPinAttachments(ids)
+	if err != nil {
+		return err
+	}
+	for i := range pins {
+		for _, a := range attachments[pins[i].ID] {
+			pins[i].Attachments = append(pins[i].Attachments, toAttachmentDTO(a))
+		}
+		e := engagement[pins[i].ID]
+		pins[i].Reactions = e.Reactions
+		pins[i].MyReactions = e.MyReactions
+		pins[i].CommentCount = e.Comments
+	}
+	return nil
+}
+
+// pinResponse is the DTO of a single pin sent to userID, details included.
+func pinResponse(pinRepo repositories.PinRepository, userID uuid.UUID, pin models.Pin) (dtos.Pin, error) {
+	pins := []dtos.Pin{toPinDTO(pin)}
+	err := withPinDetails(pinRepo, userID, pins)
+	return pins[0], err
+}
+
+// pinCursor is the cursor of a pin in a newest-first list.
+func pinCursor(pin models.Pin) repositories.Cursor {
+	return repositories.Cursor{CreatedAt: pin.CreatedAt, ID: pin.ID}
+}
+
+// GET /pins/friends?limit=&cursor=
 func GetPinsFriendsHandler(pinRepo repositories.PinRepository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		userID := r.Context().Value("userID").(uuid.UUID)
 
-		pins, err := pinRepo.QueryFriendPins(userID)
+		page, err := parsePage(r, defaultPageSize, maxPageSize)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		pins, err := pinRepo.QueryFriendPins(userID, page)
 		if err != nil {
 			log.Println("query friend pins:", err)
 			http.Error(w, "unable to fetch pins", http.StatusInternalServerError)
 			return
 		}
 
-		resp := dtos.GetPinListResponse{Pins: make([]dtos.Pin, 0, len(pins))}
-		for _, pin := range pins {
-			p := dtos.Pin{
-				UserID:    pin.UserID,
-				Emotion:   pin.Emotion,
-				Longitude: pin.Location.Longitude,
-				Latitude:  pin.Location.Latitude,
-				CreatedAt: pin.CreatedAt,
-			}
-			if pin.Message.Valid {
-				p.Message = pin.Message.String
-			}
-			resp.Pins = append(resp.Pins, p)
+		var resp dtos.GetPinListResponse
+		resp.Pins, resp.NextCursor = paginate(pins, page, toPinDTO, pinCursor)
+		if err := withPinDetails(pinRepo, userID, resp.Pins); err != nil {
+			log.Println("query pin details:", err)
+			http.Error(w, "unable to fetch pins", http.StatusInternalServerError)
+			return
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -47,7 +152,8 @@ func GetPinsFriendsHandler(pinRepo repositories.PinRepository) http.HandlerFunc
 	}
 }
 
-func GetPinsNearbyHandler(pinRepo repositories.PinRepository) http.HandlerFunc {
+// GET /pins/nearby?longitude=&latitude=&radius_km=&sort=recent|distance|relevance&limit=&cursor=&since=&emotion=
+func GetPinsNearbyHandler(pinRepo repositories.PinRepository, emotionRepo repositories.EmotionRepository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		query := r.URL.Query()
 		required := map[string]string{
@@ -88,27 +194,80 @@ func GetPinsNearbyHandler(pinRepo repositories.PinRepository) http.HandlerFunc {
 			radiusKm = maxNearbyRadiusKm
 		}
 
+		nearby := repositories.NearbyPinQuery{
+			Longitude: longitude,
+			Latitude:  latitude,
+			RadiusKm:  radiusKm,
+			Sort:      repositories.PinSortRecent,
+		}
+
+		switch sort := query.Get("sort"); sort {
+		case "":
+		case repositories.PinSortRecent, repositories.PinSortDistance, repositories.PinSortRelevance:
+			nearby.Sort = sort
+		default:
+			http.Error(w, "sort must be one of recent, distance, relevance", http.StatusBadRequest)
+			return
+		}
+
+		nearby.Page, err = parsePage(r, defaultPinLimit, maxPinLimit)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		nearby.AsOf = time.Now()
+		if nearby.Page.After != nil && !nearby.Page.After.AsOf.IsZero() {
+			nearby.AsOf = nearby.Page.After.AsOf
+		}
+
+		if raw := query.Get("since"); raw != "" {
+			since, err := time.Parse(time.RFC3339, raw)
+			if err != nil {
+				http.Error(w, "since must be an RFC 3339 timestamp", http.StatusBadRequest)
+				return
+			}
+			nearby.Since = &since
+		}
+
+		if raw := query.Get("emotion"); raw != "" {
+			emotion, ok := resolveEmotion(w, emotionRepo, raw)
+			if !ok {
+				return
+			}
+			nearby.Emotion = emotion
+		}
+
 		userID := r.Context().Value("userID").(uuid.UUID)
-		pins, err := pinRepo.QueryNearbyPins(userID, longitude, latitude, radiusKm)
+		pins, err := pinRepo.QueryNearbyPins(userID, nearby)
 		if err != nil {
 			log.Println("query nearby pins:", err)
 			http.Error(w, "unable to fetch pins", http.StatusInternalServerError)
 			return
 		}
 
-		resp := dtos.GetPinListResponse{Pins: make([]dtos.Pin, 0, len(pins))}
-		for _, pin := range pins {
-			p := dtos.Pin{
-				UserID:    pin.UserID,
-				Emotion:   pin.Emotion,
-				Longitude: pin.Location.Longitude,
-				Latitude:  pin.Location.Latitude,
-				CreatedAt: pin.CreatedAt,
-			}
-			if pin.Message.Valid {
-				p.Message = pin.Message.String
+		toDTO := func(pin models.NearbyPin) dtos.Pin {
+			p := toPinDTO(pin.Pin)
+			p.DistanceM = &pin.DistanceM
+			p.BearingDeg = &pin.BearingDeg
+			return p
+		}
+		cursorOf := func(pin models.NearbyPin) repositories.Cursor {
+			c := repositories.Cursor{CreatedAt: pin.CreatedAt, AsOf: nearby.AsOf, ID: pin.ID}
+			switch nearby.Sort {
+			case repositories.PinSortDistance:
+				c.Score = pin.DistanceM
+			case repositories.PinSortRelevance:
+				c.Score = pin.Relevance
 			}
-			resp.Pins = append(resp.Pins, p)
+			return c
+		}
+
+		var resp dtos.GetPinListResponse
+		resp.Pins, resp.NextCursor = paginate(pins, nearby.Page, toDTO, cursorOf)
+		if err := withPinDetails(pinRepo, userID, resp.Pins); err != nil {
+			log.Println("query pin details:", err)
+			http.Error(w, "unable to fetch pins", http.StatusInternalServerError)
+			return
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -118,30 +277,79 @@ func GetPinsNearbyHandler(pinRepo repositories.PinRepository) http.HandlerFunc {
 	}
 }
 
+// GET /pins?bbox=minLon,minLat,maxLon,maxLat&limit=
+func GetPinsHandler(pinRepo repositories.PinRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if query.Get("bbox") == "" {
+			http.Error(w, "missing required query parameter: bbox", http.StatusBadRequest)
+			return
+		}
+
+		bbox, err := parseBBox(query.Get("bbox"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if bbox.AreaKm2() > maxBBoxAreaKm2 {
+			http.Error(w, "bbox is too large; zoom in", http.StatusBadRequest)
+			return
+		}
+
+		limit, err := parseLimit(query.Get("limit"), defaultPinLimit, maxPinLimit)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		userID := r.Context().Value("userID").(uuid.UUID)
+		pins, err := pinRepo.QueryPinsInBBox(userID, bbox, limit)
+		if err != nil {
+			log.Println("query pins in bbox:", err)
+			http.Error(w, "unable to fetch pins", http.StatusInternalServerError)
+			return
+		}
+
+		resp := dtos.GetPinListResponse{Pins: make([]dtos.Pin, 0, len(pins))}
+		for _, pin := range pins {
+			resp.Pins = append(resp.Pins, toPinDTO(pin))
+		}
+		if err := withPinDetails(pinRepo, userID, resp.Pins); err != nil {
+			log.Println("query pin details:", err)
+			http.Error(w, "unable to fetch pins", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.Println("encode bbox pins response:", err)
+		}
+	}
+}
+
 func GetPinsMeHandler(pinRepo repositories.PinRepository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		userID := r.Context().Value("userID").(uuid.UUID)
 
-		pins, err := pinRepo.QueryUserPins(userID)
+		page, err := parsePage(r, defaultPageSize, maxPageSize)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		pins, err := pinRepo.QueryUserPins(userID, page)
 		if err != nil {
 			log.Println("query user pins:", err)
 			http.Error(w, "unable to fetch pins", http.StatusInternalServerError)
 			return
 		}
 
-		resp := dtos.GetPinListResponse{Pins: make([]dtos.Pin, 0, len(pins))}
-		for _, pin := range pins {
-			p := dtos.Pin{
-				UserID:    pin.UserID,
-				Emotion:   pin.Emotion,
-				Longitude: pin.Location.Longitude,
-				Latitude:  pin.Location.Latitude,
-				CreatedAt: pin.CreatedAt,
-			}
-			if pin.Message.Valid {
-				p.Message = pin.Message.String
-			}
-			resp.Pins = append(resp.Pins, p)
+		var resp dtos.GetPinListResponse
+		resp.Pins, resp.NextCursor = paginate(pins, page, toPinDTO, pinCursor)
+		if err := withPinDetails(pinRepo, userID, resp.Pins); err != nil {
+			log.Println("query pin details:", err)
+			http.Error(w, "unable to fetch pins", http.StatusInternalServerError)
+			return
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -152,21 +360,228 @@ func GetPinsMeHandler(pinRepo repositories.PinRepository) http.HandlerFunc {
 }
 
 // POST /pins
-func PostPinsHandler(pinRepo repositories.PinRepository) http.HandlerFunc {
+func PostPinsHandler(pinRepo repositories.PinRepository, emotionRepo repositories.EmotionRepository, notificationRepo repositories.NotificationRepository, userRepo repositories.UserRepository, zoneRepo repositories.ZoneRepository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		userID := r.Context().Value("userID").(uuid.UUID)
 
 		var req dtos.CreatePinRequest
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-			http.Error(w, "invalid request body", http.StatusBadRequest)
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+
+		emotion, ok := resolveEmotion(w, emotionRepo, req.Emotion)
+		if !ok {
 			return
 		}
 
-		if err := pinRepo.CreatePin(userID, req.Emotion, req.Message, req.Longitude, req.Latitude, req.Visibility); err != nil {
+		visibility, precision, err := applyPrivateZones(userRepo, zoneRepo, userID, req.Longitude, req.Latitude, req.Visibility, req.LocationPrecision)
+		if err != nil {
+			log.Println("apply private zones:", err)
+			http.Error(w, "unable to create pin", http.StatusInternalServerError)
+			return
+		}
+
+		now := time.Now()
+		expiresAt := capPinExpiry(visibility, requestedPinExpiry(req, now), now)
+
+		pin, err := pinRepo.CreatePin(userID, emotion, req.Message, req.Longitude, req.Latitude, visibility, precision, expiresAt)
+		if err != nil || pin == nil {
+			log.Println("create pin:", err)
 			http.Error(w, "unable to create pin", http.StatusInternalServerError)
 			return
 		}
+		indexPinMessage(pinRepo, notificationRepo, userID, *pin)
 
+		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusCreated)
+		if err := json.NewEncoder(w).Encode(toPinDTO(*pin)); err != nil {
+			log.Println("encode created pin response:", err)
+		}
+	}
+}
+
+// GET /pins/{pinID}
+func GetPinHandler(pinRepo repositories.PinRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("userID").(uuid.UUID)
+
+		pinID, err := uuid.Parse(chi.URLParam(r, "pinID"))
+		if err != nil {
+			http.Error(w, "invalid pin ID", http.StatusBadRequest)
+			return
+		}
+
+		pin, err := pinRepo.GetPin(userID, pinID)
+		if err != nil {
+			log.Println("get pin:", err)
+			http.Error(w, "unable to fetch pin", http.StatusInternalServerError)
+			return
+		}
+		if pin == nil {
+			http.Error(w, "pin does not exist", http.StatusNotFound)
+			return
+		}
+
+		resp, err := pinResponse(pinRepo, userID, *pin)
+		if err != nil {
+			log.Println("query pin details:", err)
+			http.Error(w, "unable to fetch pin", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.Println("encode pin response:", err)
+		}
+	}
+}
+
+// PATCH /pins/{pinID}
+func PatchPinHandler(pinRepo repositories.PinRepository, emotionRepo repositories.EmotionRepository, notificationRepo repositories.NotificationRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("userID").(uuid.UUID)
+
+		pinID, err := uuid.Parse(chi.URLParam(r, "pinID"))
+		if err != nil {
+			http.Error(w, "invalid pin ID", http.StatusBadRequest)
+			return
+		}
+
+		var req dtos.UpdatePinRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+
+		if req.Emotion != nil {
+			emotion, ok := resolveEmotion(w, emotionRepo, *req.Emotion)
+			if !ok {
+				return
+			}
+			req.Emotion = &emotion
+		}
+
+		update := repositories.PinUpdate{
+			Emotion:    req.Emotion,
+			Message:    req.Message,
+			Visibility: req.Visibility,
+			Precision:  req.LocationPrecision,
+		}
+
+		// Changing the visibility or expiry must keep the pin within its new lifetime cap.
+		if req.Visibility != nil || req.ExpiresAt != nil {
+			current, err := pinRepo.GetPin(userID, pinID)
+			if err != nil {
+				log.Println("get pin:", err)
+				http.Error(w, "unable to update pin", http.StatusInternalServerError)
+				return
+			}
+			if current == nil {
+				http.Error(w, "pin does not exist", http.StatusNotFound)
+				return
+			}
+
+			visibility := current.Visibility
+			if req.Visibility != nil {
+				visibility = *req.Visibility
+			}
+			var requested *time.Time
+			switch {
+			case req.ExpiresAt != nil && !req.ExpiresAt.IsZero():
+				requested = &req.ExpiresAt.Time
+			case req.ExpiresAt == nil && current.ExpiresAt.Valid:
+				requested = &current.ExpiresAt.Time
+			}
+			update.ExpiresAt = capPinExpiry(visibility, requested, current.CreatedAt)
+			update.ClearExpiry = update.ExpiresAt == nil
+		}
+
+		pin, err := pinRepo.UpdatePin(userID, pinID, update)
+		if err != nil {
+			writePinOwnerError(w, err, "unable to update pin")
+			return
+		}
+		if pin == nil {
+			// The update succeeded but the pin is no longer readable, e.g. it just expired.
+			http.Error(w, "pin does not exist", http.StatusNotFound)
+			return
+		}
+		// Friends mentioned while the pin was private are notified once they can see it
+		if req.Message != nil || req.Visibility != nil {
+			indexPinMessage(pinRepo, notificationRepo, userID, *pin)
+		}
+
+		resp, err := pinResponse(pinRepo, userID, *pin)
+		if err != nil {
+			log.Println("query pin details:", err)
+			http.Error(w, "unable to fetch pin", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.Println("encode updated pin response:", err)
+		}
+	}
+}
+
+// DELETE /pins/{pinID}
+func DeletePinHandler(pinRepo repositories.PinRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("userID").(uuid.UUID)
+
+		pinID, err := uuid.Parse(chi.URLParam(r, "pinID"))
+		if err != nil {
+			http.Error(w, "invalid pin ID", http.StatusBadRequest)
+			return
+		}
+
+		if err := pinRepo.DeletePin(userID, pinID); err != nil {
+			writePinOwnerError(w, err, "unable to delete pin")
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// indexPinMessage indexes the hashtags in a newly written pin message and
+// notifies the friends it newly mentions, if they can see the pin. Failures
+// are only logged; the pin itself is already saved.
+func indexPinMessage(pinRepo repositories.PinRepository, notificationRepo repositories.NotificationRepository, userID uuid.UUID, pin models.Pin) {
+	mentioned, err := pinRepo.SavePinMessageRefs(pin.ID, models.ParseMessage(pin.Message.String))
+	if err != nil {
+		log.Println("save pin message refs:", err)
+		return
+	}
+
+	for _, mentionedID := range mentioned {
+		visible, err := pinRepo.GetPin(mentionedID, pin.ID)
+		if err != nil {
+			log.Println("get pin:", err)
+			continue
+		}
+		if visible == nil {
+			continue
+		}
+		notify(notificationRepo, repositories.NewNotification{
+			RecipientID: mentionedID,
+			ActorID:     userID,
+			Type:        models.NotificationPinMention,
+			PinID:       &pin.ID,
+			Data:        map[string]any{"preview": commentPreview(pin.Message.String)},
+		})
+	}
+}
+
+// writePinOwnerError maps the errors of owner-only pin operations to a response.
+func writePinOwnerError(w http.ResponseWriter, err error, fallback string) {
+	switch {
+	case errors.Is(err, repositories.ErrPinNotFound):
+		http.Error(w, "pin does not exist", http.StatusNotFound)
+	case errors.Is(err, repositories.ErrNotPinOwner):
+		http.Error(w, "only the pin's owner can modify it", http.StatusForbidden)
+	default:
+		log.Println(fallback+":", err)
+		http.Error(w, fallback, http.StatusInternalServerError)
 	}
 }
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"ember/api/dtos"
+	"ember/api/models"
+	"ember/api/repositories"
+
+	"github.com/google/uuid"
+)
+
+func writeLocationPrivacy(w http.ResponseWriter, privacy models.LocationPrivacy) {
+	w.Header().Set("Content-Type", "application/json")
+	resp := dtos.LocationPrivacy{
+		FriendsPrecision: privacy.FriendsPrecision,
+		PublicPrecision:  privacy.PublicPrecision,
+	}
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Println("encode location privacy response:", err)
+	}
+}
+
+// GET /me/location-privacy
+func GetLocationPrivacyHandler(userRepo repositories.UserRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("userID").(uuid.UUID)
+
+		privacy, err := userRepo.GetLocationPrivacy(userID)
+		if err != nil {
+			log.Println("get location privacy:", err)
+			http.Error(w, "unable to fetch location privacy", http.StatusInternalServerError)
+			return
+		}
+		if privacy == nil {
+			http.Error(w, "user not found", http.StatusNotFound)
+			return
+		}
+
+		writeLocationPrivacy(w, *privacy)
+	}
+}
+
+// PUT /me/location-privacy
+func PutLocationPrivacyHandler(userRepo repositories.UserRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("userID").(uuid.UUID)
+
+		var req dtos.LocationPrivacy
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+
+		privacy := models.LocationPrivacy{
+			FriendsPrecision: req.FriendsPrecision,
+			PublicPrecision:  req.PublicPrecision,
+		}
+		if err := userRepo.SaveLocationPrivacy(userID, privacy); err != nil {
+			log.Println("save location privacy:", err)
+			http.Error(w, "unable to save location privacy", http.StatusInternalServerError)
+			return
+		}
+
+		writeLocationPrivacy(w, privacy)
+	}
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"ember/api/dtos"
+	"ember/api/models"
+
+	"github.com/google/uuid"
+)
+
+func TestGetLocationPrivacyHandler_Defaults(t *testing.T) {
+	rec := httptest.NewRecorder()
+	GetLocationPrivacyHandler(&mockUserRepo{})(rec, authedRequest(http.MethodGet, "/me/location-privacy", "", uuid.New()))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d got %d", http.StatusOK, rec.Code)
+	}
+	var resp dtos.LocationPrivacy
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.FriendsPrecision != models.Precision100m || resp.PublicPrecision != models.Precision1km {
+		t.Fatalf("unexpected privacy %+v", resp)
+	}
+}
+
+func TestPutLocationPrivacyHandler_Saves(t *testing.T) {
+	userID := uuid.New()
+	userRepo := &mockUserRepo{}
+
+	rec := httptest.NewRecorder()
+	PutLocationPrivacyHandler(userRepo)(rec, authedRequest(http.MethodPut, "/me/location-privacy", `{"friends_precision":"exact","public_precision":"city"}`, userID))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	saved := userRepo.privacy[userID]
+	if saved.FriendsPrecision != models.PrecisionExact || saved.PublicPrecision != models.PrecisionCity {
+		t.Fatalf("unexpected saved privacy %+v", saved)
+	}
+}
+
+func TestPutLocationPrivacyHandler_RejectsUnknownPrecision(t *testing.T) {
+	userRepo := &mockUserRepo{}
+
+	rec := httptest.NewRecorder()
+	PutLocationPrivacyHandler(userRepo)(rec, authedRequest(http.MethodPut, "/me/location-privacy", `{"friends_precision":"street","public_precision":"city"}`, uuid.New()))
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status %d got %d", http.StatusUnprocessableEntity, rec.Code)
+	}
+	if len(userRepo.privacy) != 0 {
+		t.Fatalf("invalid privacy was saved")
+	}
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"ember/api/dtos"
+	"ember/api/models"
+	"ember/api/repositories"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// POST /me/devices
+func PostDevicesHandler(pushRepo repositories.PushRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("userID").(uuid.UUID)
+
+		var req dtos.RegisterDeviceRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+
+		if err := pushRepo.RegisterDevice(userID, req.Platform, req.Token); err != nil {
+			log.Println("register device:", err)
+			http.Error(w, "unable to register device", http.StatusInternalServerError)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// DELETE /me/devices/{token}
+func DeleteDeviceHandler(pushRepo repositories.PushRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("userID").(uuid.UUID)
+
+		deleted, err := pushRepo.DeleteDevice(userID, chi.URLParam(r, "token"))
+		if err != nil {
+			log.Println("delete device:", err)
+			http.Error(w, "unable to delete device", http.StatusInternalServerError)
+			return
+		}
+		if !deleted {
+			http.Error(w, "device does not exist", http.StatusNotFound)
+			return
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func minuteOfDay(clock string) *int {
+	t, err := time.Parse(dtos.QuietHoursLayout, clock)
+	if err != nil {
+		return nil
+	}
+	minute := t.Hour()*60 + t.Minute()
+	return &minute
+}
+
+func toPushPreferencesDTO(prefs models.PushPreferences) dtos.PushPreferences {
+	resp := dtos.PushPreferences{
+		Enabled:    prefs.Enabled,
+		MutedTypes: prefs.MutedTypes,
+		TimeZone:   prefs.TimeZone,
+	}
+	if resp.MutedTypes == nil {
+		resp.MutedTypes = []string{}
+	}
+	if prefs.QuietStart != nil && prefs.QuietEnd != nil {
+		clock := func(minute int) string {
+			return time.Date(0, 1, 1, minute/60, minute%60, 0, 0, time.UTC).Format(dtos.QuietHoursLayout)
+		}
+		resp.QuietHours = &dtos.QuietHours{Start: clock(*prefs.QuietStart), End: clock(*prefs.QuietEnd)}
+	}
+	return resp
+}
+
+func writePushPreferences(w http.ResponseWriter, prefs models.PushPreferences) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(toPushPreferencesDTO(prefs)); err != nil {
+		log.Println("encode push preferences response:", err)
+	}
+}
+
+// GET /me/push-preferences
+func GetPushPreferencesHandler(pushRepo repositories.PushRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("userID").(uuid.UUID)
+
+		prefs, err := pushRepo.GetPushPreferences(userID)
+		if err != nil {
+			log.Println("get push preferences:", err)
+			http.Error(w, "unable to fetch push preferences", http.StatusInternalServerError)
+			return
+		}
+
+		writePushPreferences(w, prefs)
+	}
+}
+
+// PUT /me/push-preferences
+func PutPushPreferencesHandler(pushRepo repositories.PushRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("userID").(uuid.UUID)
+
+		var req dtos.PushPreferences
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+
+		prefs := models.PushPreferences{
+			Enabled:    req.Enabled,
+			MutedTypes: req.MutedTypes,
+			TimeZone:   req.TimeZone,
+		}
+		if req.QuietHours != nil {
+			prefs.QuietStart = minuteOfDay(req.QuietHours.Start)
+			prefs.QuietEnd = minuteOfDay(req.QuietHours.End)
+		}
+
+		if err := pushRepo.SavePushPreferences(userID, prefs); err != nil {
+			log.Println("save push preferences:", err)
+			http.Error(w, "unable to save push preferences", http.StatusInternalServerError)
+			return
+		}
+
+		writePushPreferences(w, prefs)
+	}
+}
@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"ember/api/dtos"
+
+	"github.com/google/uuid"
+)
+
+func TestPostDevicesHandler_RegistersToken(t *testing.T) {
+	userID := uuid.New()
+	repo := newMockPushRepo()
+	token := strings.Repeat("ab", 32)
+
+	rec := httptest.NewRecorder()
+	PostDevicesHandler(repo)(rec, authedRequest(http.MethodPost, "/me/devices", `{"token":"`+token+`","platform":"ios"}`, userID))
+
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d got %d: %s", http.StatusNoContent, rec.Code, rec.Body)
+	}
+	if repo.devices[token] != userID {
+		t.Errorf("token not registered to the caller")
+	}
+}
+
+func TestPostDevicesHandler_RejectsInvalidDevice(t *testing.T) {
+	for _, body := range []string{
+		`{"token":"not-hex","platform":"ios"}`,
+		`{"token":"","platform":"ios"}`,
+		`{"token":"abcd","platform":"android"}`,
+	} {
+		rec := httptest.NewRecorder()
+		PostDevicesHandler(newMockPushRepo())(rec, authedRequest(http.MethodPost, "/me/devices", body, uuid.New()))
+
+		if rec.Code != http.StatusUnprocessableEntity {
+			t.Errorf("%s: expected status %d got %d", body, http.StatusUnprocessableEntity, rec.Code)
+		}
+	}
+}
+
+func TestDeleteDeviceHandler_OnlyDeletesOwnTokens(t *testing.T) {
+	owner, other := uuid.New(), uuid.New()
+	repo := newMockPushRepo()
+	repo.devices["abcd"] = owner
+
+	req := authedRequest(http.MethodDelete, "/me/devices/abcd", "", other, "token", "abcd")
+	rec := httptest.NewRecorder()
+	DeleteDeviceHandler(repo)(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d for another user's token, got %d", http.StatusNotFound, rec.Code)
+	}
+
+	req = authedRequest(http.MethodDelete, "/me/devices/abcd", "", owner, "token", "abcd")
+	rec = httptest.NewRecorder()
+	DeleteDeviceHandler(repo)(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d got %d", http.StatusNoContent, rec.Code)
+	}
+	if _, ok := repo.devices["abcd"]; ok {
+		t.Error("token should be gone")
+	}
+}
+
+func TestPushPreferencesHandlers_RoundTrip(t *testing.T) {
+	userID := uuid.New()
+	repo := newMockPushRepo()
+
+	rec := httptest.NewRecorder()
+	GetPushPreferencesHandler(repo)(rec, authedRequest(http.MethodGet, "/me/push-preferences", "", userID))
+	var defaults dtos.PushPreferences
+	if err := json.NewDecoder(rec.Body).Decode(&defaults); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if !defaults.Enabled || defaults.QuietHours != nil || defaults.TimeZone != "UTC" {
+		t.Errorf("unexpected defaults %+v", defaults)
+	}
+
+	body := `{"enabled":true,"muted_types":["pin_reaction"],"quiet_hours":{"start":"22:30","end":"07:00"},"time_zone":"America/Vancouver"}`
+	rec = httptest.NewRecorder()
+	PutPushPreferencesHandler(repo)(rec, authedRequest(http.MethodPut, "/me/push-preferences", body, userID))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+
+	saved := repo.prefs[userID]
+	if saved.QuietStart == nil || *saved.QuietStart != 22*60+30 || *saved.QuietEnd != 7*60 {
+		t.Errorf("quiet hours saved as %v-%v", saved.QuietStart, saved.QuietEnd)
+	}
+
+	rec = httptest.NewRecorder()
+	GetPushPreferencesHandler(repo)(rec, authedRequest(http.MethodGet, "/me/push-preferences", "", userID))
+	var got dtos.PushPreferences
+	json.NewDecoder(rec.Body).Decode(&got)
+	if got.QuietHours == nil || got.QuietHours.Start != "22:30" || got.QuietHours.End != "07:00" {
+		t.Errorf("quiet hours = %+v", got.QuietHours)
+	}
+	if len(got.MutedTypes) != 1 || got.MutedTypes[0] != "pin_reaction" {
+		t.Errorf("muted types = %v", got.MutedTypes)
+	}
+}
+
+func TestPutPushPreferencesHandler_RejectsInvalidPreferences(t *testing.T) {
+	for _, body := range []string{
+		`{"enabled":true,"muted_types":["nope"],"time_zone":"UTC"}`,
+		`{"enabled":true,"quiet_hours":{"start":"25:00","end":"07:00"},"time_zone":"UTC"}`,
+		`{"enabled":true,"time_zone":"Mars/Olympus"}`,
+	} {
+		rec := httptest.NewRecorder()
+		PutPushPreferencesHandler(newMockPushRepo())(rec, authedRequest(http.MethodPut, "/me/push-preferences", body, uuid.New()))
+
+		if rec.Code != http.StatusUnprocessableEntity {
+			t.Errorf("%s: expected status %d got %d", body, http.StatusUnprocessableEntity, rec.Code)
+		}
+	}
+}
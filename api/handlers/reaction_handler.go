@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"ember/api/dtos"
+	"ember/api/models"
+	"ember/api/repositories"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// getReactedPin reads the pin as userID now sees it, responding with 404 or
+// 500 and returning nil if it can't.
+func getReactedPin(w http.ResponseWriter, pinRepo repositories.PinRepository, userID uuid.UUID, pinID uuid.UUID) *models.Pin {
+	pin, err := pinRepo.GetPin(userID, pinID)
+	if err != nil {
+		log.Println("get pin:", err)
+		http.Error(w, "unable to fetch pin", http.StatusInternalServerError)
+		return nil
+	}
+	if pin == nil {
+		http.Error(w, "pin does not exist", http.StatusNotFound)
+		return nil
+	}
+	return pin
+}
+
+// writeReactedPin responds with pin and its reactions as userID sees them.
+func writeReactedPin(w http.ResponseWriter, pinRepo repositories.PinRepository, userID uuid.UUID, pin models.Pin) {
+	resp, err := pinResponse(pinRepo, userID, pin)
+	if err != nil {
+		log.Println("query pin details:", err)
+		http.Error(w, "unable to fetch pin", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Println("encode reacted pin response:", err)
+	}
+}
+
+// PUT /pins/{pinID}/reactions
+func PutPinReactionHandler(pinRepo repositories.PinRepository, notificationRepo repositories.NotificationRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("userID").(uuid.UUID)
+
+		pinID, err := uuid.Parse(chi.URLParam(r, "pinID"))
+		if err != nil {
+			http.Error(w, "invalid pin ID", http.StatusBadRequest)
+			return
+		}
+
+		var req dtos.ReactionRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+
+		added, err := pinRepo.AddPinReaction(userID, pinID, req.Emoji)
+		if errors.Is(err, repositories.ErrPinNotFound) {
+			http.Error(w, "pin does not exist", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Println("add pin reaction:", err)
+			http.Error(w, "unable to react to pin", http.StatusInternalServerError)
+			return
+		}
+
+		pin := getReactedPin(w, pinRepo, userID, pinID)
+		if pin == nil {
+			return
+		}
+
+		if added {
+			// Only the owner is notified, and only of new reactions, so
+			// repeating a PUT doesn't notify twice.
+			notify(notificationRepo, repositories.NewNotification{
+				RecipientID: pin.UserID,
+				ActorID:     userID,
+				Type:        models.NotificationPinReaction,
+				PinID:       &pinID,
+				Data:        map[string]any{"emoji": req.Emoji},
+			})
+		}
+
+		writeReactedPin(w, pinRepo, userID, *pin)
+	}
+}
+
+// DELETE /pins/{pinID}/reactions?emoji=
+func DeletePinReactionHandler(pinRepo repositories.PinRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("userID").(uuid.UUID)
+
+		pinID, err := uuid.Parse(chi.URLParam(r, "pinID"))
+		if err != nil {
+			http.Error(w, "invalid pin ID", http.StatusBadRequest)
+			return
+		}
+
+		req := dtos.ReactionRequest{Emoji: r.URL.Query().Get("emoji")}
+		if err := req.Validate(); err != nil {
+			writeValidationError(w, err)
+			return
+		}
+
+		if err := pinRepo.RemovePinReaction(userID, pinID, req.Emoji); err != nil {
+			log.Println("remove pin reaction:", err)
+			http.Error(w, "unable to remove reaction", http.StatusInternalServerError)
+			return
+		}
+
+		if pin := getReactedPin(w, pinRepo, userID, pinID); pin != nil {
+			writeReactedPin(w, pinRepo, userID, *pin)
+		}
+	}
+}
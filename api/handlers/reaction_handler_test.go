@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ember/api/dtos"
+	"ember/api/models"
+	"ember/api/repositories"
+
+	"github.com/google/uuid"
+)
+
+// reactablePinRepo serves one pin owned by ownerID and tracks reactions to it in memory.
+func reactablePinRepo(pinID uuid.UUID, ownerID uuid.UUID) (*mockPinRepo, map[string]map[uuid.UUID]bool) {
+	reactions := map[string]map[uuid.UUID]bool{}
+	repo := &mockPinRepo{
+		getPinFn: func(userID uuid.UUID, id uuid.UUID) (*models.Pin, error) {
+			return &models.Pin{ID: pinID, UserID: ownerID, Emotion: "happy", Visibility: "friends", CreatedAt: time.Now()}, nil
+		},
+		addReactionFn: func(userID uuid.UUID, id uuid.UUID, emoji string) (bool, error) {
+			if reactions[emoji] == nil {
+				reactions[emoji] = map[uuid.UUID]bool{}
+			}
+			if reactions[emoji][userID] {
+				return false, nil
+			}
+			reactions[emoji][userID] = true
+			return true, nil
+		},
+		removeReactionFn: func(userID uuid.UUID, id uuid.UUID, emoji string) error {
+			delete(reactions[emoji], userID)
+			return nil
+		},
+		engagementFn: func(userID uuid.UUID, ids []uuid.UUID) (map[uuid.UUID]models.PinEngagement, error) {
+			e := models.PinEngagement{Reactions: map[string]int{}}
+			for emoji, users := range reactions {
+				if len(users) > 0 {
+					e.Reactions[emoji] = len(users)
+				}
+				if users[userID] {
+					e.MyReactions = append(e.MyReactions, emoji)
+				}
+			}
+			return map[uuid.UUID]models.PinEngagement{pinID: e}, nil
+		},
+	}
+	return repo, reactions
+}
+
+func TestPutPinReactionHandler_ReactsAndNotifiesOwnerOnce(t *testing.T) {
+	userID, ownerID, pinID := uuid.New(), uuid.New(), uuid.New()
+	repo, _ := reactablePinRepo(pinID, ownerID)
+	getPin := repo.getPinFn
+	reads := 0
+	repo.getPinFn = func(userID uuid.UUID, id uuid.UUID) (*models.Pin, error) {
+		reads++
+		return getPin(userID, id)
+	}
+	notifications := &mockNotificationRepo{}
+	handler := PutPinReactionHandler(repo, notifications)
+
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		handler(rec, authedRequest(http.MethodPut, "/pins/"+pinID.String()+"/reactions", `{"emoji":"🔥"}`, userID, "pinID", pinID.String()))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status %d got %d: %s", http.StatusOK, rec.Code, rec.Body)
+		}
+
+		var pin dtos.Pin
+		if err := json.NewDecoder(rec.Body).Decode(&pin); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if pin.Reactions["🔥"] != 1 || len(pin.MyReactions) != 1 || pin.MyReactions[0] != "🔥" {
+			t.Errorf("reactions = %v, mine = %v", pin.Reactions, pin.MyReactions)
+		}
+	}
+
+	if reads != 2 {
+		t.Errorf("expected the pin to be read once per request, got %d reads", reads)
+	}
+	if len(notifications.created) != 1 {
+		t.Fatalf("expected one notification for a repeated reaction, got %d", len(notifications.created))
+	}
+	n := notifications.created[0]
+	if n.Type != models.NotificationPinReaction || n.RecipientID != ownerID || n.ActorID != userID {
+		t.Errorf("unexpected notification %+v", n)
+	}
+	if n.PinID == nil || *n.PinID != pinID || n.Data["emoji"] != "🔥" {
+		t.Errorf("notification should carry the pin and emoji: %+v", n)
+	}
+}
+
+func TestPutPinReactionHandler_HiddenPin(t *testing.T) {
+	pinID := uuid.New()
+	repo := &mockPinRepo{
+		addReactionFn: func(userID uuid.UUID, id uuid.UUID, emoji string) (bool, error) {
+			return false, repositories.ErrPinNotFound
+		},
+	}
+	notifications := &mockNotificationRepo{}
+
+	rec := httptest.NewRecorder()
+	PutPinReactionHandler(repo, notifications)(rec, authedRequest(http.MethodPut, "/", `{"emoji":"🔥"}`, uuid.New(), "pinID", pinID.String()))
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d got %d", http.StatusNotFound, rec.Code)
+	}
+	if len(notifications.created) != 0 {
+		t.Error("no notification for a pin the caller can't see")
+	}
+}
+
+func TestPutPinReactionHandler_RejectsNonEmoji(t *testing.T) {
+	for _, body := range []string{`{"emoji":""}`, `{"emoji":"lol"}`, `{"emoji":"🔥🔥🔥🔥🔥🔥🔥🔥🔥🔥🔥🔥🔥🔥🔥🔥🔥"}`} {
+		rec := httptest.NewRecorder()
+		PutPinReactionHandler(&mockPinRepo{}, &mockNotificationRepo{})(rec, authedRequest(http.MethodPut, "/", body, uuid.New(), "pinID", uuid.New().String()))
+
+		if rec.Code != http.StatusUnprocessableEntity {
+			t.Errorf("%s: expected status %d got %d", body, http.StatusUnprocessableEntity, rec.Code)
+		}
+	}
+}
+
+func TestDeletePinReactionHandler_RemovesOwnReaction(t *testing.T) {
+	userID, ownerID, pinID := uuid.New(), uuid.New(), uuid.New()
+	repo, reactions := reactablePinRepo(pinID, ownerID)
+	reactions["🔥"] = map[uuid.UUID]bool{userID: true, ownerID: true}
+
+	rec := httptest.NewRecorder()
+	DeletePinReactionHandler(repo)(rec, authedRequest(http.MethodDelete, "/pins/"+pinID.String()+"/reactions?emoji=%F0%9F%94%A5", "", userID, "pinID", pinID.String()))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	var pin dtos.Pin
+	json.NewDecoder(rec.Body).Decode(&pin)
+	if pin.Reactions["🔥"] != 1 || len(pin.MyReactions) != 0 {
+		t.Errorf("reactions = %v, mine = %v", pin.Reactions, pin.MyReactions)
+	}
+}
+
+func TestGetPinsFriendsHandler_IncludesReactions(t *testing.T) {
+	userID, pinID := uuid.New(), uuid.New()
+	repo := &mockPinRepo{
+		queryFriendPinsFn: func(u uuid.UUID, page repositories.Page) ([]models.Pin, error) {
+			return []models.Pin{{ID: pinID, UserID: uuid.New(), Emotion: "happy", CreatedAt: time.Now()}}, nil
+		},
+		engagementFn: func(u uuid.UUID, ids []uuid.UUID) (map[uuid.UUID]models.PinEngagement, error) {
+			if u != userID || len(ids) != 1 || ids[0] != pinID {
+				t.Errorf("engagement asked for %v by %v", ids, u)
+			}
+			return map[uuid.UUID]models.PinEngagement{
+				pinID: {Reactions: map[string]int{"❤️": 3}, MyReactions: []string{"❤️"}},
+			}, nil
+		},
+	}
+
+	req := authedRequest(http.MethodGet, "/pins/friends", "", userID)
+	rec := httptest.NewRecorder()
+	GetPinsFriendsHandler(repo)(rec, req)
+
+	var resp dtos.GetPinListResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Pins) != 1 || resp.Pins[0].Reactions["❤️"] != 3 || len(resp.Pins[0].MyReactions) != 1 {
+		t.Errorf("unexpected pins %+v", resp.Pins)
+	}
+}
@@ -0,0 +1,81 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"ember/api/dtos"
+	"ember/api/validation"
+)
+
+// maxRequestBodyBytes bounds every JSON request body; pins and profiles are far smaller.
+const maxRequestBodyBytes = 64 << 10
+
+// decodeRequest reads a JSON body into dst and validates it when dst is a
+// validation.Validator. On failure it writes the response (400 for malformed
+// JSON, 413 for oversized bodies, 422 for invalid fields) and returns false.
+func decodeRequest(w http.ResponseWriter, r *http.Request, dst any) bool {
+	return decodeRequestUpTo(w, r, dst, maxRequestBodyBytes)
+}
+
+// decodeRequestUpTo is decodeRequest for bodies allowed to exceed
+// maxRequestBodyBytes, up to maxBytes.
+func decodeRequestUpTo(w http.ResponseWriter, r *http.Request, dst any, maxBytes int64) bool {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+	if err := json.NewDecoder(r.Body).Decode(dst); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "request body too large", http.StatusRequestEntityTooLarge)
+			return false
+		}
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return false
+	}
+
+	if v, ok := dst.(validation.Validator); ok {
+		if err := v.Validate(); err != nil {
+			writeValidationError(w, err)
+			return false
+		}
+	}
+
+	return true
+}
+
+// parseLimit parses an optional "limit" query value, defaulting to def and
+// clamping to max.
+func parseLimit(raw string, def int, max int) (int, error) {
+	if raw == "" {
+		return def, nil
+	}
+
+	limit, err := strconv.Atoi(raw)
+	if err != nil || limit <= 0 {
+		return 0, errors.New("limit must be a positive integer")
+	}
+	if limit > max {
+		limit = max
+	}
+	return limit, nil
+}
+
+// writeValidationError responds 422 with the failing fields of err.
+func writeValidationError(w http.ResponseWriter, err error) {
+	resp := dtos.ValidationErrorResponse{Error: "validation failed"}
+
+	var fields validation.Errors
+	if errors.As(err, &fields) {
+		resp.Fields = fields
+	} else {
+		resp.Fields = []validation.FieldError{{Field: "", Message: err.Error()}}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusUnprocessableEntity)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Println("encode validation error response:", err)
+	}
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"ember/api/dtos"
+	"ember/api/models"
+	"ember/api/repositories"
+	"ember/api/validation"
+
+	"github.com/google/uuid"
+)
+
+const (
+	maxSearchQueryLength  = 200
+	defaultSearchPageSize = 20
+	maxSearchPageSize     = 100
+)
+
+// parseTimeParam parses an optional RFC 3339 query parameter.
+func parseTimeParam(r *http.Request, name string) (*time.Time, bool) {
+	raw := r.URL.Query().Get(name)
+	if raw == "" {
+		return nil, true
+	}
+	t, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		return nil, false
+	}
+	return &t, true
+}
+
+// GET /pins/search?q=&bbox=&since=&until=&limit=&cursor=
+func GetPinsSearchHandler(pinRepo repositories.PinRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("userID").(uuid.UUID)
+		query := r.URL.Query()
+
+		search := repositories.PinSearchQuery{Text: strings.TrimSpace(query.Get("q"))}
+		if search.Text == "" {
+			http.Error(w, "missing required query parameter: q", http.StatusBadRequest)
+			return
+		}
+		if !validation.MaxRunes(search.Text, maxSearchQueryLength) {
+			http.Error(w, "q must be at most 200 characters", http.StatusBadRequest)
+			return
+		}
+
+		if raw := query.Get("bbox"); raw != "" {
+			bbox, err := parseBBox(raw)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			search.BBox = &bbox
+		}
+
+		var ok bool
+		if search.Since, ok = parseTimeParam(r, "since"); !ok {
+			http.Error(w, "since must be an RFC 3339 timestamp", http.StatusBadRequest)
+			return
+		}
+		if search.Until, ok = parseTimeParam(r, "until"); !ok {
+			http.Error(w, "until must be an RFC 3339 timestamp", http.StatusBadRequest)
+			return
+		}
+		if search.Since != nil && search.Until != nil && !search.Since.Before(*search.Until) {
+			http.Error(w, "since must be before until", http.StatusBadRequest)
+			return
+		}
+
+		var err error
+		search.Page, err = parsePage(r, defaultSearchPageSize, maxSearchPageSize)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		search.AsOf = time.Now()
+		if search.Page.After != nil && !search.Page.After.AsOf.IsZero() {
+			search.AsOf = search.Page.After.AsOf
+		}
+
+		pins, err := pinRepo.SearchPins(userID, search)
+		if err != nil {
+			log.Println("search pins:", err)
+			http.Error(w, "unable to search pins", http.StatusInternalServerError)
+			return
+		}
+
+		toDTO := func(pin models.SearchedPin) dtos.Pin {
+			p := toPinDTO(pin.Pin)
+			p.Snippet = &pin.Snippet
+			return p
+		}
+		cursorOf := func(pin models.SearchedPin) repositories.Cursor {
+			return repositories.Cursor{Score: pin.Score, AsOf: search.AsOf, ID: pin.ID}
+		}
+
+		var resp dtos.GetPinListResponse
+		resp.Pins, resp.NextCursor = paginate(pins, search.Page, toDTO, cursorOf)
+		if err := withPinDetails(pinRepo, userID, resp.Pins); err != nil {
+			log.Println("query pin details:", err)
+			http.Error(w, "unable to fetch pins", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.Println("encode pin search response:", err)
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"ember/api/dtos"
+	"ember/api/models"
+	"ember/api/repositories"
+
+	"github.com/google/uuid"
+)
+
+func TestGetPinsSearchHandler_PassesBoundsAndPaginates(t *testing.T) {
+	userID := uuid.New()
+	var queries []repositories.PinSearchQuery
+	results := []models.SearchedPin{
+		{Pin: models.Pin{ID: uuid.New(), Emotion: "happy", Visibility: "public"}, Score: 0.9, Snippet: "best <mark>coffee</mark>"},
+		{Pin: models.Pin{ID: uuid.New(), Emotion: "calm", Visibility: "public"}, Score: 0.5, Snippet: "<mark>coffee</mark> again"},
+	}
+	pinRepo := &mockPinRepo{
+		searchPinsFn: func(id uuid.UUID, query repositories.PinSearchQuery) ([]models.SearchedPin, error) {
+			queries = append(queries, query)
+			return results, nil
+		},
+	}
+	handler := GetPinsSearchHandler(pinRepo)
+
+	params := url.Values{
+		"q":     {"  coffee  "},
+		"bbox":  {"-123.2,49.2,-123.0,49.3"},
+		"since": {"2025-01-01T00:00:00Z"},
+		"limit": {"1"},
+	}
+	rec := httptest.NewRecorder()
+	handler(rec, authedRequest(http.MethodGet, "/pins/search?"+params.Encode(), "", userID))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+
+	q := queries[0]
+	if q.Text != "coffee" || q.BBox == nil || q.BBox.MinLon != -123.2 || q.Until != nil {
+		t.Errorf("unexpected query %+v", q)
+	}
+	if q.Since == nil || !q.Since.Equal(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("since = %v", q.Since)
+	}
+
+	var resp dtos.GetPinListResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Pins) != 1 || resp.Pins[0].Snippet == nil || *resp.Pins[0].Snippet != "best <mark>coffee</mark>" {
+		t.Fatalf("unexpected pins %+v", resp.Pins)
+	}
+	if resp.NextCursor == nil {
+		t.Fatal("expected a next cursor")
+	}
+
+	// The next page keeps the first page's clock and resumes after its last score.
+	params.Set("cursor", *resp.NextCursor)
+	rec = httptest.NewRecorder()
+	handler(rec, authedRequest(http.MethodGet, "/pins/search?"+params.Encode(), "", userID))
+	next := queries[1]
+	if !next.AsOf.Equal(q.AsOf) || next.Page.After == nil || next.Page.After.Score != 0.9 || next.Page.After.ID != results[0].ID {
+		t.Errorf("unexpected next page query %+v", next)
+	}
+}
+
+func TestGetPinsSearchHandler_InvalidParams(t *testing.T) {
+	handler := GetPinsSearchHandler(&mockPinRepo{})
+
+	tests := map[string]url.Values{
+		"missing q":   {},
+		"blank q":     {"q": {"   "}},
+		"bad bbox":    {"q": {"coffee"}, "bbox": {"1,2,3"}},
+		"bad since":   {"q": {"coffee"}, "since": {"yesterday"}},
+		"empty range": {"q": {"coffee"}, "since": {"2025-01-02T00:00:00Z"}, "until": {"2025-01-01T00:00:00Z"}},
+	}
+	for name, params := range tests {
+		rec := httptest.NewRecorder()
+		handler(rec, authedRequest(http.MethodGet, "/pins/search?"+params.Encode(), "", uuid.New()))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: expected status %d got %d", name, http.StatusBadRequest, rec.Code)
+		}
+	}
+}
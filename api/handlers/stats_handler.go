@@ -0,0 +1,277 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+
+	"ember/api/dtos"
+	"ember/api/models"
+	"ember/api/repositories"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// statsPlaceCellDeg (~200 m) is the grid pins are clustered on to find places.
+	statsPlaceCellDeg = 0.002
+	maxStatsPlaces    = 5
+	// maxStatsPins bounds how much history /me/stats reads, newest first.
+	maxStatsPins = 10000
+	calendarDate = "2006-01-02"
+)
+
+// GET /me/stats?tz=Area/City
+func GetMeStatsHandler(pinRepo repositories.PinRepository, emotionRepo repositories.EmotionRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		loc := time.UTC
+		if tz := r.URL.Query().Get("tz"); tz != "" {
+			var err error
+			loc, err = time.LoadLocation(tz)
+			if err != nil {
+				http.Error(w, "tz must be an IANA time zone name", http.StatusBadRequest)
+				return
+			}
+		}
+
+		userID := r.Context().Value("userID").(uuid.UUID)
+		pins, err := queryPinHistory(pinRepo, userID, maxStatsPins)
+		if err != nil {
+			log.Println("query user pins:", err)
+			http.Error(w, "unable to compute stats", http.StatusInternalServerError)
+			return
+		}
+
+		catalog, err := emotionRepo.GetCatalog()
+		if err != nil {
+			log.Println("get emotion catalog:", err)
+			http.Error(w, "unable to compute stats", http.StatusInternalServerError)
+			return
+		}
+		valences := make(map[string]float64, len(catalog.Emotions))
+		for _, e := range catalog.Emotions {
+			valences[e.Key] = e.Valence
+		}
+
+		resp := computeUserStats(pins, valences, loc, time.Now())
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.Println("encode stats response:", err)
+		}
+	}
+}
+
+// queryPinHistory pages through a user's pins, newest first, up to max.
+func queryPinHistory(pinRepo repositories.PinRepository, userID uuid.UUID, max int) ([]models.Pin, error) {
+	var pins []models.Pin
+	page := repositories.Page{Limit: maxPinLimit}
+	for len(pins) < max {
+		batch, err := pinRepo.QueryUserPins(userID, page)
+		if err != nil {
+			return nil, err
+		}
+		if len(batch) <= page.Limit {
+			return append(pins, batch...), nil
+		}
+
+		batch = batch[:page.Limit]
+		pins = append(pins, batch...)
+		cursor := pinCursor(batch[len(batch)-1])
+		page.After = &cursor
+	}
+	return pins[:max], nil
+}
+
+// moodSlot accumulates a dtos.MoodSlot; valence is averaged only over pins
+// whose emotion is in the catalogue.
+type moodSlot struct {
+	emotions     map[string]int
+	count        int
+	valenceSum   float64
+	valenceCount int
+}
+
+func (s *moodSlot) add(emotion string, valences map[string]float64) {
+	if s.emotions == nil {
+		s.emotions = map[string]int{}
+	}
+	s.emotions[emotion]++
+	s.count++
+	if v, ok := valences[emotion]; ok {
+		s.valenceSum += v
+		s.valenceCount++
+	}
+}
+
+func (s *moodSlot) dto() dtos.MoodSlot {
+	slot := dtos.MoodSlot{PinCount: s.count, Emotions: map[string]int{}}
+	for emotion, count := range s.emotions {
+		slot.Emotions[emotion] = count
+	}
+	if s.count > 0 {
+		slot.DominantEmotion = models.DominantEmotion(s.emotions)
+	}
+	if s.valenceCount > 0 {
+		mean := s.valenceSum / float64(s.valenceCount)
+		slot.MeanValence = &mean
+	}
+	return slot
+}
+
+// computeUserStats derives /me/stats from a user's pins, bucketing days and
+// hours in loc.
+func computeUserStats(pins []models.Pin, valences map[string]float64, loc *time.Location, now time.Time) dtos.GetMeStatsResponse {
+	resp := dtos.GetMeStatsResponse{
+		TimeZone:  loc.String(),
+		TotalPins: len(pins),
+		Emotions:  map[string]int{},
+		TopPlaces: []dtos.StatsPlace{},
+		Calendar:  []dtos.CalendarDay{},
+	}
+
+	var weekdays [7]moodSlot
+	var hours [24]moodSlot
+	days := map[string]*moodSlot{}
+
+	type placeKey struct{ x, y int64 }
+	type place struct {
+		lonSum, latSum float64
+		slot           moodSlot
+	}
+	places := map[placeKey]*place{}
+
+	for _, pin := range pins {
+		resp.Emotions[pin.Emotion]++
+
+		local := pin.CreatedAt.In(loc)
+		weekdays[local.Weekday()].add(pin.Emotion, valences)
+		hours[local.Hour()].add(pin.Emotion, valences)
+
+		date := local.Format(calendarDate)
+		if days[date] == nil {
+			days[date] = &moodSlot{}
+		}
+		days[date].add(pin.Emotion, valences)
+
+		key := placeKey{
+			x: int64(math.Floor(pin.Location.Longitude / statsPlaceCellDeg)),
+			y: int64(math.Floor(pin.Location.Latitude / statsPlaceCellDeg)),
+		}
+		if places[key] == nil {
+			places[key] = &place{}
+		}
+		places[key].lonSum += pin.Location.Longitude
+		places[key].latSum += pin.Location.Latitude
+		places[key].slot.add(pin.Emotion, valences)
+	}
+
+	for i := range weekdays {
+		resp.ByWeekday = append(resp.ByWeekday, weekdays[i].dto())
+	}
+	for i := range hours {
+		resp.ByHour = append(resp.ByHour, hours[i].dto())
+	}
+
+	dates := make([]string, 0, len(days))
+	for date := range days {
+		dates = append(dates, date)
+	}
+	sort.Strings(dates)
+	for _, date := range dates {
+		resp.Calendar = append(resp.Calendar, dtos.CalendarDay{
+			Date:            date,
+			PinCount:        days[date].count,
+			DominantEmotion: models.DominantEmotion(days[date].emotions),
+		})
+	}
+	resp.CurrentStreak, resp.LongestStreak = pinningStreaks(dates, now.In(loc))
+
+	// Grid cells split places that straddle a cell edge, so each busy cell
+	// absorbs its unclaimed neighbours before becoming a place.
+	keys := make([]placeKey, 0, len(places))
+	for key := range places {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := places[keys[i]].slot.count, places[keys[j]].slot.count
+		if a != b {
+			return a > b
+		}
+		if keys[i].y != keys[j].y {
+			return keys[i].y < keys[j].y
+		}
+		return keys[i].x < keys[j].x
+	})
+	claimed := map[placeKey]bool{}
+	for _, key := range keys {
+		if claimed[key] {
+			continue
+		}
+		merged := place{slot: moodSlot{emotions: map[string]int{}}}
+		for dx := int64(-1); dx <= 1; dx++ {
+			for dy := int64(-1); dy <= 1; dy++ {
+				neighbour := placeKey{key.x + dx, key.y + dy}
+				p, ok := places[neighbour]
+				if !ok || claimed[neighbour] {
+					continue
+				}
+				claimed[neighbour] = true
+				merged.lonSum += p.lonSum
+				merged.latSum += p.latSum
+				merged.slot.count += p.slot.count
+				for emotion, count := range p.slot.emotions {
+					merged.slot.emotions[emotion] += count
+				}
+			}
+		}
+
+		n := float64(merged.slot.count)
+		resp.TopPlaces = append(resp.TopPlaces, dtos.StatsPlace{
+			Longitude:       merged.lonSum / n,
+			Latitude:        merged.latSum / n,
+			PinCount:        merged.slot.count,
+			DominantEmotion: models.DominantEmotion(merged.slot.emotions),
+		})
+	}
+	sort.SliceStable(resp.TopPlaces, func(i, j int) bool {
+		a, b := resp.TopPlaces[i], resp.TopPlaces[j]
+		return a.PinCount > b.PinCount
+	})
+	if len(resp.TopPlaces) > maxStatsPlaces {
+		resp.TopPlaces = resp.TopPlaces[:maxStatsPlaces]
+	}
+
+	return resp
+}
+
+// pinningStreaks finds runs of consecutive days in sorted YYYY-MM-DD dates.
+// The current streak is the run ending today or yesterday, so a streak isn't
+// broken before the user has had a chance to pin today.
+func pinningStreaks(dates []string, today time.Time) (current dtos.Streak, longest dtos.Streak) {
+	var run dtos.Streak
+	var prev time.Time
+	for _, date := range dates {
+		day, _ := time.Parse(calendarDate, date)
+		if run.Days > 0 && day.Equal(prev.AddDate(0, 0, 1)) {
+			run.Days++
+			run.End = date
+		} else {
+			run = dtos.Streak{Days: 1, Start: date, End: date}
+		}
+		if run.Days > longest.Days {
+			longest = run
+		}
+		prev = day
+	}
+
+	todayDate := today.Format(calendarDate)
+	yesterdayDate := today.AddDate(0, 0, -1).Format(calendarDate)
+	if run.End == todayDate || run.End == yesterdayDate {
+		current = run
+	}
+	return current, longest
+}
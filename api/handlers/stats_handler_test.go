@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ember/api/dtos"
+	"ember/api/models"
+	"ember/api/repositories"
+
+	"github.com/google/uuid"
+)
+
+func statsPin(emotion string, createdAt time.Time, lon float64, lat float64) models.Pin {
+	return models.Pin{
+		ID:        uuid.New(),
+		Emotion:   emotion,
+		CreatedAt: createdAt,
+		Location:  models.Location{Longitude: lon, Latitude: lat},
+	}
+}
+
+func TestComputeUserStats(t *testing.T) {
+	vancouver, err := time.LoadLocation("America/Vancouver")
+	if err != nil {
+		t.Fatalf("load location: %v", err)
+	}
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, vancouver)
+
+	pins := []models.Pin{
+		// 2026-03-01 and 03-02 form a two-day streak
+		statsPin("happy", time.Date(2026, 3, 1, 9, 0, 0, 0, vancouver), -123.1, 49.2),
+		statsPin("happy", time.Date(2026, 3, 2, 9, 0, 0, 0, vancouver), -123.1, 49.2),
+		// 03-08 .. 03-09 local, but 03-10 in UTC for the late-evening pin
+		statsPin("sad", time.Date(2026, 3, 8, 9, 0, 0, 0, vancouver), -123.1001, 49.2001),
+		statsPin("calm", time.Date(2026, 3, 9, 20, 0, 0, 0, vancouver), -122.5, 49.0),
+		statsPin("retired", time.Date(2026, 3, 9, 21, 0, 0, 0, vancouver), -122.5, 49.0),
+	}
+	valences := map[string]float64{"happy": 0.6, "sad": -0.6, "calm": 0.5}
+
+	stats := computeUserStats(pins, valences, vancouver, now)
+
+	if stats.TotalPins != 5 || stats.Emotions["happy"] != 2 {
+		t.Fatalf("unexpected distribution: %+v", stats.Emotions)
+	}
+	if stats.CurrentStreak != (dtos.Streak{Days: 2, Start: "2026-03-08", End: "2026-03-09"}) {
+		t.Fatalf("unexpected current streak: %+v", stats.CurrentStreak)
+	}
+	if stats.LongestStreak.Days != 2 || stats.LongestStreak.Start != "2026-03-01" {
+		t.Fatalf("expected the earliest two-day streak to be longest, got %+v", stats.LongestStreak)
+	}
+	if len(stats.Calendar) != 4 || stats.Calendar[3].Date != "2026-03-09" || stats.Calendar[3].PinCount != 2 {
+		t.Fatalf("unexpected calendar: %+v", stats.Calendar)
+	}
+	if len(stats.TopPlaces) != 2 || stats.TopPlaces[0].PinCount != 3 || stats.TopPlaces[0].DominantEmotion != "happy" {
+		t.Fatalf("unexpected top places: %+v", stats.TopPlaces)
+	}
+	if len(stats.ByWeekday) != 7 || len(stats.ByHour) != 24 {
+		t.Fatalf("expected 7 weekdays and 24 hours, got %d and %d", len(stats.ByWeekday), len(stats.ByHour))
+	}
+
+	// Both 21:00 pins fall in the same hour; only the catalogued one counts toward valence
+	late := stats.ByHour[21]
+	if late.PinCount != 1 || late.MeanValence != nil {
+		t.Fatalf("unexpected 21:00 slot: %+v", late)
+	}
+	evening := stats.ByHour[20]
+	if evening.MeanValence == nil || *evening.MeanValence != 0.5 {
+		t.Fatalf("unexpected 20:00 slot: %+v", evening)
+	}
+	if stats.ByWeekday[time.Sunday].PinCount != 2 {
+		t.Fatalf("expected two Sunday pins, got %+v", stats.ByWeekday[time.Sunday])
+	}
+}
+
+func TestComputeUserStats_StreakBroken(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	pins := []models.Pin{statsPin("happy", now.AddDate(0, 0, -3), 0, 0)}
+
+	stats := computeUserStats(pins, nil, time.UTC, now)
+
+	if stats.CurrentStreak.Days != 0 || stats.LongestStreak.Days != 1 {
+		t.Fatalf("unexpected streaks: %+v %+v", stats.CurrentStreak, stats.LongestStreak)
+	}
+}
+
+func TestGetMeStatsHandler_InvalidTimeZone(t *testing.T) {
+	req := authedRequest(http.MethodGet, "/me/stats?tz=Mars/Olympus", "", uuid.New())
+	rec := httptest.NewRecorder()
+
+	GetMeStatsHandler(&mockPinRepo{}, &mockEmotionRepo{})(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestGetMeStatsHandler_Success(t *testing.T) {
+	userID := uuid.New()
+	pinRepo := &mockPinRepo{
+		queryUserPinsFn: func(id uuid.UUID, page repositories.Page) ([]models.Pin, error) {
+			if id != userID {
+				t.Fatalf("unexpected user %s", id)
+			}
+			return []models.Pin{statsPin("happy", time.Now(), -123.1, 49.2)}, nil
+		},
+	}
+
+	req := authedRequest(http.MethodGet, "/me/stats?tz=Asia/Tokyo", "", userID)
+	rec := httptest.NewRecorder()
+
+	GetMeStatsHandler(pinRepo, &mockEmotionRepo{})(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d got %d", http.StatusOK, rec.Code)
+	}
+
+	var resp dtos.GetMeStatsResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.TimeZone != "Asia/Tokyo" || resp.TotalPins != 1 || resp.CurrentStreak.Days != 1 {
+		t.Fatalf("unexpected response: %+v", resp)
+	}
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"ember/api/dtos"
+	"ember/api/events"
+	"ember/api/models"
+	"ember/api/repositories"
+	"ember/api/validation"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// pinStreamHeartbeat keeps idle streams alive through proxies and lets the
+// server notice clients that have gone away.
+const pinStreamHeartbeat = 15 * time.Second
+
+// minStreamBBoxMeters keeps stream viewports from being shrunk around a
+// single point to learn exactly where the pins leaving it were.
+const minStreamBBoxMeters = 1000.0
+
+// parseStreamBBox parses a stream viewport, which must be at least
+// minStreamBBoxMeters across and at most maxBBoxAreaKm2.
+func parseStreamBBox(raw string) (models.BBox, error) {
+	bbox, err := parseBBox(raw)
+	if err != nil {
+		return bbox, err
+	}
+	if bbox.AreaKm2() > maxBBoxAreaKm2 {
+		return bbox, errors.New("bbox is too large; zoom in")
+	}
+	if bbox.MinSideMeters() < minStreamBBoxMeters {
+		return bbox, errors.New("bbox is too small; zoom out")
+	}
+	return bbox, nil
+}
+
+// streamEventFor decides what, if anything, a subscriber is told about an
+// event. Created and updated pins are re-read as the subscriber so they see
+// the location at their precision. A pin that leaves the subscriber's sight
+// (deleted, expired, made private, or out of the viewport) is only reported
+// if they could see it, private zones included, and it was served to them
+// inside the viewport before. Event pins are located
+// exactly, and served within models.MaxFuzzMeters of that, so pins further
+// than that from the viewport are never looked up.
+func streamEventFor(pinRepo repositories.PinRepository, sub *events.Subscription, event events.PinEvent) (*dtos.PinStreamEvent, error) {
+	bbox := sub.BBox()
+	near := bbox.Grow(models.MaxFuzzMeters)
+
+	if event.Type == events.PinCreated || event.Type == events.PinUpdated {
+		if near.Contains(event.Pin.Location.Longitude, event.Pin.Location.Latitude) {
+			served, err := pinRepo.GetPin(sub.UserID, event.Pin.ID)
+			if err != nil {
+				return nil, err
+			}
+			if served != nil && bbox.Contains(served.Location.Longitude, served.Location.Latitude) {
+				pin := toPinDTO(*served)
+				return &dtos.PinStreamEvent{Type: string(event.Type), PinID: event.Pin.ID, Pin: &pin}, nil
+			}
+		}
+	}
+
+	before := event.Before
+	if before == nil || !near.Contains(before.Location.Longitude, before.Location.Latitude) {
+		return nil, nil
+	}
+	wasAt, err := pinRepo.ServePinSnapshot(sub.UserID, *before)
+	if err != nil || wasAt == nil || !bbox.Contains(wasAt.Longitude, wasAt.Latitude) {
+		return nil, err
+	}
+
+	eventType := event.Type
+	if eventType == events.PinUpdated {
+		eventType = events.PinDeleted
+	}
+	return &dtos.PinStreamEvent{Type: string(eventType), PinID: before.ID}, nil
+}
+
+func writeSSE(w http.ResponseWriter, event string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, payload)
+	return err
+}
+
+// GET /pins/stream?bbox=minLon,minLat,maxLon,maxLat
+func GetPinStreamHandler(broker *events.Broker, pinRepo repositories.PinRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		bbox, err := parseStreamBBox(r.URL.Query().Get("bbox"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+			return
+		}
+
+		userID := r.Context().Value("userID").(uuid.UUID)
+		sub := broker.Subscribe(userID, bbox)
+		defer sub.Close()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("Connection", "keep-alive")
+		w.Header().Set("X-Accel-Buffering", "no")
+
+		if err := writeSSE(w, "ready", dtos.PinStreamReady{StreamID: sub.ID}); err != nil {
+			return
+		}
+		flusher.Flush()
+
+		heartbeat := time.NewTicker(pinStreamHeartbeat)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-heartbeat.C:
+				if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+					return
+				}
+			case event, ok := <-sub.Events:
+				if !ok {
+					// Dropped for falling behind; the client reconnects and refetches
+					return
+				}
+				msg, err := streamEventFor(pinRepo, sub, event)
+				if err != nil {
+					log.Println("filter pin stream event:", err)
+					continue
+				}
+				if msg == nil {
+					continue
+				}
+				if err := writeSSE(w, msg.Type, msg); err != nil {
+					return
+				}
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// PATCH /pins/stream/{streamID}
+func PatchPinStreamHandler(broker *events.Broker) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		streamID, err := uuid.Parse(chi.URLParam(r, "streamID"))
+		if err != nil {
+			http.Error(w, "invalid stream ID", http.StatusBadRequest)
+			return
+		}
+
+		var req dtos.UpdatePinStreamRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+
+		bbox, err := parseStreamBBox(req.BBox)
+		if err != nil {
+			var errs validation.Errors
+			errs.Add("bbox", err.Error())
+			writeValidationError(w, errs)
+			return
+		}
+
+		userID := r.Context().Value("userID").(uuid.UUID)
+		sub := broker.Subscription(streamID)
+		if sub == nil || sub.UserID != userID {
+			http.Error(w, "stream not found", http.StatusNotFound)
+			return
+		}
+
+		sub.SetBBox(bbox)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
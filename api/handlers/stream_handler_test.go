@@ -0,0 +1,329 @@
+package handlers
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"ember/api/dtos"
+	"ember/api/events"
+	"ember/api/models"
+	"ember/api/repositories"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+func streamPin(author uuid.UUID, visibility string, lon float64, lat float64) models.Pin {
+	return models.Pin{
+		ID:         uuid.New(),
+		UserID:     author,
+		Emotion:    "happy",
+		Visibility: visibility,
+		Location:   models.Location{Longitude: lon, Latitude: lat},
+	}
+}
+
+func snapshotOf(pin models.Pin) *repositories.PinSnapshot {
+	return &repositories.PinSnapshot{ID: pin.ID, UserID: pin.UserID, Visibility: pin.Visibility, Location: pin.Location}
+}
+
+func TestStreamEventFor_Visibility(t *testing.T) {
+	viewer, friend, stranger := uuid.New(), uuid.New(), uuid.New()
+	sub := events.NewBroker().Subscribe(viewer, models.BBox{MinLon: -124, MinLat: 49, MaxLon: -122, MaxLat: 50})
+	defer sub.Close()
+
+	public := streamPin(stranger, "public", -123, 49.5)
+	friendsOnly := streamPin(friend, "friends", -123, 49.5)
+	strangersFriendsOnly := streamPin(stranger, "friends", -123, 49.5)
+	madePrivate := friendsOnly
+	madePrivate.Visibility = "private"
+	movedAway := public
+	movedAway.Location = models.Location{Longitude: 10, Latitude: 10}
+	// Exactly inside the viewport, but served to the viewer just outside it
+	fuzzedAway := streamPin(stranger, "public", -122.001, 49.5)
+
+	// Public, but inside its author's private zone
+	inZone := streamPin(stranger, "public", -123, 49.5)
+
+	// Where the viewer is served a pin, now or before it changed, or nil
+	serve := func(userID uuid.UUID, pin models.Pin) *models.Location {
+		switch {
+		case pin.ID == inZone.ID && userID != pin.UserID:
+			return nil
+		case pin.Visibility == "private" && userID != pin.UserID:
+			return nil
+		case pin.Visibility == "friends" && !(userID == viewer && pin.UserID == friend):
+			return nil
+		case pin.ID == fuzzedAway.ID:
+			return &models.Location{Longitude: -121.99, Latitude: pin.Location.Latitude}
+		}
+		return &pin.Location
+	}
+	var current models.Pin
+	pinRepo := &mockPinRepo{
+		getPinFn: func(userID uuid.UUID, pinID uuid.UUID) (*models.Pin, error) {
+			loc := serve(userID, current)
+			if loc == nil {
+				return nil, nil
+			}
+			served := current
+			served.Location = *loc
+			return &served, nil
+		},
+		serveSnapshotFn: func(userID uuid.UUID, pin repositories.PinSnapshot) (*models.Location, error) {
+			return serve(userID, models.Pin{ID: pin.ID, UserID: pin.UserID, Visibility: pin.Visibility, Location: pin.Location}), nil
+		},
+	}
+
+	cases := []struct {
+		name  string
+		event events.PinEvent
+		want  string
+	}{
+		{"public pin", events.PinEvent{Type: events.PinCreated, Pin: public}, "created"},
+		{"friend's pin", events.PinEvent{Type: events.PinCreated, Pin: friendsOnly}, "created"},
+		{"stranger's friends-only pin", events.PinEvent{Type: events.PinCreated, Pin: strangersFriendsOnly}, ""},
+		{"stranger's friends-only pin deleted", events.PinEvent{Type: events.PinDeleted, Before: snapshotOf(strangersFriendsOnly)}, ""},
+		{"made private", events.PinEvent{Type: events.PinUpdated, Pin: madePrivate, Before: snapshotOf(friendsOnly)}, "deleted"},
+		{"moved out of view", events.PinEvent{Type: events.PinUpdated, Pin: movedAway, Before: snapshotOf(public)}, "deleted"},
+		{"expired", events.PinEvent{Type: events.PinExpired, Before: snapshotOf(public)}, "expired"},
+		{"served out of view", events.PinEvent{Type: events.PinCreated, Pin: fuzzedAway}, ""},
+		{"deleted where it was served out of view", events.PinEvent{Type: events.PinDeleted, Before: snapshotOf(fuzzedAway)}, ""},
+		{"public pin in a private zone deleted", events.PinEvent{Type: events.PinDeleted, Before: snapshotOf(inZone)}, ""},
+		{"public pin in a private zone expired", events.PinEvent{Type: events.PinExpired, Before: snapshotOf(inZone)}, ""},
+	}
+
+	for _, tc := range cases {
+		current = tc.event.Pin
+		msg, err := streamEventFor(pinRepo, sub, tc.event)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		got := ""
+		if msg != nil {
+			got = msg.Type
+			pinID := tc.event.Pin.ID
+			if tc.event.Before != nil {
+				pinID = tc.event.Before.ID
+			}
+			if msg.PinID != pinID {
+				t.Fatalf("%s: unexpected pin ID %s", tc.name, msg.PinID)
+			}
+		}
+		if got != tc.want {
+			t.Fatalf("%s: expected %q got %q", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestStreamEventFor_SkipsFarAwayPins(t *testing.T) {
+	sub := events.NewBroker().Subscribe(uuid.New(), models.BBox{MinLon: -124, MinLat: 49, MaxLon: -122, MaxLat: 50})
+	defer sub.Close()
+
+	reads := 0
+	pinRepo := &mockPinRepo{
+		getPinFn: func(userID uuid.UUID, pinID uuid.UUID) (*models.Pin, error) {
+			reads++
+			return nil, nil
+		},
+	}
+
+	paris := streamPin(uuid.New(), "public", 2.35, 48.85)
+	// Outside the viewport, but close enough to be served inside it
+	edge := streamPin(uuid.New(), "public", -121.95, 49.5)
+	for _, pin := range []models.Pin{paris, edge} {
+		if _, err := streamEventFor(pinRepo, sub, events.PinEvent{Type: events.PinCreated, Pin: pin}); err != nil {
+			t.Fatalf("stream event: %v", err)
+		}
+	}
+
+	if reads != 1 {
+		t.Fatalf("expected only the pin near the viewport to be re-read, got %d reads", reads)
+	}
+}
+
+func TestBrokerPublish_RoutesPinsThatCanBeServedInView(t *testing.T) {
+	broker := events.NewBroker()
+	sub := broker.Subscribe(uuid.New(), models.BBox{MinLon: -124, MinLat: 49, MaxLon: -122, MaxLat: 50})
+	defer sub.Close()
+
+	// Exactly just outside the viewport, so it may be served inside it
+	broker.Publish(events.PinEvent{Type: events.PinCreated, Pin: streamPin(uuid.New(), "public", -121.95, 49.5)})
+	broker.Publish(events.PinEvent{Type: events.PinCreated, Pin: streamPin(uuid.New(), "public", 2.35, 48.85)})
+
+	if len(sub.Events) != 1 {
+		t.Fatalf("expected only the pin near the viewport to be routed, got %d events", len(sub.Events))
+	}
+}
+
+// readSSE reads the next non-heartbeat event from an SSE stream.
+func readSSE(t *testing.T, r *bufio.Reader) (string, string) {
+	t.Helper()
+	var event, data string
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read stream: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		switch {
+		case strings.HasPrefix(line, "event: "):
+			event = strings.TrimPrefix(line, "event: ")
+		case strings.HasPrefix(line, "data: "):
+			data = strings.TrimPrefix(line, "data: ")
+		case line == "" && event != "":
+			return event, data
+		}
+	}
+}
+
+func TestPinStream_DeliversAndMovesViewport(t *testing.T) {
+	userID := uuid.New()
+	broker := events.NewBroker()
+	var mu sync.Mutex
+	published := map[uuid.UUID]models.Pin{}
+	publish := func(pin models.Pin) {
+		mu.Lock()
+		published[pin.ID] = pin
+		mu.Unlock()
+		broker.Publish(events.PinEvent{Type: events.PinCreated, Pin: pin})
+	}
+	pinRepo := &mockPinRepo{
+		getPinFn: func(userID uuid.UUID, pinID uuid.UUID) (*models.Pin, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			pin := published[pinID]
+			return &pin, nil
+		},
+	}
+
+	r := chi.NewRouter()
+	r.Use(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			next.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), "userID", userID)))
+		})
+	})
+	r.Get("/pins/stream", GetPinStreamHandler(broker, pinRepo))
+	r.Patch("/pins/stream/{streamID}", PatchPinStreamHandler(broker))
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/pins/stream?bbox=-123.5,49,-122.5,49.5", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("open stream: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Type") != "text/event-stream" {
+		t.Fatalf("unexpected content type %q", resp.Header.Get("Content-Type"))
+	}
+	stream := bufio.NewReader(resp.Body)
+
+	event, data := readSSE(t, stream)
+	var ready dtos.PinStreamReady
+	if event != "ready" || json.Unmarshal([]byte(data), &ready) != nil {
+		t.Fatalf("expected ready event, got %q %q", event, data)
+	}
+
+	pin := streamPin(uuid.New(), "public", -123, 49.25)
+	publish(pin)
+
+	event, data = readSSE(t, stream)
+	var created dtos.PinStreamEvent
+	if event != "created" || json.Unmarshal([]byte(data), &created) != nil || created.Pin == nil || created.Pin.ID != pin.ID {
+		t.Fatalf("expected created event for %s, got %q %q", pin.ID, event, data)
+	}
+
+	patch, _ := http.NewRequest(http.MethodPatch, server.URL+"/pins/stream/"+ready.StreamID.String(), strings.NewReader(`{"bbox":"2,48,3,49"}`))
+	patchResp, err := http.DefaultClient.Do(patch)
+	if err != nil {
+		t.Fatalf("patch stream: %v", err)
+	}
+	patchResp.Body.Close()
+	if patchResp.StatusCode != http.StatusNoContent {
+		t.Fatalf("expected status %d got %d", http.StatusNoContent, patchResp.StatusCode)
+	}
+
+	// The old viewport no longer matches; the new one does
+	publish(streamPin(uuid.New(), "public", -123, 49.25))
+	paris := streamPin(uuid.New(), "public", 2.35, 48.85)
+	publish(paris)
+
+	event, data = readSSE(t, stream)
+	if err := json.Unmarshal([]byte(data), &created); err != nil || event != "created" || created.PinID != paris.ID {
+		t.Fatalf("expected only the pin in the new viewport, got %q %q", event, data)
+	}
+}
+
+func TestPatchPinStreamHandler_OtherUsersStream(t *testing.T) {
+	broker := events.NewBroker()
+	sub := broker.Subscribe(uuid.New(), models.BBox{MinLon: 0, MinLat: 0, MaxLon: 1, MaxLat: 1})
+	defer sub.Close()
+
+	req := authedRequest(http.MethodPatch, "/pins/stream/"+sub.ID.String(), `{"bbox":"2,48,3,49"}`, uuid.New(), "streamID", sub.ID.String())
+	rec := httptest.NewRecorder()
+
+	PatchPinStreamHandler(broker)(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d got %d", http.StatusNotFound, rec.Code)
+	}
+	if sub.BBox().MinLon != 0 {
+		t.Fatalf("another user's viewport was moved")
+	}
+}
+
+func TestPatchPinStreamHandler_ViewportTooSmall(t *testing.T) {
+	broker := events.NewBroker()
+	userID := uuid.New()
+	sub := broker.Subscribe(userID, models.BBox{MinLon: 0, MinLat: 0, MaxLon: 1, MaxLat: 1})
+	defer sub.Close()
+
+	// About 10 m across, small enough to single out one pin
+	req := authedRequest(http.MethodPatch, "/pins/stream/"+sub.ID.String(), `{"bbox":"2.35,48.85,2.3501,48.8501"}`, userID, "streamID", sub.ID.String())
+	rec := httptest.NewRecorder()
+
+	PatchPinStreamHandler(broker)(rec, req)
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status %d got %d", http.StatusUnprocessableEntity, rec.Code)
+	}
+	if sub.BBox().MinLon != 0 {
+		t.Fatalf("the viewport was moved")
+	}
+}
+
+func TestPublishPinChanges_PublishesCreates(t *testing.T) {
+	broker := events.NewBroker()
+	sub := broker.Subscribe(uuid.New(), models.BBox{MinLon: -124, MinLat: 49, MaxLon: -122, MaxLat: 50})
+	defer sub.Close()
+
+	created := streamPin(uuid.New(), "public", -123, 49.5)
+	pinRepo := events.PublishPinChanges(&mockPinRepo{
+		createPinFn: func(userID uuid.UUID, emotion string, message string, lon float64, lat float64, visibility string, precision string, expiresAt *time.Time) (*models.Pin, error) {
+			return &created, nil
+		},
+	}, broker)
+
+	if _, err := pinRepo.CreatePin(created.UserID, "happy", "", -123, 49.5, "public", "", nil); err != nil {
+		t.Fatalf("create pin: %v", err)
+	}
+
+	select {
+	case event := <-sub.Events:
+		if event.Type != events.PinCreated || event.Pin.ID != created.ID {
+			t.Fatalf("unexpected event %+v", event)
+		}
+	default:
+		t.Fatalf("expected a created event")
+	}
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"ember/api/dtos"
+	"ember/api/repositories"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultSyncPins = 200
+	maxSyncPins     = 500
+	// syncOverlap moves a finished sync's token back so that changes still
+	// being committed when it was issued are picked up next time. Clients
+	// apply changes idempotently, so seeing some twice is harmless.
+	syncOverlap = time.Minute
+)
+
+var errInvalidSyncToken = errors.New("invalid sync token")
+
+// encodeSyncToken makes a sync token opaque to clients, like a cursor.
+func encodeSyncToken(t repositories.SyncToken) string {
+	raw, _ := json.Marshal(t)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeSyncToken(s string) (*repositories.SyncToken, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errInvalidSyncToken
+	}
+
+	var t repositories.SyncToken
+	if err := json.Unmarshal(raw, &t); err != nil || t.Since.IsZero() {
+		return nil, errInvalidSyncToken
+	}
+	return &t, nil
+}
+
+// orEmpty keeps lists of IDs from encoding as null.
+func orEmpty(ids []uuid.UUID) []uuid.UUID {
+	if ids == nil {
+		return []uuid.UUID{}
+	}
+	return ids
+}
+
+// GET /sync?since=&limit=
+func GetSyncHandler(syncRepo repositories.SyncRepository, pinRepo repositories.PinRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("userID").(uuid.UUID)
+		query := r.URL.Query()
+
+		limit, err := parseLimit(query.Get("limit"), defaultSyncPins, maxSyncPins)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var token *repositories.SyncToken
+		if raw := query.Get("since"); raw != "" {
+			token, err = decodeSyncToken(raw)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if time.Since(token.Since) > repositories.SyncRetention {
+				http.Error(w, "sync token has expired; sync again without one", http.StatusGone)
+				return
+			}
+		}
+
+		changes, err := syncRepo.GetChanges(userID, token, limit)
+		if err != nil {
+			log.Println("get sync changes:", err)
+			http.Error(w, "unable to sync", http.StatusInternalServerError)
+			return
+		}
+
+		resp := dtos.SyncResponse{
+			Token:                 encodeSyncToken(repositories.SyncToken{Since: changes.AsOf.Add(-syncOverlap)}),
+			Pins:                  make([]dtos.Pin, 0, len(changes.Pins)),
+			DeletedPins:           orEmpty(changes.DeletedPins),
+			Friends:               make([]dtos.Friend, 0, len(changes.Friends)),
+			RemovedFriends:        orEmpty(changes.RemovedFriends),
+			IncomingRequests:      []dtos.FriendRequest{},
+			OutgoingRequests:      []dtos.FriendRequest{},
+			RemovedFriendRequests: orEmpty(changes.RemovedRequests),
+		}
+
+		pins := changes.Pins
+		if len(pins) > limit {
+			pins = pins[:limit]
+			last := pins[len(pins)-1]
+			resp.Token = encodeSyncToken(repositories.SyncToken{Since: last.ChangedAt, AfterID: &last.ID})
+			resp.HasMore = true
+		}
+		for _, pin := range pins {
+			resp.Pins = append(resp.Pins, toPinDTO(pin.Pin))
+		}
+		if err := withPinDetails(pinRepo, userID, resp.Pins); err != nil {
+			log.Println("query pin details:", err)
+			http.Error(w, "unable to sync", http.StatusInternalServerError)
+			return
+		}
+
+		if changes.Profile != nil {
+			profile := toMeDTO(*changes.Profile)
+			resp.Profile = &profile
+		}
+		for _, friend := range changes.Friends {
+			resp.Friends = append(resp.Friends, toFriendDTO(friend))
+		}
+		for _, request := range changes.Requests {
+			if request.Incoming {
+				resp.IncomingRequests = append(resp.IncomingRequests, toFriendRequestDTO(request))
+			} else {
+				resp.OutgoingRequests = append(resp.OutgoingRequests, toFriendRequestDTO(request))
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.Println("encode sync response:", err)
+		}
+	}
+}
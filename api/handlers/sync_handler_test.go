@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"ember/api/dtos"
+	"ember/api/models"
+	"ember/api/repositories"
+
+	"github.com/google/uuid"
+)
+
+func decodeSyncResponse(t *testing.T, rec *httptest.ResponseRecorder) dtos.SyncResponse {
+	t.Helper()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	var resp dtos.SyncResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	return resp
+}
+
+func TestGetSyncHandler_FromScratch(t *testing.T) {
+	userID, friendID := uuid.New(), uuid.New()
+	asOf := time.Now().UTC().Truncate(time.Second)
+	var gotToken *repositories.SyncToken
+
+	syncRepo := &mockSyncRepo{
+		getChangesFn: func(u uuid.UUID, token *repositories.SyncToken, pinLimit int) (*models.SyncChanges, error) {
+			gotToken = token
+			return &models.SyncChanges{
+				AsOf:    asOf,
+				Profile: &models.User{ID: u, Username: "me"},
+				Pins:    []models.SyncedPin{{Pin: models.Pin{ID: uuid.New(), UserID: friendID, Visibility: "friends"}, ChangedAt: asOf}},
+				Friends: []models.User{{ID: friendID, Username: "friend"}},
+				Requests: []models.FriendRequest{
+					{User: models.User{ID: uuid.New()}, Incoming: true},
+					{User: models.User{ID: uuid.New()}},
+				},
+			}, nil
+		},
+	}
+
+	rec := httptest.NewRecorder()
+	GetSyncHandler(syncRepo, &mockPinRepo{})(rec, authedRequest(http.MethodGet, "/sync"+"", "", userID))
+	resp := decodeSyncResponse(t, rec)
+
+	if gotToken != nil {
+		t.Fatalf("expected a sync from scratch, got token %+v", gotToken)
+	}
+	if resp.HasMore || resp.Profile == nil || resp.Profile.Username != "me" {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+	if len(resp.Pins) != 1 || len(resp.Friends) != 1 || len(resp.IncomingRequests) != 1 || len(resp.OutgoingRequests) != 1 {
+		t.Fatalf("unexpected changes %+v", resp)
+	}
+	if resp.DeletedPins == nil || resp.RemovedFriends == nil || resp.RemovedFriendRequests == nil {
+		t.Fatalf("expected empty lists rather than null: %s", rec.Body)
+	}
+
+	// The next sync resumes from the snapshot, less the overlap
+	next, err := decodeSyncToken(resp.Token)
+	if err != nil {
+		t.Fatalf("decode token: %v", err)
+	}
+	if !next.Since.Equal(asOf.Add(-syncOverlap)) || next.AfterID != nil {
+		t.Fatalf("unexpected token %+v", next)
+	}
+}
+
+func TestGetSyncHandler_ResumesFromToken(t *testing.T) {
+	since := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+	var gotToken *repositories.SyncToken
+
+	syncRepo := &mockSyncRepo{
+		getChangesFn: func(u uuid.UUID, token *repositories.SyncToken, pinLimit int) (*models.SyncChanges, error) {
+			gotToken = token
+			return &models.SyncChanges{AsOf: time.Now(), DeletedPins: []uuid.UUID{uuid.New()}}, nil
+		},
+	}
+
+	token := encodeSyncToken(repositories.SyncToken{Since: since})
+	rec := httptest.NewRecorder()
+	GetSyncHandler(syncRepo, &mockPinRepo{})(rec, authedRequest(http.MethodGet, "/sync"+"?since="+token, "", uuid.New()))
+	resp := decodeSyncResponse(t, rec)
+
+	if gotToken == nil || !gotToken.Since.Equal(since) {
+		t.Fatalf("expected changes since %v, got %+v", since, gotToken)
+	}
+	if resp.Profile != nil || len(resp.DeletedPins) != 1 {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+}
+
+func TestGetSyncHandler_MorePinsThanLimit(t *testing.T) {
+	changedAt := time.Now().Add(-time.Minute).UTC()
+	syncRepo := &mockSyncRepo{
+		getChangesFn: func(u uuid.UUID, token *repositories.SyncToken, pinLimit int) (*models.SyncChanges, error) {
+			// The repository returns one pin past the limit
+			var pins []models.SyncedPin
+			for range pinLimit + 1 {
+				pins = append(pins, models.SyncedPin{Pin: models.Pin{ID: uuid.New(), UserID: u}, ChangedAt: changedAt})
+			}
+			return &models.SyncChanges{AsOf: time.Now(), Pins: pins}, nil
+		},
+	}
+
+	rec := httptest.NewRecorder()
+	GetSyncHandler(syncRepo, &mockPinRepo{})(rec, authedRequest(http.MethodGet, "/sync"+"?limit=2", "", uuid.New()))
+	resp := decodeSyncResponse(t, rec)
+
+	if !resp.HasMore || len(resp.Pins) != 2 {
+		t.Fatalf("expected 2 pins and more to come, got %d, has_more=%v", len(resp.Pins), resp.HasMore)
+	}
+	next, err := decodeSyncToken(resp.Token)
+	if err != nil {
+		t.Fatalf("decode token: %v", err)
+	}
+	if next.AfterID == nil || *next.AfterID != resp.Pins[1].ID || !next.Since.Equal(changedAt) {
+		t.Fatalf("expected the token to resume after the last pin, got %+v", next)
+	}
+}
+
+func TestGetSyncHandler_BadTokens(t *testing.T) {
+	expired := encodeSyncToken(repositories.SyncToken{Since: time.Now().Add(-repositories.SyncRetention - time.Hour)})
+	cases := []struct {
+		query string
+		want  int
+	}{
+		{"?since=not-a-token", http.StatusBadRequest},
+		{"?since=" + encodeSyncToken(repositories.SyncToken{}), http.StatusBadRequest},
+		{"?since=" + expired, http.StatusGone},
+		{"?limit=0", http.StatusBadRequest},
+	}
+	for _, tc := range cases {
+		syncRepo := &mockSyncRepo{
+			getChangesFn: func(u uuid.UUID, token *repositories.SyncToken, pinLimit int) (*models.SyncChanges, error) {
+				t.Fatalf("%s: GetChanges must not be called", tc.query)
+				return nil, nil
+			},
+		}
+		rec := httptest.NewRecorder()
+		GetSyncHandler(syncRepo, &mockPinRepo{})(rec, authedRequest(http.MethodGet, "/sync"+tc.query, "", uuid.New()))
+		if rec.Code != tc.want {
+			t.Errorf("%s: expected status %d got %d", tc.query, tc.want, rec.Code)
+		}
+	}
+}
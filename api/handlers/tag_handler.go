@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"time"
+
+	"ember/api/dtos"
+	"ember/api/models"
+	"ember/api/repositories"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	// Trending tags count pins posted within this window.
+	trendingTagWindow = 24 * time.Hour
+	maxTrendingTags   = 20
+)
+
+// GET /tags/{tag}/pins?limit=&cursor=
+func GetTagPinsHandler(pinRepo repositories.PinRepository, tagRepo repositories.TagRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("userID").(uuid.UUID)
+
+		tag, ok := models.NormalizeTag(chi.URLParam(r, "tag"))
+		if !ok {
+			http.Error(w, "invalid tag", http.StatusBadRequest)
+			return
+		}
+
+		page, err := parsePage(r, defaultPageSize, maxPageSize)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		pins, err := tagRepo.QueryTagPins(userID, tag, page)
+		if err != nil {
+			log.Println("query tag pins:", err)
+			http.Error(w, "unable to fetch pins", http.StatusInternalServerError)
+			return
+		}
+
+		var resp dtos.GetPinListResponse
+		resp.Pins, resp.NextCursor = paginate(pins, page, toPinDTO, pinCursor)
+		if err := withPinDetails(pinRepo, userID, resp.Pins); err != nil {
+			log.Println("query pin details:", err)
+			http.Error(w, "unable to fetch pins", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.Println("encode tag pins response:", err)
+		}
+	}
+}
+
+// GET /tags/trending?bbox=minLon,minLat,maxLon,maxLat
+func GetTrendingTagsHandler(tagRepo repositories.TagRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("userID").(uuid.UUID)
+
+		raw := r.URL.Query().Get("bbox")
+		if raw == "" {
+			http.Error(w, "missing required query parameter: bbox", http.StatusBadRequest)
+			return
+		}
+		bbox, err := parseBBox(raw)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if bbox.AreaKm2() > maxBBoxAreaKm2 {
+			http.Error(w, "bbox is too large; zoom in", http.StatusBadRequest)
+			return
+		}
+
+		tags, err := tagRepo.QueryTrendingTags(userID, bbox, time.Now().Add(-trendingTagWindow), maxTrendingTags)
+		if err != nil {
+			log.Println("query trending tags:", err)
+			http.Error(w, "unable to fetch trending tags", http.StatusInternalServerError)
+			return
+		}
+
+		resp := dtos.GetTrendingTagsResponse{Tags: make([]dtos.TrendingTag, 0, len(tags))}
+		for _, t := range tags {
+			resp.Tags = append(resp.Tags, dtos.TrendingTag{Tag: t.Tag, PinCount: t.Pins, AuthorCount: t.Authors})
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.Println("encode trending tags response:", err)
+		}
+	}
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"ember/api/dtos"
+	"ember/api/models"
+	"ember/api/repositories"
+
+	"github.com/google/uuid"
+)
+
+func TestPostPinsHandler_NotifiesMentionedFriendsWhoCanSeeThePin(t *testing.T) {
+	userID, pinID := uuid.New(), uuid.New()
+	canSee, cannotSee := uuid.New(), uuid.New()
+	message := "sunset with @dana and @eli #goldenhour"
+
+	var saved models.MessageRefs
+	pin := &models.Pin{ID: pinID, UserID: userID, Emotion: "happy", Message: sql.NullString{String: message, Valid: true}, Visibility: "friends", CreatedAt: time.Now()}
+	pinRepo := &mockPinRepo{
+		createPinFn: func(uuid.UUID, string, string, float64, float64, string, string, *time.Time) (*models.Pin, error) {
+			return pin, nil
+		},
+		getPinFn: func(viewerID uuid.UUID, id uuid.UUID) (*models.Pin, error) {
+			if viewerID == cannotSee {
+				return nil, nil
+			}
+			return pin, nil
+		},
+		saveRefsFn: func(id uuid.UUID, refs models.MessageRefs) ([]uuid.UUID, error) {
+			saved = refs
+			return []uuid.UUID{canSee, cannotSee}, nil
+		},
+	}
+	notifications := &mockNotificationRepo{}
+
+	body := `{"emotion":"happy","message":"` + message + `","longitude":-123.12,"latitude":49.28,"visibility":"friends"}`
+	req := authedRequest(http.MethodPost, "/pins", body, userID)
+	rec := httptest.NewRecorder()
+	PostPinsHandler(pinRepo, stubEmotions("happy"), notifications, &mockUserRepo{}, newMockZoneRepo())(rec, req)
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status %d got %d: %s", http.StatusCreated, rec.Code, rec.Body)
+	}
+	if !reflect.DeepEqual(saved.Mentions, []string{"dana", "eli"}) || !reflect.DeepEqual(saved.Tags, []string{"goldenhour"}) {
+		t.Errorf("saved refs = %+v", saved)
+	}
+	if len(notifications.created) != 1 {
+		t.Fatalf("expected 1 notification got %d", len(notifications.created))
+	}
+	n := notifications.created[0]
+	if n.RecipientID != canSee || n.Type != models.NotificationPinMention || *n.PinID != pinID {
+		t.Errorf("unexpected notification %+v", n)
+	}
+}
+
+func TestPatchPinHandler_ReindexesOnlyWhenMessageOrVisibilityChanges(t *testing.T) {
+	userID, pinID := uuid.New(), uuid.New()
+	calls := 0
+	pin := &models.Pin{ID: pinID, UserID: userID, Emotion: "happy", Visibility: "public", CreatedAt: time.Now()}
+	pinRepo := &mockPinRepo{
+		getPinFn: func(uuid.UUID, uuid.UUID) (*models.Pin, error) {
+			return pin, nil
+		},
+		updatePinFn: func(uuid.UUID, uuid.UUID, repositories.PinUpdate) (*models.Pin, error) {
+			return pin, nil
+		},
+		saveRefsFn: func(uuid.UUID, models.MessageRefs) ([]uuid.UUID, error) {
+			calls++
+			return nil, nil
+		},
+	}
+	handler := PatchPinHandler(pinRepo, stubEmotions("happy"), &mockNotificationRepo{})
+
+	for _, body := range []string{`{"emotion":"happy"}`, `{"message":"now #tagged"}`, `{"visibility":"friends"}`} {
+		req := authedRequest(http.MethodPatch, "/pins/"+pinID.String(), body, userID, "pinID", pinID.String())
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status %d got %d: %s", http.StatusOK, rec.Code, rec.Body)
+		}
+	}
+	if calls != 2 {
+		t.Errorf("expected refs to be saved twice got %d", calls)
+	}
+}
+
+func TestGetTagPinsHandler_NormalizesTag(t *testing.T) {
+	userID := uuid.New()
+	var queried string
+	tagRepo := &mockTagRepo{
+		tagPinsFn: func(id uuid.UUID, tag string, page repositories.Page) ([]models.Pin, error) {
+			queried = tag
+			return []models.Pin{{ID: uuid.New(), UserID: uuid.New(), Emotion: "calm", Visibility: "public", CreatedAt: time.Now()}}, nil
+		},
+	}
+
+	req := authedRequest(http.MethodGet, "/tags/Sunset/pins", "", userID, "tag", "Sunset")
+	rec := httptest.NewRecorder()
+	GetTagPinsHandler(&mockPinRepo{}, tagRepo)(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	if queried != "sunset" {
+		t.Errorf("queried tag %q", queried)
+	}
+	var resp dtos.GetPinListResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Pins) != 1 || resp.NextCursor != nil {
+		t.Errorf("unexpected response %+v", resp)
+	}
+}
+
+func TestGetTagPinsHandler_InvalidTag(t *testing.T) {
+	req := authedRequest(http.MethodGet, "/tags/2024/pins", "", uuid.New(), "tag", "2024")
+	rec := httptest.NewRecorder()
+	GetTagPinsHandler(&mockPinRepo{}, &mockTagRepo{})(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestGetTrendingTagsHandler(t *testing.T) {
+	var gotSince time.Time
+	tagRepo := &mockTagRepo{
+		trendingFn: func(userID uuid.UUID, bbox models.BBox, since time.Time, limit int) ([]models.TrendingTag, error) {
+			gotSince = since
+			return []models.TrendingTag{{Tag: "sunset", Pins: 5, Authors: 3}}, nil
+		},
+	}
+	handler := GetTrendingTagsHandler(tagRepo)
+
+	req := authedRequest(http.MethodGet, "/tags/trending?bbox=-123.2,49.2,-123.0,49.3", "", uuid.New())
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status %d got %d: %s", http.StatusOK, rec.Code, rec.Body)
+	}
+	if time.Since(gotSince) < trendingTagWindow-time.Minute {
+		t.Errorf("since = %v", gotSince)
+	}
+	var resp dtos.GetTrendingTagsResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Tags) != 1 || resp.Tags[0] != (dtos.TrendingTag{Tag: "sunset", PinCount: 5, AuthorCount: 3}) {
+		t.Errorf("unexpected response %+v", resp)
+	}
+
+	req = authedRequest(http.MethodGet, "/tags/trending", "", uuid.New())
+	rec = httptest.NewRecorder()
+	handler(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("missing bbox: expected status %d got %d", http.StatusBadRequest, rec.Code)
+	}
+}
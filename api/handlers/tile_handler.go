@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"ember/api/repositories"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	maxTileZoom = 22
+	// webMercatorWorldMeters is the width of the whole web-mercator plane.
+	webMercatorWorldMeters = 40075016.68557849
+	// tileCellsPerSide merges pins closer than 1/32 of a tile into one feature.
+	tileCellsPerSide = 32
+	// tileCacheTTL is both the server cache lifetime and the client max-age.
+	tileCacheTTL        = 30 * time.Second
+	tileCacheMaxEntries = 10000
+)
+
+// tileCache keeps rendered tiles per user and tile. Keys include the user
+// because visibility rules make every user's tiles different.
+type tileCache struct {
+	mu      sync.Mutex
+	entries map[string]tileCacheEntry
+}
+
+type tileCacheEntry struct {
+	tile    []byte
+	etag    string
+	expires time.Time
+}
+
+func newTileCache() *tileCache {
+	return &tileCache{entries: map[string]tileCacheEntry{}}
+}
+
+func (c *tileCache) get(key string, now time.Time) (tileCacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || now.After(entry.expires) {
+		return tileCacheEntry{}, false
+	}
+	return entry, true
+}
+
+func (c *tileCache) put(key string, entry tileCacheEntry, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) >= tileCacheMaxEntries {
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		// Still full of live entries: drop an arbitrary one.
+		for k := range c.entries {
+			if len(c.entries) < tileCacheMaxEntries {
+				break
+			}
+			delete(c.entries, k)
+		}
+	}
+	c.entries[key] = entry
+}
+
+// parseTileCoords validates z/x/y against the web-mercator tile pyramid.
+func parseTileCoords(r *http.Request) (int, int, int, bool) {
+	z, errZ := strconv.Atoi(chi.URLParam(r, "z"))
+	x, errX := strconv.Atoi(chi.URLParam(r, "x"))
+	y, errY := strconv.Atoi(chi.URLParam(r, "y"))
+	if errZ != nil || errX != nil || errY != nil || z < 0 || z > maxTileZoom {
+		return 0, 0, 0, false
+	}
+
+	n := 1 << z
+	if x < 0 || x >= n || y < 0 || y >= n {
+		return 0, 0, 0, false
+	}
+	return z, x, y, true
+}
+
+// GET /tiles/pins/{z}/{x}/{y}.mvt
+func GetPinTileHandler(pinRepo repositories.PinRepository) http.HandlerFunc {
+	cache := newTileCache()
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("userID").(uuid.UUID)
+
+		z, x, y, ok := parseTileCoords(r)
+		if !ok {
+			http.Error(w, "invalid tile coordinates", http.StatusBadRequest)
+			return
+		}
+
+		now := time.Now()
+		key := fmt.Sprintf("%s/%d/%d/%d", userID, z, x, y)
+		entry, hit := cache.get(key, now)
+		if !hit {
+			cellMeters := webMercatorWorldMeters / math.Exp2(float64(z)) / tileCellsPerSide
+			tile, err := pinRepo.QueryPinTile(userID, z, x, y, cellMeters)
+			if err != nil {
+				log.Println("query pin tile:", err)
+				http.Error(w, "unable to render tile", http.StatusInternalServerError)
+				return
+			}
+
+			sum := sha256.Sum256(append([]byte(key), tile...))
+			entry = tileCacheEntry{
+				tile:    tile,
+				etag:    `"` + hex.EncodeToString(sum[:16]) + `"`,
+				expires: now.Add(tileCacheTTL),
+			}
+			cache.put(key, entry, now)
+		}
+
+		// Tiles differ per user, so only the user's own client may cache them.
+		w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(tileCacheTTL.Seconds())))
+		w.Header().Set("Vary", "Authorization")
+		w.Header().Set("ETag", entry.etag)
+
+		if etagMatches(r.Header.Get("If-None-Match"), entry.etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		if len(entry.tile) == 0 {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/vnd.mapbox-vector-tile")
+		if _, err := w.Write(entry.tile); err != nil {
+			log.Println("write pin tile:", err)
+		}
+	}
+}
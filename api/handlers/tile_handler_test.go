@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+func newTileRouter(h http.HandlerFunc) chi.Router {
+	r := chi.NewRouter()
+	r.Get("/tiles/pins/{z}/{x}/{y}.mvt", h)
+	return r
+}
+
+func TestGetPinTileHandler_CachesPerUser(t *testing.T) {
+	calls := map[uuid.UUID]int{}
+	pinRepo := &mockPinRepo{
+		queryPinTileFn: func(id uuid.UUID, z int, x int, y int, cellMeters float64) ([]byte, error) {
+			if z != 3 || x != 2 || y != 1 {
+				t.Fatalf("unexpected tile %d/%d/%d", z, x, y)
+			}
+			calls[id]++
+			return []byte("tile-for-" + id.String()), nil
+		},
+	}
+	router := newTileRouter(GetPinTileHandler(pinRepo))
+	alice, bob := uuid.New(), uuid.New()
+
+	for i := 0; i < 2; i++ {
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, authedRequest(http.MethodGet, "/tiles/pins/3/2/1.mvt", "", alice))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected status %d got %d", http.StatusOK, rec.Code)
+		}
+		if rec.Header().Get("Content-Type") != "application/vnd.mapbox-vector-tile" {
+			t.Fatalf("unexpected content type %q", rec.Header().Get("Content-Type"))
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, authedRequest(http.MethodGet, "/tiles/pins/3/2/1.mvt", "", bob))
+	if rec.Body.String() != "tile-for-"+bob.String() {
+		t.Fatalf("served another user's tile: %q", rec.Body.String())
+	}
+
+	if calls[alice] != 1 || calls[bob] != 1 {
+		t.Fatalf("expected one render per user, got %v", calls)
+	}
+}
+
+func TestGetPinTileHandler_NotModified(t *testing.T) {
+	pinRepo := &mockPinRepo{
+		queryPinTileFn: func(id uuid.UUID, z int, x int, y int, cellMeters float64) ([]byte, error) {
+			return []byte("tile"), nil
+		},
+	}
+	router := newTileRouter(GetPinTileHandler(pinRepo))
+	userID := uuid.New()
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, authedRequest(http.MethodGet, "/tiles/pins/0/0/0.mvt", "", userID))
+	etag := rec.Header().Get("ETag")
+	if etag == "" {
+		t.Fatalf("expected an ETag")
+	}
+
+	req := authedRequest(http.MethodGet, "/tiles/pins/0/0/0.mvt", "", userID)
+	req.Header.Set("If-None-Match", etag)
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotModified {
+		t.Fatalf("expected status %d got %d", http.StatusNotModified, rec.Code)
+	}
+}
+
+func TestGetPinTileHandler_OutOfRange(t *testing.T) {
+	router := newTileRouter(GetPinTileHandler(&mockPinRepo{}))
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, authedRequest(http.MethodGet, "/tiles/pins/2/4/0.mvt", "", uuid.New()))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d got %d", http.StatusBadRequest, rec.Code)
+	}
+}
@@ -5,12 +5,32 @@ import (
 	"net/http"
 
 	"ember/api/dtos"
+	"ember/api/models"
 	"ember/api/repositories"
 	"log"
 
 	"github.com/google/uuid"
 )
 
+func toMeDTO(user models.User) dtos.GetMeResponse {
+	resp := dtos.GetMeResponse{
+		ID:        user.ID,
+		Username:  user.Username,
+		CreatedAt: user.CreatedAt,
+		UpdatedAt: user.UpdatedAt,
+	}
+
+	if user.DisplayName.Valid {
+		resp.DisplayName = user.DisplayName.String
+	}
+
+	if user.Bio.Valid {
+		resp.Bio = user.Bio.String
+	}
+
+	return resp
+}
+
 // GET /me
 func GetMeHandler(userRepo repositories.UserRepository) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -24,23 +44,8 @@ func GetMeHandler(userRepo repositories.UserRepository) http.HandlerFunc {
 			return
 		}
 
-		resp := dtos.GetMeResponse{
-			ID:        user.ID,
-			Username:  user.Username,
-			CreatedAt: user.CreatedAt,
-			UpdatedAt: user.UpdatedAt,
-		}
-
-		if user.DisplayName.Valid {
-			resp.DisplayName = user.DisplayName.String
-		}
-
-		if user.Bio.Valid {
-			resp.Bio = user.Bio.String
-		}
-
 		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(resp)
+		json.NewEncoder(w).Encode(toMeDTO(*user))
 	}
 }
 
@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"ember/api/dtos"
+	"ember/api/models"
+	"ember/api/repositories"
+	"ember/api/validation"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+func toZoneDTO(zone models.Zone) dtos.Zone {
+	z := dtos.Zone{
+		ID:         zone.ID,
+		Name:       zone.Name,
+		Visibility: zone.Visibility,
+		Precision:  zone.Precision,
+		CreatedAt:  zone.CreatedAt,
+		UpdatedAt:  zone.UpdatedAt,
+	}
+	if zone.Center != nil {
+		z.Circle = &dtos.ZoneCircle{Longitude: zone.Center.Longitude, Latitude: zone.Center.Latitude, RadiusM: zone.RadiusM}
+	}
+	for _, loc := range zone.Polygon {
+		z.Polygon = append(z.Polygon, [2]float64{loc.Longitude, loc.Latitude})
+	}
+	return z
+}
+
+func toZoneModel(req dtos.ZoneRequest) models.Zone {
+	zone := models.Zone{
+		Name:       req.Name,
+		Visibility: req.Visibility,
+		Precision:  req.Precision,
+	}
+	if req.Circle != nil {
+		zone.Center = &models.Location{Longitude: req.Circle.Longitude, Latitude: req.Circle.Latitude}
+		zone.RadiusM = req.Circle.RadiusM
+	} else {
+		zone.Polygon = req.Ring()
+	}
+	return zone
+}
+
+func writeZone(w http.ResponseWriter, status int, zone models.Zone) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(toZoneDTO(zone)); err != nil {
+		log.Println("encode zone response:", err)
+	}
+}
+
+// applyPrivateZones downgrades a new pin's visibility and precision for each
+// of the author's zones it is in. A pin following the author's defaults is
+// coarsened from the friends default, as strangers never see it anyway.
+func applyPrivateZones(userRepo repositories.UserRepository, zoneRepo repositories.ZoneRepository, userID uuid.UUID, lon float64, lat float64, visibility string, precision string) (string, string, error) {
+	zones, err := zoneRepo.QueryZonesAt(userID, lon, lat)
+	if err != nil {
+		return "", "", err
+	}
+
+	for _, zone := range zones {
+		if zone.Precision != "" && precision == "" {
+			privacy, err := userRepo.GetLocationPrivacy(userID)
+			if err != nil {
+				return "", "", err
+			}
+			if privacy != nil {
+				precision = privacy.FriendsPrecision
+			}
+		}
+		visibility, precision = zone.Downgrade(visibility, precision)
+	}
+	return visibility, precision, nil
+}
+
+// GET /me/zones
+func GetZonesHandler(zoneRepo repositories.ZoneRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("userID").(uuid.UUID)
+
+		zones, err := zoneRepo.ListZones(userID)
+		if err != nil {
+			log.Println("list zones:", err)
+			http.Error(w, "unable to fetch zones", http.StatusInternalServerError)
+			return
+		}
+
+		resp := dtos.GetZonesResponse{Zones: make([]dtos.Zone, 0, len(zones))}
+		for _, zone := range zones {
+			resp.Zones = append(resp.Zones, toZoneDTO(zone))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.Println("encode zones response:", err)
+		}
+	}
+}
+
+// POST /me/zones
+func PostZonesHandler(zoneRepo repositories.ZoneRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("userID").(uuid.UUID)
+
+		var req dtos.ZoneRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+
+		zone, err := zoneRepo.CreateZone(userID, toZoneModel(req))
+		if errors.Is(err, repositories.ErrTooManyZones) {
+			var errs validation.Errors
+			errs.Add("zones", fmt.Sprintf("a user can have at most %d zones", models.MaxZonesPerUser))
+			writeValidationError(w, errs)
+			return
+		}
+		if err != nil {
+			log.Println("create zone:", err)
+			http.Error(w, "unable to create zone", http.StatusInternalServerError)
+			return
+		}
+
+		writeZone(w, http.StatusCreated, *zone)
+	}
+}
+
+// PUT /me/zones/{zoneID}
+func PutZoneHandler(zoneRepo repositories.ZoneRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("userID").(uuid.UUID)
+
+		zoneID, err := uuid.Parse(chi.URLParam(r, "zoneID"))
+		if err != nil {
+			http.Error(w, "invalid zone ID", http.StatusBadRequest)
+			return
+		}
+
+		var req dtos.ZoneRequest
+		if !decodeRequest(w, r, &req) {
+			return
+		}
+
+		zone, err := zoneRepo.UpdateZone(userID, zoneID, toZoneModel(req))
+		if errors.Is(err, repositories.ErrZoneNotFound) {
+			http.Error(w, "zone does not exist", http.StatusNotFound)
+			return
+		}
+		if err != nil {
+			log.Println("update zone:", err)
+			http.Error(w, "unable to update zone", http.StatusInternalServerError)
+			return
+		}
+
+		writeZone(w, http.StatusOK, *zone)
+	}
+}
+
+// DELETE /me/zones/{zoneID}
+func DeleteZoneHandler(zoneRepo repositories.ZoneRepository) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := r.Context().Value("userID").(uuid.UUID)
+
+		zoneID, err := uuid.Parse(chi.URLParam(r, "zoneID"))
+		if err != nil {
+			http.Error(w, "invalid zone ID", http.StatusBadRequest)
+			return
+		}
+
+		err = zoneRepo.DeleteZone(userID, zoneID)
+		switch {
+		case errors.Is(err, repositories.ErrZoneNotFound):
+			http.Error(w, "zone does not exist", http.StatusNotFound)
+		case err != nil:
+			log.Println("delete zone:", err)
+			http.Error(w, "unable to delete zone", http.StatusInternalServerError)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}
+}
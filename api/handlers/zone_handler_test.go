@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"ember/api/dtos"
+	"ember/api/models"
+
+	"github.com/google/uuid"
+)
+
+func TestPostZonesHandler_Circle(t *testing.T) {
+	userID := uuid.New()
+	repo := newMockZoneRepo()
+
+	body := `{"name":"Home","circle":{"longitude":-123.12,"latitude":49.28,"radius_m":200},"visibility":"friends"}`
+	rec := httptest.NewRecorder()
+	PostZonesHandler(repo)(rec, authedRequest(http.MethodPost, "/me/zones", body, userID))
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status %d got %d: %s", http.StatusCreated, rec.Code, rec.Body)
+	}
+	var resp dtos.Zone
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if resp.Circle == nil || resp.Circle.RadiusM != 200 || resp.Polygon != nil || resp.Visibility != "friends" {
+		t.Fatalf("unexpected zone %+v", resp)
+	}
+	if repo.owners[resp.ID] != userID {
+		t.Fatalf("zone not saved for the caller")
+	}
+}
+
+func TestPostZonesHandler_PolygonDropsClosingVertex(t *testing.T) {
+	repo := newMockZoneRepo()
+
+	body := `{"name":"Work","polygon":[[-123.1,49.2],[-123.09,49.2],[-123.09,49.21],[-123.1,49.2]],"location_precision":"city"}`
+	rec := httptest.NewRecorder()
+	PostZonesHandler(repo)(rec, authedRequest(http.MethodPost, "/me/zones", body, uuid.New()))
+
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("expected status %d got %d: %s", http.StatusCreated, rec.Code, rec.Body)
+	}
+	var resp dtos.Zone
+	if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(resp.Polygon) != 3 || resp.Circle != nil || resp.Precision != models.PrecisionCity {
+		t.Fatalf("unexpected zone %+v", resp)
+	}
+}
+
+func TestPostZonesHandler_ValidationErrors(t *testing.T) {
+	for _, body := range []string{
+		`{"name":"","circle":{"longitude":0,"latitude":0,"radius_m":200},"visibility":"private"}`,
+		`{"name":"Home","visibility":"private"}`,
+		`{"name":"Home","circle":{"longitude":0,"latitude":0,"radius_m":200},"polygon":[[0,0],[1,0],[0,1]],"visibility":"private"}`,
+		`{"name":"Home","circle":{"longitude":0,"latitude":0,"radius_m":5},"visibility":"private"}`,
+		`{"name":"Home","circle":{"longitude":0,"latitude":0,"radius_m":200}}`,
+		`{"name":"Home","circle":{"longitude":0,"latitude":0,"radius_m":200},"visibility":"public"}`,
+		`{"name":"Home","circle":{"longitude":0,"latitude":0,"radius_m":200},"location_precision":"exact"}`,
+		`{"name":"Bow tie","polygon":[[0,0],[0.01,0.01],[0.01,0],[0,0.01]],"visibility":"private"}`,
+		`{"name":"Country","polygon":[[0,0],[5,0],[5,5],[0,5]],"visibility":"private"}`,
+	} {
+		repo := newMockZoneRepo()
+		rec := httptest.NewRecorder()
+		PostZonesHandler(repo)(rec, authedRequest(http.MethodPost, "/me/zones", body, uuid.New()))
+
+		if rec.Code != http.StatusUnprocessableEntity {
+			t.Errorf("%s: expected status %d got %d", body, http.StatusUnprocessableEntity, rec.Code)
+		}
+		if len(repo.zones) != 0 {
+			t.Errorf("%s: invalid zone was saved", body)
+		}
+	}
+}
+
+func TestPostZonesHandler_TooMany(t *testing.T) {
+	userID := uuid.New()
+	repo := newMockZoneRepo()
+	for range models.MaxZonesPerUser {
+		repo.CreateZone(userID, models.Zone{Name: "Zone", Visibility: "private"})
+	}
+
+	body := `{"name":"One more","circle":{"longitude":0,"latitude":0,"radius_m":200},"visibility":"private"}`
+	rec := httptest.NewRecorder()
+	PostZonesHandler(repo)(rec, authedRequest(http.MethodPost, "/me/zones", body, userID))
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status %d got %d", http.StatusUnprocessableEntity, rec.Code)
+	}
+}
+
+func TestPutZoneHandler_OtherUsersZone(t *testing.T) {
+	repo := newMockZoneRepo()
+	zone, _ := repo.CreateZone(uuid.New(), models.Zone{Name: "Home", Visibility: "private"})
+
+	body := `{"name":"Mine now","circle":{"longitude":0,"latitude":0,"radius_m":200},"visibility":"friends"}`
+	req := authedRequest(http.MethodPut, "/me/zones/"+zone.ID.String(), body, uuid.New(), "zoneID", zone.ID.String())
+	rec := httptest.NewRecorder()
+	PutZoneHandler(repo)(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d got %d", http.StatusNotFound, rec.Code)
+	}
+	if repo.zones[zone.ID].Name != "Home" {
+		t.Fatalf("another user's zone was changed")
+	}
+}
+
+func TestDeleteZoneHandler(t *testing.T) {
+	userID := uuid.New()
+	repo := newMockZoneRepo()
+	zone, _ := repo.CreateZone(userID, models.Zone{Name: "Home", Visibility: "private"})
+
+	req := authedRequest(http.MethodDelete, "/me/zones/"+zone.ID.String(), "", userID, "zoneID", zone.ID.String())
+	rec := httptest.NewRecorder()
+	DeleteZoneHandler(repo)(rec, req)
+
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d got %d", http.StatusNoContent, rec.Code)
+	}
+	if len(repo.zones) != 0 {
+		t.Fatalf("zone was not deleted")
+	}
+}
+
+func TestPostPinsHandler_DowngradedInsidePrivateZone(t *testing.T) {
+	var gotVisibility, gotPrecision string
+	pinRepo := &mockPinRepo{
+		createPinFn: func(u uuid.UUID, emotion string, message string, lon float64, lat float64, visibility string, precision string, expiresAt *time.Time) (*models.Pin, error) {
+			gotVisibility, gotPrecision = visibility, precision
+			return &models.Pin{ID: uuid.New(), UserID: u, Emotion: emotion, Visibility: visibility}, nil
+		},
+	}
+	zoneRepo := newMockZoneRepo()
+
+	cases := []struct {
+		name                   string
+		zones                  []models.Zone
+		body                   string
+		wantVis, wantPrecision string
+	}{
+		{"outside any zone", nil, `{"emotion":"happy","longitude":0,"latitude":0,"visibility":"public"}`, "public", ""},
+		{"zone visibility", []models.Zone{{Visibility: "friends"}}, `{"emotion":"happy","longitude":0,"latitude":0,"visibility":"public"}`, "friends", ""},
+		// The mock user's friends default is 100m
+		{"coarser than the defaults", []models.Zone{{Precision: models.Precision1km}}, `{"emotion":"happy","longitude":0,"latitude":0,"visibility":"public"}`, "public", models.Precision1km},
+		{"coarser than requested", []models.Zone{{Precision: models.Precision100m}}, `{"emotion":"happy","longitude":0,"latitude":0,"visibility":"friends","location_precision":"exact"}`, "friends", models.Precision100m},
+		{"requested is coarser", []models.Zone{{Precision: models.Precision100m}}, `{"emotion":"happy","longitude":0,"latitude":0,"visibility":"friends","location_precision":"city"}`, "friends", models.PrecisionCity},
+		{"strictest of several", []models.Zone{{Visibility: "friends"}, {Visibility: "private", Precision: models.PrecisionCity}}, `{"emotion":"happy","longitude":0,"latitude":0,"visibility":"public"}`, "private", models.PrecisionCity},
+	}
+
+	for _, tc := range cases {
+		zoneRepo.zonesAt = tc.zones
+		rec := httptest.NewRecorder()
+		PostPinsHandler(pinRepo, stubEmotions("happy"), &mockNotificationRepo{}, &mockUserRepo{}, zoneRepo)(rec, authedRequest(http.MethodPost, "/pins", tc.body, uuid.New()))
+
+		if rec.Code != http.StatusCreated {
+			t.Fatalf("%s: expected status %d got %d: %s", tc.name, http.StatusCreated, rec.Code, rec.Body)
+		}
+		if gotVisibility != tc.wantVis || gotPrecision != tc.wantPrecision {
+			t.Errorf("%s: expected (%q, %q) got (%q, %q)", tc.name, tc.wantVis, tc.wantPrecision, gotVisibility, gotPrecision)
+		}
+		if !strings.Contains(rec.Body.String(), `"visibility":"`+tc.wantVis+`"`) {
+			t.Errorf("%s: response doesn't show the downgraded visibility: %s", tc.name, rec.Body)
+		}
+	}
+}
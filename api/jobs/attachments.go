@@ -0,0 +1,68 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"ember/api/media"
+	"ember/api/repositories"
+
+	"github.com/google/uuid"
+)
+
+const attachmentSweepBatchSize = 100
+
+// RunAttachmentSweeper deletes the stored images of attachments that were
+// removed or whose pin was deleted, once per interval, until ctx is
+// cancelled. Rows are purged only after both of their blobs are gone, so a
+// failed delete is retried on the next sweep.
+func RunAttachmentSweeper(ctx context.Context, attachmentRepo repositories.AttachmentRepository, store media.BlobStore, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		swept, err := sweepAttachments(ctx, attachmentRepo, store)
+		if err != nil {
+			log.Println("sweep attachments:", err)
+		} else if swept > 0 {
+			log.Printf("Swept %d detached attachments\n", swept)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func sweepAttachments(ctx context.Context, attachmentRepo repositories.AttachmentRepository, store media.BlobStore) (int, error) {
+	ids, err := attachmentRepo.ListDetachedAttachments(attachmentSweepBatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	deleted := make([]uuid.UUID, 0, len(ids))
+	for _, id := range ids {
+		ok := true
+		for _, variant := range []string{media.VariantFull, media.VariantThumb} {
+			// Delete treats a missing blob as already deleted.
+			if err := store.Delete(ctx, media.AttachmentKey(id, variant)); err != nil {
+				log.Println("delete attachment blob:", err)
+				ok = false
+			}
+		}
+		if ok {
+			deleted = append(deleted, id)
+		}
+	}
+	if len(deleted) == 0 {
+		return 0, nil
+	}
+
+	if err := attachmentRepo.PurgeAttachments(deleted); err != nil {
+		return 0, err
+	}
+	return len(deleted), nil
+}
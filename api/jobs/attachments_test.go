@@ -0,0 +1,81 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"ember/api/media"
+	"ember/api/models"
+
+	"github.com/google/uuid"
+)
+
+type mockAttachmentRepo struct {
+	detached []uuid.UUID
+	purged   []uuid.UUID
+}
+
+func (m *mockAttachmentRepo) AddAttachments(userID uuid.UUID, pinID uuid.UUID, attachments []models.Attachment, max int) ([]models.Attachment, error) {
+	return nil, nil
+}
+
+func (m *mockAttachmentRepo) GetAttachment(pinID uuid.UUID, attachmentID uuid.UUID) (*models.Attachment, error) {
+	return nil, nil
+}
+
+func (m *mockAttachmentRepo) DetachAttachment(userID uuid.UUID, pinID uuid.UUID, attachmentID uuid.UUID) error {
+	return nil
+}
+
+func (m *mockAttachmentRepo) ListDetachedAttachments(limit int) ([]uuid.UUID, error) {
+	return m.detached, nil
+}
+
+func (m *mockAttachmentRepo) PurgeAttachments(ids []uuid.UUID) error {
+	m.purged = append(m.purged, ids...)
+	return nil
+}
+
+// failingBlobStore fails deletes for one attachment.
+type failingBlobStore struct {
+	media.BlobStore
+	fail uuid.UUID
+}
+
+func (s failingBlobStore) Delete(ctx context.Context, key string) error {
+	if strings.Contains(key, s.fail.String()) {
+		return errors.New("disk on fire")
+	}
+	return s.BlobStore.Delete(ctx, key)
+}
+
+func TestSweepAttachments_PurgesOnlyDeletedBlobs(t *testing.T) {
+	ctx := context.Background()
+	local, err := media.NewLocalBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("blob store: %v", err)
+	}
+
+	gone, stuck := uuid.New(), uuid.New()
+	for _, id := range []uuid.UUID{gone, stuck} {
+		for _, variant := range []string{media.VariantFull, media.VariantThumb} {
+			if err := local.Put(ctx, media.AttachmentKey(id, variant), strings.NewReader("jpeg")); err != nil {
+				t.Fatalf("put: %v", err)
+			}
+		}
+	}
+
+	repo := &mockAttachmentRepo{detached: []uuid.UUID{gone, stuck}}
+	swept, err := sweepAttachments(ctx, repo, failingBlobStore{BlobStore: local, fail: stuck})
+	if err != nil {
+		t.Fatalf("sweep: %v", err)
+	}
+	if swept != 1 || len(repo.purged) != 1 || repo.purged[0] != gone {
+		t.Fatalf("swept %d, purged %v", swept, repo.purged)
+	}
+	if _, err := local.Open(ctx, media.AttachmentKey(gone, media.VariantFull)); !errors.Is(err, media.ErrBlobNotFound) {
+		t.Errorf("expected blob to be deleted, got %v", err)
+	}
+}
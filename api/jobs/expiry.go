@@ -0,0 +1,39 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"ember/api/events"
+	"ember/api/repositories"
+)
+
+// RunExpiryPublisher publishes an expired event for every pin whose
+// expires_at passed since the previous run, once per interval, until ctx is
+// cancelled. Expiry happens by the clock rather than by a write, so nothing
+// else would tell live subscribers to drop the pin.
+func RunExpiryPublisher(ctx context.Context, pinRepo repositories.PinRepository, broker *events.Broker, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		now := time.Now()
+		pins, err := pinRepo.QueryExpiredPins(last, now)
+		if err != nil {
+			log.Println("query expired pins:", err)
+			continue
+		}
+		for _, pin := range pins {
+			broker.Publish(events.PinEvent{Type: events.PinExpired, Before: &pin})
+		}
+		last = now
+	}
+}
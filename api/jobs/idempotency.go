@@ -0,0 +1,31 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"ember/api/repositories"
+)
+
+// RunIdempotencyKeyPruner deletes expired idempotency keys once per
+// interval, until ctx is cancelled.
+func RunIdempotencyKeyPruner(ctx context.Context, idempotencyRepo repositories.IdempotencyRepository, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		pruned, err := idempotencyRepo.PruneIdempotencyKeys()
+		if err != nil {
+			log.Println("prune idempotency keys:", err)
+		} else if pruned > 0 {
+			log.Printf("Pruned %d idempotency keys\n", pruned)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
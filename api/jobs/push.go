@@ -0,0 +1,253 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"ember/api/models"
+	"ember/api/push"
+	"ember/api/repositories"
+
+	"github.com/google/uuid"
+)
+
+const (
+	pushBatchSize = 100
+	// Claimed notifications stay hidden from other workers this long.
+	pushLease = 2 * time.Minute
+
+	// Transient failures are retried after pushRetryBase, doubling per
+	// attempt up to pushRetryMax, and given up on after maxPushAttempts.
+	pushRetryBase   = 30 * time.Second
+	pushRetryMax    = 30 * time.Minute
+	maxPushAttempts = 6
+
+	// Older notifications are left in the inbox but no longer pushed.
+	maxPushAge = 24 * time.Hour
+)
+
+// RunPushDelivery pushes due notifications through sender, once per interval,
+// until ctx is cancelled. A full batch is followed straight away by the next.
+func RunPushDelivery(ctx context.Context, pushRepo repositories.PushRepository, sender push.Sender, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		claimed, err := deliverPushes(ctx, pushRepo, sender, time.Now())
+		if err != nil {
+			log.Println("deliver pushes:", err)
+		}
+		if claimed == pushBatchSize {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// pushGroup is a run of one recipient's notifications that collapse into a
+// single push, oldest first.
+type pushGroup struct {
+	key    string
+	pushes []models.PendingPush
+}
+
+// collapseKey groups notifications of the same type about the same pin, so
+// five reactions arrive as one alert rather than five.
+func collapseKey(n models.Notification) string {
+	if n.PinID.Valid {
+		return n.Type + ":" + n.PinID.UUID.String()
+	}
+	return n.Type
+}
+
+func collapsePushes(pushes []models.PendingPush) []pushGroup {
+	var groups []pushGroup
+	index := map[string]int{}
+	for _, p := range pushes {
+		key := collapseKey(p.Notification)
+		i, ok := index[p.RecipientID.String()+"/"+key]
+		if !ok {
+			i = len(groups)
+			index[p.RecipientID.String()+"/"+key] = i
+			groups = append(groups, pushGroup{key: key})
+		}
+		groups[i].pushes = append(groups[i].pushes, p)
+	}
+	return groups
+}
+
+func actorName(u *models.User) string {
+	if u == nil {
+		return "Someone"
+	}
+	if u.DisplayName.Valid && u.DisplayName.String != "" {
+		return u.DisplayName.String
+	}
+	return u.Username
+}
+
+// pushText words the alert for a group, naming the latest actor and counting
+// the others.
+func pushText(g pushGroup) (title string, body string) {
+	latest := g.pushes[len(g.pushes)-1]
+
+	actors := map[uuid.UUID]bool{}
+	for _, p := range g.pushes {
+		if p.Actor != nil {
+			actors[p.Actor.ID] = true
+		}
+	}
+	who := actorName(latest.Actor)
+	if others := len(actors) - 1; others == 1 {
+		who += " and 1 other"
+	} else if others > 1 {
+		who += fmt.Sprintf(" and %d others", others)
+	}
+
+	switch latest.Type {
+	case models.NotificationFriendRequest:
+		return "Friend request", who + " sent you a friend request"
+	case models.NotificationFriendAccepted:
+		return "New friend", who + " accepted your friend request"
+	case models.NotificationPinReaction:
+		var data struct {
+			Emoji string `json:"emoji"`
+		}
+		json.Unmarshal(latest.Data, &data)
+		if len(actors) <= 1 && data.Emoji != "" {
+			return "New reaction", who + " reacted " + data.Emoji + " to your pin"
+		}
+		return "New reaction", who + " reacted to your pin"
+	case models.NotificationPinComment, models.NotificationCommentReply:
+		var data struct {
+			Preview string `json:"preview"`
+		}
+		json.Unmarshal(latest.Data, &data)
+		title := who + " commented on your pin"
+		if latest.Type == models.NotificationCommentReply {
+			title = who + " replied to your comment"
+		}
+		return title, data.Preview
+	case models.NotificationPinMention:
+		var data struct {
+			Preview string `json:"preview"`
+		}
+		json.Unmarshal(latest.Data, &data)
+		return who + " mentioned you", data.Preview
+	default:
+		return "Ember", "You have a new notification"
+	}
+}
+
+func pushMessage(g pushGroup) push.Message {
+	latest := g.pushes[len(g.pushes)-1]
+	title, body := pushText(g)
+
+	data := map[string]any{
+		"type":            latest.Type,
+		"notification_id": latest.ID.String(),
+	}
+	if latest.PinID.Valid {
+		data["pin_id"] = latest.PinID.UUID.String()
+	}
+
+	return push.Message{
+		Title:      title,
+		Body:       body,
+		CollapseID: g.key,
+		ThreadID:   latest.Type,
+		Data:       data,
+		Expiration: latest.CreatedAt.Add(maxPushAge),
+	}
+}
+
+func pushRetryDelay(attempts int) time.Duration {
+	delay := pushRetryBase
+	for i := 0; i < attempts && delay < pushRetryMax; i++ {
+		delay *= 2
+	}
+	return min(delay, pushRetryMax)
+}
+
+// deliverPushes claims one batch of due notifications and settles each: sent,
+// skipped by preferences, deferred past quiet hours, or rescheduled after a
+// transient failure. It returns how many notifications were claimed.
+func deliverPushes(ctx context.Context, pushRepo repositories.PushRepository, sender push.Sender, now time.Time) (int, error) {
+	pushes, err := pushRepo.ClaimDuePushes(pushBatchSize, pushLease)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, g := range collapsePushes(pushes) {
+		if err := deliverPushGroup(ctx, pushRepo, sender, g, now); err != nil {
+			log.Printf("deliver %s push: %v", g.key, err)
+		}
+	}
+	return len(pushes), nil
+}
+
+func deliverPushGroup(ctx context.Context, pushRepo repositories.PushRepository, sender push.Sender, g pushGroup, now time.Time) error {
+	latest := g.pushes[len(g.pushes)-1]
+	prefs := latest.Preferences
+
+	ids := make([]uuid.UUID, 0, len(g.pushes))
+	for _, p := range g.pushes {
+		ids = append(ids, p.ID)
+	}
+
+	if prefs.Muted(latest.Type) || len(latest.DeviceTokens) == 0 || now.Sub(latest.CreatedAt) > maxPushAge {
+		return pushRepo.FinishPushes(ids, models.PushSkipped)
+	}
+	if quiet, until := prefs.Quiet(now); quiet {
+		return pushRepo.ReschedulePushes(ids, until, false)
+	}
+
+	msg := pushMessage(g)
+	var delivered, transient int
+	var dead []string
+	for _, token := range latest.DeviceTokens {
+		msg.Token = token
+		err := sender.Send(ctx, msg)
+		switch {
+		case err == nil:
+			delivered++
+		case errors.Is(err, push.ErrInvalidToken):
+			dead = append(dead, token)
+		case errors.Is(err, push.ErrRejected):
+			log.Printf("push %s rejected: %v", g.key, err)
+		default:
+			transient++
+			log.Printf("push %s failed: %v", g.key, err)
+		}
+	}
+
+	if len(dead) > 0 {
+		if _, err := pushRepo.DeleteDeviceTokens(dead); err != nil {
+			log.Println("prune dead device tokens:", err)
+		}
+	}
+
+	// A push that reached any device is done; retrying would duplicate it
+	// on the devices that already have it.
+	switch {
+	case delivered > 0:
+		return pushRepo.FinishPushes(ids, models.PushSent)
+	case transient > 0 && latest.Attempts+1 < maxPushAttempts:
+		return pushRepo.ReschedulePushes(ids, now.Add(pushRetryDelay(latest.Attempts)), true)
+	case transient > 0:
+		return pushRepo.FinishPushes(ids, models.PushFailed)
+	case len(dead) == len(latest.DeviceTokens):
+		return pushRepo.FinishPushes(ids, models.PushSkipped)
+	default:
+		return pushRepo.FinishPushes(ids, models.PushFailed)
+	}
+}
@@ -0,0 +1,202 @@
+package jobs
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"ember/api/models"
+	"ember/api/push"
+
+	"github.com/google/uuid"
+)
+
+type mockPushRepo struct {
+	due []models.PendingPush
+
+	finished    map[uuid.UUID]string
+	rescheduled map[uuid.UUID]time.Time
+	counted     map[uuid.UUID]bool
+	pruned      []string
+}
+
+func newMockPushRepo(due ...models.PendingPush) *mockPushRepo {
+	return &mockPushRepo{
+		due:         due,
+		finished:    map[uuid.UUID]string{},
+		rescheduled: map[uuid.UUID]time.Time{},
+		counted:     map[uuid.UUID]bool{},
+	}
+}
+
+func (m *mockPushRepo) RegisterDevice(uuid.UUID, string, string) error { return nil }
+func (m *mockPushRepo) DeleteDevice(uuid.UUID, string) (bool, error)   { return false, nil }
+func (m *mockPushRepo) GetPushPreferences(uuid.UUID) (models.PushPreferences, error) {
+	return models.DefaultPushPreferences(), nil
+}
+func (m *mockPushRepo) SavePushPreferences(uuid.UUID, models.PushPreferences) error { return nil }
+
+func (m *mockPushRepo) DeleteDeviceTokens(tokens []string) (int64, error) {
+	m.pruned = append(m.pruned, tokens...)
+	return int64(len(tokens)), nil
+}
+
+func (m *mockPushRepo) ClaimDuePushes(limit int, lease time.Duration) ([]models.PendingPush, error) {
+	due := m.due
+	m.due = nil
+	return due, nil
+}
+
+func (m *mockPushRepo) FinishPushes(ids []uuid.UUID, status string) error {
+	for _, id := range ids {
+		m.finished[id] = status
+	}
+	return nil
+}
+
+func (m *mockPushRepo) ReschedulePushes(ids []uuid.UUID, at time.Time, countAttempt bool) error {
+	for _, id := range ids {
+		m.rescheduled[id] = at
+		m.counted[id] = countAttempt
+	}
+	return nil
+}
+
+var (
+	pushNow   = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	recipient = uuid.New()
+)
+
+func pendingPush(typ string, actor string, pinID uuid.UUID, tokens ...string) models.PendingPush {
+	p := models.PendingPush{
+		Notification: models.Notification{
+			ID:        uuid.New(),
+			Type:      typ,
+			Actor:     &models.User{ID: uuid.New(), Username: actor},
+			Data:      json.RawMessage(`{}`),
+			CreatedAt: pushNow.Add(-time.Minute),
+		},
+		RecipientID:  recipient,
+		Preferences:  models.DefaultPushPreferences(),
+		DeviceTokens: tokens,
+	}
+	if pinID != uuid.Nil {
+		p.PinID = uuid.NullUUID{UUID: pinID, Valid: true}
+	}
+	return p
+}
+
+func TestDeliverPushesCollapsesReactionsOnSamePin(t *testing.T) {
+	pinID := uuid.New()
+	first := pendingPush(models.NotificationPinReaction, "ana", pinID, "tok")
+	second := pendingPush(models.NotificationPinReaction, "ben", pinID, "tok")
+	request := pendingPush(models.NotificationFriendRequest, "cy", uuid.Nil, "tok")
+	repo := newMockPushRepo(first, second, request)
+	sender := push.NewFakeSender()
+
+	if _, err := deliverPushes(context.Background(), repo, sender, pushNow); err != nil {
+		t.Fatalf("deliverPushes: %v", err)
+	}
+
+	sent := sender.Sent()
+	if len(sent) != 2 {
+		t.Fatalf("sent %d pushes, want 2 (reactions collapsed): %+v", len(sent), sent)
+	}
+	if sent[0].Body != "ben and 1 other reacted to your pin" {
+		t.Errorf("collapsed body = %q", sent[0].Body)
+	}
+	if sent[0].CollapseID != "pin_reaction:"+pinID.String() {
+		t.Errorf("collapse id = %q", sent[0].CollapseID)
+	}
+	for _, p := range []models.PendingPush{first, second, request} {
+		if repo.finished[p.ID] != models.PushSent {
+			t.Errorf("%s: status %q, want sent", p.Type, repo.finished[p.ID])
+		}
+	}
+}
+
+func TestDeliverPushesPrunesInvalidTokens(t *testing.T) {
+	p := pendingPush(models.NotificationFriendAccepted, "ana", uuid.Nil, "dead", "live")
+	repo := newMockPushRepo(p)
+	sender := push.NewFakeSender()
+	sender.Fail("dead", push.ErrInvalidToken)
+
+	deliverPushes(context.Background(), repo, sender, pushNow)
+
+	if len(repo.pruned) != 1 || repo.pruned[0] != "dead" {
+		t.Errorf("pruned = %v, want [dead]", repo.pruned)
+	}
+	if len(sender.Sent()) != 1 || repo.finished[p.ID] != models.PushSent {
+		t.Errorf("expected delivery to the live device; status %q", repo.finished[p.ID])
+	}
+}
+
+func TestDeliverPushesRetriesTransientFailuresWithBackoff(t *testing.T) {
+	p := pendingPush(models.NotificationFriendRequest, "ana", uuid.Nil, "tok")
+	p.Attempts = 2
+	repo := newMockPushRepo(p)
+	sender := push.NewFakeSender()
+	sender.Fail("tok", errors.New("apns 503 ServiceUnavailable"))
+
+	deliverPushes(context.Background(), repo, sender, pushNow)
+
+	if want := pushNow.Add(4 * pushRetryBase); !repo.rescheduled[p.ID].Equal(want) {
+		t.Errorf("rescheduled at %v, want %v", repo.rescheduled[p.ID], want)
+	}
+	if !repo.counted[p.ID] {
+		t.Error("a failed attempt should be counted")
+	}
+
+	p.Attempts = maxPushAttempts - 1
+	repo = newMockPushRepo(p)
+	deliverPushes(context.Background(), repo, sender, pushNow)
+	if repo.finished[p.ID] != models.PushFailed {
+		t.Errorf("status %q after the last attempt, want failed", repo.finished[p.ID])
+	}
+}
+
+func TestDeliverPushesHonoursPreferences(t *testing.T) {
+	muted := pendingPush(models.NotificationPinReaction, "ana", uuid.New(), "tok")
+	muted.Preferences.MutedTypes = []string{models.NotificationPinReaction}
+
+	noDevices := pendingPush(models.NotificationFriendRequest, "ben", uuid.Nil)
+
+	// 22:00–07:00 in Vancouver; noon UTC is 05:00 there
+	start, end := 22*60, 7*60
+	quiet := pendingPush(models.NotificationFriendAccepted, "cy", uuid.Nil, "tok")
+	quiet.RecipientID = uuid.New()
+	quiet.Preferences.QuietStart = &start
+	quiet.Preferences.QuietEnd = &end
+	quiet.Preferences.TimeZone = "America/Vancouver"
+
+	repo := newMockPushRepo(muted, noDevices, quiet)
+	sender := push.NewFakeSender()
+	deliverPushes(context.Background(), repo, sender, pushNow)
+
+	if len(sender.Sent()) != 0 {
+		t.Errorf("sent %+v, want nothing", sender.Sent())
+	}
+	if repo.finished[muted.ID] != models.PushSkipped || repo.finished[noDevices.ID] != models.PushSkipped {
+		t.Errorf("muted %q, no devices %q, want both skipped", repo.finished[muted.ID], repo.finished[noDevices.ID])
+	}
+	if want := pushNow.Add(2 * time.Hour); !repo.rescheduled[quiet.ID].Equal(want) {
+		t.Errorf("quiet push rescheduled at %v, want %v", repo.rescheduled[quiet.ID], want)
+	}
+	if repo.counted[quiet.ID] {
+		t.Error("deferring for quiet hours should not count as an attempt")
+	}
+}
+
+func TestPushTextUsesDisplayNameAndEmoji(t *testing.T) {
+	p := pendingPush(models.NotificationPinReaction, "ana", uuid.New(), "tok")
+	p.Actor.DisplayName = sql.NullString{String: "Ana P", Valid: true}
+	p.Data = json.RawMessage(`{"emoji":"🔥"}`)
+
+	_, body := pushText(pushGroup{pushes: []models.PendingPush{p}})
+	if body != "Ana P reacted 🔥 to your pin" {
+		t.Errorf("body = %q", body)
+	}
+}
@@ -0,0 +1,32 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"ember/api/repositories"
+)
+
+// RunPinReaper hard-deletes pins that expired more than retention ago, once
+// per interval, until ctx is cancelled. Expired pins are already hidden from
+// every read; the retention window only delays their physical removal.
+func RunPinReaper(ctx context.Context, pinRepo repositories.PinRepository, interval time.Duration, retention time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		deleted, err := pinRepo.DeleteExpiredPins(retention)
+		if err != nil {
+			log.Println("reap expired pins:", err)
+		} else if deleted > 0 {
+			log.Printf("Reaped %d expired pins\n", deleted)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
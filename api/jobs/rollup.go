@@ -0,0 +1,30 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"ember/api/repositories"
+)
+
+// RunMoodRollupRefresher keeps pin_mood_rollups current, once per interval,
+// until ctx is cancelled. Each run rebuilds the hours since the previous run
+// plus lookback, so lookback must stay shorter than the expired-pin retention
+// or reaped pins would drop out of history.
+func RunMoodRollupRefresher(ctx context.Context, insightRepo repositories.InsightRepository, interval time.Duration, lookback time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := insightRepo.RefreshMoodRollups(lookback); err != nil {
+			log.Println("refresh mood rollups:", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
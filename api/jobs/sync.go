@@ -0,0 +1,31 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"ember/api/repositories"
+)
+
+// RunSyncPruner forgets sync deletions past repositories.SyncRetention, once
+// per interval, until ctx is cancelled.
+func RunSyncPruner(ctx context.Context, syncRepo repositories.SyncRepository, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		pruned, err := syncRepo.PruneDeletions()
+		if err != nil {
+			log.Println("prune sync deletions:", err)
+		} else if pruned > 0 {
+			log.Printf("Pruned %d sync deletions\n", pruned)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -3,6 +3,10 @@ package main
 import (
     "context"
     "database/sql"
+    "ember/api/events"
+    "ember/api/jobs"
+    "ember/api/media"
+    "ember/api/push"
     "ember/api/repositories"
     "ember/api/router"
     "encoding/json"
@@ -11,10 +15,37 @@ import (
     "net/http"
     "os"
     "time"
+    _ "time/tzdata" // GET /me/stats resolves IANA zones even on images without zoneinfo
 
     _ "github.com/jackc/pgx/v5/stdlib"
 )
 
+const (
+	// Expired pins are hidden immediately but kept this long before being hard-deleted.
+	expiredPinRetention = 24 * time.Hour
+	pinReaperInterval   = 10 * time.Minute
+
+	// Trends lag by at most the refresh interval; the lookback re-aggregates
+	// recent hours so late edits and deletions are reflected.
+	moodRollupInterval = 15 * time.Minute
+	moodRollupLookback = 2 * time.Hour
+
+	// How often live pin streams are told about pins that expired
+	expiryPublishInterval = 30 * time.Second
+
+	// How often due notifications are pushed to devices
+	pushDeliveryInterval = 5 * time.Second
+
+	// How often images of removed attachments are deleted from storage
+	attachmentSweepInterval = 15 * time.Minute
+
+	// How often deletions older than the sync retention are forgotten
+	syncPruneInterval = time.Hour
+
+	// How often expired idempotency keys are deleted
+	idempotencyPruneInterval = time.Hour
+)
+
 func main() {
     dsn := os.Getenv("DB_SOURCE")
     if dsn == "" {
@@ -43,10 +74,87 @@ func main() {
 	})
 
     userRepo := repositories.NewUserRepository(db)
-	pinRepo := repositories.NewPinRepository(db)
+	broker := events.NewBroker()
+	pinRepo := events.PublishPinChanges(repositories.NewPinRepository(db), broker)
+	emotionRepo := repositories.NewEmotionRepository(db)
+	insightRepo := repositories.NewInsightRepository(db)
+	notificationRepo := repositories.NewNotificationRepository(db)
+	pushRepo := repositories.NewPushRepository(db)
+	commentRepo := repositories.NewCommentRepository(db)
+	attachmentRepo := repositories.NewAttachmentRepository(db)
+	tagRepo := repositories.NewTagRepository(db)
+	zoneRepo := repositories.NewZoneRepository(db)
+	syncRepo := repositories.NewSyncRepository(db)
+	idempotencyRepo := repositories.NewIdempotencyRepository(db)
+	blobStore := newBlobStore()
+
+	go jobs.RunPinReaper(context.Background(), pinRepo, pinReaperInterval, expiredPinRetention)
+	go jobs.RunMoodRollupRefresher(context.Background(), insightRepo, moodRollupInterval, moodRollupLookback)
+	go jobs.RunExpiryPublisher(context.Background(), pinRepo, broker, expiryPublishInterval)
+	go jobs.RunPushDelivery(context.Background(), pushRepo, newPushSender(), pushDeliveryInterval)
+	go jobs.RunAttachmentSweeper(context.Background(), attachmentRepo, blobStore, attachmentSweepInterval)
+	go jobs.RunSyncPruner(context.Background(), syncRepo, syncPruneInterval)
+	go jobs.RunIdempotencyKeyPruner(context.Background(), idempotencyRepo, idempotencyPruneInterval)
+
+	r := router.CreateRouter(router.Dependencies{
+		Users:         userRepo,
+		Pins:          pinRepo,
+		Emotions:      emotionRepo,
+		Insights:      insightRepo,
+		Events:        broker,
+		Notifications: notificationRepo,
+		Push:          pushRepo,
+		Comments:      commentRepo,
+		Attachments:   attachmentRepo,
+		Blobs:         blobStore,
+		Tags:          tagRepo,
+		Zones:         zoneRepo,
+		Sync:          syncRepo,
+		Idempotency:   idempotencyRepo,
+	})
 
 	log.Println("Server running on :8080")
-	log.Fatal(http.ListenAndServe(":8080", router.CreateRouter(userRepo, pinRepo)))
+	log.Fatal(http.ListenAndServe(":8080", r))
+}
+
+// newPushSender sends through APNs when APNS_KEY_PATH is set and otherwise
+// only logs pushes, so local setups work without Apple credentials.
+func newPushSender() push.Sender {
+	keyPath := os.Getenv("APNS_KEY_PATH")
+	if keyPath == "" {
+		log.Println("APNS_KEY_PATH is not set; pushes will only be logged")
+		return push.NewLoggingFakeSender()
+	}
+
+	key, err := push.LoadAPNsKey(keyPath)
+	if err != nil {
+		log.Fatalf("failed to load APNs key: %v", err)
+	}
+
+	host := push.APNsProductionHost
+	if os.Getenv("APNS_SANDBOX") == "true" {
+		host = push.APNsSandboxHost
+	}
+	return push.NewAPNsSender(push.APNsConfig{
+		Host:   host,
+		Topic:  os.Getenv("APNS_TOPIC"),
+		KeyID:  os.Getenv("APNS_KEY_ID"),
+		TeamID: os.Getenv("APNS_TEAM_ID"),
+		Key:    key,
+	}, nil)
+}
+
+// newBlobStore keeps uploaded images under MEDIA_DIR, or ./media when unset.
+func newBlobStore() media.BlobStore {
+	dir := os.Getenv("MEDIA_DIR")
+	if dir == "" {
+		dir = "media"
+	}
+	store, err := media.NewLocalBlobStore(dir)
+	if err != nil {
+		log.Fatalf("failed to open media directory: %v", err)
+	}
+	return store
 }
 
 // waitForDB attempts to Ping the DB with exponential backoff until the timeout elapses.
@@ -0,0 +1,174 @@
+package media
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"math"
+
+	_ "image/gif"
+	_ "image/png"
+)
+
+const (
+	// MaxImagePixels bounds decoded size, and with it memory per upload.
+	MaxImagePixels = 25_000_000
+
+	fullMaxEdge  = 2048
+	thumbMaxEdge = 320
+	fullQuality  = 85
+	thumbQuality = 75
+
+	blurhashXComponents = 4
+	blurhashYComponents = 3
+)
+
+var (
+	ErrUnsupportedImage = errors.New("image must be a JPEG, PNG or GIF")
+	ErrImageTooLarge    = errors.New("image has too many pixels")
+)
+
+// ProcessedImage is an upload made safe to serve: re-encoded from pixels
+// alone, so EXIF (GPS position included) and every other metadata block of
+// the original is gone.
+type ProcessedImage struct {
+	Full     []byte
+	Thumb    []byte
+	Width    int // of Full
+	Height   int
+	Blurhash string
+}
+
+// ProcessImage decodes an uploaded image, turns it upright according to its
+// EXIF orientation, and re-encodes it as a bounded JPEG plus a thumbnail.
+func ProcessImage(data []byte) (*ProcessedImage, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > MaxImagePixels {
+		return nil, ErrImageTooLarge
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupportedImage
+	}
+
+	orientation := 1
+	if format == "jpeg" {
+		orientation = jpegOrientation(data)
+	}
+
+	full := orient(fit(flatten(src), fullMaxEdge), orientation)
+	thumb := fit(full, thumbMaxEdge)
+
+	processed := &ProcessedImage{
+		Width:    full.Bounds().Dx(),
+		Height:   full.Bounds().Dy(),
+		Blurhash: Blurhash(thumb, blurhashXComponents, blurhashYComponents),
+	}
+	if processed.Full, err = encodeJPEG(full, fullQuality); err != nil {
+		return nil, err
+	}
+	if processed.Thumb, err = encodeJPEG(thumb, thumbQuality); err != nil {
+		return nil, err
+	}
+	return processed, nil
+}
+
+func encodeJPEG(img image.Image, quality int) ([]byte, error) {
+	var buf bytes.Buffer
+	err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality})
+	return buf.Bytes(), err
+}
+
+// flatten draws src onto white, since JPEG has no transparency.
+func flatten(src image.Image) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), src, b.Min, draw.Over)
+	return dst
+}
+
+// fit shrinks img so neither side exceeds maxEdge, averaging the source
+// pixels under each destination pixel. Smaller images are returned as is.
+func fit(img *image.RGBA, maxEdge int) *image.RGBA {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	if w <= maxEdge && h <= maxEdge {
+		return img
+	}
+
+	scale := float64(maxEdge) / float64(max(w, h))
+	dw := max(1, int(math.Round(float64(w)*scale)))
+	dh := max(1, int(math.Round(float64(h)*scale)))
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < dh; y++ {
+		sy0, sy1 := y*h/dh, max((y+1)*h/dh, y*h/dh+1)
+		for x := 0; x < dw; x++ {
+			sx0, sx1 := x*w/dw, max((x+1)*w/dw, x*w/dw+1)
+
+			var sum [4]int
+			for sy := sy0; sy < sy1; sy++ {
+				row := img.PixOffset(sx0, sy)
+				for sx := sx0; sx < sx1; sx++ {
+					p := row + (sx-sx0)*4
+					sum[0] += int(img.Pix[p])
+					sum[1] += int(img.Pix[p+1])
+					sum[2] += int(img.Pix[p+2])
+					sum[3] += int(img.Pix[p+3])
+				}
+			}
+
+			n := (sy1 - sy0) * (sx1 - sx0)
+			d := dst.PixOffset(x, y)
+			for c := 0; c < 4; c++ {
+				dst.Pix[d+c] = uint8(sum[c] / n)
+			}
+		}
+	}
+	return dst
+}
+
+// orient applies an EXIF orientation so the image displays upright without it.
+func orient(img *image.RGBA, orientation int) *image.RGBA {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // mirrored
+				sx, sy = w-1-x, y
+			case 3: // upside down
+				sx, sy = w-1-x, h-1-y
+			case 4: // mirrored upside down
+				sx, sy = x, h-1-y
+			case 5: // mirrored, rotated
+				sx, sy = y, x
+			case 6: // rotated 90° clockwise to display
+				sx, sy = y, h-1-x
+			case 7:
+				sx, sy = w-1-y, h-1-x
+			case 8: // rotated 90° counter-clockwise to display
+				sx, sy = w-1-y, x
+			}
+			copy(dst.Pix[dst.PixOffset(x, y):][:4], img.Pix[img.PixOffset(sx, sy):][:4])
+		}
+	}
+	return dst
+}
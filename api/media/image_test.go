@@ -0,0 +1,203 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+// halves is a w×h image, red on the left and blue on the right.
+func halves(w int, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.RGBA{R: 255, A: 255}
+			if x >= w/2 {
+				c = color.RGBA{B: 255, A: 255}
+			}
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+// withEXIF inserts an APP1 block carrying an orientation and a GPS IFD right
+// after the SOI marker of a JPEG.
+func withEXIF(t *testing.T, jpg []byte, orientation uint16) []byte {
+	t.Helper()
+
+	var tiff bytes.Buffer
+	le := binary.LittleEndian
+	tiff.WriteString("II")
+	binary.Write(&tiff, le, uint16(42))
+	binary.Write(&tiff, le, uint32(8))
+	// IFD0: orientation and a pointer to the GPS IFD
+	binary.Write(&tiff, le, uint16(2))
+	binary.Write(&tiff, le, []uint16{0x0112, 3})
+	binary.Write(&tiff, le, uint32(1))
+	binary.Write(&tiff, le, []uint16{orientation, 0})
+	binary.Write(&tiff, le, []uint16{0x8825, 4})
+	binary.Write(&tiff, le, uint32(1))
+	binary.Write(&tiff, le, uint32(38))
+	binary.Write(&tiff, le, uint32(0))
+	// GPS IFD with a latitude reference, followed by a marker string
+	binary.Write(&tiff, le, uint16(1))
+	binary.Write(&tiff, le, []uint16{0x0001, 2})
+	binary.Write(&tiff, le, uint32(2))
+	tiff.WriteString("N\x00\x00\x00")
+	binary.Write(&tiff, le, uint32(0))
+	tiff.WriteString("GPS-SECRET-49.2827,-123.1207")
+
+	payload := append([]byte("Exif\x00\x00"), tiff.Bytes()...)
+	segment := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	segment = append(segment, payload...)
+
+	out := append([]byte{}, jpg[:2]...)
+	out = append(out, segment...)
+	return append(out, jpg[2:]...)
+}
+
+func encodeTestJPEG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestProcessImageStripsEXIFAndAppliesOrientation(t *testing.T) {
+	upload := withEXIF(t, encodeTestJPEG(t, halves(64, 32)), 6)
+	if jpegOrientation(upload) != 6 {
+		t.Fatalf("test upload should carry orientation 6")
+	}
+
+	processed, err := ProcessImage(upload)
+	if err != nil {
+		t.Fatalf("ProcessImage: %v", err)
+	}
+
+	for _, out := range [][]byte{processed.Full, processed.Thumb} {
+		if bytes.Contains(out, []byte("Exif")) || bytes.Contains(out, []byte("GPS-SECRET")) {
+			t.Error("processed image still carries EXIF")
+		}
+	}
+
+	// Rotated clockwise, the red left half ends up on top.
+	if processed.Width != 32 || processed.Height != 64 {
+		t.Fatalf("size = %dx%d, want 32x64", processed.Width, processed.Height)
+	}
+	img, err := jpeg.Decode(bytes.NewReader(processed.Full))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r, _, b, _ := img.At(16, 8).RGBA(); r < b {
+		t.Errorf("top should be red, got r=%d b=%d", r>>8, b>>8)
+	}
+	if r, _, b, _ := img.At(16, 56).RGBA(); b < r {
+		t.Errorf("bottom should be blue, got r=%d b=%d", r>>8, b>>8)
+	}
+}
+
+func TestProcessImageBoundsSizes(t *testing.T) {
+	var buf bytes.Buffer
+	png.Encode(&buf, halves(3000, 1500))
+
+	processed, err := ProcessImage(buf.Bytes())
+	if err != nil {
+		t.Fatalf("ProcessImage: %v", err)
+	}
+	if processed.Width != fullMaxEdge || processed.Height != fullMaxEdge/2 {
+		t.Errorf("full size = %dx%d", processed.Width, processed.Height)
+	}
+
+	thumb, err := jpeg.DecodeConfig(bytes.NewReader(processed.Thumb))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if thumb.Width != thumbMaxEdge || thumb.Height != thumbMaxEdge/2 {
+		t.Errorf("thumb size = %dx%d", thumb.Width, thumb.Height)
+	}
+	if len(processed.Blurhash) != 6+2*(blurhashXComponents*blurhashYComponents-1) {
+		t.Errorf("blurhash %q has the wrong length", processed.Blurhash)
+	}
+}
+
+func TestProcessImageRejects(t *testing.T) {
+	if _, err := ProcessImage([]byte("definitely not an image")); !errors.Is(err, ErrUnsupportedImage) {
+		t.Errorf("garbage: err = %v", err)
+	}
+
+	// A PNG header claiming 10000x10000 pixels, checked before decoding.
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewGray(image.Rect(0, 0, 1, 1)))
+	huge := buf.Bytes()
+	binary.BigEndian.PutUint32(huge[16:], 10000)
+	binary.BigEndian.PutUint32(huge[20:], 10000)
+	binary.BigEndian.PutUint32(huge[29:], crc32.ChecksumIEEE(huge[12:29]))
+	if _, err := ProcessImage(huge); !errors.Is(err, ErrImageTooLarge) {
+		t.Errorf("huge: err = %v", err)
+	}
+}
+
+func TestBlurhashOfSolidColour(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 8, 8))
+	for i := range img.Pix {
+		img.Pix[i] = 255
+	}
+
+	hash := Blurhash(img, 4, 3)
+	if len(hash) != 28 || hash[0] != 'L' {
+		t.Fatalf("Blurhash = %q, want 28 characters of a 4x3 hash", hash)
+	}
+	if dc := hash[2:6]; dc != encodeBase83(0xFFFFFF, 4) {
+		t.Errorf("average colour %q, want white", dc)
+	}
+}
+
+func TestLocalBlobStore(t *testing.T) {
+	store, err := NewLocalBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	key := AttachmentKey(uuid.New(), VariantThumb)
+
+	if err := store.Put(ctx, key, strings.NewReader("pixels")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	rc, err := store.Open(ctx, key)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(data) != "pixels" {
+		t.Errorf("read %q", data)
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, err := store.Open(ctx, key); !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("Open after delete: err = %v", err)
+	}
+	if err := store.Delete(ctx, key); err != nil {
+		t.Errorf("deleting twice: %v", err)
+	}
+	if err := store.Put(ctx, "../escape", strings.NewReader("x")); err == nil {
+		t.Error("keys must not escape the store")
+	}
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Attachment is an image attached to a pin. Its pixels live in the blob store.
+type Attachment struct {
+	ID        uuid.UUID
+	PinID     uuid.UUID
+	Position  int
+	Width     int
+	Height    int
+	Blurhash  string
+	ByteSize  int
+	CreatedAt time.Time
+}
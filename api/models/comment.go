@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Comment is a comment on a pin, or a reply to another comment. Deleted
+// comments are only listed while replies hang off them, with an empty Body.
+type Comment struct {
+	ID         uuid.UUID
+	PinID      uuid.UUID
+	ParentID   uuid.NullUUID
+	Author     User
+	Body       string
+	CreatedAt  time.Time
+	Deleted    bool
+	ReplyCount int
+}
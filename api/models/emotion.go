@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+type Emotion struct {
+	Key      string  `json:"key"`
+	Emoji    string  `json:"emoji"`
+	Label    string  `json:"label"`
+	Valence  float64 `json:"valence"`
+	Arousal  float64 `json:"arousal"`
+	Category string  `json:"category"`
+}
+
+// EmotionCatalog is the set of active emotions at a given catalogue version.
+type EmotionCatalog struct {
+	Version   int64     `json:"version"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Emotions  []Emotion `json:"emotions"`
+}
@@ -0,0 +1,100 @@
+package models
+
+import "math"
+
+const earthRadiusKm = 6371.0
+
+// BBox is a longitude/latitude bounding box in degrees. MinLon > MaxLon means
+// the box crosses the antimeridian, e.g. 170,-10,-170,10 spans 20 degrees.
+type BBox struct {
+	MinLon float64 `json:"min_lon"`
+	MinLat float64 `json:"min_lat"`
+	MaxLon float64 `json:"max_lon"`
+	MaxLat float64 `json:"max_lat"`
+}
+
+func (b BBox) CrossesAntimeridian() bool {
+	return b.MinLon > b.MaxLon
+}
+
+// WidthDeg is the box's longitudinal extent, accounting for the antimeridian.
+func (b BBox) WidthDeg() float64 {
+	if b.CrossesAntimeridian() {
+		return 360 - b.MinLon + b.MaxLon
+	}
+	return b.MaxLon - b.MinLon
+}
+
+func (b BBox) HeightDeg() float64 {
+	return b.MaxLat - b.MinLat
+}
+
+// Envelopes splits the box at the antimeridian into two boxes that never
+// cross it. A box that doesn't cross is returned twice so callers can always
+// match against both halves.
+func (b BBox) Envelopes() (BBox, BBox) {
+	if !b.CrossesAntimeridian() {
+		return b, b
+	}
+	east := BBox{MinLon: b.MinLon, MinLat: b.MinLat, MaxLon: 180, MaxLat: b.MaxLat}
+	west := BBox{MinLon: -180, MinLat: b.MinLat, MaxLon: b.MaxLon, MaxLat: b.MaxLat}
+	return east, west
+}
+
+// AreaKm2 is the surface area the box covers on a spherical Earth.
+func (b BBox) AreaKm2() float64 {
+	width := b.WidthDeg() * math.Pi / 180
+	band := math.Abs(math.Sin(b.MaxLat*math.Pi/180) - math.Sin(b.MinLat*math.Pi/180))
+	return earthRadiusKm * earthRadiusKm * width * band
+}
+
+// MinSideMeters is the length of the box's shorter side: its height, or its
+// width across its middle latitude.
+func (b BBox) MinSideMeters() float64 {
+	height := b.HeightDeg() * metersPerDegree
+	width := b.WidthDeg() * metersPerDegree * math.Cos((b.MinLat+b.MaxLat)/2*math.Pi/180)
+	return math.Min(height, width)
+}
+
+// Contains reports whether the point lies inside the box, edges included.
+func (b BBox) Contains(lon float64, lat float64) bool {
+	if lat < b.MinLat || lat > b.MaxLat {
+		return false
+	}
+	if b.CrossesAntimeridian() {
+		return lon >= b.MinLon || lon <= b.MaxLon
+	}
+	return lon >= b.MinLon && lon <= b.MaxLon
+}
+
+// Grow widens the box by meters on every side, wrapping across the
+// antimeridian. A box grown to span every longitude, as happens near the
+// poles, covers them all.
+func (b BBox) Grow(meters float64) BBox {
+	dLat := meters / metersPerDegree
+	grown := BBox{MinLon: -180, MinLat: math.Max(b.MinLat-dLat, -90), MaxLon: 180, MaxLat: math.Min(b.MaxLat+dLat, 90)}
+
+	// A degree of longitude is shortest at the edge nearest a pole
+	cos := math.Cos(math.Max(math.Abs(grown.MinLat), math.Abs(grown.MaxLat)) * math.Pi / 180)
+	if cos <= 0 {
+		return grown
+	}
+	dLon := meters / (metersPerDegree * cos)
+	if b.WidthDeg()+2*dLon >= 360 {
+		return grown
+	}
+
+	grown.MinLon = wrapLongitude(b.MinLon - dLon)
+	grown.MaxLon = wrapLongitude(b.MaxLon + dLon)
+	return grown
+}
+
+func wrapLongitude(lon float64) float64 {
+	switch {
+	case lon < -180:
+		return lon + 360
+	case lon > 180:
+		return lon - 360
+	}
+	return lon
+}
@@ -0,0 +1,31 @@
+package models
+
+import "testing"
+
+func TestBBoxGrow(t *testing.T) {
+	grown := BBox{MinLon: -123.2, MinLat: 49.2, MaxLon: -123.0, MaxLat: 49.3}.Grow(10000)
+	if !grown.Contains(-123.3, 49.25) || !grown.Contains(-123.1, 49.38) {
+		t.Errorf("expected points within 10 km to be inside %+v", grown)
+	}
+	if grown.Contains(-123.4, 49.25) || grown.Contains(-123.1, 49.45) {
+		t.Errorf("expected points beyond 10 km to be outside %+v", grown)
+	}
+
+	wrapped := BBox{MinLon: 179.95, MinLat: 0, MaxLon: 179.99, MaxLat: 1}.Grow(10000)
+	if !wrapped.CrossesAntimeridian() || !wrapped.Contains(-179.97, 0.5) {
+		t.Errorf("expected the box to wrap across the antimeridian, got %+v", wrapped)
+	}
+
+	polar := BBox{MinLon: 0, MinLat: 89.95, MaxLon: 1, MaxLat: 89.99}.Grow(10000)
+	if polar.MinLon != -180 || polar.MaxLon != 180 || polar.MaxLat != 90 {
+		t.Errorf("expected a box at the pole to cover every longitude, got %+v", polar)
+	}
+}
+
+func TestBBoxMinSideMeters(t *testing.T) {
+	// 0.02 degrees of longitude at 60 degrees north is about 1.1 km
+	side := BBox{MinLon: 10, MinLat: 59.9, MaxLon: 10.02, MaxLat: 60.1}.MinSideMeters()
+	if side < 1100 || side > 1125 {
+		t.Errorf("expected the width across the middle latitude, got %.0f m", side)
+	}
+}
@@ -0,0 +1,10 @@
+package models
+
+// IdempotentRequest is the first request made with an Idempotency-Key and,
+// once it has been handled, its response. StatusCode is 0 until then.
+type IdempotentRequest struct {
+	Fingerprint string
+	StatusCode  int
+	ContentType string
+	Body        []byte
+}
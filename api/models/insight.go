@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// MoodCell aggregates the public pins in one grid cell of a mood heatmap.
+type MoodCell struct {
+	Bounds          BBox           `json:"bounds"`
+	PinCount        int            `json:"pin_count"`
+	MeanValence     float64        `json:"mean_valence"`
+	DominantEmotion string         `json:"dominant_emotion"`
+	Emotions        map[string]int `json:"emotions"`
+}
+
+// MoodTrendBucket aggregates the public pins in one time bucket of a mood trend.
+type MoodTrendBucket struct {
+	Start           time.Time      `json:"start"`
+	PinCount        int            `json:"pin_count"`
+	MeanValence     float64        `json:"mean_valence"`
+	DominantEmotion string         `json:"dominant_emotion"`
+	Emotions        map[string]int `json:"emotions"`
+}
+
+// MoodTrend is a time series of mood buckets, current as of the last rollup refresh.
+type MoodTrend struct {
+	AsOf    time.Time         `json:"as_of"`
+	Buckets []MoodTrendBucket `json:"buckets"`
+}
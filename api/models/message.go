@@ -0,0 +1,89 @@
+package models
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Limits on what one pin message can refer to; anything past them is ignored.
+const (
+	MaxMessageMentions = 10
+	MaxMessageTags     = 10
+	MaxTagLength       = 50
+)
+
+// MessageRefs are the users and hashtags a pin message refers to.
+type MessageRefs struct {
+	// Mentions are usernames as written, without the '@'.
+	Mentions []string
+	// Tags are lowercased and without the '#'.
+	Tags []string
+}
+
+// A mention or tag must start the message or follow a character that can't
+// be part of a word, so "me@example.com" and "C#" refer to nothing.
+var (
+	mentionPattern = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_.])@([A-Za-z0-9_.]+)`)
+	tagPattern     = regexp.MustCompile(`(?:^|[^\p{L}\p{N}_])#([\p{L}\p{N}_]+)`)
+)
+
+// ParseMessage finds the @mentions and #hashtags in a pin message, each
+// once and in order of first appearance.
+func ParseMessage(message string) MessageRefs {
+	var refs MessageRefs
+
+	seen := map[string]bool{}
+	for _, m := range mentionPattern.FindAllStringSubmatch(message, -1) {
+		// A trailing '.' ends the sentence rather than the username.
+		username := strings.TrimRight(m[1], ".")
+		if len(username) < 3 || len(username) > 50 || seen[username] {
+			continue
+		}
+		seen[username] = true
+		refs.Mentions = append(refs.Mentions, username)
+		if len(refs.Mentions) == MaxMessageMentions {
+			break
+		}
+	}
+
+	seen = map[string]bool{}
+	for _, m := range tagPattern.FindAllStringSubmatch(message, -1) {
+		tag, ok := NormalizeTag(m[1])
+		if !ok || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		refs.Tags = append(refs.Tags, tag)
+		if len(refs.Tags) == MaxMessageTags {
+			break
+		}
+	}
+
+	return refs
+}
+
+// NormalizeTag lowercases tag and drops a leading '#'. It reports false for
+// anything ParseMessage would not index, such as "#2024" or "#a-b".
+func NormalizeTag(tag string) (string, bool) {
+	tag = strings.ToLower(strings.TrimPrefix(tag, "#"))
+	if tag == "" || utf8.RuneCountInString(tag) > MaxTagLength {
+		return "", false
+	}
+
+	hasLetter := false
+	for _, r := range tag {
+		switch {
+		case unicode.IsLetter(r):
+			hasLetter = true
+		case unicode.IsNumber(r), r == '_':
+		default:
+			return "", false
+		}
+	}
+	if !hasLetter {
+		return "", false
+	}
+	return tag, true
+}
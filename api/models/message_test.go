@@ -0,0 +1,67 @@
+package models
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseMessage(t *testing.T) {
+	tests := []struct {
+		message  string
+		mentions []string
+		tags     []string
+	}{
+		{"", nil, nil},
+		{"coffee with @alice and @bob.smith.", []string{"alice", "bob.smith"}, nil},
+		{"@alice @alice @Alice", []string{"alice", "Alice"}, nil},
+		{"mail me@example.com or @al", nil, nil},
+		{"#Sunset at the #beach, #sunset again", nil, []string{"sunset", "beach"}},
+		{"#2024 was C# and #a1 #über", nil, []string{"a1", "über"}},
+		{"(#rain) #rain_day!", nil, []string{"rain", "rain_day"}},
+		{"@dana loves #music", []string{"dana"}, []string{"music"}},
+	}
+
+	for _, tt := range tests {
+		refs := ParseMessage(tt.message)
+		if !reflect.DeepEqual(refs.Mentions, tt.mentions) {
+			t.Errorf("ParseMessage(%q) mentions = %q, want %q", tt.message, refs.Mentions, tt.mentions)
+		}
+		if !reflect.DeepEqual(refs.Tags, tt.tags) {
+			t.Errorf("ParseMessage(%q) tags = %q, want %q", tt.message, refs.Tags, tt.tags)
+		}
+	}
+}
+
+func TestParseMessage_Limits(t *testing.T) {
+	var b strings.Builder
+	for i := 0; i < 15; i++ {
+		b.WriteString("#tag" + string(rune('a'+i)) + " @user" + string(rune('a'+i)) + " ")
+	}
+	b.WriteString("#" + strings.Repeat("x", MaxTagLength+1))
+
+	refs := ParseMessage(b.String())
+	if len(refs.Mentions) != MaxMessageMentions || len(refs.Tags) != MaxMessageTags {
+		t.Errorf("got %d mentions and %d tags", len(refs.Mentions), len(refs.Tags))
+	}
+}
+
+func TestNormalizeTag(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"#Sunset", "sunset", true},
+		{"coffee_time", "coffee_time", true},
+		{"2024", "", false},
+		{"a-b", "", false},
+		{"#", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := NormalizeTag(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("NormalizeTag(%q) = %q, %v; want %q, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
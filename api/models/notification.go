@@ -0,0 +1,39 @@
+package models
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Notification types
+const (
+	NotificationFriendRequest  = "friend_request"
+	NotificationFriendAccepted = "friend_accepted"
+	NotificationPinReaction    = "pin_reaction"
+	NotificationPinComment     = "pin_comment"
+	NotificationCommentReply   = "comment_reply"
+	NotificationPinMention     = "pin_mention"
+)
+
+// NotificationTypes lists every notification type, e.g. for push preferences.
+var NotificationTypes = []string{
+	NotificationFriendRequest,
+	NotificationFriendAccepted,
+	NotificationPinReaction,
+	NotificationPinComment,
+	NotificationCommentReply,
+	NotificationPinMention,
+}
+
+type Notification struct {
+	ID        uuid.UUID       `json:"id"`
+	Type      string          `json:"type"`
+	Actor     *User           `json:"actor,omitempty"`
+	PinID     uuid.NullUUID   `json:"pin_id"`
+	Data      json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"created_at"`
+	ReadAt    sql.NullTime    `json:"read_at"`
+}
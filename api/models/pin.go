@@ -13,6 +13,7 @@ type Location struct {
 }
 
 type Pin struct {
+	ID         uuid.UUID      `json:"id"`
 	UserID     uuid.UUID      `json:"user_id"`
 	Emotion    string         `json:"emotion"`
 	Message    sql.NullString `json:"message,omitempty"`
@@ -20,4 +21,53 @@ type Pin struct {
 	Visibility string         `json:"visibility"`
 	CreatedAt  time.Time      `json:"created_at"`
 	ExpiresAt  sql.NullTime   `json:"expires_at,omitempty"`
+	// Precision is how precisely Location is given to the user the pin was
+	// read for; PrecisionSetting is the pin's own override of its author's
+	// defaults, only ever read for the author.
+	Precision        string         `json:"precision"`
+	PrecisionSetting sql.NullString `json:"precision_setting,omitempty"`
+}
+
+// NearbyPin is a pin with its distance (metres) and bearing (degrees
+// clockwise from north) from the point a nearby query was made from, and the
+// relevance score the query ranks by.
+type NearbyPin struct {
+	Pin
+	DistanceM  float64 `json:"distance_m"`
+	BearingDeg float64 `json:"bearing_deg"`
+	Relevance  float64 `json:"relevance"`
+}
+
+// SearchedPin is a pin matching a full-text search, with the score results
+// are ranked by and a highlighted excerpt of its message.
+type SearchedPin struct {
+	Pin
+	Score   float64 `json:"score"`
+	Snippet string  `json:"snippet"`
+}
+
+// PinEngagement is what others did with a pin, as seen by one viewer.
+type PinEngagement struct {
+	Reactions   map[string]int // count per emoji
+	MyReactions []string       // the viewer's own reactions
+	Comments    int            // comments not deleted
+}
+
+// PinCluster summarises the pins that fall into one grid cell of a clustered map.
+type PinCluster struct {
+	Location        Location       `json:"location"` // centroid of the clustered pins
+	Count           int            `json:"count"`
+	DominantEmotion string         `json:"dominant_emotion"`
+	Emotions        map[string]int `json:"emotions"`
+}
+
+// DominantEmotion picks the most frequent emotion, breaking ties by key so results are stable.
+func DominantEmotion(histogram map[string]int) string {
+	best, bestCount := "", -1
+	for emotion, count := range histogram {
+		if count > bestCount || (count == bestCount && emotion < best) {
+			best, bestCount = emotion, count
+		}
+	}
+	return best
 }
@@ -0,0 +1,77 @@
+package models
+
+import (
+	"math"
+	"slices"
+)
+
+// Location precisions, finest first. The approximate ones show a pin at a
+// point in a grid cell of about the named size instead of where it is.
+const (
+	PrecisionExact = "exact"
+	Precision100m  = "100m"
+	Precision1km   = "1km"
+	PrecisionCity  = "city"
+)
+
+// LocationPrecisions lists every precision, finest first.
+var LocationPrecisions = []string{PrecisionExact, Precision100m, Precision1km, PrecisionCity}
+
+// ApproximatePrecisions are the precisions a pin stores a point for besides
+// its exact location.
+var ApproximatePrecisions = []string{Precision100m, Precision1km, PrecisionCity}
+
+// precisionCellMeters is the grid cell edge of each approximate precision; a
+// city is taken to be about 10 km across.
+var precisionCellMeters = map[string]float64{
+	Precision100m: 100,
+	Precision1km:  1000,
+	PrecisionCity: 10000,
+}
+
+// MaxFuzzMeters bounds how far an approximate location can be from the pin:
+// the diagonal of the coarsest cell.
+const MaxFuzzMeters = 10000 * math.Sqrt2
+
+// LocationPrivacy is how precisely a user's pins are shown by default, to
+// friends and to everyone else. Pins may override it; authors always see
+// their own pins exactly.
+type LocationPrivacy struct {
+	FriendsPrecision string
+	PublicPrecision  string
+}
+
+const metersPerDegree = 111320.0
+
+// ApproximateLocation snaps loc to its cell in the precision's grid and puts
+// it at fraction (fx, fy), each in [0, 1), of the way across that cell, so
+// whoever is shown the result learns the cell and nothing finer. The exact
+// precision, and unknown ones, return loc unchanged.
+func ApproximateLocation(loc Location, precision string, fx float64, fy float64) Location {
+	cell, ok := precisionCellMeters[precision]
+	if !ok {
+		return loc
+	}
+
+	dLat := cell / metersPerDegree
+	south := math.Floor((loc.Latitude+90)/dLat)*dLat - 90
+
+	// Cells are as wide in metres as they are tall, measured at their middle.
+	mid := math.Min(math.Abs(south+dLat/2), 89)
+	dLon := math.Min(cell/(metersPerDegree*math.Cos(mid*math.Pi/180)), 360)
+	west := math.Floor((loc.Longitude+180)/dLon)*dLon - 180
+
+	return Location{
+		Latitude:  math.Min(south+fy*dLat, 90),
+		Longitude: math.Min(west+fx*dLon, 180),
+	}
+}
+
+// CoarserPrecision returns whichever of a and b shows less. An empty or
+// unknown precision counts as finer than any other.
+func CoarserPrecision(a string, b string) string {
+	if slices.Index(LocationPrecisions, b) > slices.Index(LocationPrecisions, a) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"math"
+	"testing"
+)
+
+// distanceMeters is the haversine distance between two points.
+func distanceMeters(a Location, b Location) float64 {
+	lat1, lat2 := a.Latitude*math.Pi/180, b.Latitude*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := (b.Longitude - a.Longitude) * math.Pi / 180
+	h := math.Pow(math.Sin(dLat/2), 2) + math.Cos(lat1)*math.Cos(lat2)*math.Pow(math.Sin(dLon/2), 2)
+	return 2 * earthRadiusKm * 1000 * math.Asin(math.Sqrt(h))
+}
+
+func TestApproximateLocation_StaysInCell(t *testing.T) {
+	home := Location{Latitude: 49.2827, Longitude: -123.1207}
+	neighbour := Location{Latitude: 49.28271, Longitude: -123.12069}
+
+	for _, precision := range ApproximatePrecisions {
+		cell := precisionCellMeters[precision]
+		for _, f := range [][2]float64{{0, 0}, {0.5, 0.5}, {0.999, 0.999}} {
+			got := ApproximateLocation(home, precision, f[0], f[1])
+			if d := distanceMeters(home, got); d > cell*math.Sqrt2*1.01 {
+				t.Errorf("%s at %v: %.0f m from the pin", precision, f, d)
+			}
+			if d := distanceMeters(home, got); d > MaxFuzzMeters {
+				t.Errorf("%s at %v: beyond MaxFuzzMeters", precision, f)
+			}
+		}
+
+		// Points in the same cell share their approximation, so nothing
+		// finer than the cell can be recovered from it.
+		a := ApproximateLocation(home, precision, 0.3, 0.7)
+		b := ApproximateLocation(neighbour, precision, 0.3, 0.7)
+		if a != b {
+			t.Errorf("%s: nearby points approximate to %v and %v", precision, a, b)
+		}
+	}
+}
+
+func TestApproximateLocation_Exact(t *testing.T) {
+	home := Location{Latitude: 49.2827, Longitude: -123.1207}
+	if got := ApproximateLocation(home, PrecisionExact, 0.5, 0.5); got != home {
+		t.Errorf("exact precision moved the pin to %v", got)
+	}
+}
+
+func TestApproximateLocation_Extremes(t *testing.T) {
+	for _, loc := range []Location{{Latitude: 89.9999, Longitude: 179.9999}, {Latitude: -90, Longitude: -180}} {
+		got := ApproximateLocation(loc, PrecisionCity, 0.999, 0.999)
+		if got.Latitude < -90 || got.Latitude > 90 || got.Longitude < -180 || got.Longitude > 180 {
+			t.Errorf("%v approximated out of range to %v", loc, got)
+		}
+	}
+}
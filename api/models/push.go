@@ -0,0 +1,89 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Device platforms accepted for push registration
+const (
+	DevicePlatformIOS = "ios"
+)
+
+// Push delivery states of a notification
+const (
+	PushPending = "pending"
+	PushSent    = "sent"
+	PushSkipped = "skipped"
+	PushFailed  = "failed"
+)
+
+// PushPreferences controls which notifications a user is pushed and when.
+// QuietStart and QuietEnd are minutes of the day in TimeZone; quiet hours
+// wrap past midnight when QuietEnd is before QuietStart.
+type PushPreferences struct {
+	Enabled    bool
+	MutedTypes []string
+	QuietStart *int
+	QuietEnd   *int
+	TimeZone   string
+}
+
+// DefaultPushPreferences apply to users who never saved any.
+func DefaultPushPreferences() PushPreferences {
+	return PushPreferences{Enabled: true, MutedTypes: []string{}, TimeZone: "UTC"}
+}
+
+// PendingPush is a notification due for push delivery, together with what
+// the delivery worker needs to deliver it.
+type PendingPush struct {
+	Notification
+	RecipientID  uuid.UUID
+	Attempts     int
+	Preferences  PushPreferences
+	DeviceTokens []string
+}
+
+// Quiet reports whether t falls in the quiet hours and, if so, when they end.
+func (p PushPreferences) Quiet(t time.Time) (bool, time.Time) {
+	if p.QuietStart == nil || p.QuietEnd == nil || *p.QuietStart == *p.QuietEnd {
+		return false, time.Time{}
+	}
+	loc, err := time.LoadLocation(p.TimeZone)
+	if err != nil {
+		loc = time.UTC
+	}
+	local := t.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	start, end := *p.QuietStart, *p.QuietEnd
+
+	var quiet bool
+	if start < end {
+		quiet = minute >= start && minute < end
+	} else {
+		quiet = minute >= start || minute < end
+	}
+	if !quiet {
+		return false, time.Time{}
+	}
+
+	until := time.Date(local.Year(), local.Month(), local.Day(), end/60, end%60, 0, 0, loc)
+	if !until.After(local) {
+		until = time.Date(local.Year(), local.Month(), local.Day()+1, end/60, end%60, 0, 0, loc)
+	}
+	return true, until
+}
+
+// Muted reports whether notifications of the given type are never pushed.
+func (p PushPreferences) Muted(notificationType string) bool {
+	if !p.Enabled {
+		return true
+	}
+	for _, t := range p.MutedTypes {
+		if t == notificationType {
+			return true
+		}
+	}
+	return false
+}
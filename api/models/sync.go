@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SyncedPin is a pin in a sync with when it last changed for the reader: its
+// own update, the reader befriending its author, or the author changing
+// settings that affect how it is served.
+type SyncedPin struct {
+	Pin
+	ChangedAt time.Time
+}
+
+// SyncChanges is what changed for a user since a sync token, as of AsOf.
+// Profile is nil when it hasn't changed.
+type SyncChanges struct {
+	AsOf            time.Time
+	Profile         *User
+	Pins            []SyncedPin
+	DeletedPins     []uuid.UUID
+	Friends         []User
+	RemovedFriends  []uuid.UUID
+	Requests        []FriendRequest
+	RemovedRequests []uuid.UUID
+}
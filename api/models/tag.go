@@ -0,0 +1,9 @@
+package models
+
+// TrendingTag is a hashtag with how many pins and distinct authors used it
+// within a trending window.
+type TrendingTag struct {
+	Tag     string
+	Pins    int
+	Authors int
+}
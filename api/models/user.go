@@ -14,3 +14,10 @@ type User struct {
 	CreatedAt   time.Time      `json:"created_at"`
 	UpdatedAt   time.Time      `json:"updated_at"`
 }
+
+// FriendRequest is a pending request to (Incoming) or from the listing user.
+type FriendRequest struct {
+	User
+	Incoming    bool      `json:"incoming"`
+	RequestedAt time.Time `json:"requested_at"`
+}
@@ -0,0 +1,120 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	MaxZonesPerUser    = 20
+	MinZoneRadiusM     = 25
+	MaxZoneRadiusM     = 5000
+	MaxZonePolygonSize = 100
+	// MaxZoneAreaKm2 bounds a polygon zone's bounding box, a little over
+	// the largest circle's.
+	MaxZoneAreaKm2 = 100
+)
+
+// Zone is an area, a circle or a polygon, that the user keeps private. Pins
+// the user creates inside it are downgraded to its Visibility and Precision,
+// either of which may be empty to leave that alone. Strangers never see pins
+// inside another user's zones.
+type Zone struct {
+	ID   uuid.UUID
+	Name string
+	// Set for circles
+	Center  *Location
+	RadiusM int
+	// Set for polygons; the ring is open, its last vertex joining the first
+	Polygon    []Location
+	Visibility string
+	Precision  string
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+}
+
+// visibilityRank orders pin visibilities from most to least shared.
+var visibilityRank = map[string]int{"public": 1, "friends": 2, "private": 3}
+
+// StricterVisibility returns whichever of a and b shares the pin with fewer
+// people. An empty or unknown visibility counts as the least strict.
+func StricterVisibility(a string, b string) string {
+	if visibilityRank[b] > visibilityRank[a] {
+		return b
+	}
+	return a
+}
+
+// Downgrade applies the zone to a pin's visibility and precision.
+func (z Zone) Downgrade(visibility string, precision string) (string, string) {
+	return StricterVisibility(visibility, z.Visibility), CoarserPrecision(precision, z.Precision)
+}
+
+// SimplePolygon reports whether the open ring has at least three distinct
+// vertices and none of its edges cross or overlap, so it bounds a single area.
+func SimplePolygon(ring []Location) bool {
+	n := len(ring)
+	if n < 3 {
+		return false
+	}
+	for i := range n {
+		prev, cur, next := ring[(i+n-1)%n], ring[i], ring[(i+1)%n]
+		if cur == next {
+			return false
+		}
+		// An edge doubling back along the one before it
+		if orientation(prev, cur, next) == 0 && (onSegment(prev, cur, next) || onSegment(cur, next, prev)) {
+			return false
+		}
+	}
+	for i := range n {
+		a, b := ring[i], ring[(i+1)%n]
+		for j := i + 1; j < n; j++ {
+			// Neighbouring edges share a vertex and may only touch there
+			if j == i+1 || (i == 0 && j == n-1) {
+				continue
+			}
+			if segmentsIntersect(a, b, ring[j], ring[(j+1)%n]) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// orientation is the sign of the turn p -> q -> r: positive counter-clockwise,
+// negative clockwise, zero when collinear.
+func orientation(p Location, q Location, r Location) float64 {
+	return (q.Longitude-p.Longitude)*(r.Latitude-p.Latitude) - (q.Latitude-p.Latitude)*(r.Longitude-p.Longitude)
+}
+
+// onSegment reports whether r, collinear with p and q, lies between them.
+func onSegment(p Location, q Location, r Location) bool {
+	return min(p.Longitude, q.Longitude) <= r.Longitude && r.Longitude <= max(p.Longitude, q.Longitude) &&
+		min(p.Latitude, q.Latitude) <= r.Latitude && r.Latitude <= max(p.Latitude, q.Latitude)
+}
+
+func segmentsIntersect(p1 Location, p2 Location, q1 Location, q2 Location) bool {
+	d1, d2 := orientation(q1, q2, p1), orientation(q1, q2, p2)
+	d3, d4 := orientation(p1, p2, q1), orientation(p1, p2, q2)
+	if ((d1 > 0 && d2 < 0) || (d1 < 0 && d2 > 0)) && ((d3 > 0 && d4 < 0) || (d3 < 0 && d4 > 0)) {
+		return true
+	}
+	return (d1 == 0 && onSegment(q1, q2, p1)) || (d2 == 0 && onSegment(q1, q2, p2)) ||
+		(d3 == 0 && onSegment(p1, p2, q1)) || (d4 == 0 && onSegment(p1, p2, q2))
+}
+
+// RingBounds is the smallest box holding every vertex of the ring. Rings are
+// taken not to cross the antimeridian.
+func RingBounds(ring []Location) BBox {
+	if len(ring) == 0 {
+		return BBox{}
+	}
+	b := BBox{MinLon: ring[0].Longitude, MinLat: ring[0].Latitude, MaxLon: ring[0].Longitude, MaxLat: ring[0].Latitude}
+	for _, loc := range ring[1:] {
+		b.MinLon, b.MaxLon = min(b.MinLon, loc.Longitude), max(b.MaxLon, loc.Longitude)
+		b.MinLat, b.MaxLat = min(b.MinLat, loc.Latitude), max(b.MaxLat, loc.Latitude)
+	}
+	return b
+}
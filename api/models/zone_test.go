@@ -0,0 +1,52 @@
+package models
+
+import "testing"
+
+func ring(points ...[2]float64) []Location {
+	locs := make([]Location, 0, len(points))
+	for _, p := range points {
+		locs = append(locs, Location{Longitude: p[0], Latitude: p[1]})
+	}
+	return locs
+}
+
+func TestSimplePolygon(t *testing.T) {
+	cases := []struct {
+		name string
+		ring []Location
+		want bool
+	}{
+		{"triangle", ring([2]float64{0, 0}, [2]float64{1, 0}, [2]float64{0, 1}), true},
+		{"concave", ring([2]float64{0, 0}, [2]float64{2, 0}, [2]float64{1, 1}, [2]float64{2, 2}, [2]float64{0, 2}), true},
+		{"two points", ring([2]float64{0, 0}, [2]float64{1, 0}), false},
+		{"repeated vertex", ring([2]float64{0, 0}, [2]float64{1, 0}, [2]float64{1, 0}, [2]float64{0, 1}), false},
+		{"bow tie", ring([2]float64{0, 0}, [2]float64{1, 1}, [2]float64{1, 0}, [2]float64{0, 1}), false},
+		{"collinear", ring([2]float64{0, 0}, [2]float64{1, 0}, [2]float64{2, 0}), false},
+		{"vertex along an edge", ring([2]float64{0, 0}, [2]float64{1, 0}, [2]float64{2, 0}, [2]float64{2, 2}, [2]float64{0, 2}), true},
+	}
+	for _, tc := range cases {
+		if got := SimplePolygon(tc.ring); got != tc.want {
+			t.Errorf("%s: expected %v got %v", tc.name, tc.want, got)
+		}
+	}
+}
+
+func TestZoneDowngrade(t *testing.T) {
+	cases := []struct {
+		zone                  Zone
+		visibility, precision string
+		wantVis, wantPrec     string
+	}{
+		{Zone{Visibility: "friends"}, "public", "", "friends", ""},
+		{Zone{Visibility: "friends"}, "private", "exact", "private", "exact"},
+		{Zone{Precision: PrecisionCity}, "public", "", "public", PrecisionCity},
+		{Zone{Precision: Precision1km}, "friends", PrecisionCity, "friends", PrecisionCity},
+		{Zone{Visibility: "private", Precision: Precision100m}, "public", PrecisionExact, "private", Precision100m},
+	}
+	for _, tc := range cases {
+		vis, prec := tc.zone.Downgrade(tc.visibility, tc.precision)
+		if vis != tc.wantVis || prec != tc.wantPrec {
+			t.Errorf("%+v on (%q, %q): expected (%q, %q) got (%q, %q)", tc.zone, tc.visibility, tc.precision, tc.wantVis, tc.wantPrec, vis, prec)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	APNsProductionHost = "https://api.push.apple.com"
+	APNsSandboxHost    = "https://api.sandbox.push.apple.com"
+
+	// Apple rejects provider tokens older than an hour and throttles
+	// refreshes more frequent than every 20 minutes.
+	apnsTokenLifetime = 50 * time.Minute
+
+	apnsRequestTimeout = 10 * time.Second
+	maxAPNsCollapseID  = 64
+)
+
+// APNsConfig holds the token-based credentials of an APNs provider.
+type APNsConfig struct {
+	Host   string // APNsProductionHost or APNsSandboxHost
+	Topic  string // the app's bundle ID
+	KeyID  string
+	TeamID string
+	Key    *ecdsa.PrivateKey
+}
+
+// APNsSender sends alerts through the APNs HTTP/2 provider API.
+type APNsSender struct {
+	cfg    APNsConfig
+	client *http.Client
+
+	mu       sync.Mutex
+	token    string
+	issuedAt time.Time
+}
+
+// NewAPNsSender returns a sender for cfg. A nil client gets one that speaks
+// HTTP/2, which APNs requires.
+func NewAPNsSender(cfg APNsConfig, client *http.Client) *APNsSender {
+	if client == nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.ForceAttemptHTTP2 = true
+		client = &http.Client{Transport: transport, Timeout: apnsRequestTimeout}
+	}
+	return &APNsSender{cfg: cfg, client: client}
+}
+
+// LoadAPNsKey reads the .p8 signing key downloaded from the developer portal.
+func LoadAPNsKey(path string) (*ecdsa.PrivateKey, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return jwt.ParseECPrivateKeyFromPEM(pem)
+}
+
+// providerToken returns the cached JWT, signing a new one once it ages out.
+func (s *APNsSender) providerToken() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && time.Since(s.issuedAt) < apnsTokenLifetime {
+		return s.token, nil
+	}
+
+	now := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"iss": s.cfg.TeamID,
+		"iat": now.Unix(),
+	})
+	token.Header["kid"] = s.cfg.KeyID
+
+	signed, err := token.SignedString(s.cfg.Key)
+	if err != nil {
+		return "", err
+	}
+	s.token, s.issuedAt = signed, now
+	return signed, nil
+}
+
+// resetProviderToken drops a token APNs refused so the next send signs anew.
+func (s *APNsSender) resetProviderToken(rejected string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token == rejected {
+		s.token = ""
+	}
+}
+
+func apnsPayload(msg Message) ([]byte, error) {
+	aps := map[string]any{
+		"alert": map[string]string{
+			"title": msg.Title,
+			"body":  msg.Body,
+		},
+		"sound": "default",
+	}
+	if msg.ThreadID != "" {
+		aps["thread-id"] = msg.ThreadID
+	}
+
+	payload := map[string]any{}
+	for k, v := range msg.Data {
+		payload[k] = v
+	}
+	payload["aps"] = aps
+	return json.Marshal(payload)
+}
+
+func (s *APNsSender) Send(ctx context.Context, msg Message) error {
+	body, err := apnsPayload(msg)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrRejected, err)
+	}
+
+	token, err := s.providerToken()
+	if err != nil {
+		return fmt.Errorf("sign apns provider token: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.Host+"/3/device/"+msg.Token, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("apns-topic", s.cfg.Topic)
+	req.Header.Set("apns-push-type", "alert")
+	req.Header.Set("apns-priority", "10")
+	req.Header.Set("apns-expiration", "0")
+	if !msg.Expiration.IsZero() {
+		req.Header.Set("apns-expiration", strconv.FormatInt(msg.Expiration.Unix(), 10))
+	}
+	if id := msg.CollapseID; id != "" {
+		if len(id) > maxAPNsCollapseID {
+			id = id[:maxAPNsCollapseID]
+		}
+		req.Header.Set("apns-collapse-id", id)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+
+	var reply struct {
+		Reason string `json:"reason"`
+	}
+	json.NewDecoder(io.LimitReader(resp.Body, 4096)).Decode(&reply)
+
+	switch {
+	case resp.StatusCode == http.StatusGone,
+		reply.Reason == "BadDeviceToken",
+		reply.Reason == "Unregistered",
+		reply.Reason == "DeviceTokenNotForTopic":
+		return fmt.Errorf("%w: apns %d %s", ErrInvalidToken, resp.StatusCode, reply.Reason)
+	case reply.Reason == "ExpiredProviderToken", reply.Reason == "InvalidProviderToken":
+		s.resetProviderToken(token)
+		return fmt.Errorf("apns %d %s", resp.StatusCode, reply.Reason)
+	case resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode >= 500:
+		return fmt.Errorf("apns %d %s", resp.StatusCode, reply.Reason)
+	default:
+		return fmt.Errorf("%w: apns %d %s", ErrRejected, resp.StatusCode, reply.Reason)
+	}
+}
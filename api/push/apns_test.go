@@ -0,0 +1,160 @@
+package push
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type apnsReply struct {
+	status int
+	reason string
+}
+
+// newTestAPNs starts an HTTP/2 server that answers with replies in turn and
+// records each request it saw.
+func newTestAPNs(t *testing.T, replies ...apnsReply) (*APNsSender, *ecdsa.PrivateKey, *[]*http.Request, *[]map[string]any) {
+	t.Helper()
+
+	var requests []*http.Request
+	var payloads []map[string]any
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload map[string]any
+		json.NewDecoder(r.Body).Decode(&payload)
+		requests = append(requests, r)
+		payloads = append(payloads, payload)
+
+		reply := apnsReply{status: http.StatusOK}
+		if len(replies) > 0 {
+			reply, replies = replies[0], replies[1:]
+		}
+		w.WriteHeader(reply.status)
+		if reply.reason != "" {
+			json.NewEncoder(w).Encode(map[string]string{"reason": reply.reason})
+		}
+	}))
+	srv.EnableHTTP2 = true
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender := NewAPNsSender(APNsConfig{
+		Host:   srv.URL,
+		Topic:  "com.example.ember",
+		KeyID:  "KEY123",
+		TeamID: "TEAM456",
+		Key:    key,
+	}, srv.Client())
+	return sender, key, &requests, &payloads
+}
+
+func TestAPNsSenderSendsSignedHTTP2Request(t *testing.T) {
+	sender, key, requests, payloads := newTestAPNs(t)
+
+	err := sender.Send(context.Background(), Message{
+		Token:      "abc123",
+		Title:      "New friend request",
+		Body:       "sam wants to be friends",
+		CollapseID: strings.Repeat("x", 100),
+		Data:       map[string]any{"type": "friend_request"},
+	})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	req := (*requests)[0]
+	if req.ProtoMajor != 2 {
+		t.Errorf("proto = %s, want HTTP/2", req.Proto)
+	}
+	if req.URL.Path != "/3/device/abc123" {
+		t.Errorf("path = %q", req.URL.Path)
+	}
+	if got := req.Header.Get("apns-topic"); got != "com.example.ember" {
+		t.Errorf("apns-topic = %q", got)
+	}
+	if got := req.Header.Get("apns-collapse-id"); len(got) != maxAPNsCollapseID {
+		t.Errorf("apns-collapse-id has %d bytes, want it cut to %d", len(got), maxAPNsCollapseID)
+	}
+
+	raw := strings.TrimPrefix(req.Header.Get("Authorization"), "bearer ")
+	token, err := jwt.Parse(raw, func(*jwt.Token) (any, error) { return &key.PublicKey, nil },
+		jwt.WithValidMethods([]string{"ES256"}))
+	if err != nil {
+		t.Fatalf("provider token: %v", err)
+	}
+	if token.Header["kid"] != "KEY123" {
+		t.Errorf("kid = %v", token.Header["kid"])
+	}
+	if iss, _ := token.Claims.GetIssuer(); iss != "TEAM456" {
+		t.Errorf("iss = %q", iss)
+	}
+
+	payload := (*payloads)[0]
+	if payload["type"] != "friend_request" {
+		t.Errorf("custom data missing: %v", payload)
+	}
+	alert := payload["aps"].(map[string]any)["alert"].(map[string]any)
+	if alert["title"] != "New friend request" {
+		t.Errorf("alert = %v", alert)
+	}
+}
+
+func TestAPNsSenderClassifiesFailures(t *testing.T) {
+	tests := []struct {
+		name     string
+		reply    apnsReply
+		invalid  bool
+		rejected bool
+	}{
+		{"unregistered", apnsReply{http.StatusGone, "Unregistered"}, true, false},
+		{"bad token", apnsReply{http.StatusBadRequest, "BadDeviceToken"}, true, false},
+		{"bad payload", apnsReply{http.StatusBadRequest, "PayloadTooLarge"}, false, true},
+		{"throttled", apnsReply{http.StatusTooManyRequests, "TooManyRequests"}, false, false},
+		{"unavailable", apnsReply{http.StatusServiceUnavailable, "ServiceUnavailable"}, false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sender, _, _, _ := newTestAPNs(t, tt.reply)
+
+			err := sender.Send(context.Background(), Message{Token: "abc123"})
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if got := errors.Is(err, ErrInvalidToken); got != tt.invalid {
+				t.Errorf("ErrInvalidToken = %v, want %v (%v)", got, tt.invalid, err)
+			}
+			if got := errors.Is(err, ErrRejected); got != tt.rejected {
+				t.Errorf("ErrRejected = %v, want %v (%v)", got, tt.rejected, err)
+			}
+		})
+	}
+}
+
+func TestAPNsSenderResignsExpiredProviderToken(t *testing.T) {
+	sender, _, requests, _ := newTestAPNs(t, apnsReply{http.StatusForbidden, "ExpiredProviderToken"})
+
+	if err := sender.Send(context.Background(), Message{Token: "abc123"}); err == nil {
+		t.Fatal("expected the expired token to fail the send")
+	}
+	first := (*requests)[0].Header.Get("Authorization")
+
+	if err := sender.Send(context.Background(), Message{Token: "abc123"}); err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	if (*requests)[1].Header.Get("Authorization") == first {
+		t.Error("expected a freshly signed provider token after ExpiredProviderToken")
+	}
+}
@@ -0,0 +1,61 @@
+package push
+
+import (
+	"context"
+	"log"
+	"sync"
+)
+
+// FakeSender records messages instead of sending them. It stands in for a
+// real push service in tests and in local setups without APNs credentials.
+type FakeSender struct {
+	mu       sync.Mutex
+	sent     []Message
+	failures map[string]error
+	logged   bool
+}
+
+func NewFakeSender() *FakeSender {
+	return &FakeSender{failures: map[string]error{}}
+}
+
+// NewLoggingFakeSender is a FakeSender that also logs each message.
+func NewLoggingFakeSender() *FakeSender {
+	f := NewFakeSender()
+	f.logged = true
+	return f
+}
+
+// Fail makes every later send to token return err; a nil err clears it.
+func (f *FakeSender) Fail(token string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err == nil {
+		delete(f.failures, token)
+		return
+	}
+	f.failures[token] = err
+}
+
+// Sent returns the messages delivered so far, in order.
+func (f *FakeSender) Sent() []Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]Message(nil), f.sent...)
+}
+
+func (f *FakeSender) Send(ctx context.Context, msg Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err, ok := f.failures[msg.Token]; ok {
+		return err
+	}
+	f.sent = append(f.sent, msg)
+	if f.logged {
+		log.Printf("push to %s: %s: %s", msg.Token, msg.Title, msg.Body)
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+// Package push delivers notifications to users' devices.
+package push
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+var (
+	// ErrInvalidToken means the device token is dead and should be forgotten.
+	ErrInvalidToken = errors.New("push: invalid device token")
+	// ErrRejected means the push service refused the message itself;
+	// sending it again will not help.
+	ErrRejected = errors.New("push: message rejected")
+)
+
+// Message is a single alert for a single device.
+type Message struct {
+	Token string
+	Title string
+	Body  string
+
+	// Messages sharing a CollapseID replace each other on the device.
+	CollapseID string
+	// Pushes with the same ThreadID are grouped together on the device.
+	ThreadID string
+	// Custom payload for the app, alongside the alert.
+	Data map[string]any
+	// The push service drops the message if it can't deliver it by then.
+	// Zero means deliver once or not at all.
+	Expiration time.Time
+}
+
+// Sender delivers messages to a push service. Errors other than
+// ErrInvalidToken and ErrRejected are transient and worth retrying.
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
@@ -0,0 +1,206 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+
+	"ember/api/models"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrAttachmentNotFound = errors.New("attachment does not exist")
+	ErrTooManyAttachments = errors.New("pin has too many attachments")
+)
+
+// interface
+type AttachmentRepository interface {
+	AddAttachments(userID uuid.UUID, pinID uuid.UUID, attachments []models.Attachment, max int) ([]models.Attachment, error)
+	GetAttachment(pinID uuid.UUID, attachmentID uuid.UUID) (*models.Attachment, error)
+	DetachAttachment(userID uuid.UUID, pinID uuid.UUID, attachmentID uuid.UUID) error
+	ListDetachedAttachments(limit int) ([]uuid.UUID, error)
+	PurgeAttachments(ids []uuid.UUID) error
+}
+
+// implementation
+type attachmentRepository struct {
+	db *sql.DB
+}
+
+func NewAttachmentRepository(db *sql.DB) AttachmentRepository {
+	return &attachmentRepository{
+		db: db,
+	}
+}
+
+// attachmentColumns selects an attachment (a) on pin p in the order
+// scanAttachment expects.
+const attachmentColumns = `
+			a.uuid,
+			p.uuid,
+			a.position,
+			a.width,
+			a.height,
+			a.blurhash,
+			a.byte_size,
+			a.created_at`
+
+func scanAttachment(row rowScanner) (models.Attachment, error) {
+	var a models.Attachment
+	err := row.Scan(
+		&a.ID,
+		&a.PinID,
+		&a.Position,
+		&a.Width,
+		&a.Height,
+		&a.Blurhash,
+		&a.ByteSize,
+		&a.CreatedAt,
+	)
+	return a, err
+}
+
+// AddAttachments appends attachments, whose blobs are already stored, to a
+// pin userID owns. The pin is locked while counting, so concurrent uploads
+// can't push it past max together.
+func (ar *attachmentRepository) AddAttachments(userID uuid.UUID, pinID uuid.UUID, attachments []models.Attachment, max int) ([]models.Attachment, error) {
+	tx, err := ar.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var id int64
+	var ownerID uuid.UUID
+	err = tx.QueryRow(
+		`SELECT p.id, u.uuid
+		 FROM pins p
+		 JOIN users u ON u.id = p.user_id
+		 WHERE p.uuid = $1
+		 FOR UPDATE OF p`,
+		pinID.String(),
+	).Scan(&id, &ownerID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrPinNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if ownerID != userID {
+		return nil, ErrNotPinOwner
+	}
+
+	var count, last int
+	if err := tx.QueryRow(
+		`SELECT COUNT(*), COALESCE(MAX(position), -1) FROM pin_attachments WHERE pin_id = $1`,
+		id,
+	).Scan(&count, &last); err != nil {
+		return nil, err
+	}
+	if count+len(attachments) > max {
+		return nil, ErrTooManyAttachments
+	}
+
+	added := make([]models.Attachment, 0, len(attachments))
+	for i, a := range attachments {
+		const q = `
+			WITH a AS (
+				INSERT INTO pin_attachments (uuid, pin_id, position, width, height, blurhash, byte_size)
+				VALUES ($1, $2, $3, $4, $5, $6, $7)
+				RETURNING *
+			)
+			SELECT` + attachmentColumns + `
+			FROM a
+			JOIN pins p ON p.id = a.pin_id
+		`
+
+		inserted, err := scanAttachment(tx.QueryRow(q, a.ID.String(), id, last+1+i, a.Width, a.Height, a.Blurhash, a.ByteSize))
+		if err != nil {
+			return nil, err
+		}
+		added = append(added, inserted)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return added, nil
+}
+
+// GetAttachment returns an attachment of pinID, or nil if there is none.
+// Callers check that the pin is visible first.
+func (ar *attachmentRepository) GetAttachment(pinID uuid.UUID, attachmentID uuid.UUID) (*models.Attachment, error) {
+	const q = `
+		SELECT` + attachmentColumns + `
+		FROM pin_attachments a
+		JOIN pins p ON p.id = a.pin_id
+		WHERE p.uuid = $1 AND a.uuid = $2
+	`
+
+	a, err := scanAttachment(ar.db.QueryRow(q, pinID.String(), attachmentID.String()))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &a, nil
+}
+
+// DetachAttachment removes an attachment from a pin userID owns. The row
+// stays behind, detached, until the sweeper has deleted its blobs.
+func (ar *attachmentRepository) DetachAttachment(userID uuid.UUID, pinID uuid.UUID, attachmentID uuid.UUID) error {
+	var ownerID uuid.UUID
+	err := ar.db.QueryRow(
+		`SELECT u.uuid
+		 FROM pin_attachments a
+		 JOIN pins p ON p.id = a.pin_id
+		 JOIN users u ON u.id = p.user_id
+		 WHERE p.uuid = $1 AND a.uuid = $2`,
+		pinID.String(), attachmentID.String(),
+	).Scan(&ownerID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrAttachmentNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if ownerID != userID {
+		return ErrNotPinOwner
+	}
+
+	_, err = ar.db.Exec(`UPDATE pin_attachments SET pin_id = NULL WHERE uuid = $1`, attachmentID.String())
+	return err
+}
+
+// ListDetachedAttachments returns up to limit attachments whose pin is gone.
+func (ar *attachmentRepository) ListDetachedAttachments(limit int) ([]uuid.UUID, error) {
+	rows, err := ar.db.Query(
+		`SELECT uuid FROM pin_attachments WHERE pin_id IS NULL ORDER BY id LIMIT $1`,
+		limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// PurgeAttachments deletes detached attachment rows once their blobs are gone.
+func (ar *attachmentRepository) PurgeAttachments(ids []uuid.UUID) error {
+	_, err := ar.db.Exec(
+		`DELETE FROM pin_attachments WHERE uuid = ANY($1::uuid[]) AND pin_id IS NULL`,
+		uuidStrings(ids),
+	)
+	return err
+}
@@ -0,0 +1,216 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+
+	"ember/api/models"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrCommentNotFound  = errors.New("comment does not exist")
+	ErrNotCommentAuthor = errors.New("comment belongs to another user")
+)
+
+// interface
+type CommentRepository interface {
+	CreateComment(userID uuid.UUID, pinID uuid.UUID, parentID *uuid.UUID, body string) (*models.Comment, error)
+	GetComment(userID uuid.UUID, commentID uuid.UUID) (*models.Comment, error)
+	ListComments(userID uuid.UUID, pinID uuid.UUID, parentID *uuid.UUID, page Page) ([]models.Comment, error)
+	DeleteComment(userID uuid.UUID, commentID uuid.UUID) error
+}
+
+// implementation
+type commentRepository struct {
+	db *sql.DB
+}
+
+func NewCommentRepository(db *sql.DB) CommentRepository {
+	return &commentRepository{
+		db: db,
+	}
+}
+
+// commentColumns selects a comment (c) on pin p, its parent (pc, may be NULL)
+// and author (a) in the order scanComment expects.
+const commentColumns = `
+			c.uuid,
+			p.uuid,
+			pc.uuid,
+			a.uuid,
+			a.username,
+			a.display_name,
+			COALESCE(c.body, ''),
+			c.created_at,
+			c.deleted_at IS NOT NULL,
+			(
+				SELECT COUNT(*) FROM pin_comments rc
+				WHERE rc.parent_id = c.id
+				  AND (rc.deleted_at IS NULL OR EXISTS (SELECT 1 FROM pin_comments x WHERE x.parent_id = rc.id))
+			)`
+
+// commentOnVisiblePin joins a comment (c) to its pin (p, authored by u), the
+// requester ($1, as r), its parent (pc) and author (a), keeping only comments
+// on pins the requester can see.
+const commentOnVisiblePin = `
+		FROM pin_comments c
+		JOIN pins p ON p.id = c.pin_id
+		JOIN users u ON u.id = p.user_id
+		JOIN users a ON a.id = c.user_id
+		JOIN users r ON r.uuid = $1
+		LEFT JOIN pin_comments pc ON pc.id = c.parent_id
+		WHERE ` + notExpired + `
+		AND ` + visibleToRequester
+
+func scanComment(row rowScanner) (models.Comment, error) {
+	var c models.Comment
+	err := row.Scan(
+		&c.ID,
+		&c.PinID,
+		&c.ParentID,
+		&c.Author.ID,
+		&c.Author.Username,
+		&c.Author.DisplayName,
+		&c.Body,
+		&c.CreatedAt,
+		&c.Deleted,
+		&c.ReplyCount,
+	)
+	return c, err
+}
+
+// CreateComment comments on a pin userID can see, or replies to parentID on
+// that pin. It returns ErrPinNotFound if the pin isn't visible and
+// ErrCommentNotFound if the parent isn't a live comment on the same pin.
+func (cr *commentRepository) CreateComment(userID uuid.UUID, pinID uuid.UUID, parentID *uuid.UUID, body string) (*models.Comment, error) {
+	var parent any
+	if parentID != nil {
+		parent = parentID.String()
+	}
+
+	const q = `
+		WITH target AS (
+			SELECT p.id AS pin_id, r.id AS user_id
+			FROM pins p
+			JOIN users u ON u.id = p.user_id
+			JOIN users r ON r.uuid = $1
+			WHERE p.uuid = $2
+			AND ` + notExpired + `
+			AND ` + visibleToRequester + `
+		), parent AS (
+			SELECT pc.id
+			FROM pin_comments pc
+			JOIN target t ON t.pin_id = pc.pin_id
+			WHERE pc.uuid = $3::uuid AND pc.deleted_at IS NULL
+		), inserted AS (
+			INSERT INTO pin_comments (pin_id, user_id, parent_id, body)
+			SELECT t.pin_id, t.user_id, (SELECT id FROM parent), $4
+			FROM target t
+			WHERE $3::uuid IS NULL OR EXISTS (SELECT 1 FROM parent)
+			RETURNING uuid
+		)
+		SELECT EXISTS (SELECT 1 FROM target), (SELECT uuid FROM inserted)
+	`
+
+	var visible bool
+	var commentID uuid.NullUUID
+	if err := cr.db.QueryRow(q, userID.String(), pinID.String(), parent, body).Scan(&visible, &commentID); err != nil {
+		return nil, err
+	}
+	if !visible {
+		return nil, ErrPinNotFound
+	}
+	if !commentID.Valid {
+		return nil, ErrCommentNotFound
+	}
+
+	return cr.GetComment(userID, commentID.UUID)
+}
+
+// GetComment returns a comment on a pin userID can see, or nil otherwise.
+func (cr *commentRepository) GetComment(userID uuid.UUID, commentID uuid.UUID) (*models.Comment, error) {
+	const q = `
+		SELECT` + commentColumns + commentOnVisiblePin + `
+		AND c.uuid = $2
+	`
+
+	c, err := scanComment(cr.db.QueryRow(q, userID.String(), commentID.String()))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &c, nil
+}
+
+// ListComments returns the top-level comments on a pin, or the replies to
+// parentID, oldest first. Pins userID can't see have no comments.
+func (cr *commentRepository) ListComments(userID uuid.UUID, pinID uuid.UUID, parentID *uuid.UUID, page Page) ([]models.Comment, error) {
+	var parent any
+	if parentID != nil {
+		parent = parentID.String()
+	}
+
+	const q = `
+		SELECT` + commentColumns + commentOnVisiblePin + `
+		AND p.uuid = $2
+		AND (($3::uuid IS NULL AND c.parent_id IS NULL) OR pc.uuid = $3::uuid)
+		AND ($4::timestamptz IS NULL OR (c.created_at, c.uuid) > ($4, $5::uuid))
+		-- deleted comments are only listed while replies hang off them
+		AND (c.deleted_at IS NULL OR EXISTS (SELECT 1 FROM pin_comments x WHERE x.parent_id = c.id))
+		ORDER BY c.created_at, c.uuid
+		LIMIT $6
+	`
+
+	afterTime, afterID := page.createdAtArgs()
+	rows, err := cr.db.Query(q, userID.String(), pinID.String(), parent, afterTime, afterID, page.fetchLimit())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var comments []models.Comment
+	for rows.Next() {
+		c, err := scanComment(rows)
+		if err != nil {
+			return nil, err
+		}
+		comments = append(comments, c)
+	}
+
+	return comments, rows.Err()
+}
+
+// DeleteComment deletes a comment on behalf of its author or the owner of the
+// pin it is on. The row is kept, without its body, for the replies under it.
+// Comments on pins userID can't see are reported as not found, whoever wrote
+// them.
+func (cr *commentRepository) DeleteComment(userID uuid.UUID, commentID uuid.UUID) error {
+	const q = `
+		SELECT a.uuid, u.uuid` + commentOnVisiblePin + `
+		AND c.uuid = $2
+		AND c.deleted_at IS NULL
+	`
+
+	var authorID, pinOwnerID uuid.UUID
+	err := cr.db.QueryRow(q, userID.String(), commentID.String()).Scan(&authorID, &pinOwnerID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrCommentNotFound
+		}
+		return err
+	}
+
+	if userID != authorID && userID != pinOwnerID {
+		return ErrNotCommentAuthor
+	}
+
+	_, err = cr.db.Exec(
+		`UPDATE pin_comments SET body = NULL, deleted_at = NOW() WHERE uuid = $1`,
+		commentID.String(),
+	)
+	return err
+}
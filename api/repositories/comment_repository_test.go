@@ -0,0 +1,34 @@
+package repositories
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDeleteComment_PinNoLongerVisible(t *testing.T) {
+	db := openTestDB(t)
+	pinRepo := NewPinRepository(db)
+	commentRepo := NewCommentRepository(db)
+	author, commenter := createTestUser(t, db), createTestUser(t, db)
+
+	pin, err := pinRepo.CreatePin(author, "happy", "", -140.0, 16.0, "public", "", nil)
+	if err != nil {
+		t.Fatalf("create pin: %v", err)
+	}
+	comment, err := commentRepo.CreateComment(commenter, pin.ID, nil, "hello")
+	if err != nil || comment == nil {
+		t.Fatalf("create comment: %v", err)
+	}
+
+	private := "private"
+	if _, err := pinRepo.UpdatePin(author, pin.ID, PinUpdate{Visibility: &private}); err != nil {
+		t.Fatalf("update pin: %v", err)
+	}
+
+	if err := commentRepo.DeleteComment(commenter, comment.ID); !errors.Is(err, ErrCommentNotFound) {
+		t.Fatalf("expected ErrCommentNotFound on a pin the commenter can't see, got %v", err)
+	}
+	if err := commentRepo.DeleteComment(author, comment.ID); err != nil {
+		t.Fatalf("expected the pin's owner to delete the comment, got %v", err)
+	}
+}
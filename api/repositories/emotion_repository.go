@@ -0,0 +1,92 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+
+	"ember/api/models"
+)
+
+// interface
+type EmotionRepository interface {
+	GetCatalog() (*models.EmotionCatalog, error)
+	GetCatalogVersion() (int64, error)
+	FindEmotion(keyOrEmoji string) (*models.Emotion, error)
+}
+
+// implementation
+type emotionRepository struct {
+	db *sql.DB
+}
+
+func NewEmotionRepository(db *sql.DB) EmotionRepository {
+	return &emotionRepository{
+		db: db,
+	}
+}
+
+func (er *emotionRepository) GetCatalog() (*models.EmotionCatalog, error) {
+	// Read the version and rows from one snapshot so the ETag matches the body.
+	tx, err := er.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var catalog models.EmotionCatalog
+	if err := tx.QueryRow(
+		`SELECT version, updated_at FROM emotion_catalog WHERE id = 1`,
+	).Scan(&catalog.Version, &catalog.UpdatedAt); err != nil {
+		return nil, err
+	}
+
+	rows, err := tx.Query(`
+		SELECT key, emoji, label, valence, arousal, category
+		FROM emotions
+		WHERE active
+		ORDER BY sort_order, key
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var e models.Emotion
+		if err := rows.Scan(&e.Key, &e.Emoji, &e.Label, &e.Valence, &e.Arousal, &e.Category); err != nil {
+			return nil, err
+		}
+		catalog.Emotions = append(catalog.Emotions, e)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return &catalog, nil
+}
+
+func (er *emotionRepository) GetCatalogVersion() (int64, error) {
+	var version int64
+	err := er.db.QueryRow(`SELECT version FROM emotion_catalog WHERE id = 1`).Scan(&version)
+	return version, err
+}
+
+// FindEmotion resolves an active emotion by key or emoji, returning nil if there is none.
+func (er *emotionRepository) FindEmotion(keyOrEmoji string) (*models.Emotion, error) {
+	var e models.Emotion
+	err := er.db.QueryRow(`
+		SELECT key, emoji, label, valence, arousal, category
+		FROM emotions
+		WHERE active AND (key = $1 OR emoji = $1)
+	`, keyOrEmoji).Scan(&e.Key, &e.Emoji, &e.Label, &e.Valence, &e.Arousal, &e.Category)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &e, nil
+}
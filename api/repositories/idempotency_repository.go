@@ -0,0 +1,107 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"ember/api/models"
+)
+
+// interface
+type IdempotencyRepository interface {
+	ClaimIdempotencyKey(scope string, key string, fingerprint string, lockTTL time.Duration) (*models.IdempotentRequest, error)
+	SaveIdempotentResponse(scope string, key string, req models.IdempotentRequest, ttl time.Duration) error
+	ReleaseIdempotencyKey(scope string, key string) error
+	PruneIdempotencyKeys() (int64, error)
+}
+
+// implementation
+type idempotencyRepository struct {
+	db *sql.DB
+}
+
+func NewIdempotencyRepository(db *sql.DB) IdempotencyRepository {
+	return &idempotencyRepository{
+		db: db,
+	}
+}
+
+// ClaimIdempotencyKey records a request with key for lockTTL, or until its
+// response is saved, and returns nil. If the key is already in use the first
+// request made with it is returned instead. Expired keys are reused.
+func (ir *idempotencyRepository) ClaimIdempotencyKey(scope string, key string, fingerprint string, lockTTL time.Duration) (*models.IdempotentRequest, error) {
+	const q = `
+        INSERT INTO idempotency_keys (scope, idempotency_key, fingerprint, expires_at)
+        VALUES ($1, $2, $3, NOW() + make_interval(secs => $4))
+        ON CONFLICT (scope, idempotency_key) DO UPDATE
+        SET fingerprint = EXCLUDED.fingerprint,
+            status_code = NULL,
+            content_type = NULL,
+            body = NULL,
+            created_at = NOW(),
+            expires_at = EXCLUDED.expires_at
+        WHERE idempotency_keys.expires_at <= NOW()
+        RETURNING true
+    `
+
+	var claimed bool
+	err := ir.db.QueryRow(q, scope, key, fingerprint, lockTTL.Seconds()).Scan(&claimed)
+	if err == nil {
+		return nil, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	var (
+		first       models.IdempotentRequest
+		statusCode  sql.NullInt64
+		contentType sql.NullString
+	)
+	err = ir.db.QueryRow(
+		`SELECT fingerprint, status_code, content_type, body FROM idempotency_keys WHERE scope = $1 AND idempotency_key = $2`,
+		scope, key,
+	).Scan(&first.Fingerprint, &statusCode, &contentType, &first.Body)
+	if err != nil {
+		return nil, err
+	}
+	first.StatusCode = int(statusCode.Int64)
+	first.ContentType = contentType.String
+	return &first, nil
+}
+
+// SaveIdempotentResponse stores the response to a claimed key and keeps it
+// for ttl.
+func (ir *idempotencyRepository) SaveIdempotentResponse(scope string, key string, req models.IdempotentRequest, ttl time.Duration) error {
+	const q = `
+        UPDATE idempotency_keys
+        SET status_code = $4,
+            content_type = NULLIF($5, ''),
+            body = $6,
+            expires_at = NOW() + make_interval(secs => $7)
+        WHERE scope = $1 AND idempotency_key = $2 AND fingerprint = $3
+    `
+
+	_, err := ir.db.Exec(q, scope, key, req.Fingerprint, req.StatusCode, req.ContentType, req.Body, ttl.Seconds())
+	return err
+}
+
+// ReleaseIdempotencyKey forgets a claimed key that has no response yet, so
+// the request can be retried.
+func (ir *idempotencyRepository) ReleaseIdempotencyKey(scope string, key string) error {
+	_, err := ir.db.Exec(
+		`DELETE FROM idempotency_keys WHERE scope = $1 AND idempotency_key = $2 AND status_code IS NULL`,
+		scope, key,
+	)
+	return err
+}
+
+// PruneIdempotencyKeys deletes expired keys.
+func (ir *idempotencyRepository) PruneIdempotencyKeys() (int64, error) {
+	res, err := ir.db.Exec(`DELETE FROM idempotency_keys WHERE expires_at <= NOW()`)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
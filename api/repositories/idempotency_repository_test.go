@@ -0,0 +1,43 @@
+package repositories
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestPruneIdempotencyKeys(t *testing.T) {
+	db := openTestDB(t)
+	idempotencyRepo := NewIdempotencyRepository(db)
+	scope := uuid.NewString()
+	t.Cleanup(func() { db.Exec(`DELETE FROM idempotency_keys WHERE scope = $1`, scope) })
+
+	if _, err := idempotencyRepo.ClaimIdempotencyKey(scope, "stale", "a", -time.Second); err != nil {
+		t.Fatalf("claim key: %v", err)
+	}
+	if _, err := idempotencyRepo.ClaimIdempotencyKey(scope, "live", "a", time.Minute); err != nil {
+		t.Fatalf("claim key: %v", err)
+	}
+
+	if _, err := idempotencyRepo.PruneIdempotencyKeys(); err != nil {
+		t.Fatalf("prune keys: %v", err)
+	}
+
+	var keys []string
+	rows, err := db.Query(`SELECT idempotency_key FROM idempotency_keys WHERE scope = $1`, scope)
+	if err != nil {
+		t.Fatalf("list keys: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			t.Fatalf("scan key: %v", err)
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) != 1 || keys[0] != "live" {
+		t.Fatalf("expected only the unexpired key to be kept, got %v", keys)
+	}
+}
@@ -0,0 +1,258 @@
+package repositories
+
+import (
+	"database/sql"
+	"time"
+
+	"ember/api/models"
+)
+
+// interface
+type InsightRepository interface {
+	QueryMoodGrid(bbox models.BBox, since time.Time, cellDeg float64, minUsers int) ([]models.MoodCell, error)
+	QueryMoodTrends(lon float64, lat float64, radiusKm float64, bucket string, since time.Time, minAuthors int) (*models.MoodTrend, error)
+	RefreshMoodRollups(lookback time.Duration) (int64, error)
+}
+
+// moodRollupCellDeg is the grid size of pin_mood_rollups (~1 km). Trend
+// queries match whole cells, so radii are only accurate to about this much.
+// moodRollupCellMeters is more than the distance from a cell's centre to its
+// corners.
+const (
+	moodRollupCellDeg    = 0.01
+	moodRollupCellMeters = moodRollupCellDeg * 111320
+)
+
+// implementation
+type insightRepository struct {
+	db *sql.DB
+}
+
+func NewInsightRepository(db *sql.DB) InsightRepository {
+	return &insightRepository{
+		db: db,
+	}
+}
+
+// QueryMoodGrid aggregates public pins, outside their authors' private zones,
+// created since the given time inside bbox into cells of cellDeg degrees.
+// Cells with pins from fewer than minUsers distinct users are suppressed so
+// no individual can be singled out.
+func (ir *insightRepository) QueryMoodGrid(bbox models.BBox, since time.Time, cellDeg float64, minUsers int) ([]models.MoodCell, error) {
+	const q = `
+		WITH public_pins AS (
+			SELECT
+				p.user_id,
+				p.emotion,
+				e.valence,
+				floor(ST_X(p.location::geometry) / $10)::bigint AS cx,
+				floor(ST_Y(p.location::geometry) / $10)::bigint AS cy
+			FROM pins p
+			JOIN emotions e ON e.key = p.emotion
+			WHERE p.visibility = 'public'
+			AND NOT ` + inAuthorsZone + `
+			AND p.created_at >= $1
+			AND ` + notExpired + `
+			AND ` + inBBoxExact + `
+		),
+		cells AS (
+			SELECT cx, cy, COUNT(*) AS pin_count, AVG(valence) AS mean_valence
+			FROM public_pins
+			GROUP BY cx, cy
+			HAVING COUNT(DISTINCT user_id) >= $11
+		)
+		SELECT c.cx, c.cy, c.pin_count, c.mean_valence, pp.emotion, COUNT(*)
+		FROM cells c
+		JOIN public_pins pp USING (cx, cy)
+		GROUP BY c.cx, c.cy, c.pin_count, c.mean_valence, pp.emotion
+		ORDER BY c.cy, c.cx
+	`
+
+	args := append([]any{since}, bboxArgs(bbox)...)
+	args = append(args, cellDeg, minUsers)
+
+	rows, err := ir.db.Query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	type cellKey struct{ x, y int64 }
+	cells := map[cellKey]*models.MoodCell{}
+	var order []cellKey
+
+	for rows.Next() {
+		var key cellKey
+		var pinCount, emotionCount int
+		var meanValence float64
+		var emotion string
+		if err := rows.Scan(&key.x, &key.y, &pinCount, &meanValence, &emotion, &emotionCount); err != nil {
+			return nil, err
+		}
+
+		cell, ok := cells[key]
+		if !ok {
+			cell = &models.MoodCell{
+				Bounds: models.BBox{
+					MinLon: float64(key.x) * cellDeg,
+					MinLat: float64(key.y) * cellDeg,
+					MaxLon: float64(key.x+1) * cellDeg,
+					MaxLat: float64(key.y+1) * cellDeg,
+				},
+				PinCount:    pinCount,
+				MeanValence: meanValence,
+				Emotions:    map[string]int{},
+			}
+			cells[key] = cell
+			order = append(order, key)
+		}
+		cell.Emotions[emotion] = emotionCount
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result := make([]models.MoodCell, 0, len(order))
+	for _, key := range order {
+		cell := cells[key]
+		cell.DominantEmotion = models.DominantEmotion(cell.Emotions)
+		result = append(result, *cell)
+	}
+
+	return result, nil
+}
+
+// QueryMoodTrends sums the mood rollups whose cell centre lies within radiusKm
+// of (lon, lat) into buckets of the given date_trunc unit (hour, day or week).
+// Buckets with pins from fewer than minAuthors distinct users are suppressed.
+func (ir *insightRepository) QueryMoodTrends(lon float64, lat float64, radiusKm float64, bucket string, since time.Time, minAuthors int) (*models.MoodTrend, error) {
+	const q = `
+		WITH matched AS (
+			SELECT date_trunc($4::text, r.bucket_start, 'UTC') AS bucket, r.emotion, r.pin_count, r.valence_sum, r.author_ids
+			FROM pin_mood_rollups r
+			WHERE ST_DWithin(r.location, ST_MakePoint($1, $2)::geography, $3 * 1000)
+			AND r.bucket_start >= date_trunc($4::text, $5::timestamptz, 'UTC')
+		),
+		anonymous_buckets AS (
+			SELECT m.bucket
+			FROM matched m, unnest(m.author_ids) AS author_id
+			GROUP BY m.bucket
+			HAVING COUNT(DISTINCT author_id) >= $6
+		)
+		SELECT m.bucket, m.emotion, SUM(m.pin_count), SUM(m.valence_sum)
+		FROM matched m
+		JOIN anonymous_buckets USING (bucket)
+		GROUP BY m.bucket, m.emotion
+		ORDER BY m.bucket
+	`
+
+	trend := &models.MoodTrend{Buckets: []models.MoodTrendBucket{}}
+	if err := ir.db.QueryRow(`SELECT refreshed_at FROM pin_mood_rollup_state`).Scan(&trend.AsOf); err != nil {
+		return nil, err
+	}
+
+	rows, err := ir.db.Query(q, lon, lat, radiusKm, bucket, since, minAuthors)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var current *models.MoodTrendBucket
+	var valenceSum float64
+	flush := func() {
+		if current == nil {
+			return
+		}
+		current.MeanValence = valenceSum / float64(current.PinCount)
+		current.DominantEmotion = models.DominantEmotion(current.Emotions)
+		trend.Buckets = append(trend.Buckets, *current)
+	}
+
+	for rows.Next() {
+		var start time.Time
+		var emotion string
+		var count int
+		var emotionValence float64
+		if err := rows.Scan(&start, &emotion, &count, &emotionValence); err != nil {
+			return nil, err
+		}
+
+		if current == nil || !current.Start.Equal(start) {
+			flush()
+			current = &models.MoodTrendBucket{Start: start, Emotions: map[string]int{}}
+			valenceSum = 0
+		}
+		current.PinCount += count
+		current.Emotions[emotion] = count
+		valenceSum += emotionValence
+	}
+	flush()
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return trend, nil
+}
+
+// RefreshMoodRollups rebuilds every rollup hour from the last refresh (less
+// lookback, to catch late writes and deletions) up to now, and returns the
+// number of rollup rows written. The state row is locked so concurrent
+// refreshes serialize.
+func (ir *insightRepository) RefreshMoodRollups(lookback time.Duration) (int64, error) {
+	tx, err := ir.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var from time.Time
+	err = tx.QueryRow(`
+		SELECT date_trunc('hour', refreshed_at - make_interval(secs => $1), 'UTC')
+		FROM pin_mood_rollup_state
+		FOR UPDATE
+	`, lookback.Seconds()).Scan(&from)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM pin_mood_rollups WHERE bucket_start >= $1`, from); err != nil {
+		return 0, err
+	}
+
+	res, err := tx.Exec(`
+		INSERT INTO pin_mood_rollups (bucket_start, cell_x, cell_y, emotion, pin_count, valence_sum, author_ids, location)
+		SELECT
+			bucket_start, cell_x, cell_y, emotion, COUNT(*), SUM(valence), array_agg(DISTINCT user_id),
+			ST_MakePoint((cell_x + 0.5) * $2, (cell_y + 0.5) * $2)::geography
+		FROM (
+			SELECT
+				date_trunc('hour', p.created_at, 'UTC') AS bucket_start,
+				floor(ST_X(p.location::geometry) / $2)::int AS cell_x,
+				floor(ST_Y(p.location::geometry) / $2)::int AS cell_y,
+				p.user_id,
+				p.emotion,
+				e.valence
+			FROM pins p
+			JOIN emotions e ON e.key = p.emotion
+			WHERE p.visibility = 'public'
+			AND NOT `+inAuthorsZone+`
+			AND p.created_at >= $1
+		) public_pins
+		GROUP BY bucket_start, cell_x, cell_y, emotion
+	`, from, moodRollupCellDeg)
+	if err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec(`UPDATE pin_mood_rollup_state SET refreshed_at = NOW()`); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	return res.RowsAffected()
+}
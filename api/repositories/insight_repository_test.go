@@ -0,0 +1,43 @@
+package repositories
+
+import (
+	"testing"
+	"time"
+
+	"ember/api/models"
+)
+
+func TestQueryMoodGrid(t *testing.T) {
+	db := openTestDB(t)
+	pinRepo := NewPinRepository(db)
+	first, second := createTestUser(t, db), createTestUser(t, db)
+
+	// Far out at sea, away from anyone else's pins
+	lon, lat := -130.005, -20.005
+	if _, err := pinRepo.CreatePin(first, "happy", "", lon, lat, "public", "", nil); err != nil {
+		t.Fatalf("create pin: %v", err)
+	}
+	if _, err := pinRepo.CreatePin(second, "happy", "", lon, lat, "public", "", nil); err != nil {
+		t.Fatalf("create pin: %v", err)
+	}
+
+	bbox := models.BBox{MinLon: lon - 0.1, MinLat: lat - 0.1, MaxLon: lon + 0.1, MaxLat: lat + 0.1}
+	since := time.Now().Add(-time.Hour)
+	insightRepo := NewInsightRepository(db)
+
+	cells, err := insightRepo.QueryMoodGrid(bbox, since, 0.01, 2)
+	if err != nil {
+		t.Fatalf("query mood grid: %v", err)
+	}
+	if len(cells) != 1 || cells[0].PinCount != 2 || cells[0].DominantEmotion != "happy" {
+		t.Fatalf("expected one cell of both pins, got %+v", cells)
+	}
+
+	cells, err = insightRepo.QueryMoodGrid(bbox, since, 0.01, 3)
+	if err != nil {
+		t.Fatalf("query mood grid: %v", err)
+	}
+	if len(cells) != 0 {
+		t.Fatalf("expected a cell with too few users to be suppressed, got %+v", cells)
+	}
+}
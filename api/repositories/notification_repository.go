@@ -0,0 +1,199 @@
+package repositories
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"ember/api/models"
+
+	"github.com/google/uuid"
+)
+
+// NewNotification is a notification to be delivered to RecipientID. ActorID
+// and PinID are optional; Data holds type-specific details.
+type NewNotification struct {
+	RecipientID uuid.UUID
+	ActorID     uuid.UUID
+	Type        string
+	PinID       *uuid.UUID
+	Data        map[string]any
+}
+
+// interface
+type NotificationRepository interface {
+	CreateNotification(n NewNotification) (*models.Notification, error)
+	ListNotifications(userID uuid.UUID, page Page) ([]models.Notification, error)
+	CountUnreadNotifications(userID uuid.UUID) (int, error)
+	MarkNotificationsRead(userID uuid.UUID, ids []uuid.UUID) (int64, error)
+	MarkAllNotificationsRead(userID uuid.UUID) (int64, error)
+}
+
+// implementation
+type notificationRepository struct {
+	db *sql.DB
+}
+
+func NewNotificationRepository(db *sql.DB) NotificationRepository {
+	return &notificationRepository{
+		db: db,
+	}
+}
+
+// notificationColumns selects a notification (n), its actor (a, may be NULL)
+// and pin (p, may be NULL) in the order scanNotification expects.
+const notificationColumns = `
+			n.uuid,
+			n.type,
+			a.uuid,
+			a.username,
+			a.display_name,
+			p.uuid,
+			n.data,
+			n.created_at,
+			n.read_at`
+
+// scanNotification scans the notificationColumns of row, followed by any
+// extra columns the query selects after them.
+func scanNotification(row rowScanner, extra ...any) (models.Notification, error) {
+	var n models.Notification
+	var actorID uuid.NullUUID
+	var actorUsername, actorDisplayName sql.NullString
+	var data []byte
+
+	dest := []any{
+		&n.ID,
+		&n.Type,
+		&actorID,
+		&actorUsername,
+		&actorDisplayName,
+		&n.PinID,
+		&data,
+		&n.CreatedAt,
+		&n.ReadAt,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return n, err
+	}
+
+	n.Data = json.RawMessage(data)
+	if actorID.Valid {
+		n.Actor = &models.User{
+			ID:          actorID.UUID,
+			Username:    actorUsername.String,
+			DisplayName: actorDisplayName,
+		}
+	}
+	return n, nil
+}
+
+func (nr *notificationRepository) CreateNotification(n NewNotification) (*models.Notification, error) {
+	data := n.Data
+	if data == nil {
+		data = map[string]any{}
+	}
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	var actorID, pinID any
+	if n.ActorID != uuid.Nil {
+		actorID = n.ActorID.String()
+	}
+	if n.PinID != nil {
+		pinID = n.PinID.String()
+	}
+
+	const q = `
+		WITH inserted AS (
+			INSERT INTO notifications (user_id, actor_id, type, pin_id, data)
+			SELECT
+				(SELECT id FROM users WHERE uuid = $1),
+				(SELECT id FROM users WHERE uuid = $2::uuid),
+				$3,
+				(SELECT id FROM pins WHERE uuid = $4::uuid),
+				$5
+			RETURNING *
+		)
+		SELECT` + notificationColumns + `
+		FROM inserted n
+		LEFT JOIN users a ON a.id = n.actor_id
+		LEFT JOIN pins p ON p.id = n.pin_id
+	`
+
+	created, err := scanNotification(nr.db.QueryRow(q, n.RecipientID.String(), actorID, n.Type, pinID, payload))
+	if err != nil {
+		return nil, err
+	}
+	return &created, nil
+}
+
+// ListNotifications returns a user's notifications, newest first.
+func (nr *notificationRepository) ListNotifications(userID uuid.UUID, page Page) ([]models.Notification, error) {
+	const q = `
+		SELECT` + notificationColumns + `
+		FROM notifications n
+		LEFT JOIN users a ON a.id = n.actor_id
+		LEFT JOIN pins p ON p.id = n.pin_id
+		WHERE n.user_id = (SELECT id FROM users WHERE uuid = $1)
+		  AND ($2::timestamptz IS NULL OR (n.created_at, n.uuid) < ($2, $3::uuid))
+		ORDER BY n.created_at DESC, n.uuid DESC
+		LIMIT $4
+	`
+
+	afterTime, afterID := page.createdAtArgs()
+	rows, err := nr.db.Query(q, userID.String(), afterTime, afterID, page.fetchLimit())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notifications []models.Notification
+	for rows.Next() {
+		n, err := scanNotification(rows)
+		if err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+
+	return notifications, rows.Err()
+}
+
+func (nr *notificationRepository) CountUnreadNotifications(userID uuid.UUID) (int, error) {
+	var count int
+	err := nr.db.QueryRow(
+		`SELECT COUNT(*) FROM notifications
+		 WHERE user_id = (SELECT id FROM users WHERE uuid = $1) AND read_at IS NULL`,
+		userID.String(),
+	).Scan(&count)
+	return count, err
+}
+
+// MarkNotificationsRead marks the given notifications of userID read; IDs
+// belonging to other users are ignored. It returns how many changed.
+func (nr *notificationRepository) MarkNotificationsRead(userID uuid.UUID, ids []uuid.UUID) (int64, error) {
+	result, err := nr.db.Exec(
+		`UPDATE notifications SET read_at = NOW()
+		 WHERE user_id = (SELECT id FROM users WHERE uuid = $1)
+		   AND uuid = ANY($2::uuid[])
+		   AND read_at IS NULL`,
+		userID.String(), uuidStrings(ids),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func (nr *notificationRepository) MarkAllNotificationsRead(userID uuid.UUID) (int64, error) {
+	result, err := nr.db.Exec(
+		`UPDATE notifications SET read_at = NOW()
+		 WHERE user_id = (SELECT id FROM users WHERE uuid = $1) AND read_at IS NULL`,
+		userID.String(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,41 @@
+package repositories
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Cursor marks the last row of a page in a keyset-paginated list: the value
+// of the list's sort key plus the row's UUID as a tiebreaker. Each list fills
+// in only the sort key it orders by.
+type Cursor struct {
+	CreatedAt time.Time `json:"t,omitzero"`
+	Username  string    `json:"u,omitempty"`
+	Score     float64   `json:"s,omitempty"`
+	// AsOf pins the clock for time-dependent orderings (nearby relevance) so
+	// scores don't drift between pages.
+	AsOf time.Time `json:"a,omitzero"`
+	ID   uuid.UUID `json:"id"`
+}
+
+// Page selects up to Limit rows after the row After points to, or the first
+// page when After is nil. Queries fetch Limit+1 rows; the extra row only
+// signals that another page follows and is never returned to clients.
+type Page struct {
+	Limit int
+	After *Cursor
+}
+
+// fetchLimit is the LIMIT a paginated query should use.
+func (p Page) fetchLimit() int {
+	return p.Limit + 1
+}
+
+// createdAtArgs returns the created_at and UUID of the cursor, or NULLs on the first page.
+func (p Page) createdAtArgs() (any, any) {
+	if p.After == nil {
+		return nil, nil
+	}
+	return p.After.CreatedAt, p.After.ID.String()
+}
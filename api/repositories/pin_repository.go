@@ -2,18 +2,102 @@ package repositories
 
 import (
 	"database/sql"
+	"errors"
+	"math/rand/v2"
+	"time"
 
 	"ember/api/models"
 
 	"github.com/google/uuid"
 )
 
+var (
+	ErrPinNotFound = errors.New("pin does not exist")
+	ErrNotPinOwner = errors.New("pin belongs to another user")
+)
+
+// PinUpdate holds the fields of a PATCH /pins/{pinID}; nil fields are left unchanged.
+// An empty Precision goes back to the author's defaults.
+type PinUpdate struct {
+	Emotion    *string
+	Message    *string
+	Visibility *string
+	Precision  *string
+	ExpiresAt  *time.Time
+	// Removes the expiry; ExpiresAt is ignored
+	ClearExpiry bool
+}
+
+// PinSnapshot is a pin as stored, at its exact location and every
+// approximate one, kept so that where it was served to someone can still be
+// worked out once it has changed or gone. It is never served as it is.
+type PinSnapshot struct {
+	ID         uuid.UUID
+	UserID     uuid.UUID
+	Visibility string
+	Precision  sql.NullString
+	Location   models.Location
+	// Approximate holds the pin's point at each of models.ApproximatePrecisions.
+	Approximate map[string]models.Location
+}
+
+// Orderings for QueryNearbyPins.
+const (
+	PinSortRecent    = "recent"
+	PinSortDistance  = "distance"
+	PinSortRelevance = "relevance"
+)
+
+// NearbyPinQuery holds the filters of GET /pins/nearby. A RadiusKm of zero
+// or less means unbounded; zero-valued Since and Emotion are ignored.
+type NearbyPinQuery struct {
+	Longitude float64
+	Latitude  float64
+	RadiusKm  float64
+	Sort      string
+	Since     *time.Time
+	Emotion   string
+	// AsOf is the clock relevance is scored against; callers keep it fixed
+	// across the pages of one listing.
+	AsOf time.Time
+	Page Page
+}
+
+// PinSearchQuery holds the filters of GET /pins/search. A nil BBox, Since or
+// Until leaves that bound open.
+type PinSearchQuery struct {
+	Text  string
+	BBox  *models.BBox
+	Since *time.Time
+	Until *time.Time
+	// AsOf is the clock recency is scored against, fixed across pages.
+	AsOf time.Time
+	Page Page
+}
+
 // interface
 type PinRepository interface {
-	CreatePin(userID uuid.UUID, emotion string, message string, lon float64, lat float64, visibility string) error
-	QueryNearbyPins(userID uuid.UUID, lon float64, lat float64, radiusKm float64) ([]models.Pin, error)
-	QueryFriendPins(userID uuid.UUID) ([]models.Pin, error)
-	QueryUserPins(userID uuid.UUID) ([]models.Pin, error)
+	CreatePin(userID uuid.UUID, emotion string, message string, lon float64, lat float64, visibility string, precision string, expiresAt *time.Time) (*models.Pin, error)
+	CreateClientPin(userID uuid.UUID, pin ClientPin) (*models.Pin, bool, error)
+	GetPin(userID uuid.UUID, pinID uuid.UUID) (*models.Pin, error)
+	GetPinSnapshot(pinID uuid.UUID) (*PinSnapshot, error)
+	ServePinSnapshot(userID uuid.UUID, pin PinSnapshot) (*models.Location, error)
+	UpdatePin(userID uuid.UUID, pinID uuid.UUID, update PinUpdate) (*models.Pin, error)
+	DeletePin(userID uuid.UUID, pinID uuid.UUID) error
+	QueryNearbyPins(userID uuid.UUID, query NearbyPinQuery) ([]models.NearbyPin, error)
+	SearchPins(userID uuid.UUID, query PinSearchQuery) ([]models.SearchedPin, error)
+	QueryPinsInBBox(userID uuid.UUID, bbox models.BBox, limit int) ([]models.Pin, error)
+	QueryPinClusters(userID uuid.UUID, bbox models.BBox, cellDeg float64, minClusterSize int) ([]models.PinCluster, []models.Pin, error)
+	QueryPinTile(userID uuid.UUID, z int, x int, y int, cellMeters float64) ([]byte, error)
+	QueryFriendPins(userID uuid.UUID, page Page) ([]models.Pin, error)
+	QueryUserPins(userID uuid.UUID, page Page) ([]models.Pin, error)
+	QueryExpiredPins(from time.Time, to time.Time) ([]PinSnapshot, error)
+	DeleteExpiredPins(retention time.Duration) (int64, error)
+	AddPinReaction(userID uuid.UUID, pinID uuid.UUID, emoji string) (bool, error)
+	RemovePinReaction(userID uuid.UUID, pinID uuid.UUID, emoji string) error
+	QueryPinEngagement(userID uuid.UUID, pinIDs []uuid.UUID) (map[uuid.UUID]models.PinEngagement, error)
+	QueryPinAttachments(pinIDs []uuid.UUID) (map[uuid.UUID][]models.Attachment, error)
+	SavePinMessageRefs(pinID uuid.UUID, refs models.MessageRefs) ([]uuid.UUID, error)
 }
 
 // implementation
@@ -27,195 +111,1189 @@ func NewPinRepository(db *sql.DB) PinRepository {
 	}
 }
 
-func (p *pinRepository) CreatePin(userID uuid.UUID, emotion string, message string, lon float64, lat float64, visibility string) error {
+// pinColumns selects a pin (p) and its author (u), located where it is
+// served to the reader (sv), in the order scanPin expects.
+const pinColumns = `
+			p.uuid,
+			u.uuid,
+			p.emotion,
+			p.message,
+			ST_X(sv.location::geometry) AS longitude,
+			ST_Y(sv.location::geometry) AS latitude,
+			p.visibility,
+			p.created_at,
+			p.expires_at,
+			sv.precision,
+			CASE WHEN p.user_id = r.id THEN p.location_precision END`
+
+// requesterIsFriend holds when the reader (r) and the author of the pin (p)
+// are friends.
+const requesterIsFriend = `EXISTS (
+					SELECT 1
+					FROM friendships f
+					WHERE f.status = 'accepted'
+						AND (
+							(f.user_id = r.id AND f.friend_id = p.user_id)
+							OR (f.friend_id = r.id AND f.user_id = p.user_id)
+						)
+				)`
+
+// inAuthorsZone matches pins (p) inside one of their author's private zones.
+const inAuthorsZone = `EXISTS (
+				SELECT 1
+				FROM private_zones z
+				WHERE z.user_id = p.user_id
+					AND ST_Covers(z.area, p.location)
+			)`
+
+// visibleToRequester keeps the pins (p, authored by u) that the requesting
+// user ($1, whose users row is r) is allowed to see. Pins inside the
+// author's private zones are never shown to strangers, whatever their
+// visibility says.
+const visibleToRequester = `(
+			(
+				p.visibility = 'public'
+				AND NOT ` + inAuthorsZone + `
+			)
+			OR u.uuid = $1
+			OR (
+				p.visibility IN ('public', 'friends')
+				AND ` + requesterIsFriend + `
+			)
+		)`
+
+// servedToRequester joins where a pin (p, by u) is shown to its reader (r)
+// as sv.location, with sv.precision saying how precise that is. Authors see
+// their pins exactly; everyone else gets the pin's own precision if it has
+// one, or else the author's default for friends or for everyone else. Every
+// read goes through it, so the exact location only ever reaches the author.
+const servedToRequester = `
+		CROSS JOIN LATERAL (
+			SELECT
+				CASE sp.precision
+					WHEN '100m' THEN p.location_100m
+					WHEN '1km' THEN p.location_1km
+					WHEN 'city' THEN p.location_city
+					ELSE p.location
+				END AS location,
+				sp.precision
+			FROM (
+				SELECT CASE
+					WHEN p.user_id = r.id THEN 'exact'
+					WHEN p.location_precision IS NOT NULL THEN p.location_precision
+					WHEN ` + requesterIsFriend + ` THEN u.friends_location_precision
+					ELSE u.public_location_precision
+				END AS precision
+			) sp
+		) sv`
+
+// notExpired hides pins (p) whose expires_at has passed; every read applies it.
+const notExpired = `(p.expires_at IS NULL OR p.expires_at > NOW())`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanPin scans the pinColumns of row, followed by any extra columns the
+// query selects after them.
+func scanPin(row rowScanner, extra ...any) (models.Pin, error) {
+	var pin models.Pin
+	dest := []any{
+		&pin.ID,
+		&pin.UserID,
+		&pin.Emotion,
+		&pin.Message,
+		&pin.Location.Longitude,
+		&pin.Location.Latitude,
+		&pin.Visibility,
+		&pin.CreatedAt,
+		&pin.ExpiresAt,
+		&pin.Precision,
+		&pin.PrecisionSetting,
+	}
+	err := row.Scan(append(dest, extra...)...)
+	return pin, err
+}
+
+func scanPins(rows *sql.Rows) ([]models.Pin, error) {
+	defer rows.Close()
+
+	var pins []models.Pin
+	for rows.Next() {
+		pin, err := scanPin(rows)
+		if err != nil {
+			return nil, err
+		}
+		pins = append(pins, pin)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return pins, nil
+}
+
+// approximateLocationArgs picks the approximate points of a pin at lon, lat:
+// a longitude and latitude per models.ApproximatePrecisions.
+func approximateLocationArgs(lon float64, lat float64) []any {
+	var args []any
+	exact := models.Location{Longitude: lon, Latitude: lat}
+	for _, approx := range models.ApproximatePrecisions {
+		loc := models.ApproximateLocation(exact, approx, rand.Float64(), rand.Float64())
+		args = append(args, loc.Longitude, loc.Latitude)
+	}
+	return args
+}
+
+// CreatePin saves a pin with its exact location and one approximate point
+// per precision. An empty precision follows the author's defaults.
+func (p *pinRepository) CreatePin(userID uuid.UUID, emotion string, message string, lon float64, lat float64, visibility string, precision string, expiresAt *time.Time) (*models.Pin, error) {
 	const q = `
-        INSERT INTO pins (user_id, emotion, message, location, visibility)
+        INSERT INTO pins (user_id, emotion, message, location, visibility, location_precision, expires_at,
+                          location_100m, location_1km, location_city)
         VALUES (
             (SELECT id FROM users WHERE uuid = $1),
             $2,
-            $3,
+            NULLIF($3, ''),
             ST_SetSRID(ST_MakePoint($4, $5), 4326)::geography,
-            $6
+            $6,
+            NULLIF($7, ''),
+            $8,
+            ST_SetSRID(ST_MakePoint($9, $10), 4326)::geography,
+            ST_SetSRID(ST_MakePoint($11, $12), 4326)::geography,
+            ST_SetSRID(ST_MakePoint($13, $14), 4326)::geography
         )
+        RETURNING uuid
     `
 
-	_, err := p.db.Exec(q, userID.String(), emotion, message, lon, lat, visibility)
-	return err
+	args := []any{userID.String(), emotion, message, lon, lat, visibility, precision, expiresAt}
+	args = append(args, approximateLocationArgs(lon, lat)...)
+
+	var pinID uuid.UUID
+	if err := p.db.QueryRow(q, args...).Scan(&pinID); err != nil {
+		return nil, err
+	}
+
+	return p.GetPin(userID, pinID)
 }
 
-func (p *pinRepository) QueryNearbyPins(userID uuid.UUID, lon float64, lat float64, radiusKm float64) ([]models.Pin, error) {
+// ClientPin is a pin created on a device, possibly offline, and uploaded
+// later. ClientID is the device's ID for it, so uploading it again finds the
+// pin already created instead of making another.
+type ClientPin struct {
+	ClientID   uuid.UUID
+	CreatedAt  time.Time
+	Emotion    string
+	Message    string
+	Longitude  float64
+	Latitude   float64
+	Visibility string
+	Precision  string
+	ExpiresAt  *time.Time
+}
+
+// CreateClientPin saves a pin uploaded by a device with its original
+// created_at, reporting whether it was created. A pin the user already
+// uploaded with the same client ID is returned as it is instead; it is nil
+// if it has since expired.
+func (p *pinRepository) CreateClientPin(userID uuid.UUID, pin ClientPin) (*models.Pin, bool, error) {
+	const q = `
+        INSERT INTO pins (user_id, client_id, created_at, emotion, message, location, visibility, location_precision, expires_at,
+                          location_100m, location_1km, location_city)
+        VALUES (
+            (SELECT id FROM users WHERE uuid = $1),
+            $2,
+            $3,
+            $4,
+            NULLIF($5, ''),
+            ST_SetSRID(ST_MakePoint($6, $7), 4326)::geography,
+            $8,
+            NULLIF($9, ''),
+            $10,
+            ST_SetSRID(ST_MakePoint($11, $12), 4326)::geography,
+            ST_SetSRID(ST_MakePoint($13, $14), 4326)::geography,
+            ST_SetSRID(ST_MakePoint($15, $16), 4326)::geography
+        )
+        ON CONFLICT (user_id, client_id) DO NOTHING
+        RETURNING uuid
+    `
+
+	args := []any{
+		userID.String(), pin.ClientID.String(), pin.CreatedAt, pin.Emotion, pin.Message,
+		pin.Longitude, pin.Latitude, pin.Visibility, pin.Precision, pin.ExpiresAt,
+	}
+	args = append(args, approximateLocationArgs(pin.Longitude, pin.Latitude)...)
+
+	var pinID uuid.UUID
+	created := true
+	err := p.db.QueryRow(q, args...).Scan(&pinID)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Uploaded before
+		created = false
+		err = p.db.QueryRow(
+			`SELECT p.uuid FROM pins p JOIN users u ON u.id = p.user_id WHERE u.uuid = $1 AND p.client_id = $2`,
+			userID.String(), pin.ClientID.String(),
+		).Scan(&pinID)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	saved, err := p.GetPin(userID, pinID)
+	return saved, created, err
+}
+
+// GetPin returns the pin if it exists and is visible to userID, or nil otherwise.
+func (p *pinRepository) GetPin(userID uuid.UUID, pinID uuid.UUID) (*models.Pin, error) {
 	const q = `
 		WITH requester AS (
 			SELECT id FROM users WHERE uuid = $1
 		)
-		SELECT
+		SELECT` + pinColumns + `
+		FROM pins p
+		JOIN users u ON u.id = p.user_id
+		JOIN requester r ON TRUE` + servedToRequester + `
+		WHERE p.uuid = $2
+		AND ` + notExpired + `
+		AND ` + visibleToRequester
+
+	pin, err := scanPin(p.db.QueryRow(q, userID.String(), pinID.String()))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &pin, nil
+}
+
+// pinSnapshotColumns selects a pin (p) by its author (u) in the order
+// scanPinSnapshot expects.
+const pinSnapshotColumns = `
+			p.uuid,
 			u.uuid,
-			p.emotion,
-			p.message,
-			ST_X(p.location::geometry) AS longitude,
-			ST_Y(p.location::geometry) AS latitude,
 			p.visibility,
-			p.created_at,
-			p.expires_at
+			p.location_precision,
+			ST_X(p.location::geometry),
+			ST_Y(p.location::geometry),
+			ST_X(p.location_100m::geometry),
+			ST_Y(p.location_100m::geometry),
+			ST_X(p.location_1km::geometry),
+			ST_Y(p.location_1km::geometry),
+			ST_X(p.location_city::geometry),
+			ST_Y(p.location_city::geometry)`
+
+func scanPinSnapshot(row rowScanner) (PinSnapshot, error) {
+	pin := PinSnapshot{Approximate: map[string]models.Location{}}
+	approx := make([]models.Location, len(models.ApproximatePrecisions))
+	dest := []any{
+		&pin.ID,
+		&pin.UserID,
+		&pin.Visibility,
+		&pin.Precision,
+		&pin.Location.Longitude,
+		&pin.Location.Latitude,
+	}
+	for i := range approx {
+		dest = append(dest, &approx[i].Longitude, &approx[i].Latitude)
+	}
+	if err := row.Scan(dest...); err != nil {
+		return pin, err
+	}
+
+	for i, precision := range models.ApproximatePrecisions {
+		pin.Approximate[precision] = approx[i]
+	}
+	return pin, nil
+}
+
+// GetPinSnapshot returns the pin as stored, expired or not, or nil if it
+// doesn't exist. Callers take a snapshot before changing or deleting a pin.
+func (p *pinRepository) GetPinSnapshot(pinID uuid.UUID) (*PinSnapshot, error) {
+	const q = `
+		SELECT` + pinSnapshotColumns + `
+		FROM pins p
+		JOIN users u ON u.id = p.user_id
+		WHERE p.uuid = $1
+	`
+
+	pin, err := scanPinSnapshot(p.db.QueryRow(q, pinID.String()))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &pin, nil
+}
+
+// ServePinSnapshot returns where the snapshotted pin is served to userID, or
+// nil if they can't see it, under the rules of visibleToRequester and
+// servedToRequester as they stand now. Friendships and private zones are
+// those of today, not of when the snapshot was taken.
+func (p *pinRepository) ServePinSnapshot(userID uuid.UUID, pin PinSnapshot) (*models.Location, error) {
+	const q = `
+		WITH p AS (
+			SELECT
+				a.id AS user_id,
+				$3::text AS visibility,
+				$4::text AS location_precision,
+				ST_SetSRID(ST_MakePoint($5, $6), 4326)::geography AS location,
+				ST_SetSRID(ST_MakePoint($7, $8), 4326)::geography AS location_100m,
+				ST_SetSRID(ST_MakePoint($9, $10), 4326)::geography AS location_1km,
+				ST_SetSRID(ST_MakePoint($11, $12), 4326)::geography AS location_city
+			FROM users a
+			WHERE a.uuid = $2
+		)
+		SELECT ST_X(sv.location::geometry), ST_Y(sv.location::geometry)
+		FROM p
+		JOIN users u ON u.id = p.user_id
+		JOIN users r ON r.uuid = $1` + servedToRequester + `
+		WHERE ` + visibleToRequester
+
+	args := []any{userID.String(), pin.UserID.String(), pin.Visibility, pin.Precision, pin.Location.Longitude, pin.Location.Latitude}
+	for _, precision := range models.ApproximatePrecisions {
+		loc := pin.Approximate[precision]
+		args = append(args, loc.Longitude, loc.Latitude)
+	}
+
+	var served models.Location
+	err := p.db.QueryRow(q, args...).Scan(&served.Longitude, &served.Latitude)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &served, nil
+}
+
+// checkPinOwner returns ErrPinNotFound or ErrNotPinOwner unless userID authored pinID.
+func (p *pinRepository) checkPinOwner(userID uuid.UUID, pinID uuid.UUID) error {
+	var ownerID uuid.UUID
+	err := p.db.QueryRow(
+		`SELECT u.uuid FROM pins p JOIN users u ON u.id = p.user_id WHERE p.uuid = $1`,
+		pinID.String(),
+	).Scan(&ownerID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrPinNotFound
+		}
+		return err
+	}
+
+	if ownerID != userID {
+		return ErrNotPinOwner
+	}
+
+	return nil
+}
+
+func (p *pinRepository) UpdatePin(userID uuid.UUID, pinID uuid.UUID, update PinUpdate) (*models.Pin, error) {
+	if err := p.checkPinOwner(userID, pinID); err != nil {
+		return nil, err
+	}
+
+	// An empty message clears it and an empty precision unsets it; nil keeps
+	// the current value.
+	const q = `
+		UPDATE pins
+		SET emotion = COALESCE($2::varchar, emotion),
+			message = CASE WHEN $3::text IS NULL THEN message ELSE NULLIF($3::text, '') END,
+			visibility = COALESCE($4::varchar, visibility),
+			expires_at = CASE WHEN $7::boolean THEN NULL ELSE COALESCE($5::timestamptz, expires_at) END,
+			location_precision = CASE WHEN $6::varchar IS NULL THEN location_precision ELSE NULLIF($6::varchar, '') END,
+			updated_at = NOW()
+		WHERE uuid = $1
+	`
+
+	if _, err := p.db.Exec(q, pinID.String(), update.Emotion, update.Message, update.Visibility, update.ExpiresAt, update.Precision, update.ClearExpiry); err != nil {
+		return nil, err
+	}
+
+	return p.GetPin(userID, pinID)
+}
+
+func (p *pinRepository) DeletePin(userID uuid.UUID, pinID uuid.UUID) error {
+	if err := p.checkPinOwner(userID, pinID); err != nil {
+		return err
+	}
+
+	_, err := p.db.Exec(`DELETE FROM pins WHERE uuid = $1`, pinID.String())
+	return err
+}
+
+// nearbyRelevance adds a recency score halving every 6 hours to a proximity
+// score halving every kilometre, so a fresh pin a few blocks away beats a
+// day-old one next door. It is scored against $11 rather than NOW() so that
+// the order is stable across pages.
+const nearbyRelevance = `(
+			power(0.5, GREATEST(EXTRACT(EPOCH FROM $11::timestamptz - p.created_at), 0) / 21600)
+			+ power(0.5, ST_Distance(sv.location, o.point) / 1000)
+		)`
+
+// nearbyPinOrders maps each NearbyPinQuery.Sort to its ORDER BY and the
+// keyset condition that resumes after the cursor (a). PostgreSQL resolves
+// output aliases in ORDER BY only when they stand alone, so an ordering by an
+// expression must select it as a column and order by that alias.
+var nearbyPinOrders = map[string]struct{ after, orderBy string }{
+	PinSortRecent: {
+		after:   `(p.created_at, p.uuid) < (a.created_at, a.id)`,
+		orderBy: `p.created_at DESC, p.uuid DESC`,
+	},
+	PinSortDistance: {
+		after:   `(ST_Distance(sv.location, o.point), p.uuid) > (a.score, a.id)`,
+		orderBy: `distance_m ASC, p.uuid ASC`,
+	},
+	PinSortRelevance: {
+		after:   `(` + nearbyRelevance + `, p.uuid) < (a.score, a.id)`,
+		orderBy: `relevance DESC, p.uuid DESC`,
+	},
+}
+
+// QueryNearbyPins measures distances, bearings and the radius to where each
+// pin is served to userID, so none of them give away a fuzzed location. The
+// exact location, padded by the most a pin can be fuzzed, only narrows the
+// search to what the location index can find.
+func (p *pinRepository) QueryNearbyPins(userID uuid.UUID, query NearbyPinQuery) ([]models.NearbyPin, error) {
+	order, ok := nearbyPinOrders[query.Sort]
+	if !ok {
+		order = nearbyPinOrders[PinSortRecent]
+	}
+
+	q := `
+		WITH requester AS (
+			SELECT id FROM users WHERE uuid = $1
+		),
+		origin AS (
+			SELECT ST_SetSRID(ST_MakePoint($2, $3), 4326)::geography AS point
+		),
+		after AS (
+			SELECT $8::timestamptz AS created_at, $9::uuid AS id, $10::float8 AS score
+		)
+		SELECT` + pinColumns + `,
+			ST_Distance(sv.location, o.point) AS distance_m,
+			COALESCE(degrees(ST_Azimuth(o.point, sv.location)), 0) AS bearing_deg,
+			` + nearbyRelevance + ` AS relevance
 		FROM pins p
 		JOIN users u ON u.id = p.user_id
 		JOIN requester r ON TRUE
-		WHERE (
-			p.visibility = 'public'
-			OR u.uuid = $1
-			OR (
-				p.visibility = 'friends'
-				AND EXISTS (
-					SELECT 1
-					FROM friendships f
-					WHERE f.status = 'accepted'
-						AND (
-							(f.user_id = r.id AND f.friend_id = p.user_id)
-							OR (f.friend_id = r.id AND f.user_id = p.user_id)
-						)
-				)
-			)
+		JOIN origin o ON TRUE
+		JOIN after a ON TRUE` + servedToRequester + `
+		WHERE ` + visibleToRequester + `
+		AND ` + notExpired + `
+		AND ($4 <= 0 OR (
+			ST_DWithin(p.location, o.point, $4 * 1000 + $12)
+			AND ST_DWithin(sv.location, o.point, $4 * 1000)
+		))
+		AND ($5::timestamptz IS NULL OR p.created_at >= $5)
+		AND ($6 = '' OR p.emotion = $6)
+		AND (a.id IS NULL OR ` + order.after + `)
+		ORDER BY ` + order.orderBy + `
+		LIMIT $7
+	`
+
+	var afterTime, afterID, afterScore any
+	if c := query.Page.After; c != nil {
+		afterTime, afterID, afterScore = c.CreatedAt, c.ID.String(), c.Score
+	}
+
+	rows, err := p.db.Query(q,
+		userID.String(), query.Longitude, query.Latitude, query.RadiusKm, query.Since, query.Emotion,
+		query.Page.fetchLimit(), afterTime, afterID, afterScore, query.AsOf, models.MaxFuzzMeters,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pins []models.NearbyPin
+	for rows.Next() {
+		var pin models.NearbyPin
+		var err error
+		pin.Pin, err = scanPin(rows, &pin.DistanceM, &pin.BearingDeg, &pin.Relevance)
+		if err != nil {
+			return nil, err
+		}
+		pins = append(pins, pin)
+	}
+
+	return pins, rows.Err()
+}
+
+// searchScore is a pin's text relevance to the query (s), normalised to
+// [0, 1), boosted up to twice over for fresh pins; the boost halves every
+// day, measured from $14 so that the order is stable across pages.
+const searchScore = `(
+			ts_rank_cd(p.message_tsv, s.query, 32)
+			* (1 + power(0.5, GREATEST(EXTRACT(EPOCH FROM $14::timestamptz - p.created_at), 0) / 86400))
+		)`
+
+// searchSnippet highlights the query's matches in an HTML-escaped copy of
+// the message, so the <mark> tags are the only markup in it.
+const searchSnippet = `ts_headline(
+			'english',
+			replace(replace(replace(p.message, '&', '&amp;'), '<', '&lt;'), '>', '&gt;'),
+			s.query,
+			'StartSel=<mark>, StopSel=</mark>, MinWords=10, MaxWords=30, MaxFragments=2, FragmentDelimiter=" … "'
+		)`
+
+// SearchPins finds the pins userID can see whose message matches query.Text,
+// a web-search style query ("quoted phrases", or, -excluded), best first.
+func (p *pinRepository) SearchPins(userID uuid.UUID, query PinSearchQuery) ([]models.SearchedPin, error) {
+	const q = `
+		WITH requester AS (
+			SELECT id FROM users WHERE uuid = $1
+		),
+		search AS (
+			SELECT websearch_to_tsquery('english', $11) AS query
+		),
+		after AS (
+			SELECT $15::float8 AS score, $16::uuid AS id
 		)
-		AND (
-			$4 <= 0
-			OR ST_DWithin(
-				p.location,
-				ST_SetSRID(ST_MakePoint($2, $3), 4326)::geography,
-				$4 * 1000
+		SELECT` + pinColumns + `,
+			` + searchScore + ` AS score,
+			` + searchSnippet + ` AS snippet
+		FROM pins p
+		JOIN users u ON u.id = p.user_id
+		JOIN requester r ON TRUE
+		JOIN search s ON TRUE
+		JOIN after a ON TRUE` + servedToRequester + `
+		WHERE p.message_tsv @@ s.query
+		AND ` + visibleToRequester + `
+		AND ` + notExpired + `
+		AND (NOT $10 OR ` + inBBox + `)
+		AND ($12::timestamptz IS NULL OR p.created_at >= $12)
+		AND ($13::timestamptz IS NULL OR p.created_at < $13)
+		AND (a.id IS NULL OR (` + searchScore + `, p.uuid) < (a.score, a.id))
+		ORDER BY score DESC, p.uuid DESC
+		LIMIT $17
+	`
+
+	var bbox models.BBox
+	if query.BBox != nil {
+		bbox = *query.BBox
+	}
+	var afterScore, afterID any
+	if c := query.Page.After; c != nil {
+		afterScore, afterID = c.Score, c.ID.String()
+	}
+
+	args := append([]any{userID.String()}, bboxArgs(bbox)...)
+	args = append(args,
+		query.BBox != nil, query.Text, query.Since, query.Until, query.AsOf,
+		afterScore, afterID, query.Page.fetchLimit(),
+	)
+
+	rows, err := p.db.Query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pins []models.SearchedPin
+	for rows.Next() {
+		var pin models.SearchedPin
+		var err error
+		pin.Pin, err = scanPin(rows, &pin.Score, &pin.Snippet)
+		if err != nil {
+			return nil, err
+		}
+		pins = append(pins, pin)
+	}
+
+	return pins, rows.Err()
+}
+
+// inBBox keeps pins served (sv) inside either of two envelopes ($2-$5 and
+// $6-$9), which is how bboxArgs passes a viewport split at the antimeridian.
+// The exact location is first matched against both halves grown by
+// fuzzMarginDeg, which is what pins_location_geom_idx can answer.
+const inBBox = `(
+			(
+				p.location::geometry && ST_Expand(ST_MakeEnvelope($2, $3, $4, $5, 4326), ` + fuzzMarginDeg + `)
+				OR p.location::geometry && ST_Expand(ST_MakeEnvelope($6, $7, $8, $9, 4326), ` + fuzzMarginDeg + `)
+			)
+			AND (
+				sv.location::geometry && ST_MakeEnvelope($2, $3, $4, $5, 4326)
+				OR sv.location::geometry && ST_MakeEnvelope($6, $7, $8, $9, 4326)
 			)
+		)`
+
+// inBBoxExact keeps pins whose exact location is inside either envelope, for
+// aggregate queries that have no requester to serve locations to.
+const inBBoxExact = `(
+			p.location::geometry && ST_MakeEnvelope($2, $3, $4, $5, 4326)
+			OR p.location::geometry && ST_MakeEnvelope($6, $7, $8, $9, 4326)
+		)`
+
+// fuzzMarginDeg is at least models.MaxFuzzMeters in degrees of latitude and,
+// short of the polar circles, of longitude.
+const fuzzMarginDeg = `0.35`
+
+// bboxArgs returns the eight envelope parameters inBBox and inBBoxExact
+// expect.
+func bboxArgs(bbox models.BBox) []any {
+	east, west := bbox.Envelopes()
+	return []any{
+		east.MinLon, east.MinLat, east.MaxLon, east.MaxLat,
+		west.MinLon, west.MinLat, west.MaxLon, west.MaxLat,
+	}
+}
+
+// QueryPinsInBBox returns the newest pins visible to userID inside bbox,
+// applying the same visibility rules as QueryNearbyPins.
+func (p *pinRepository) QueryPinsInBBox(userID uuid.UUID, bbox models.BBox, limit int) ([]models.Pin, error) {
+	const q = `
+		WITH requester AS (
+			SELECT id FROM users WHERE uuid = $1
 		)
+		SELECT` + pinColumns + `
+		FROM pins p
+		JOIN users u ON u.id = p.user_id
+		JOIN requester r ON TRUE` + servedToRequester + `
+		WHERE ` + inBBox + `
+		AND ` + notExpired + `
+		AND ` + visibleToRequester + `
 		ORDER BY p.created_at DESC
+		LIMIT $10
 	`
 
-	rows, err := p.db.Query(q, userID.String(), lon, lat, radiusKm)
+	args := append([]any{userID.String()}, bboxArgs(bbox)...)
+	args = append(args, limit)
+
+	rows, err := p.db.Query(q, args...)
 	if err != nil {
 		return nil, err
 	}
+
+	return scanPins(rows)
+}
+
+// clusteredPins buckets the visible pins in a bbox into square grid cells of
+// $10 degrees; cx and cy identify the cell.
+const clusteredPins = `
+		WITH requester AS (
+			SELECT id FROM users WHERE uuid = $1
+		),
+		visible AS (
+			SELECT
+				p.id,
+				p.emotion,
+				ST_X(sv.location::geometry) AS lon,
+				ST_Y(sv.location::geometry) AS lat,
+				floor(ST_X(sv.location::geometry) / $10)::bigint AS cx,
+				floor(ST_Y(sv.location::geometry) / $10)::bigint AS cy
+			FROM pins p
+			JOIN users u ON u.id = p.user_id
+			JOIN requester r ON TRUE` + servedToRequester + `
+			WHERE ` + inBBox + `
+			AND ` + notExpired + `
+			AND ` + visibleToRequester + `
+		)`
+
+// QueryPinClusters groups the pins visible to userID inside bbox into grid
+// cells of cellDeg degrees. Cells holding fewer than minClusterSize pins are
+// returned as individual pins instead of clusters.
+func (p *pinRepository) QueryPinClusters(userID uuid.UUID, bbox models.BBox, cellDeg float64, minClusterSize int) ([]models.PinCluster, []models.Pin, error) {
+	const clustersQuery = clusteredPins + `
+		SELECT cx, cy, emotion, COUNT(*), AVG(lon), AVG(lat)
+		FROM visible
+		GROUP BY cx, cy, emotion
+	`
+
+	args := append([]any{userID.String()}, bboxArgs(bbox)...)
+	args = append(args, cellDeg)
+
+	rows, err := p.db.Query(clustersQuery, args...)
+	if err != nil {
+		return nil, nil, err
+	}
 	defer rows.Close()
 
-	var pins []models.Pin
+	type cellKey struct{ x, y int64 }
+	type cellSums struct {
+		cluster models.PinCluster
+		lonSum  float64
+		latSum  float64
+	}
+	cells := map[cellKey]*cellSums{}
+	var order []cellKey
+
 	for rows.Next() {
-		var pin models.Pin
-		if err := rows.Scan(
-			&pin.UserID,
-			&pin.Emotion,
-			&pin.Message,
-			&pin.Location.Longitude,
-			&pin.Location.Latitude,
-			&pin.Visibility,
-			&pin.CreatedAt,
-			&pin.ExpiresAt,
-		); err != nil {
-			return nil, err
+		var key cellKey
+		var emotion string
+		var count int
+		var avgLon, avgLat float64
+		if err := rows.Scan(&key.x, &key.y, &emotion, &count, &avgLon, &avgLat); err != nil {
+			return nil, nil, err
 		}
-		pins = append(pins, pin)
+
+		cell, ok := cells[key]
+		if !ok {
+			cell = &cellSums{cluster: models.PinCluster{Emotions: map[string]int{}}}
+			cells[key] = cell
+			order = append(order, key)
+		}
+		cell.cluster.Count += count
+		cell.cluster.Emotions[emotion] = count
+		cell.lonSum += avgLon * float64(count)
+		cell.latSum += avgLat * float64(count)
 	}
 
 	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	clusters := make([]models.PinCluster, 0, len(cells))
+	hasSmallCells := false
+	for _, key := range order {
+		cell := cells[key]
+		if cell.cluster.Count < minClusterSize {
+			hasSmallCells = true
+			continue
+		}
+		cell.cluster.Location = models.Location{
+			Longitude: cell.lonSum / float64(cell.cluster.Count),
+			Latitude:  cell.latSum / float64(cell.cluster.Count),
+		}
+		cell.cluster.DominantEmotion = models.DominantEmotion(cell.cluster.Emotions)
+		clusters = append(clusters, cell.cluster)
+	}
+
+	if !hasSmallCells {
+		return clusters, nil, nil
+	}
+
+	const pinsQuery = clusteredPins + `,
+		small AS (
+			SELECT cx, cy FROM visible GROUP BY cx, cy HAVING COUNT(*) < $11
+		)
+		SELECT` + pinColumns + `
+		FROM pins p
+		JOIN users u ON u.id = p.user_id
+		JOIN requester r ON TRUE` + servedToRequester + `
+		WHERE p.id IN (SELECT v.id FROM visible v JOIN small s USING (cx, cy))
+		ORDER BY p.created_at DESC
+	`
+
+	pinRows, err := p.db.Query(pinsQuery, append(args, minClusterSize)...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pins, err := scanPins(pinRows)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return clusters, pins, nil
+}
+
+// QueryPinTile renders the pins visible to userID in web-mercator tile z/x/y
+// as a Mapbox Vector Tile with a single "pins" layer. Pins are merged into
+// cells of cellMeters; each feature carries the cell's dominant emotion, pin
+// count, age in seconds of its newest pin and, for single pins, the pin ID.
+func (p *pinRepository) QueryPinTile(userID uuid.UUID, z int, x int, y int, cellMeters float64) ([]byte, error) {
+	const q = `
+		WITH requester AS (
+			SELECT id FROM users WHERE uuid = $1
+		),
+		bounds AS (
+			SELECT
+				ST_TileEnvelope($2, $3, $4) AS merc,
+				ST_Transform(ST_TileEnvelope($2, $3, $4), 4326) AS geo
+		),
+		visible AS (
+			SELECT
+				p.uuid,
+				p.emotion,
+				p.created_at,
+				ST_Transform(sv.location::geometry, 3857) AS geom
+			FROM pins p
+			JOIN users u ON u.id = p.user_id
+			JOIN requester r ON TRUE
+			CROSS JOIN bounds b` + servedToRequester + `
+			WHERE p.location::geometry && ST_Expand(b.geo, ` + fuzzMarginDeg + `)
+			AND sv.location::geometry && b.geo
+			AND ` + notExpired + `
+			AND ` + visibleToRequester + `
+		),
+		cells AS (
+			SELECT
+				ST_Centroid(ST_Collect(geom)) AS geom,
+				COUNT(*) AS count,
+				mode() WITHIN GROUP (ORDER BY emotion) AS emotion,
+				EXTRACT(EPOCH FROM NOW() - MAX(created_at))::bigint AS age_s,
+				CASE WHEN COUNT(*) = 1 THEN (array_agg(uuid::text))[1] END AS pin_id
+			FROM visible
+			GROUP BY ST_SnapToGrid(geom, $5)
+		)
+		SELECT COALESCE(ST_AsMVT(tile, 'pins', 4096, 'geom'), ''::bytea)
+		FROM (
+			SELECT
+				ST_AsMVTGeom(c.geom, b.merc, 4096, 64, true) AS geom,
+				c.emotion,
+				c.count,
+				c.age_s,
+				c.pin_id
+			FROM cells c
+			CROSS JOIN bounds b
+		) AS tile
+		WHERE tile.geom IS NOT NULL
+	`
+
+	var tile []byte
+	if err := p.db.QueryRow(q, userID.String(), z, x, y, cellMeters).Scan(&tile); err != nil {
 		return nil, err
 	}
 
-	return pins, nil
+	return tile, nil
 }
 
-func (p *pinRepository) QueryFriendPins(userID uuid.UUID) ([]models.Pin, error) {
+// afterCreatedAt keeps pins (p) past the page's created_at cursor ($2, $3) in
+// newest-first order; both are NULL on the first page.
+const afterCreatedAt = `($2::timestamptz IS NULL OR (p.created_at, p.uuid) < ($2, $3::uuid))`
+
+func (p *pinRepository) QueryFriendPins(userID uuid.UUID, page Page) ([]models.Pin, error) {
 	const q = `
-		SELECT
-			u.uuid,
-			p.emotion,
-			p.message,
-			ST_X(p.location::geometry) AS longitude,
-			ST_Y(p.location::geometry) AS latitude,
-			p.visibility,
-			p.created_at,
-			p.expires_at
+		SELECT` + pinColumns + `
 		FROM pins p
 		JOIN users u ON u.id = p.user_id
+		JOIN users r ON r.uuid = $1` + servedToRequester + `
 		WHERE p.visibility IN ('public', 'friends')
+		  AND ` + notExpired + `
+		  AND ` + afterCreatedAt + `
 		  AND p.user_id IN (
 			SELECT friend_id
 			FROM friendships
 			WHERE status = 'accepted'
 			  AND user_id = (SELECT id FROM users WHERE uuid = $1)
 		)
-		ORDER BY p.created_at DESC
+		ORDER BY p.created_at DESC, p.uuid DESC
+		LIMIT $4
 	`
 
-	rows, err := p.db.Query(q, userID.String())
+	afterTime, afterID := page.createdAtArgs()
+	rows, err := p.db.Query(q, userID.String(), afterTime, afterID, page.fetchLimit())
+	if err != nil {
+		return nil, err
+	}
+
+	return scanPins(rows)
+}
+
+func (p *pinRepository) QueryUserPins(userID uuid.UUID, page Page) ([]models.Pin, error) {
+	const q = `
+		SELECT` + pinColumns + `
+		FROM pins p
+		JOIN users u ON u.id = p.user_id
+		JOIN users r ON r.id = u.id` + servedToRequester + `
+		WHERE u.uuid = $1
+		  AND ` + notExpired + `
+		  AND ` + afterCreatedAt + `
+		ORDER BY p.created_at DESC, p.uuid DESC
+		LIMIT $4
+	`
+
+	afterTime, afterID := page.createdAtArgs()
+	rows, err := p.db.Query(q, userID.String(), afterTime, afterID, page.fetchLimit())
+	if err != nil {
+		return nil, err
+	}
+
+	return scanPins(rows)
+}
+
+// QueryExpiredPins snapshots the pins whose expires_at falls in (from, to],
+// regardless of visibility; callers filter before telling anyone.
+func (p *pinRepository) QueryExpiredPins(from time.Time, to time.Time) ([]PinSnapshot, error) {
+	const q = `
+		SELECT` + pinSnapshotColumns + `
+		FROM pins p
+		JOIN users u ON u.id = p.user_id
+		WHERE p.expires_at > $1
+		  AND p.expires_at <= $2
+	`
+
+	rows, err := p.db.Query(q, from, to)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var pins []models.Pin
+	var pins []PinSnapshot
 	for rows.Next() {
-		var pin models.Pin
-		if err := rows.Scan(
-			&pin.UserID,
-			&pin.Emotion,
-			&pin.Message,
-			&pin.Location.Longitude,
-			&pin.Location.Latitude,
-			&pin.Visibility,
-			&pin.CreatedAt,
-			&pin.ExpiresAt,
-		); err != nil {
+		pin, err := scanPinSnapshot(rows)
+		if err != nil {
 			return nil, err
 		}
 		pins = append(pins, pin)
 	}
 
+	return pins, rows.Err()
+}
+
+// DeleteExpiredPins hard-deletes pins that expired more than retention ago and
+// returns how many were removed.
+func (p *pinRepository) DeleteExpiredPins(retention time.Duration) (int64, error) {
+	result, err := p.db.Exec(
+		`DELETE FROM pins WHERE expires_at < NOW() - make_interval(secs => $1)`,
+		retention.Seconds(),
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// AddPinReaction reacts to a pin userID can see. It returns false if the
+// reaction already existed and ErrPinNotFound if the pin isn't visible.
+func (p *pinRepository) AddPinReaction(userID uuid.UUID, pinID uuid.UUID, emoji string) (bool, error) {
+	const q = `
+		WITH target AS (
+			SELECT p.id AS pin_id, r.id AS user_id
+			FROM pins p
+			JOIN users u ON u.id = p.user_id
+			JOIN users r ON r.uuid = $1
+			WHERE p.uuid = $2
+			AND ` + notExpired + `
+			AND ` + visibleToRequester + `
+		), inserted AS (
+			INSERT INTO pin_reactions (pin_id, user_id, emoji)
+			SELECT pin_id, user_id, $3 FROM target
+			ON CONFLICT DO NOTHING
+			RETURNING 1
+		)
+		SELECT EXISTS (SELECT 1 FROM inserted) FROM target
+	`
+
+	var added bool
+	err := p.db.QueryRow(q, userID.String(), pinID.String(), emoji).Scan(&added)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, ErrPinNotFound
+	}
+	return added, err
+}
+
+// RemovePinReaction takes back a reaction; removing one that doesn't exist is
+// not an error.
+func (p *pinRepository) RemovePinReaction(userID uuid.UUID, pinID uuid.UUID, emoji string) error {
+	_, err := p.db.Exec(
+		`DELETE FROM pin_reactions
+		 WHERE pin_id = (SELECT id FROM pins WHERE uuid = $2)
+		   AND user_id = (SELECT id FROM users WHERE uuid = $1)
+		   AND emoji = $3`,
+		userID.String(), pinID.String(), emoji,
+	)
+	return err
+}
+
+// QueryPinEngagement returns reactions and comment counts on the given pins
+// as seen by userID. Callers pass pins userID can already see; pins nobody
+// engaged with are absent from the result.
+func (p *pinRepository) QueryPinEngagement(userID uuid.UUID, pinIDs []uuid.UUID) (map[uuid.UUID]models.PinEngagement, error) {
+	engagement := map[uuid.UUID]models.PinEngagement{}
+	if len(pinIDs) == 0 {
+		return engagement, nil
+	}
+	ids := uuidStrings(pinIDs)
+
+	const reactionsQ = `
+		SELECT p.uuid, pr.emoji, COUNT(*), bool_or(r.uuid = $1)
+		FROM pins p
+		JOIN pin_reactions pr ON pr.pin_id = p.id
+		JOIN users r ON r.id = pr.user_id
+		WHERE p.uuid = ANY($2::uuid[])
+		GROUP BY p.uuid, pr.emoji
+		ORDER BY p.uuid, COUNT(*) DESC, pr.emoji
+	`
+
+	rows, err := p.db.Query(reactionsQ, userID.String(), ids)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var pinID uuid.UUID
+		var emoji string
+		var count int
+		var mine bool
+		if err := rows.Scan(&pinID, &emoji, &count, &mine); err != nil {
+			return nil, err
+		}
+
+		e, ok := engagement[pinID]
+		if !ok {
+			e.Reactions = map[string]int{}
+		}
+		e.Reactions[emoji] = count
+		if mine {
+			e.MyReactions = append(e.MyReactions, emoji)
+		}
+		engagement[pinID] = e
+	}
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
 
-	return pins, nil
+	const commentsQ = `
+		SELECT p.uuid, COUNT(*)
+		FROM pins p
+		JOIN pin_comments c ON c.pin_id = p.id
+		WHERE p.uuid = ANY($1::uuid[])
+		  AND c.deleted_at IS NULL
+		GROUP BY p.uuid
+	`
+
+	commentRows, err := p.db.Query(commentsQ, ids)
+	if err != nil {
+		return nil, err
+	}
+	defer commentRows.Close()
+
+	for commentRows.Next() {
+		var pinID uuid.UUID
+		var count int
+		if err := commentRows.Scan(&pinID, &count); err != nil {
+			return nil, err
+		}
+
+		e := engagement[pinID]
+		e.Comments = count
+		engagement[pinID] = e
+	}
+
+	return engagement, commentRows.Err()
 }
 
-func (p *pinRepository) QueryUserPins(userID uuid.UUID) ([]models.Pin, error) {
+// QueryPinAttachments returns the attachments of the given pins in order.
+// Callers pass pins the viewer can already see.
+func (p *pinRepository) QueryPinAttachments(pinIDs []uuid.UUID) (map[uuid.UUID][]models.Attachment, error) {
+	attachments := map[uuid.UUID][]models.Attachment{}
+	if len(pinIDs) == 0 {
+		return attachments, nil
+	}
+
 	const q = `
-		SELECT
-			u.uuid,
-			p.emotion,
-			p.message,
-			ST_X(p.location::geometry) AS longitude,
-			ST_Y(p.location::geometry) AS latitude,
-			p.visibility,
-			p.created_at,
-			p.expires_at
-		FROM pins p
-		JOIN users u ON u.id = p.user_id
-		WHERE u.uuid = $1
-		ORDER BY p.created_at DESC
+		SELECT` + attachmentColumns + `
+		FROM pin_attachments a
+		JOIN pins p ON p.id = a.pin_id
+		WHERE p.uuid = ANY($1::uuid[])
+		ORDER BY p.uuid, a.position
 	`
 
-	rows, err := p.db.Query(q, userID.String())
+	rows, err := p.db.Query(q, uuidStrings(pinIDs))
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var pins []models.Pin
 	for rows.Next() {
-		var pin models.Pin
-		if err := rows.Scan(
-			&pin.UserID,
-			&pin.Emotion,
-			&pin.Message,
-			&pin.Location.Longitude,
-			&pin.Location.Latitude,
-			&pin.Visibility,
-			&pin.CreatedAt,
-			&pin.ExpiresAt,
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		attachments[a.PinID] = append(attachments[a.PinID], a)
+	}
+
+	return attachments, rows.Err()
+}
+
+// SavePinMessageRefs replaces the hashtags indexed for a pin with refs.Tags,
+// forgets mentions no longer in the message, and records refs.Mentions that
+// name friends of the pin's author once the pin is visible to friends. It
+// returns the friends who had not been recorded as mentioned before; a
+// mention in a private pin is recorded, and returned, when it stops being
+// private.
+func (p *pinRepository) SavePinMessageRefs(pinID uuid.UUID, refs models.MessageRefs) ([]uuid.UUID, error) {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var id, authorID int64
+	var visibility string
+	err = tx.QueryRow(`SELECT id, user_id, visibility FROM pins WHERE uuid = $1`, pinID.String()).Scan(&id, &authorID, &visibility)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrPinNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(`DELETE FROM pin_tags WHERE pin_id = $1`, id); err != nil {
+		return nil, err
+	}
+	if len(refs.Tags) > 0 {
+		if _, err := tx.Exec(
+			`INSERT INTO pin_tags (pin_id, tag) SELECT $1, unnest($2::text[]) ON CONFLICT DO NOTHING`,
+			id, refs.Tags,
 		); err != nil {
 			return nil, err
 		}
-		pins = append(pins, pin)
 	}
 
-	if err := rows.Err(); err != nil {
+	if _, err := tx.Exec(
+		`DELETE FROM pin_mentions m USING users u WHERE m.pin_id = $1 AND u.id = m.user_id AND NOT u.username = ANY(COALESCE($2::text[], '{}'))`,
+		id, refs.Mentions,
+	); err != nil {
 		return nil, err
 	}
 
-	return pins, nil
+	var mentioned []uuid.UUID
+	if len(refs.Mentions) > 0 && visibility != "private" {
+		const q = `
+			WITH friends AS (
+				SELECT u.id, u.uuid
+				FROM users u
+				WHERE u.username = ANY($3::text[])
+				AND EXISTS (
+					SELECT 1
+					FROM friendships f
+					WHERE f.status = 'accepted'
+						AND (
+							(f.user_id = $2 AND f.friend_id = u.id)
+							OR (f.friend_id = $2 AND f.user_id = u.id)
+						)
+				)
+			), inserted AS (
+				INSERT INTO pin_mentions (pin_id, user_id)
+				SELECT $1, id FROM friends
+				ON CONFLICT DO NOTHING
+				RETURNING user_id
+			)
+			SELECT f.uuid FROM friends f JOIN inserted i ON i.user_id = f.id
+		`
+
+		rows, err := tx.Query(q, id, authorID, refs.Mentions)
+		if err != nil {
+			return nil, err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var userID uuid.UUID
+			if err := rows.Scan(&userID); err != nil {
+				return nil, err
+			}
+			mentioned = append(mentioned, userID)
+		}
+		if err := rows.Err(); err != nil {
+			return nil, err
+		}
+		rows.Close()
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return mentioned, nil
 }
@@ -0,0 +1,144 @@
+package repositories
+
+import (
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	"ember/api/models"
+
+	"github.com/google/uuid"
+	_ "github.com/jackc/pgx/v5/stdlib"
+)
+
+// openTestDB connects to the database in TEST_DB_SOURCE, which must have
+// db/schema/init.sql applied, and skips the test when it is unset.
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DB_SOURCE")
+	if dsn == "" {
+		t.Skip("TEST_DB_SOURCE is not set")
+	}
+
+	db, err := sql.Open("pgx", dsn)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if err := db.Ping(); err != nil {
+		t.Fatalf("ping database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// createTestUser creates a user that is deleted, with their pins, when the
+// test ends.
+func createTestUser(t *testing.T, db *sql.DB) uuid.UUID {
+	t.Helper()
+	name := "test_" + uuid.NewString()[:8]
+	userID, err := NewUserRepository(db).CreateUser(name, name+"@example.com", "x")
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	t.Cleanup(func() { db.Exec(`DELETE FROM users WHERE uuid = $1`, userID) })
+	return userID
+}
+
+func TestQueryNearbyPins_SortRelevance(t *testing.T) {
+	db := openTestDB(t)
+	pinRepo := NewPinRepository(db)
+	userID := createTestUser(t, db)
+
+	// Far out at sea, away from anyone else's pins
+	lon, lat := -140.0, 10.0
+	near, err := pinRepo.CreatePin(userID, "happy", "", lon, lat, "public", "", nil)
+	if err != nil {
+		t.Fatalf("create pin: %v", err)
+	}
+	far, err := pinRepo.CreatePin(userID, "happy", "", lon+0.05, lat, "public", "", nil)
+	if err != nil {
+		t.Fatalf("create pin: %v", err)
+	}
+
+	query := NearbyPinQuery{
+		Longitude: lon,
+		Latitude:  lat,
+		RadiusKm:  10,
+		Sort:      PinSortRelevance,
+		AsOf:      time.Now(),
+		Page:      Page{Limit: 1},
+	}
+	first, err := pinRepo.QueryNearbyPins(userID, query)
+	if err != nil {
+		t.Fatalf("query nearby pins: %v", err)
+	}
+	if len(first) != 2 || first[0].ID != near.ID {
+		t.Fatalf("expected the nearer pin first, got %+v", first)
+	}
+
+	query.Page.After = &Cursor{ID: first[0].ID, Score: first[0].Relevance}
+	second, err := pinRepo.QueryNearbyPins(userID, query)
+	if err != nil {
+		t.Fatalf("query next page: %v", err)
+	}
+	if len(second) != 1 || second[0].ID != far.ID {
+		t.Fatalf("expected the farther pin on the next page, got %+v", second)
+	}
+}
+
+func TestServePinSnapshot_AfterDeletion(t *testing.T) {
+	db := openTestDB(t)
+	pinRepo := NewPinRepository(db)
+	author, stranger := createTestUser(t, db), createTestUser(t, db)
+
+	pin, err := pinRepo.CreatePin(author, "happy", "", -140.0, 12.0, "public", "1km", nil)
+	if err != nil {
+		t.Fatalf("create pin: %v", err)
+	}
+	snapshot, err := pinRepo.GetPinSnapshot(pin.ID)
+	if err != nil || snapshot == nil {
+		t.Fatalf("snapshot pin: %v", err)
+	}
+	if err := pinRepo.DeletePin(author, pin.ID); err != nil {
+		t.Fatalf("delete pin: %v", err)
+	}
+
+	served, err := pinRepo.ServePinSnapshot(author, *snapshot)
+	if err != nil || served == nil || *served != snapshot.Location {
+		t.Fatalf("expected the author to be served the exact location, got %v %v", served, err)
+	}
+	served, err = pinRepo.ServePinSnapshot(stranger, *snapshot)
+	if err != nil || served == nil || *served != snapshot.Approximate["1km"] {
+		t.Fatalf("expected a stranger to be served the 1km location, got %v %v", served, err)
+	}
+}
+
+func TestServePinSnapshot_PrivateZone(t *testing.T) {
+	db := openTestDB(t)
+	pinRepo := NewPinRepository(db)
+	author, stranger := createTestUser(t, db), createTestUser(t, db)
+
+	// Created before the zone, so it is still public
+	pin, err := pinRepo.CreatePin(author, "happy", "", -140.0, 14.0, "public", "", nil)
+	if err != nil {
+		t.Fatalf("create pin: %v", err)
+	}
+	zone := models.Zone{Name: "home", Center: &models.Location{Longitude: -140.0, Latitude: 14.0}, RadiusM: 500}
+	if _, err := NewZoneRepository(db).CreateZone(author, zone); err != nil {
+		t.Fatalf("create zone: %v", err)
+	}
+	snapshot, err := pinRepo.GetPinSnapshot(pin.ID)
+	if err != nil || snapshot == nil {
+		t.Fatalf("snapshot pin: %v", err)
+	}
+
+	served, err := pinRepo.ServePinSnapshot(stranger, *snapshot)
+	if err != nil || served != nil {
+		t.Fatalf("expected a pin in a private zone to be hidden from strangers, got %v %v", served, err)
+	}
+	served, err = pinRepo.ServePinSnapshot(author, *snapshot)
+	if err != nil || served == nil {
+		t.Fatalf("expected the author to still see their pin, got %v %v", served, err)
+	}
+}
@@ -0,0 +1,237 @@
+package repositories
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"ember/api/models"
+
+	"github.com/google/uuid"
+)
+
+// interface
+type PushRepository interface {
+	RegisterDevice(userID uuid.UUID, platform string, token string) error
+	DeleteDevice(userID uuid.UUID, token string) (bool, error)
+	DeleteDeviceTokens(tokens []string) (int64, error)
+	GetPushPreferences(userID uuid.UUID) (models.PushPreferences, error)
+	SavePushPreferences(userID uuid.UUID, prefs models.PushPreferences) error
+	ClaimDuePushes(limit int, lease time.Duration) ([]models.PendingPush, error)
+	FinishPushes(ids []uuid.UUID, status string) error
+	ReschedulePushes(ids []uuid.UUID, at time.Time, countAttempt bool) error
+}
+
+// implementation
+type pushRepository struct {
+	db *sql.DB
+}
+
+func NewPushRepository(db *sql.DB) PushRepository {
+	return &pushRepository{
+		db: db,
+	}
+}
+
+// RegisterDevice stores a device token for userID. A token already known
+// under another account moves to userID, since only the last user signed in
+// on a device should receive its pushes.
+func (pr *pushRepository) RegisterDevice(userID uuid.UUID, platform string, token string) error {
+	_, err := pr.db.Exec(
+		`INSERT INTO devices (user_id, platform, token)
+		 VALUES ((SELECT id FROM users WHERE uuid = $1), $2, $3)
+		 ON CONFLICT (token) DO UPDATE
+		 SET user_id = EXCLUDED.user_id, platform = EXCLUDED.platform, last_seen_at = NOW()`,
+		userID.String(), platform, token,
+	)
+	return err
+}
+
+func (pr *pushRepository) DeleteDevice(userID uuid.UUID, token string) (bool, error) {
+	result, err := pr.db.Exec(
+		`DELETE FROM devices
+		 WHERE user_id = (SELECT id FROM users WHERE uuid = $1) AND token = $2`,
+		userID.String(), token,
+	)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// DeleteDeviceTokens forgets tokens the push service reported as dead.
+func (pr *pushRepository) DeleteDeviceTokens(tokens []string) (int64, error) {
+	result, err := pr.db.Exec(`DELETE FROM devices WHERE token = ANY($1::text[])`, tokens)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// GetPushPreferences returns the user's preferences, or the defaults if they
+// never saved any.
+func (pr *pushRepository) GetPushPreferences(userID uuid.UUID) (models.PushPreferences, error) {
+	prefs := models.DefaultPushPreferences()
+	var muted []byte
+	var quietStart, quietEnd sql.NullInt32
+
+	err := pr.db.QueryRow(
+		`SELECT pp.enabled, pp.muted_types, pp.quiet_start, pp.quiet_end, pp.time_zone
+		 FROM push_preferences pp
+		 JOIN users u ON u.id = pp.user_id
+		 WHERE u.uuid = $1`,
+		userID.String(),
+	).Scan(&prefs.Enabled, &muted, &quietStart, &quietEnd, &prefs.TimeZone)
+	if err == sql.ErrNoRows {
+		return prefs, nil
+	}
+	if err != nil {
+		return prefs, err
+	}
+
+	if err := json.Unmarshal(muted, &prefs.MutedTypes); err != nil {
+		return prefs, err
+	}
+	prefs.QuietStart = nullIntPtr(quietStart)
+	prefs.QuietEnd = nullIntPtr(quietEnd)
+	return prefs, nil
+}
+
+func (pr *pushRepository) SavePushPreferences(userID uuid.UUID, prefs models.PushPreferences) error {
+	muted := prefs.MutedTypes
+	if muted == nil {
+		muted = []string{}
+	}
+	payload, err := json.Marshal(muted)
+	if err != nil {
+		return err
+	}
+
+	_, err = pr.db.Exec(
+		`INSERT INTO push_preferences (user_id, enabled, muted_types, quiet_start, quiet_end, time_zone)
+		 VALUES ((SELECT id FROM users WHERE uuid = $1), $2, $3, $4, $5, $6)
+		 ON CONFLICT (user_id) DO UPDATE
+		 SET enabled = EXCLUDED.enabled,
+		     muted_types = EXCLUDED.muted_types,
+		     quiet_start = EXCLUDED.quiet_start,
+		     quiet_end = EXCLUDED.quiet_end,
+		     time_zone = EXCLUDED.time_zone,
+		     updated_at = NOW()`,
+		userID.String(), prefs.Enabled, payload, prefs.QuietStart, prefs.QuietEnd, prefs.TimeZone,
+	)
+	return err
+}
+
+// ClaimDuePushes leases up to limit notifications whose push is due, oldest
+// first. Leased rows are pushed back by lease so concurrent workers skip them
+// and a crashed worker's claims become due again.
+func (pr *pushRepository) ClaimDuePushes(limit int, lease time.Duration) ([]models.PendingPush, error) {
+	const q = `
+		WITH due AS (
+			SELECT id FROM notifications
+			WHERE push_status = 'pending' AND push_next_attempt_at <= NOW()
+			ORDER BY push_next_attempt_at
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		), leased AS (
+			UPDATE notifications n
+			SET push_next_attempt_at = NOW() + make_interval(secs => $2)
+			FROM due
+			WHERE n.id = due.id
+			RETURNING n.*
+		)
+		SELECT` + notificationColumns + `,
+			r.uuid,
+			n.push_attempts,
+			COALESCE(pp.enabled, TRUE),
+			COALESCE(pp.muted_types, '[]'),
+			pp.quiet_start,
+			pp.quiet_end,
+			COALESCE(pp.time_zone, 'UTC'),
+			COALESCE((SELECT json_agg(d.token) FROM devices d WHERE d.user_id = n.user_id), '[]')
+		FROM leased n
+		JOIN users r ON r.id = n.user_id
+		LEFT JOIN users a ON a.id = n.actor_id
+		LEFT JOIN pins p ON p.id = n.pin_id
+		LEFT JOIN push_preferences pp ON pp.user_id = n.user_id
+		ORDER BY n.created_at
+	`
+
+	rows, err := pr.db.Query(q, limit, lease.Seconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pushes []models.PendingPush
+	for rows.Next() {
+		var push models.PendingPush
+		var muted, tokens []byte
+		var quietStart, quietEnd sql.NullInt32
+
+		n, err := scanNotification(rows,
+			&push.RecipientID,
+			&push.Attempts,
+			&push.Preferences.Enabled,
+			&muted,
+			&quietStart,
+			&quietEnd,
+			&push.Preferences.TimeZone,
+			&tokens,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(muted, &push.Preferences.MutedTypes); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(tokens, &push.DeviceTokens); err != nil {
+			return nil, err
+		}
+		push.Notification = n
+		push.Preferences.QuietStart = nullIntPtr(quietStart)
+		push.Preferences.QuietEnd = nullIntPtr(quietEnd)
+		pushes = append(pushes, push)
+	}
+
+	return pushes, rows.Err()
+}
+
+// FinishPushes settles the push state of notifications for good.
+func (pr *pushRepository) FinishPushes(ids []uuid.UUID, status string) error {
+	_, err := pr.db.Exec(
+		`UPDATE notifications SET push_status = $2 WHERE uuid = ANY($1::uuid[])`,
+		uuidStrings(ids), status,
+	)
+	return err
+}
+
+// ReschedulePushes makes notifications due again at the given time, counting
+// a failed attempt when countAttempt is set.
+func (pr *pushRepository) ReschedulePushes(ids []uuid.UUID, at time.Time, countAttempt bool) error {
+	_, err := pr.db.Exec(
+		`UPDATE notifications
+		 SET push_next_attempt_at = $2,
+		     push_attempts = push_attempts + CASE WHEN $3 THEN 1 ELSE 0 END
+		 WHERE uuid = ANY($1::uuid[])`,
+		uuidStrings(ids), at, countAttempt,
+	)
+	return err
+}
+
+func nullIntPtr(n sql.NullInt32) *int {
+	if !n.Valid {
+		return nil
+	}
+	v := int(n.Int32)
+	return &v
+}
+
+func uuidStrings(ids []uuid.UUID) []string {
+	strs := make([]string, 0, len(ids))
+	for _, id := range ids {
+		strs = append(strs, id.String())
+	}
+	return strs
+}
@@ -0,0 +1,306 @@
+package repositories
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"ember/api/models"
+
+	"github.com/google/uuid"
+)
+
+// SyncRetention is how long deletions are kept for GET /sync. Older tokens
+// can't be caught up from and clients must sync from scratch.
+const SyncRetention = 30 * 24 * time.Hour
+
+// SyncToken is how far a client has synced: every change up to Since and,
+// part-way through the pins changed at Since, those up to AfterID. A nil
+// token syncs from scratch.
+type SyncToken struct {
+	Since   time.Time  `json:"t"`
+	AfterID *uuid.UUID `json:"id,omitempty"`
+}
+
+// interface
+type SyncRepository interface {
+	GetChanges(userID uuid.UUID, token *SyncToken, pinLimit int) (*models.SyncChanges, error)
+	PruneDeletions() (int64, error)
+}
+
+// implementation
+type syncRepository struct {
+	db *sql.DB
+}
+
+func NewSyncRepository(db *sql.DB) SyncRepository {
+	return &syncRepository{
+		db: db,
+	}
+}
+
+// maxUUID sorts after every other UUID, so a token without AfterID only
+// matches changes strictly after Since.
+const maxUUID = "ffffffff-ffff-ffff-ffff-ffffffffffff"
+
+// syncedPinScope joins the pins (p, by u) a reader (r) syncs, their own and
+// their friends' (f, when accepted), and when each last changed for them.
+const syncedPinScope = `
+		FROM pins p
+		JOIN users u ON u.id = p.user_id
+		JOIN users r ON r.uuid = $1
+		LEFT JOIN friendships f ON f.user_id = r.id AND f.friend_id = p.user_id AND f.status = 'accepted'
+		CROSS JOIN LATERAL (SELECT GREATEST(p.updated_at, f.updated_at, u.updated_at) AS changed_at) c`
+
+// removedFriend matches a subject users row (x) that stopped being the
+// reader's (r) friend since $2.
+const removedFriend = `EXISTS (
+				SELECT 1 FROM sync_deletions d
+				WHERE d.kind = 'friend' AND d.owner_id = r.id AND d.subject_uuid = x.uuid AND d.deleted_at > $2
+			)`
+
+// GetChanges reads everything that changed for userID since token in a
+// single snapshot. At most pinLimit+1 pins are returned, oldest change first;
+// the rest of the changes are always complete.
+func (sr *syncRepository) GetChanges(userID uuid.UUID, token *SyncToken, pinLimit int) (*models.SyncChanges, error) {
+	tx, err := sr.db.BeginTx(context.Background(), &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var since, afterID any
+	if token != nil {
+		since, afterID = token.Since, maxUUID
+		if token.AfterID != nil {
+			afterID = token.AfterID.String()
+		}
+	}
+
+	changes := models.SyncChanges{}
+	if err := tx.QueryRow(`SELECT NOW()`).Scan(&changes.AsOf); err != nil {
+		return nil, err
+	}
+
+	if changes.Profile, err = syncProfile(tx, userID, since); err != nil {
+		return nil, err
+	}
+	if changes.Pins, err = syncPins(tx, userID, since, afterID, pinLimit); err != nil {
+		return nil, err
+	}
+	if changes.Friends, err = syncFriends(tx, userID, since); err != nil {
+		return nil, err
+	}
+	if changes.Requests, err = syncRequests(tx, userID, since); err != nil {
+		return nil, err
+	}
+	if token != nil {
+		if changes.DeletedPins, err = syncDeletedPins(tx, userID, since); err != nil {
+			return nil, err
+		}
+		if changes.RemovedFriends, err = syncRemoved(tx, userID, since, "friend", "f.status = 'accepted'"); err != nil {
+			return nil, err
+		}
+		if changes.RemovedRequests, err = syncRemoved(tx, userID, since, "friend_request", "f.status = 'pending'"); err != nil {
+			return nil, err
+		}
+	}
+
+	return &changes, tx.Commit()
+}
+
+func syncProfile(tx *sql.Tx, userID uuid.UUID, since any) (*models.User, error) {
+	var user models.User
+	err := tx.QueryRow(
+		`SELECT uuid, username, display_name, bio, created_at, updated_at
+		 FROM users
+		 WHERE uuid = $1 AND ($2::timestamptz IS NULL OR updated_at > $2)`,
+		userID.String(), since,
+	).Scan(&user.ID, &user.Username, &user.DisplayName, &user.Bio, &user.CreatedAt, &user.UpdatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// syncPins returns the reader's and their friends' visible pins changed since
+// the token, served as every other read serves them.
+func syncPins(tx *sql.Tx, userID uuid.UUID, since any, afterID any, limit int) ([]models.SyncedPin, error) {
+	const q = `
+		SELECT` + pinColumns + `,
+			c.changed_at` + syncedPinScope + servedToRequester + `
+		WHERE (p.user_id = r.id OR (f.id IS NOT NULL AND p.visibility IN ('public', 'friends')))
+		AND ` + notExpired + `
+		AND ($2::timestamptz IS NULL OR (c.changed_at, p.uuid) > ($2, $3::uuid))
+		ORDER BY c.changed_at, p.uuid
+		LIMIT $4
+	`
+
+	rows, err := tx.Query(q, userID.String(), since, afterID, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var pins []models.SyncedPin
+	for rows.Next() {
+		var pin models.SyncedPin
+		if pin.Pin, err = scanPin(rows, &pin.ChangedAt); err != nil {
+			return nil, err
+		}
+		pins = append(pins, pin)
+	}
+
+	return pins, rows.Err()
+}
+
+// syncDeletedPins returns the pins the reader may hold that are gone, or
+// that they can no longer see: deleted, expired, made private by a friend,
+// or by a friend they have since removed.
+func syncDeletedPins(tx *sql.Tx, userID uuid.UUID, since any) ([]uuid.UUID, error) {
+	const q = `
+		SELECT d.subject_uuid
+		FROM sync_deletions d
+		JOIN users r ON r.uuid = $1
+		LEFT JOIN users x ON x.id = d.owner_id
+		WHERE d.kind = 'pin' AND d.deleted_at > $2
+		AND (
+			d.owner_id = r.id
+			OR EXISTS (
+				SELECT 1 FROM friendships f
+				WHERE f.user_id = r.id AND f.friend_id = d.owner_id AND f.status = 'accepted'
+			)
+			OR ` + removedFriend + `
+		)
+		UNION
+		SELECT p.uuid` + syncedPinScope + `
+		WHERE (p.user_id = r.id OR f.id IS NOT NULL)
+		AND (
+			(p.visibility = 'private' AND p.user_id <> r.id AND c.changed_at > $2)
+			OR (p.expires_at > $2 AND p.expires_at <= NOW())
+		)
+		UNION
+		SELECT p.uuid
+		FROM pins p
+		JOIN users x ON x.id = p.user_id
+		JOIN users r ON r.uuid = $1
+		WHERE ` + removedFriend + `
+		AND NOT EXISTS (
+			SELECT 1 FROM friendships f
+			WHERE f.user_id = r.id AND f.friend_id = x.id AND f.status = 'accepted'
+		)
+	`
+
+	return syncUUIDs(tx, q, userID.String(), since)
+}
+
+func syncFriends(tx *sql.Tx, userID uuid.UUID, since any) ([]models.User, error) {
+	rows, err := tx.Query(
+		`SELECT u.uuid, u.username, u.display_name, u.bio, u.created_at, u.updated_at
+		 FROM friendships f
+		 JOIN users r ON r.id = f.user_id
+		 JOIN users u ON u.id = f.friend_id
+		 WHERE r.uuid = $1 AND f.status = 'accepted'
+		 AND ($2::timestamptz IS NULL OR f.updated_at > $2 OR u.updated_at > $2)
+		 ORDER BY u.username`,
+		userID.String(), since,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var friends []models.User
+	for rows.Next() {
+		var u models.User
+		if err := rows.Scan(&u.ID, &u.Username, &u.DisplayName, &u.Bio, &u.CreatedAt, &u.UpdatedAt); err != nil {
+			return nil, err
+		}
+		friends = append(friends, u)
+	}
+
+	return friends, rows.Err()
+}
+
+func syncRequests(tx *sql.Tx, userID uuid.UUID, since any) ([]models.FriendRequest, error) {
+	rows, err := tx.Query(
+		`SELECT u.uuid, u.username, u.display_name, f.friend_id = r.id, f.created_at
+		 FROM friendships f
+		 JOIN users r ON r.id IN (f.user_id, f.friend_id)
+		 JOIN users u ON u.id IN (f.user_id, f.friend_id) AND u.id <> r.id
+		 WHERE r.uuid = $1 AND f.status = 'pending'
+		 AND ($2::timestamptz IS NULL OR f.updated_at > $2 OR u.updated_at > $2)
+		 ORDER BY f.created_at DESC, u.uuid DESC`,
+		userID.String(), since,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var requests []models.FriendRequest
+	for rows.Next() {
+		var req models.FriendRequest
+		if err := rows.Scan(&req.ID, &req.Username, &req.DisplayName, &req.Incoming, &req.RequestedAt); err != nil {
+			return nil, err
+		}
+		requests = append(requests, req)
+	}
+
+	return requests, rows.Err()
+}
+
+// syncRemoved returns the other side of the reader's friendships or requests
+// (kind) deleted since the token, unless a friendship matching current has
+// since taken its place.
+func syncRemoved(tx *sql.Tx, userID uuid.UUID, since any, kind string, current string) ([]uuid.UUID, error) {
+	q := `
+		SELECT DISTINCT x.uuid
+		FROM sync_deletions d
+		JOIN users r ON r.uuid = $1
+		JOIN users x ON x.uuid = d.subject_uuid
+		WHERE d.kind = $3 AND d.owner_id = r.id AND d.deleted_at > $2
+		AND NOT EXISTS (
+			SELECT 1 FROM friendships f
+			WHERE ((f.user_id = r.id AND f.friend_id = x.id) OR (f.user_id = x.id AND f.friend_id = r.id))
+			AND (` + current + `)
+		)
+	`
+
+	return syncUUIDs(tx, q, userID.String(), since, kind)
+}
+
+func syncUUIDs(tx *sql.Tx, q string, args ...any) ([]uuid.UUID, error) {
+	rows, err := tx.Query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+// PruneDeletions forgets deletions older than SyncRetention.
+func (sr *syncRepository) PruneDeletions() (int64, error) {
+	res, err := sr.db.Exec(
+		`DELETE FROM sync_deletions WHERE deleted_at < NOW() - make_interval(secs => $1)`,
+		SyncRetention.Seconds(),
+	)
+	if err != nil {
+		return 0, err
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,98 @@
+package repositories
+
+import (
+	"database/sql"
+	"time"
+
+	"ember/api/models"
+
+	"github.com/google/uuid"
+)
+
+// interface
+type TagRepository interface {
+	QueryTagPins(userID uuid.UUID, tag string, page Page) ([]models.Pin, error)
+	QueryTrendingTags(userID uuid.UUID, bbox models.BBox, since time.Time, limit int) ([]models.TrendingTag, error)
+}
+
+// implementation
+type tagRepository struct {
+	db *sql.DB
+}
+
+func NewTagRepository(db *sql.DB) TagRepository {
+	return &tagRepository{
+		db: db,
+	}
+}
+
+// QueryTagPins returns the pins tagged with tag that userID can see, newest first.
+func (t *tagRepository) QueryTagPins(userID uuid.UUID, tag string, page Page) ([]models.Pin, error) {
+	const q = `
+		WITH requester AS (
+			SELECT id FROM users WHERE uuid = $1
+		)
+		SELECT` + pinColumns + `
+		FROM pin_tags pt
+		JOIN pins p ON p.id = pt.pin_id
+		JOIN users u ON u.id = p.user_id
+		JOIN requester r ON TRUE` + servedToRequester + `
+		WHERE pt.tag = $4
+		AND ` + notExpired + `
+		AND ` + afterCreatedAt + `
+		AND ` + visibleToRequester + `
+		ORDER BY p.created_at DESC, p.uuid DESC
+		LIMIT $5
+	`
+
+	afterTime, afterID := page.createdAtArgs()
+	rows, err := t.db.Query(q, userID.String(), afterTime, afterID, tag, page.fetchLimit())
+	if err != nil {
+		return nil, err
+	}
+
+	return scanPins(rows)
+}
+
+// QueryTrendingTags ranks the hashtags on pins userID can see inside bbox
+// that were posted since the given time. Tags used by more people rank
+// first, so one prolific author can't push a tag to the top alone.
+func (t *tagRepository) QueryTrendingTags(userID uuid.UUID, bbox models.BBox, since time.Time, limit int) ([]models.TrendingTag, error) {
+	const q = `
+		WITH requester AS (
+			SELECT id FROM users WHERE uuid = $1
+		)
+		SELECT pt.tag, COUNT(*) AS pins, COUNT(DISTINCT p.user_id) AS authors
+		FROM pin_tags pt
+		JOIN pins p ON p.id = pt.pin_id
+		JOIN users u ON u.id = p.user_id
+		JOIN requester r ON TRUE` + servedToRequester + `
+		WHERE ` + inBBox + `
+		AND p.created_at >= $10
+		AND ` + notExpired + `
+		AND ` + visibleToRequester + `
+		GROUP BY pt.tag
+		ORDER BY authors DESC, pins DESC, pt.tag
+		LIMIT $11
+	`
+
+	args := append([]any{userID.String()}, bboxArgs(bbox)...)
+	args = append(args, since, limit)
+
+	rows, err := t.db.Query(q, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []models.TrendingTag
+	for rows.Next() {
+		var tag models.TrendingTag
+		if err := rows.Scan(&tag.Tag, &tag.Pins, &tag.Authors); err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+
+	return tags, rows.Err()
+}
@@ -18,12 +18,15 @@ type UserRepository interface {
 	CreateUser(username string, email string, passwordHash string) (uuid.UUID, error)
 	GetUserByUUID(id uuid.UUID) (*models.User, error)
 	GetPasswordHashByEmail(email string) (uuid.UUID, string, error)
-	GetFriendsByUUID(id uuid.UUID) ([]models.User, error)
-	GetFriendRequestsByUUID(id uuid.UUID) ([]models.User, []models.User, error)
+	GetFriendsByUUID(id uuid.UUID, page Page) ([]models.User, error)
+	GetFriendRequestsByUUID(id uuid.UUID, page Page) ([]models.FriendRequest, error)
 	CreateFriendRequest(userID uuid.UUID, friendID uuid.UUID) (bool, error)
 	AcceptFriendRequest(userID uuid.UUID, requesterID uuid.UUID) (bool, error)
 	RejectFriendRequest(userID uuid.UUID, requesterID uuid.UUID) (bool, error)
 	DeleteFriend(userID uuid.UUID, friendID uuid.UUID) (bool, error)
+	AreFriends(userID uuid.UUID, otherID uuid.UUID) (bool, error)
+	GetLocationPrivacy(userID uuid.UUID) (*models.LocationPrivacy, error)
+	SaveLocationPrivacy(userID uuid.UUID, privacy models.LocationPrivacy) error
 }
 
 // implementation
@@ -72,16 +75,25 @@ func (ur *userRepository) GetUserByUUID(id uuid.UUID) (*models.User, error) {
 	return &user, nil
 }
 
-func (ur *userRepository) GetFriendsByUUID(id uuid.UUID) ([]models.User, error) {
+// GetFriendsByUUID lists a user's friends alphabetically by username.
+func (ur *userRepository) GetFriendsByUUID(id uuid.UUID, page Page) ([]models.User, error) {
 	var users []models.User
 
+	var afterUsername any
+	if page.After != nil {
+		afterUsername = page.After.Username
+	}
+
 	rows, err := ur.db.Query(
 		`SELECT uuid, username, display_name, bio, created_at, updated_at 
 		 FROM users WHERE id IN
 			(SELECT friend_id
 			FROM friendships WHERE status = 'accepted' AND user_id = 
-				(SELECT id FROM USERS WHERE uuid = $1))`,
-		id,
+				(SELECT id FROM USERS WHERE uuid = $1))
+		 AND ($2::text IS NULL OR username > $2)
+		 ORDER BY username
+		 LIMIT $3`,
+		id, afterUsername, page.fetchLimit(),
 	)
 	if err != nil {
 		return users, err
@@ -90,14 +102,16 @@ func (ur *userRepository) GetFriendsByUUID(id uuid.UUID) ([]models.User, error)
 
 	for rows.Next() {
 		var user models.User
-		rows.Scan(
+		if err := rows.Scan(
 			&user.ID,
 			&user.Username,
 			&user.DisplayName,
 			&user.Bio,
 			&user.CreatedAt,
 			&user.UpdatedAt,
-		)
+		); err != nil {
+			return users, err
+		}
 		users = append(users, user)
 	}
 
@@ -122,65 +136,57 @@ func (ur *userRepository) GetPasswordHashByEmail(email string) (uuid.UUID, strin
 	return id, passwordHash, nil
 }
 
-func (ur *userRepository) GetFriendRequestsByUUID(id uuid.UUID) ([]models.User, []models.User, error) {
-	var incoming []models.User
-	var outgoing []models.User
+// GetFriendRequestsByUUID lists the user's pending requests in both
+// directions, newest first.
+func (ur *userRepository) GetFriendRequestsByUUID(id uuid.UUID, page Page) ([]models.FriendRequest, error) {
+	var requests []models.FriendRequest
 
+	afterTime, afterID := page.createdAtArgs()
 	rows, err := ur.db.Query(
-		`SELECT uuid, username, display_name 
-		 FROM users WHERE id IN
-			(SELECT user_id
-			FROM friendships WHERE status = 'pending' AND friend_id = 
-				(SELECT id FROM USERS WHERE uuid = $1))`,
-		id,
+		`WITH me AS (
+			SELECT id FROM users WHERE uuid = $1
+		),
+		pending AS (
+			SELECT f.user_id AS other_id, TRUE AS incoming, f.created_at
+			FROM friendships f JOIN me ON f.friend_id = me.id
+			WHERE f.status = 'pending'
+			UNION ALL
+			SELECT f.friend_id, FALSE, f.created_at
+			FROM friendships f JOIN me ON f.user_id = me.id
+			WHERE f.status = 'pending'
+		)
+		SELECT u.uuid, u.username, u.display_name, p.incoming, p.created_at
+		FROM pending p
+		JOIN users u ON u.id = p.other_id
+		WHERE $2::timestamptz IS NULL OR (p.created_at, u.uuid) < ($2, $3::uuid)
+		ORDER BY p.created_at DESC, u.uuid DESC
+		LIMIT $4`,
+		id, afterTime, afterID, page.fetchLimit(),
 	)
 	if err != nil {
-		return incoming, outgoing, err
+		return requests, err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		var user models.User
-		rows.Scan(
-			&user.ID,
-			&user.Username,
-			&user.DisplayName,
-		)
-		incoming = append(incoming, user)
+		var request models.FriendRequest
+		if err := rows.Scan(
+			&request.ID,
+			&request.Username,
+			&request.DisplayName,
+			&request.Incoming,
+			&request.RequestedAt,
+		); err != nil {
+			return requests, err
+		}
+		requests = append(requests, request)
 	}
 
 	if err = rows.Err(); err != nil {
-		return incoming, outgoing, err
+		return requests, err
 	}
 
-	rowsOut, err := ur.db.Query(
-		`SELECT uuid, username, display_name 
-		 FROM users WHERE id IN
-			(SELECT friend_id
-			FROM friendships WHERE status = 'pending' AND user_id = 
-				(SELECT id FROM USERS WHERE uuid = $1))`,
-		id,
-	)
-	if err != nil {
-		return incoming, outgoing, err
-	}
-	defer rowsOut.Close()
-
-	for rowsOut.Next() {
-		var user models.User
-		rowsOut.Scan(
-			&user.ID,
-			&user.Username,
-			&user.DisplayName,
-		)
-		outgoing = append(outgoing, user)
-	}
-
-	if err = rowsOut.Err(); err != nil {
-		return incoming, outgoing, err
-	}
-
-	return incoming, outgoing, nil
+	return requests, nil
 }
 
 func (ur *userRepository) CreateFriendRequest(userID uuid.UUID, friendID uuid.UUID) (bool, error) {
@@ -249,7 +255,7 @@ func (ur *userRepository) AcceptFriendRequest(userID, requesterID uuid.UUID) (bo
 	// Update incoming pending request → accepted (requester → user)
 	updateQuery := `
 		UPDATE friendships
-		SET status = 'accepted', created_at = now()
+		SET status = 'accepted', created_at = now(), updated_at = now()
 		WHERE user_id = (SELECT id FROM users WHERE uuid = $1)
 		  AND friend_id = (SELECT id FROM users WHERE uuid = $2)
 		  AND status = 'pending';
@@ -352,3 +358,46 @@ func (ur *userRepository) DeleteFriend(userID uuid.UUID, friendID uuid.UUID) (bo
 
 	return true, nil
 }
+
+// AreFriends reports whether the two users have an accepted friendship.
+func (ur *userRepository) AreFriends(userID uuid.UUID, otherID uuid.UUID) (bool, error) {
+	query := `SELECT EXISTS (
+			SELECT 1 FROM friendships
+			WHERE status = 'accepted'
+			  AND user_id = (SELECT id FROM users WHERE uuid = $1)
+			  AND friend_id = (SELECT id FROM users WHERE uuid = $2)
+		)`
+
+	var friends bool
+	err := ur.db.QueryRow(query, userID.String(), otherID.String()).Scan(&friends)
+	return friends, err
+}
+
+// GetLocationPrivacy returns the user's default pin precisions, or nil if
+// the user doesn't exist.
+func (ur *userRepository) GetLocationPrivacy(userID uuid.UUID) (*models.LocationPrivacy, error) {
+	var privacy models.LocationPrivacy
+	err := ur.db.QueryRow(
+		`SELECT friends_location_precision, public_location_precision FROM users WHERE uuid = $1`,
+		userID.String(),
+	).Scan(&privacy.FriendsPrecision, &privacy.PublicPrecision)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &privacy, nil
+}
+
+// SaveLocationPrivacy changes the user's default pin precisions. Pins with
+// their own precision keep it.
+func (ur *userRepository) SaveLocationPrivacy(userID uuid.UUID, privacy models.LocationPrivacy) error {
+	_, err := ur.db.Exec(
+		`UPDATE users
+		 SET friends_location_precision = $2, public_location_precision = $3, updated_at = NOW()
+		 WHERE uuid = $1`,
+		userID.String(), privacy.FriendsPrecision, privacy.PublicPrecision,
+	)
+	return err
+}
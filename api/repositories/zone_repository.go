@@ -0,0 +1,292 @@
+package repositories
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+
+	"ember/api/models"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrZoneNotFound = errors.New("zone does not exist")
+	ErrTooManyZones = errors.New("too many zones")
+)
+
+// interface
+type ZoneRepository interface {
+	ListZones(userID uuid.UUID) ([]models.Zone, error)
+	CreateZone(userID uuid.UUID, zone models.Zone) (*models.Zone, error)
+	UpdateZone(userID uuid.UUID, zoneID uuid.UUID, zone models.Zone) (*models.Zone, error)
+	DeleteZone(userID uuid.UUID, zoneID uuid.UUID) error
+	QueryZonesAt(userID uuid.UUID, lon float64, lat float64) ([]models.Zone, error)
+}
+
+// implementation
+type zoneRepository struct {
+	db *sql.DB
+}
+
+func NewZoneRepository(db *sql.DB) ZoneRepository {
+	return &zoneRepository{
+		db: db,
+	}
+}
+
+// zoneColumns selects a zone (z) in the order scanZone expects. Polygons come
+// back as GeoJSON; circles as their center and radius.
+const zoneColumns = `
+			z.uuid,
+			z.name,
+			ST_X(z.center::geometry),
+			ST_Y(z.center::geometry),
+			COALESCE(z.radius_m, 0),
+			CASE WHEN z.center IS NULL THEN ST_AsGeoJSON(z.area) END,
+			COALESCE(z.visibility, ''),
+			COALESCE(z.location_precision, ''),
+			z.created_at,
+			z.updated_at`
+
+// zoneShape is a zone's center, radius and area from zoneShapeArgs at $3 to
+// $6. A circle is tested against as a polygon buffered around its center.
+const zoneShape = `
+			ST_SetSRID(ST_MakePoint($3::float8, $4::float8), 4326)::geography,
+			$5::int,
+			COALESCE(
+				ST_GeogFromText($6::text),
+				ST_Buffer(ST_SetSRID(ST_MakePoint($3::float8, $4::float8), 4326)::geography, $5::float8)
+			)`
+
+func scanZone(row rowScanner) (models.Zone, error) {
+	var z models.Zone
+	var lon, lat sql.NullFloat64
+	var polygon sql.NullString
+	err := row.Scan(
+		&z.ID,
+		&z.Name,
+		&lon,
+		&lat,
+		&z.RadiusM,
+		&polygon,
+		&z.Visibility,
+		&z.Precision,
+		&z.CreatedAt,
+		&z.UpdatedAt,
+	)
+	if err != nil {
+		return z, err
+	}
+
+	if lon.Valid && lat.Valid {
+		z.Center = &models.Location{Longitude: lon.Float64, Latitude: lat.Float64}
+	}
+	if polygon.Valid {
+		var geo struct {
+			Coordinates [][][2]float64 `json:"coordinates"`
+		}
+		if err := json.Unmarshal([]byte(polygon.String), &geo); err != nil {
+			return z, err
+		}
+		if len(geo.Coordinates) > 0 {
+			// GeoJSON closes the ring by repeating the first vertex
+			ring := geo.Coordinates[0]
+			for _, p := range ring[:max(len(ring)-1, 0)] {
+				z.Polygon = append(z.Polygon, models.Location{Longitude: p[0], Latitude: p[1]})
+			}
+		}
+	}
+	return z, nil
+}
+
+// zoneShapeArgs returns the center longitude, latitude and radius of a circle
+// zone and the WKT of a polygon one, each NULL where it doesn't apply.
+func zoneShapeArgs(zone models.Zone) []any {
+	if zone.Center != nil {
+		return []any{zone.Center.Longitude, zone.Center.Latitude, zone.RadiusM, nil}
+	}
+
+	coord := func(loc models.Location) string {
+		return strconv.FormatFloat(loc.Longitude, 'f', -1, 64) + " " + strconv.FormatFloat(loc.Latitude, 'f', -1, 64)
+	}
+	points := make([]string, 0, len(zone.Polygon)+1)
+	for _, loc := range zone.Polygon {
+		points = append(points, coord(loc))
+	}
+	if len(zone.Polygon) > 0 {
+		points = append(points, coord(zone.Polygon[0]))
+	}
+	return []any{nil, nil, nil, "POLYGON((" + strings.Join(points, ", ") + "))"}
+}
+
+func scanZones(rows *sql.Rows) ([]models.Zone, error) {
+	defer rows.Close()
+
+	var zones []models.Zone
+	for rows.Next() {
+		z, err := scanZone(rows)
+		if err != nil {
+			return nil, err
+		}
+		zones = append(zones, z)
+	}
+
+	return zones, rows.Err()
+}
+
+// ListZones returns the user's zones, oldest first.
+func (zr *zoneRepository) ListZones(userID uuid.UUID) ([]models.Zone, error) {
+	const q = `
+		SELECT` + zoneColumns + `
+		FROM private_zones z
+		JOIN users u ON u.id = z.user_id
+		WHERE u.uuid = $1
+		ORDER BY z.created_at, z.uuid
+	`
+
+	rows, err := zr.db.Query(q, userID.String())
+	if err != nil {
+		return nil, err
+	}
+	return scanZones(rows)
+}
+
+// CreateZone saves a new zone, or returns ErrTooManyZones if the user already
+// has models.MaxZonesPerUser.
+func (zr *zoneRepository) CreateZone(userID uuid.UUID, zone models.Zone) (*models.Zone, error) {
+	const q = `
+		INSERT INTO private_zones AS z (user_id, name, center, radius_m, area, visibility, location_precision)
+		SELECT
+			u.id,
+			$2,` + zoneShape + `,
+			NULLIF($7, ''),
+			NULLIF($8, '')
+		FROM users u
+		WHERE u.uuid = $1
+		AND (SELECT COUNT(*) FROM private_zones x WHERE x.user_id = u.id) < $9
+		RETURNING` + zoneColumns
+
+	args := append([]any{userID.String(), zone.Name}, zoneShapeArgs(zone)...)
+	args = append(args, zone.Visibility, zone.Precision, models.MaxZonesPerUser)
+
+	tx, err := zr.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	z, err := scanZone(tx.QueryRow(q, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTooManyZones
+		}
+		return nil, err
+	}
+	if err := purgeZoneRollups(tx, z.ID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &z, nil
+}
+
+// UpdateZone replaces one of the user's zones. Pins already created inside
+// the old shape keep the visibility and precision they were given.
+func (zr *zoneRepository) UpdateZone(userID uuid.UUID, zoneID uuid.UUID, zone models.Zone) (*models.Zone, error) {
+	const q = `
+		UPDATE private_zones z
+		SET name = $7,
+			(center, radius_m, area) = (` + zoneShape + `
+			),
+			visibility = NULLIF($8, ''),
+			location_precision = NULLIF($9, ''),
+			updated_at = NOW()
+		FROM users u
+		WHERE u.id = z.user_id AND u.uuid = $1 AND z.uuid = $2
+		RETURNING` + zoneColumns
+
+	args := append([]any{userID.String(), zoneID.String()}, zoneShapeArgs(zone)...)
+	args = append(args, zone.Name, zone.Visibility, zone.Precision)
+
+	tx, err := zr.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	z, err := scanZone(tx.QueryRow(q, args...))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrZoneNotFound
+		}
+		return nil, err
+	}
+	if err := purgeZoneRollups(tx, z.ID); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &z, nil
+}
+
+// purgeZoneRollups drops the mood rollups the zone's owner contributed to in
+// cells the zone reaches, so their public pins inside it stop feeding mood
+// trends. Rollups don't say which of the owner's pins were inside, so whole
+// rows go, with the other authors' pins in them; hours still in the refresh
+// lookback are rebuilt without the zone's pins, older ones are lost.
+func purgeZoneRollups(tx *sql.Tx, zoneID uuid.UUID) error {
+	_, err := tx.Exec(`
+		DELETE FROM pin_mood_rollups r
+		USING private_zones z
+		WHERE z.uuid = $1
+		AND z.user_id = ANY(r.author_ids)
+		AND ST_DWithin(r.location, z.area, $2)
+	`, zoneID.String(), moodRollupCellMeters)
+	return err
+}
+
+func (zr *zoneRepository) DeleteZone(userID uuid.UUID, zoneID uuid.UUID) error {
+	res, err := zr.db.Exec(
+		`DELETE FROM private_zones z
+		 USING users u
+		 WHERE u.id = z.user_id AND u.uuid = $1 AND z.uuid = $2`,
+		userID.String(), zoneID.String(),
+	)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrZoneNotFound
+	}
+	return nil
+}
+
+// QueryZonesAt returns the user's zones covering the point.
+func (zr *zoneRepository) QueryZonesAt(userID uuid.UUID, lon float64, lat float64) ([]models.Zone, error) {
+	const q = `
+		SELECT` + zoneColumns + `
+		FROM private_zones z
+		JOIN users u ON u.id = z.user_id
+		WHERE u.uuid = $1
+		AND ST_Covers(z.area, ST_SetSRID(ST_MakePoint($2, $3), 4326)::geography)
+		ORDER BY z.created_at, z.uuid
+	`
+
+	rows, err := zr.db.Query(q, userID.String(), lon, lat)
+	if err != nil {
+		return nil, err
+	}
+	return scanZones(rows)
+}
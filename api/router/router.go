@@ -5,15 +5,38 @@ import (
     "net/http"
 
     "ember/api/auth"
+    "ember/api/events"
     "ember/api/handlers"
+    "ember/api/media"
     "ember/api/repositories"
 
     "github.com/go-chi/chi/v5"
 )
 
-func CreateRouter(userRepo repositories.UserRepository, pinRepo repositories.PinRepository) chi.Router {
+// Dependencies are the repositories and services the handlers are built from.
+type Dependencies struct {
+	Users         repositories.UserRepository
+	Pins          repositories.PinRepository
+	Emotions      repositories.EmotionRepository
+	Insights      repositories.InsightRepository
+	Events        *events.Broker
+	Notifications repositories.NotificationRepository
+	Push          repositories.PushRepository
+	Comments      repositories.CommentRepository
+	Attachments   repositories.AttachmentRepository
+	Blobs         media.BlobStore
+	Tags          repositories.TagRepository
+	Zones         repositories.ZoneRepository
+	Sync          repositories.SyncRepository
+	Idempotency   repositories.IdempotencyRepository
+}
+
+func CreateRouter(deps Dependencies) chi.Router {
 	r := chi.NewRouter()
 
+	// Requests that create things can be retried safely with an Idempotency-Key
+	idempotent := handlers.IdempotencyMiddleware(deps.Idempotency)
+
     // Simple health/test endpoint
     r.Get("/hello", func(w http.ResponseWriter, _ *http.Request) {
         w.Header().Set("Content-Type", "application/json")
@@ -21,27 +44,72 @@ func CreateRouter(userRepo repositories.UserRepository, pinRepo repositories.Pin
     })
 
     r.Route("/auth", func(r chi.Router) {
-        r.Post("/login", handlers.PostLoginHandler(userRepo))
-        r.Post("/register", handlers.PostRegisterHandler(userRepo))
+        r.Post("/login", handlers.PostLoginHandler(deps.Users))
+        r.Post("/register", handlers.PostRegisterHandler(deps.Users))
     })
 
+	// The catalogue is public so clients can cache it before signing in
+	r.Get("/emotions", handlers.GetEmotionsHandler(deps.Emotions))
+
 	r.Group(func(r chi.Router) {
 		r.Use(auth.AuthMiddleware)
-		r.Get("/me", handlers.GetMeHandler(userRepo))
+		r.Get("/me", handlers.GetMeHandler(deps.Users))
+		r.Get("/me/stats", handlers.GetMeStatsHandler(deps.Pins, deps.Emotions))
+		r.Get("/me/notifications", handlers.GetNotificationsHandler(deps.Notifications))
+		r.Post("/me/notifications/read", handlers.PostNotificationsReadHandler(deps.Notifications))
+		r.Post("/me/devices", handlers.PostDevicesHandler(deps.Push))
+		r.Delete("/me/devices/{token}", handlers.DeleteDeviceHandler(deps.Push))
+		r.Get("/me/push-preferences", handlers.GetPushPreferencesHandler(deps.Push))
+		r.Put("/me/push-preferences", handlers.PutPushPreferencesHandler(deps.Push))
+		r.Get("/me/location-privacy", handlers.GetLocationPrivacyHandler(deps.Users))
+		r.Put("/me/location-privacy", handlers.PutLocationPrivacyHandler(deps.Users))
+		r.Get("/me/zones", handlers.GetZonesHandler(deps.Zones))
+		r.Post("/me/zones", handlers.PostZonesHandler(deps.Zones))
+		r.Put("/me/zones/{zoneID}", handlers.PutZoneHandler(deps.Zones))
+		r.Delete("/me/zones/{zoneID}", handlers.DeleteZoneHandler(deps.Zones))
+		r.Get("/sync", handlers.GetSyncHandler(deps.Sync, deps.Pins))
 		r.Route("/friends", func(r chi.Router) {
-			r.Get("/", handlers.GetFriendsHandler(userRepo))
-			r.Delete("/{friendID}", handlers.DeleteFriendsHandler(userRepo))
+			r.Get("/", handlers.GetFriendsHandler(deps.Users))
+			r.Delete("/{friendID}", handlers.DeleteFriendsHandler(deps.Users))
 			r.Route("/requests", func(r chi.Router) {
-				r.Get("/", handlers.GetFriendRequestsHandler(userRepo))
-				r.Post("/{friendID}", handlers.PostFriendRequestsHandler(userRepo))
-				r.Patch("/{friendID}", handlers.PatchFriendRequestsHandler(userRepo))
+				r.Get("/", handlers.GetFriendRequestsHandler(deps.Users))
+				r.With(idempotent).Post("/{friendID}", handlers.PostFriendRequestsHandler(deps.Users, deps.Notifications))
+				r.With(idempotent).Patch("/{friendID}", handlers.PatchFriendRequestsHandler(deps.Users, deps.Notifications))
 			})
 		})
 		r.Route("/pins", func(r chi.Router) {
-			r.Post("/", handlers.PostPinsHandler(pinRepo))
-			r.Get("/me", handlers.GetPinsMeHandler(pinRepo))
-			r.Get("/nearby", handlers.GetPinsNearbyHandler(pinRepo))
-			r.Get("/friends", handlers.GetPinsFriendsHandler(pinRepo))
+			r.Get("/", handlers.GetPinsHandler(deps.Pins))
+			r.With(idempotent).Post("/", handlers.PostPinsHandler(deps.Pins, deps.Emotions, deps.Notifications, deps.Users, deps.Zones))
+			r.Post("/batch", handlers.PostPinsBatchHandler(deps.Pins, deps.Emotions, deps.Notifications, deps.Users, deps.Zones))
+			r.Get("/me", handlers.GetPinsMeHandler(deps.Pins))
+			r.Get("/nearby", handlers.GetPinsNearbyHandler(deps.Pins, deps.Emotions))
+			r.Get("/friends", handlers.GetPinsFriendsHandler(deps.Pins))
+			r.Get("/search", handlers.GetPinsSearchHandler(deps.Pins))
+			r.Get("/clusters", handlers.GetPinClustersHandler(deps.Pins))
+			r.Get("/stream", handlers.GetPinStreamHandler(deps.Events, deps.Pins))
+			r.Patch("/stream/{streamID}", handlers.PatchPinStreamHandler(deps.Events))
+			r.Route("/{pinID}", func(r chi.Router) {
+				r.Get("/", handlers.GetPinHandler(deps.Pins))
+				r.Patch("/", handlers.PatchPinHandler(deps.Pins, deps.Emotions, deps.Notifications))
+				r.Delete("/", handlers.DeletePinHandler(deps.Pins))
+				r.Put("/reactions", handlers.PutPinReactionHandler(deps.Pins, deps.Notifications))
+				r.Delete("/reactions", handlers.DeletePinReactionHandler(deps.Pins))
+				r.Get("/comments", handlers.GetPinCommentsHandler(deps.Pins, deps.Comments))
+				r.Post("/comments", handlers.PostPinCommentsHandler(deps.Pins, deps.Comments, deps.Notifications))
+				r.Delete("/comments/{commentID}", handlers.DeletePinCommentHandler(deps.Comments))
+				r.Post("/attachments", handlers.PostPinAttachmentsHandler(deps.Pins, deps.Attachments, deps.Blobs))
+				r.Get("/attachments/{attachmentID}", handlers.GetPinAttachmentHandler(deps.Pins, deps.Attachments, deps.Blobs))
+				r.Delete("/attachments/{attachmentID}", handlers.DeletePinAttachmentHandler(deps.Attachments))
+			})
+		})
+		r.Route("/tags", func(r chi.Router) {
+			r.Get("/trending", handlers.GetTrendingTagsHandler(deps.Tags))
+			r.Get("/{tag}/pins", handlers.GetTagPinsHandler(deps.Pins, deps.Tags))
+		})
+		r.Get("/tiles/pins/{z}/{x}/{y}.mvt", handlers.GetPinTileHandler(deps.Pins))
+		r.Route("/insights", func(r chi.Router) {
+			r.Get("/mood", handlers.GetMoodInsightsHandler(deps.Insights))
+			r.Get("/trends", handlers.GetMoodTrendsHandler(deps.Insights))
 		})
 	})
 
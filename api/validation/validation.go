@@ -0,0 +1,98 @@
+package validation
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// FieldError explains why a single request field was rejected.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Errors collects every failing field of a request so clients can fix them all at once.
+type Errors []FieldError
+
+func (e Errors) Error() string {
+	parts := make([]string, 0, len(e))
+	for _, fe := range e {
+		parts = append(parts, fe.Field+": "+fe.Message)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// Add records a failure for field.
+func (e *Errors) Add(field string, message string) {
+	*e = append(*e, FieldError{Field: field, Message: message})
+}
+
+// Check records a failure for field unless ok holds.
+func (e *Errors) Check(ok bool, field string, message string) {
+	if !ok {
+		e.Add(field, message)
+	}
+}
+
+// Err returns nil when no field failed, so Validate methods can end with `return errs.Err()`.
+func (e Errors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Validator is implemented by request bodies that know how to check themselves.
+type Validator interface {
+	Validate() error
+}
+
+func Latitude(v float64) bool {
+	return v >= -90 && v <= 90
+}
+
+func Longitude(v float64) bool {
+	return v >= -180 && v <= 180
+}
+
+// MaxRunes reports whether s is at most n characters long.
+func MaxRunes(s string, n int) bool {
+	return utf8.RuneCountInString(s) <= n
+}
+
+// Required reports whether s has any non-whitespace content.
+func Required(s string) bool {
+	return strings.TrimSpace(s) != ""
+}
+
+// OneOf reports whether v is one of allowed.
+func OneOf(v string, allowed ...string) bool {
+	for _, a := range allowed {
+		if v == a {
+			return true
+		}
+	}
+	return false
+}
+
+// Emoji reports whether s is made only of emoji, including modifiers, flags,
+// keycaps and zero-width-joiner sequences. It does not check that the
+// sequence is one any platform actually renders.
+func Emoji(s string) bool {
+	if s == "" {
+		return false
+	}
+	keycap := strings.ContainsRune(s, '\u20E3')
+	for _, r := range s {
+		switch {
+		case unicode.Is(unicode.So, r), unicode.Is(unicode.Sk, r) && r > 0xFF:
+		case r == '\u200D', r == '\uFE0E', r == '\uFE0F', r == '\u20E3':
+		case r >= 0xE0020 && r <= 0xE007F: // tag sequences, e.g. subdivision flags
+		case keycap && (r == '#' || r == '*' || (r >= '0' && r <= '9')):
+		default:
+			return false
+		}
+	}
+	return true
+}
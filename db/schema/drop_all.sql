@@ -1,3 +1,21 @@
+DROP TABLE idempotency_keys;
+DROP TABLE sync_deletions;
+DROP TABLE private_zones;
+DROP TABLE push_preferences;
+DROP TABLE devices;
+DROP TABLE notifications;
+DROP TABLE pin_mood_rollups;
+DROP TABLE pin_mood_rollup_state;
 DROP TABLE friendships;
+DROP FUNCTION log_friendship_deletion;
+DROP TABLE pin_attachments;
+DROP TABLE pin_comments;
+DROP TABLE pin_mentions;
+DROP TABLE pin_tags;
+DROP TABLE pin_reactions;
 DROP TABLE pins;
+DROP FUNCTION log_pin_deletion;
+DROP TABLE emotions;
+DROP TABLE emotion_catalog;
+DROP FUNCTION bump_emotion_catalog_version;
 DROP TABLE users;
@@ -11,6 +11,11 @@ CREATE TABLE users (
     username        VARCHAR(50) UNIQUE NOT NULL,
     display_name    VARCHAR(100),
     bio             TEXT,
+    -- How precisely pins are shown to friends and to everyone else by default
+    friends_location_precision VARCHAR(10) NOT NULL DEFAULT '100m'
+        CHECK (friends_location_precision IN ('exact','100m','1km','city')),
+    public_location_precision  VARCHAR(10) NOT NULL DEFAULT '1km'
+        CHECK (public_location_precision IN ('exact','100m','1km','city')),
     created_at      TIMESTAMPTZ DEFAULT NOW(),
     updated_at      TIMESTAMPTZ DEFAULT NOW()
 );
@@ -23,17 +28,317 @@ CREATE TABLE friendships (
     friend_id       BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
     status          VARCHAR(20) NOT NULL CHECK (status IN ('pending','accepted')),
     created_at      TIMESTAMPTZ DEFAULT NOW(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
     UNIQUE(user_id, friend_id)
 );
 
+-- Emotions catalogue: the moods a pin can carry. Rows are retired (active = FALSE)
+-- rather than deleted so that existing pins keep resolving.
+CREATE TABLE emotions (
+    key             VARCHAR(50) PRIMARY KEY,               -- stable ID stored on pins, e.g. happy
+    emoji           VARCHAR(16) UNIQUE NOT NULL,
+    label           VARCHAR(100) NOT NULL,
+    valence         REAL NOT NULL CHECK (valence BETWEEN -1 AND 1),  -- unpleasant .. pleasant
+    arousal         REAL NOT NULL CHECK (arousal BETWEEN 0 AND 1),   -- calm .. energetic
+    category        VARCHAR(30) NOT NULL,                  -- e.g., joy, sadness, anger
+    sort_order      INT NOT NULL DEFAULT 0,
+    active          BOOLEAN NOT NULL DEFAULT TRUE
+);
+
+-- Single-row version of the emotions catalogue, bumped on every change so
+-- clients can cache GET /emotions and pick up new emotions without a release
+CREATE TABLE emotion_catalog (
+    id              INT PRIMARY KEY DEFAULT 1 CHECK (id = 1),
+    version         BIGINT NOT NULL DEFAULT 1,
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+INSERT INTO emotion_catalog DEFAULT VALUES;
+
+CREATE FUNCTION bump_emotion_catalog_version() RETURNS trigger AS $$
+BEGIN
+    UPDATE emotion_catalog SET version = version + 1, updated_at = NOW();
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER emotions_bump_catalog_version
+AFTER INSERT OR UPDATE OR DELETE ON emotions
+FOR EACH STATEMENT EXECUTE FUNCTION bump_emotion_catalog_version();
+
+INSERT INTO emotions (key, emoji, label, valence, arousal, category, sort_order) VALUES
+('happy',     '🙂', 'Happy',     0.6,  0.5, 'joy',      10),
+('laughing',  '😂', 'Laughing',  0.8,  0.8, 'joy',      20),
+('in_love',   '😍', 'In love',   0.9,  0.7, 'love',     30),
+('excited',   '🤩', 'Excited',   0.8,  0.9, 'joy',      40),
+('calm',      '😌', 'Calm',      0.5,  0.1, 'calm',     50),
+('curious',   '🤔', 'Curious',   0.2,  0.5, 'surprise', 60),
+('surprised', '😮', 'Surprised', 0.1,  0.8, 'surprise', 70),
+('tired',     '😴', 'Tired',    -0.2,  0.1, 'calm',     80),
+('sad',       '😢', 'Sad',      -0.7,  0.3, 'sadness',  90),
+('anxious',   '😰', 'Anxious',  -0.6,  0.8, 'fear',    100),
+('angry',     '😡', 'Angry',    -0.8,  0.9, 'anger',   110);
+
 -- Pins table: stores user-generated pins
 CREATE TABLE pins (
     id              BIGSERIAL PRIMARY KEY,
+    uuid            UUID UNIQUE NOT NULL DEFAULT gen_random_uuid(), -- external safe ID
     user_id         BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
-    emotion         VARCHAR(50) NOT NULL,                  -- e.g., happy, sad, excited
+    emotion         VARCHAR(50) NOT NULL REFERENCES emotions(key), -- e.g., happy, sad, excited
     message         TEXT,                                  -- optional message
     location        GEOGRAPHY(Point, 4326) NOT NULL,      -- PostGIS: lat/lng
+    -- Overrides the author's default precisions when set. Each approximate
+    -- point is picked once, at random within the pin's grid cell of that
+    -- size, so repeated reads can't be averaged back to the exact location.
+    location_precision VARCHAR(10) CHECK (location_precision IN ('exact','100m','1km','city')),
+    location_100m   GEOGRAPHY(Point, 4326) NOT NULL,
+    location_1km    GEOGRAPHY(Point, 4326) NOT NULL,
+    location_city   GEOGRAPHY(Point, 4326) NOT NULL,
     visibility      VARCHAR(20) NOT NULL CHECK (visibility IN ('public','friends','private')),
     created_at      TIMESTAMPTZ DEFAULT NOW(),
-    expires_at      TIMESTAMPTZ                               -- optional auto-expire
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at      TIMESTAMPTZ,                              -- optional auto-expire
+    client_id       UUID,                                     -- device's ID for pins created offline
+    message_tsv     TSVECTOR GENERATED ALWAYS AS (to_tsvector('english', COALESCE(message, ''))) STORED
 );
+
+-- GET /pins/search matches against message_tsv
+CREATE INDEX pins_message_tsv_idx ON pins USING GIN (message_tsv);
+
+-- Reads filter on expires_at and the reaper deletes by it
+CREATE INDEX pins_expires_at_idx ON pins (expires_at) WHERE expires_at IS NOT NULL;
+
+-- Uploading an offline pin again finds the one already created
+CREATE UNIQUE INDEX pins_user_id_client_id_idx ON pins (user_id, client_id);
+
+-- GET /sync finds the pins of the caller and their friends changed since a token
+CREATE INDEX pins_user_id_updated_at_idx ON pins (user_id, updated_at);
+
+-- Radius queries use the geography index, viewport (bounding-box) queries the geometry one
+CREATE INDEX pins_location_idx ON pins USING GIST (location);
+CREATE INDEX pins_location_geom_idx ON pins USING GIST ((location::geometry));
+
+-- Emoji reactions; each user may react to a pin once per emoji
+CREATE TABLE pin_reactions (
+    pin_id          BIGINT NOT NULL REFERENCES pins(id) ON DELETE CASCADE,
+    user_id         BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    emoji           VARCHAR(64) NOT NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (pin_id, user_id, emoji)
+);
+
+CREATE INDEX pin_reactions_user_id_idx ON pin_reactions (user_id);
+
+-- Hashtags in pin messages, lowercased and without the '#'
+CREATE TABLE pin_tags (
+    pin_id          BIGINT NOT NULL REFERENCES pins(id) ON DELETE CASCADE,
+    tag             VARCHAR(50) NOT NULL,
+    PRIMARY KEY (pin_id, tag)
+);
+
+CREATE INDEX pin_tags_tag_idx ON pin_tags (tag, pin_id);
+
+-- Friends mentioned in pin messages. Rows stay when an edit drops the
+-- mention, so re-adding it later doesn't notify the same friend again.
+CREATE TABLE pin_mentions (
+    pin_id          BIGINT NOT NULL REFERENCES pins(id) ON DELETE CASCADE,
+    user_id         BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (pin_id, user_id)
+);
+
+CREATE INDEX pin_mentions_user_id_idx ON pin_mentions (user_id);
+
+-- Comments on pins. Replies point at their parent; deleted comments keep
+-- their row (body cleared) so the replies under them stay threaded.
+CREATE TABLE pin_comments (
+    id              BIGSERIAL PRIMARY KEY,
+    uuid            UUID UNIQUE NOT NULL DEFAULT gen_random_uuid(), -- external safe ID
+    pin_id          BIGINT NOT NULL REFERENCES pins(id) ON DELETE CASCADE,
+    user_id         BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    parent_id       BIGINT REFERENCES pin_comments(id) ON DELETE CASCADE,
+    body            TEXT,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    deleted_at      TIMESTAMPTZ
+);
+
+CREATE INDEX pin_comments_pin_created_idx ON pin_comments (pin_id, created_at, uuid) WHERE parent_id IS NULL;
+CREATE INDEX pin_comments_parent_created_idx ON pin_comments (parent_id, created_at, uuid);
+
+-- Images attached to pins; the blobs live in the blob store under the uuid.
+-- Deleting a pin or an attachment only detaches the row, and a background
+-- sweeper removes the blobs of detached rows before deleting them.
+CREATE TABLE pin_attachments (
+    id              BIGSERIAL PRIMARY KEY,
+    uuid            UUID UNIQUE NOT NULL,
+    pin_id          BIGINT REFERENCES pins(id) ON DELETE SET NULL,
+    position        SMALLINT NOT NULL,
+    width           INT NOT NULL,
+    height          INT NOT NULL,
+    blurhash        VARCHAR(64) NOT NULL,
+    byte_size       INT NOT NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX pin_attachments_pin_id_idx ON pin_attachments (pin_id, position);
+CREATE INDEX pin_attachments_detached_idx ON pin_attachments (id) WHERE pin_id IS NULL;
+
+-- Hourly rollup of public pins per ~1 km grid cell and emotion, refreshed by a
+-- background job so trend queries never scan raw pins. Rows outlive the pins
+-- they were built from, so history survives the expired-pin reaper.
+CREATE TABLE pin_mood_rollups (
+    bucket_start    TIMESTAMPTZ NOT NULL,                  -- start of the UTC hour
+    cell_x          INT NOT NULL,
+    cell_y          INT NOT NULL,
+    emotion         VARCHAR(50) NOT NULL REFERENCES emotions(key),
+    pin_count       INT NOT NULL,
+    valence_sum     DOUBLE PRECISION NOT NULL,
+    author_ids      BIGINT[] NOT NULL,                     -- distinct authors, counted across cells and hours for k-anonymity
+    location        GEOGRAPHY(Point, 4326) NOT NULL,       -- cell centre
+    PRIMARY KEY (bucket_start, cell_x, cell_y, emotion)
+);
+
+CREATE INDEX pin_mood_rollups_location_idx ON pin_mood_rollups USING GIST (location);
+
+-- Single-row watermark of the last rollup refresh
+CREATE TABLE pin_mood_rollup_state (
+    id              INT PRIMARY KEY DEFAULT 1 CHECK (id = 1),
+    refreshed_at    TIMESTAMPTZ NOT NULL DEFAULT 'epoch'           -- first refresh rolls up every pin
+);
+
+INSERT INTO pin_mood_rollup_state DEFAULT VALUES;
+
+-- Notifications inbox: one row per social event, addressed to its recipient
+CREATE TABLE notifications (
+    id              BIGSERIAL PRIMARY KEY,
+    uuid            UUID UNIQUE NOT NULL DEFAULT gen_random_uuid(), -- external safe ID
+    user_id         BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE, -- recipient
+    actor_id        BIGINT REFERENCES users(id) ON DELETE CASCADE,          -- who caused it
+    type            VARCHAR(40) NOT NULL,                  -- e.g., friend_request, pin_reaction
+    pin_id          BIGINT REFERENCES pins(id) ON DELETE CASCADE,
+    data            JSONB NOT NULL DEFAULT '{}',           -- type-specific details, e.g. the reaction emoji
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    read_at         TIMESTAMPTZ,
+    -- Push delivery state, driven by the delivery worker
+    push_status     VARCHAR(10) NOT NULL DEFAULT 'pending' CHECK (push_status IN ('pending','sent','skipped','failed')),
+    push_attempts   INT NOT NULL DEFAULT 0,
+    push_next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX notifications_user_created_idx ON notifications (user_id, created_at DESC, uuid DESC);
+CREATE INDEX notifications_unread_idx ON notifications (user_id) WHERE read_at IS NULL;
+CREATE INDEX notifications_push_due_idx ON notifications (push_next_attempt_at) WHERE push_status = 'pending';
+
+-- Push device tokens. A token belongs to whoever registered it last.
+CREATE TABLE devices (
+    id              BIGSERIAL PRIMARY KEY,
+    user_id         BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    platform        VARCHAR(10) NOT NULL CHECK (platform IN ('ios')),
+    token           TEXT UNIQUE NOT NULL,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_seen_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX devices_user_id_idx ON devices (user_id);
+
+-- Per-user push preferences; users without a row get the defaults
+CREATE TABLE push_preferences (
+    user_id         BIGINT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    enabled         BOOLEAN NOT NULL DEFAULT TRUE,
+    muted_types     JSONB NOT NULL DEFAULT '[]',           -- notification types never pushed
+    quiet_start     SMALLINT CHECK (quiet_start BETWEEN 0 AND 1439), -- minute of the local day
+    quiet_end       SMALLINT CHECK (quiet_end BETWEEN 0 AND 1439),
+    time_zone       VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Private zones, such as home and work. Pins created inside one are
+-- downgraded to its visibility and/or precision, and strangers never see any
+-- pin inside one. Circles keep their center and radius; area is the shape
+-- (a circle as a buffered polygon) that pins are tested against.
+CREATE TABLE private_zones (
+    id              BIGSERIAL PRIMARY KEY,
+    uuid            UUID UNIQUE NOT NULL DEFAULT gen_random_uuid(),
+    user_id         BIGINT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name            VARCHAR(50) NOT NULL,
+    center          GEOGRAPHY(Point, 4326),
+    radius_m        INT CHECK (radius_m > 0),
+    area            GEOGRAPHY(Polygon, 4326) NOT NULL,
+    visibility      VARCHAR(20) CHECK (visibility IN ('friends','private')),
+    location_precision VARCHAR(10) CHECK (location_precision IN ('100m','1km','city')),
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CHECK ((center IS NULL) = (radius_m IS NULL)),
+    CHECK (visibility IS NOT NULL OR location_precision IS NOT NULL)
+);
+
+CREATE INDEX private_zones_user_id_idx ON private_zones (user_id);
+CREATE INDEX private_zones_area_idx ON private_zones USING GIST (area);
+
+-- Tombstones for GET /sync, written by triggers so every way a row goes
+-- (including cascades and the expired pin reaper) is recorded. owner_id is
+-- whose deletion it is: a pin's author, or each side of a friendship or
+-- request, with the other side as the subject. It isn't a foreign key so
+-- deleting a user can still log the rows that cascade with them. Rows older
+-- than the sync retention are pruned.
+CREATE TABLE sync_deletions (
+    id              BIGSERIAL PRIMARY KEY,
+    kind            VARCHAR(20) NOT NULL CHECK (kind IN ('pin','friend','friend_request')),
+    owner_id        BIGINT NOT NULL,
+    subject_uuid    UUID NOT NULL,
+    deleted_at      TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX sync_deletions_owner_idx ON sync_deletions (owner_id, deleted_at);
+CREATE INDEX sync_deletions_deleted_at_idx ON sync_deletions (deleted_at);
+
+CREATE FUNCTION log_pin_deletion() RETURNS trigger AS $$
+BEGIN
+    INSERT INTO sync_deletions (kind, owner_id, subject_uuid) VALUES ('pin', OLD.user_id, OLD.uuid);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER pins_log_deletion
+AFTER DELETE ON pins
+FOR EACH ROW EXECUTE FUNCTION log_pin_deletion();
+
+-- An accepted friendship is two rows, each logged for its own user_id; a
+-- pending request is one row, logged for both sides.
+CREATE FUNCTION log_friendship_deletion() RETURNS trigger AS $$
+BEGIN
+    IF OLD.status = 'accepted' THEN
+        INSERT INTO sync_deletions (kind, owner_id, subject_uuid)
+        SELECT 'friend', OLD.user_id, uuid FROM users WHERE id = OLD.friend_id;
+    ELSE
+        INSERT INTO sync_deletions (kind, owner_id, subject_uuid)
+        SELECT 'friend_request', OLD.user_id, uuid FROM users WHERE id = OLD.friend_id
+        UNION ALL
+        SELECT 'friend_request', OLD.friend_id, uuid FROM users WHERE id = OLD.user_id;
+    END IF;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER friendships_log_deletion
+AFTER DELETE ON friendships
+FOR EACH ROW EXECUTE FUNCTION log_friendship_deletion();
+
+-- Requests sent with an Idempotency-Key and the response to replay when they
+-- are retried. status_code is NULL while the first request is still being
+-- handled. scope is the caller's user uuid; unauthenticated requests are
+-- never stored, as their responses may carry credentials. Rows past
+-- expires_at (a minute while in progress, a day once answered) are pruned.
+CREATE TABLE idempotency_keys (
+    scope           VARCHAR(36) NOT NULL,
+    idempotency_key VARCHAR(255) NOT NULL,
+    fingerprint     CHAR(64) NOT NULL,                        -- SHA-256 of method, path and body
+    status_code     INT,
+    content_type    TEXT,
+    body            BYTEA,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at      TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (scope, idempotency_key)
+);
+
+CREATE INDEX idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
@@ -36,8 +36,15 @@ FROM friendships
 WHERE user_id = 1;
 
 -- Pins
-INSERT INTO pins (user_id, emotion, message, location, visibility)
-VALUES (1, 'happy', 'Feeling great in SF!', ST_SetSRID(ST_MakePoint(-122.4194, 37.7749), 4326)::geography, 'friends');
+INSERT INTO pins (user_id, emotion, message, location, location_100m, location_1km, location_city, visibility)
+VALUES (
+    1, 'happy', 'Feeling great in SF!',
+    ST_SetSRID(ST_MakePoint(-122.4194, 37.7749), 4326)::geography,
+    ST_SetSRID(ST_MakePoint(-122.4190, 37.7752), 4326)::geography,
+    ST_SetSRID(ST_MakePoint(-122.4162, 37.7733), 4326)::geography,
+    ST_SetSRID(ST_MakePoint(-122.4430, 37.7905), 4326)::geography,
+    'friends'
+);
 
 SELECT *
 FROM pins